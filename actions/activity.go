@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetSubscriberActivity returns the activity timeline of the subscriber, the sends, deliveries,
+// opens, clicks, bounces and complaints of the campaigns along with the subscriber events.
+func GetSubscriberActivity(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	query := &params.GetSubscriberActivity{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(query); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var after *entities.ActivityCursor
+	if query.StartingAfter != "" {
+		after, err = entities.DecodeActivityCursor(query.StartingAfter)
+		if errors.Is(err, entities.ErrInvalidActivityCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "starting_after field must be a valid activity cursor.",
+			})
+			return
+		}
+	}
+
+	s, err := storage.GetSubscriber(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Subscriber not found",
+		})
+		return
+	}
+
+	p := storage.NewPaginationCursor(c.Request.URL.Path, query.PerPage)
+	if len(query.Types) > 0 {
+		p.SetParams(map[string][]string{"types[]": query.Types})
+	}
+
+	err = storage.GetSubscriberActivity(c, s, query.Types, after, p)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch subscriber activity.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch the subscriber activity. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
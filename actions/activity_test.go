@@ -0,0 +1,94 @@
+package actions_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestSubscriberActivity(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3mock.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	sub := &entities.Subscriber{Email: "jane@example.com", Name: "Jane", UserID: u.ID, Active: true}
+	err = s.CreateSubscriber(sub)
+	if err != nil {
+		t.FailNow()
+	}
+
+	now := time.Now().UTC().Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		err = s.CreateOpen(&entities.Open{UserID: u.ID, CampaignID: 1, Recipient: sub.Email, CreatedAt: now.Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.FailNow()
+		}
+	}
+	err = s.CreateDelivery(&entities.Delivery{UserID: u.ID, CampaignID: 1, Recipient: sub.Email, CreatedAt: now})
+	if err != nil {
+		t.FailNow()
+	}
+
+	path := "/api/subscribers/" + strconv.FormatInt(sub.ID, 10) + "/activity"
+
+	e.GET(path).Expect().Status(http.StatusUnauthorized)
+
+	auth.GET("/api/subscribers/999/activity").Expect().Status(http.StatusNotFound)
+
+	auth.GET(path).WithQuery("starting_after", "foo").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.GET(path).WithQuery("types[]", "foo").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	obj := auth.GET(path).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	obj.ValueEqual("total", 5)
+	obj.Value("collection").Array().Length().Equal(5)
+	obj.Value("collection").Array().Last().Object().ValueEqual("type", "created")
+
+	// test the type filter is kept while paginating
+	obj = auth.GET(path).
+		WithQuery("types[]", entities.ActivityTypeOpen).
+		WithQuery("per_page", 2).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	obj.ValueEqual("total", 3)
+	obj.Value("collection").Array().Length().Equal(2)
+	next := obj.Value("links").Object().Value("next").String().Contains("types%5B%5D=open").Raw()
+
+	nextURL, err := url.Parse(next)
+	if err != nil {
+		t.FailNow()
+	}
+
+	auth.GET(nextURL.Path).
+		WithQueryString(nextURL.RawQuery).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Length().Equal(1)
+}
@@ -0,0 +1,135 @@
+package actions
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/logger"
+)
+
+// maxBlobSize is the max size of the files uploaded to the signed urls of the filesystem store.
+const maxBlobSize = 100 << 20
+
+var errBlobTooLarge = errors.New("blob is too large")
+
+// blobReader reads the uploaded file, failing with errBlobTooLarge once more than n bytes are read.
+type blobReader struct {
+	r io.Reader
+	n int64
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return n, errBlobTooLarge
+	}
+	return n, err
+}
+
+// GetBlob serves the files of the filesystem store through the urls signed by it, in
+// place of the presigned S3 urls.
+func GetBlob(c *gin.Context) {
+	fs, bucket, key, ok := verifyBlobRequest(c, "")
+	if !ok {
+		return
+	}
+
+	obj, err := fs.Get(bucket, key)
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "File not found.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).WithField("key", key).Error("Unable to get file.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to get file.",
+		})
+		return
+	}
+	defer obj.Body.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	headers := map[string]string{
+		"Content-Disposition": `attachment; filename="` + strings.ReplaceAll(path.Base(key), `"`, "") + `"`,
+	}
+	if fs.Public(bucket, key) {
+		headers = map[string]string{
+			"Cache-Control": "public, max-age=31536000",
+		}
+	}
+
+	c.DataFromReader(http.StatusOK, obj.Size, contentType, obj.Body, headers)
+}
+
+// PutBlob stores the file uploaded to an url signed by the filesystem store.
+func PutBlob(c *gin.Context) {
+	fs, bucket, key, ok := verifyBlobRequest(c, c.GetHeader("Content-Type"))
+	if !ok {
+		return
+	}
+
+	if c.Request.ContentLength > maxBlobSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": "File is too large.",
+		})
+		return
+	}
+
+	// the body is streamed to the disk, the limit is checked while it is read since
+	// the content length can be missing.
+	body := &blobReader{r: c.Request.Body, n: maxBlobSize}
+	err := fs.Write(bucket, key, body)
+	if err != nil {
+		if errors.Is(err, errBlobTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": "File is too large.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).WithField("key", key).Error("Unable to store file.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to store file.",
+		})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// verifyBlobRequest checks that the filesystem store is used and that the url is signed.
+func verifyBlobRequest(c *gin.Context, contentType string) (*blobs.FilesystemStore, string, string, bool) {
+	fs, ok := blobs.GetFromContext(c).(*blobs.FilesystemStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Not found.",
+		})
+		return nil, "", "", false
+	}
+
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	err := fs.Verify(c.Request.Method, bucket, key, contentType, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Invalid or expired signature.",
+		})
+		return nil, "", "", false
+	}
+
+	return fs, bucket, key, true
+}
@@ -0,0 +1,121 @@
+package actions_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestBlobs(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Setenv("FILES_BUCKET", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("FILES_BUCKET")
+
+	e := setupWithBlobs(t, s, fs)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test sign upload url
+	signed := auth.POST("/api/s3/sign").WithForm(params.GetSignedURL{
+		Filename:    "list.csv",
+		ContentType: "text/csv",
+		Action:      "import",
+	}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("method", http.MethodPut).
+		Value("url").String().Raw()
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test upload with invalid signature
+	e.PUT(u.Path).WithQuery("expires", u.Query().Get("expires")).WithQuery("signature", "abc").
+		WithHeader("Content-Type", "text/csv").
+		WithBytes([]byte("email,name\n")).
+		Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().
+		ValueEqual("message", "Invalid or expired signature.")
+
+	// test upload with a different content type than the signed one
+	e.PUT(u.Path).WithQueryString(u.RawQuery).
+		WithHeader("Content-Type", "text/html").
+		WithBytes([]byte("email,name\n")).
+		Expect().
+		Status(http.StatusForbidden)
+
+	// test upload
+	e.PUT(u.Path).WithQueryString(u.RawQuery).
+		WithHeader("Content-Type", "text/csv").
+		WithBytes([]byte("email,name\njo@example.com,Jo\n")).
+		Expect().
+		Status(http.StatusOK)
+
+	// test download
+	signed, err = fs.SignGet("files", u.Path[len("/api/blobs/files/"):], time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.GET(u.Path).WithQueryString(u.RawQuery).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/csv").
+		Body().Equal("email,name\njo@example.com,Jo\n")
+
+	e.GET(u.Path).
+		Expect().
+		Status(http.StatusForbidden)
+
+	// test public files
+	e.GET("/api/blobs/files/assets/1/abc/logo.png").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "File not found.")
+
+	// test the files under the public prefix of the other buckets are not public
+	e.GET("/api/blobs/templates/assets/1/abc/logo.png").
+		Expect().
+		Status(http.StatusForbidden)
+
+	// test the handler is disabled with the s3 store
+	e = setup(t, s, new(s3mock.MockS3Client))
+	e.GET(u.Path).WithQueryString(u.RawQuery).
+		Expect().
+		Status(http.StatusNotFound)
+}
@@ -0,0 +1,298 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// maxCustomFields is the max number of the custom fields of a user.
+const maxCustomFields = 100
+
+func GetCustomFields(c *gin.Context) {
+	fields, err := storage.GetCustomFields(c, middleware.GetUser(c).ID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch custom fields.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch custom fields. Please try again.",
+		})
+		return
+	}
+	if fields == nil {
+		fields = entities.CustomFields{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": fields,
+	})
+}
+
+func GetCustomField(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	f, err := storage.GetCustomField(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Custom field not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+// PostCustomField defines a custom field of the subscribers' metadata. Once the user has any
+// fields, the metadata of the created and updated subscribers is validated by them.
+func PostCustomField(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.PostCustomField{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	fields, err := storage.GetCustomFields(c, u.ID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch custom fields.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to create custom field. Please try again.",
+		})
+		return
+	}
+	if fields.Find(body.Key) != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Custom field with that key already exists.",
+		})
+		return
+	}
+	if len(fields) >= maxCustomFields {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "You can't define more than " + strconv.Itoa(maxCustomFields) + " custom fields.",
+		})
+		return
+	}
+
+	f := &entities.CustomField{
+		UserID: u.ID,
+		Key:    body.Key,
+		Type:   body.Type,
+	}
+	if ok := setCustomField(c, f, &body.PutCustomField); !ok {
+		return
+	}
+	if ok := checkExistingValues(c, f); !ok {
+		return
+	}
+
+	if err := storage.CreateCustomField(c, f); err != nil {
+		logger.From(c).WithError(err).Warn("Unable to create custom field.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create custom field.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, f)
+}
+
+// PutCustomField updates the label, the default value and the options of the custom field.
+// The values already stored in the metadata of the subscribers are not changed.
+func PutCustomField(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	f, err := storage.GetCustomField(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Custom field not found.",
+		})
+		return
+	}
+
+	body := &params.PutCustomField{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	if ok := setCustomField(c, f, body); !ok {
+		return
+	}
+	if ok := checkExistingValues(c, f); !ok {
+		return
+	}
+
+	if err := storage.UpdateCustomField(c, f); err != nil {
+		logger.From(c).WithError(err).WithField("custom_field_id", id).Warn("Unable to update custom field.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to update custom field.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+// DeleteCustomField deletes the custom field, the values of the field are kept in the metadata
+// of the subscribers.
+func DeleteCustomField(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	_, err = storage.GetCustomField(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Custom field not found.",
+		})
+		return
+	}
+
+	if err := storage.DeleteCustomField(c, id, u.ID); err != nil {
+		logger.From(c).WithError(err).WithField("custom_field_id", id).Error("Unable to delete custom field.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to delete custom field. Please try again.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setCustomField sets the params to the field. The options are required by the enum fields and
+// ignored by the rest, and the default value must be valid for the type of the field. It writes
+// the error response and returns false when the params are invalid.
+func setCustomField(c *gin.Context, f *entities.CustomField, body *params.PutCustomField) bool {
+	f.Label = body.Label
+	f.Required = body.Required
+	f.OptionsJSON = nil
+
+	if f.Type == entities.CustomFieldTypeEnum {
+		if len(body.Options) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+				"errors": map[string]string{
+					"options[]": "This field is required",
+				},
+			})
+			return false
+		}
+
+		opts, err := json.Marshal(body.Options)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to encode custom field options.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save custom field. Please try again.",
+			})
+			return false
+		}
+		f.OptionsJSON = opts
+	}
+
+	f.DefaultValue = ""
+	if body.DefaultValue != "" {
+		v, err := f.NormalizeValue(body.DefaultValue)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"default_value": err.Error(),
+				},
+			})
+			return false
+		}
+		f.DefaultValue = v
+	}
+
+	return true
+}
+
+// checkExistingValues checks whether the values the subscribers already have for the key of the field
+// match its type, e.g. the segment rules compare the numbers and the dates by the types, so the
+// values which don't match would be compared as zeros. It writes the error response and returns
+// false when any of them don't match.
+func checkExistingValues(c *gin.Context, f *entities.CustomField) bool {
+	if f.Type == entities.CustomFieldTypeString {
+		return true
+	}
+
+	counts, err := storage.GetMetadataValueCounts(c, f.UserID, f.Key)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to count the metadata values.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to save custom field. Please try again.",
+		})
+		return false
+	}
+
+	var (
+		invalid int64
+		example string
+		reason  string
+	)
+	for v, n := range counts {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		if _, err := f.NormalizeValue(v); err != nil {
+			invalid += n
+			if example == "" || v < example {
+				example, reason = v, err.Error()
+			}
+		}
+	}
+	if invalid == 0 {
+		return true
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": "Invalid data",
+		"errors": map[string]string{
+			"type": fmt.Sprintf("The values of %d subscribers don't match the type of the field, e.g. '%s': %s", invalid, example, reason),
+		},
+	})
+	return false
+}
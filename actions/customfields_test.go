@@ -0,0 +1,296 @@
+package actions_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/storage/s3"
+)
+
+func TestCustomFields(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	s3mock := new(s3.MockS3Client)
+
+	e := setup(t, s, s3mock)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	e.GET("/api/custom-fields").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/custom-fields").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Empty()
+
+	// test post custom field
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "first name").
+		WithFormField("label", "First name").
+		WithFormField("type", "text").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"key":  "Must consist only of alphanumeric and hyphen characters",
+			"type": "Must be one of: string number date bool enum",
+		})
+
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "plan").
+		WithFormField("label", "Plan").
+		WithFormField("type", "enum").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"options[]": "This field is required"})
+
+	// the subscriber was added before the custom fields were defined
+	legacy := &entities.Subscriber{
+		UserID:   u.ID,
+		Email:    "legacy@example.com",
+		Active:   true,
+		MetaJSON: []byte(`{"score":"twenty","rank":"2","notes":"vip"}`),
+	}
+	assert.Nil(t, s.CreateSubscriber(legacy))
+
+	// test the values the subscribers already have must match the type
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "score").
+		WithFormField("label", "Score").
+		WithFormField("type", "number").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"type": "The values of 1 subscribers don't match the type of the field, e.g. 'twenty': Must be a number",
+		})
+
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "age").
+		WithFormField("label", "Age").
+		WithFormField("type", "number").
+		WithFormField("default_value", "unknown").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"default_value": "Must be a number"})
+
+	ageID := auth.POST("/api/custom-fields").
+		WithFormField("key", "age").
+		WithFormField("label", "Age").
+		WithFormField("type", "number").
+		WithFormField("required", "true").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("key", "age").
+		ValueEqual("type", "number").
+		ValueEqual("required", true).
+		ValueEqual("options", nil).
+		Value("id").Number().Raw()
+	ageIDStr := strconv.FormatInt(int64(ageID), 10)
+
+	planID := auth.POST("/api/custom-fields").
+		WithFormField("key", "plan").
+		WithFormField("label", "Plan").
+		WithFormField("type", "enum").
+		WithFormField("options[]", "free").
+		WithFormField("options[]", "pro").
+		WithFormField("default_value", "free").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("options", []string{"free", "pro"}).
+		ValueEqual("default_value", "free").
+		Value("id").Number().Raw()
+	planIDStr := strconv.FormatInt(int64(planID), 10)
+
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "birthday").
+		WithFormField("label", "Birthday").
+		WithFormField("type", "date").
+		Expect().
+		Status(http.StatusCreated)
+
+	auth.POST("/api/custom-fields").
+		WithFormField("key", "age").
+		WithFormField("label", "Age").
+		WithFormField("type", "string").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Custom field with that key already exists.")
+
+	// test get custom fields
+	auth.GET("/api/custom-fields").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Length().Equal(3)
+
+	auth.GET("/api/custom-fields/"+ageIDStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("label", "Age")
+
+	auth.GET("/api/custom-fields/999").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test put custom field
+	auth.PUT("/api/custom-fields/"+planIDStr).
+		WithFormField("label", "Pricing plan").
+		WithFormField("options[]", "free").
+		WithFormField("options[]", "pro").
+		WithFormField("options[]", "enterprise").
+		WithFormField("default_value", "gold").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"default_value": "Must be one of: free pro enterprise"})
+
+	auth.PUT("/api/custom-fields/"+planIDStr).
+		WithFormField("label", "Pricing plan").
+		WithFormField("options[]", "free").
+		WithFormField("options[]", "pro").
+		WithFormField("options[]", "enterprise").
+		WithFormField("default_value", "free").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("label", "Pricing plan").
+		ValueEqual("key", "plan").
+		ValueEqual("options", []string{"free", "pro", "enterprise"})
+
+	// test the metadata of the subscribers is validated by the custom fields
+	auth.POST("/api/subscribers").
+		WithFormField("email", "jane@example.com").
+		WithFormField("metadata[age]", "thirty").
+		WithFormField("metadata[plan]", "gold").
+		WithFormField("metadata[ciy]", "Skopje").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"metadata[age]":  "Must be a number",
+			"metadata[plan]": "Must be one of: free pro enterprise",
+		})
+
+	auth.POST("/api/subscribers").
+		WithFormField("email", "jane@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"metadata[age]": "This field is required"})
+
+	subID := auth.POST("/api/subscribers").
+		WithFormField("email", "jane@example.com").
+		WithFormField("metadata[age]", "30.0").
+		WithFormField("metadata[birthday]", "1991-05-06T10:00:00Z").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("metadata", map[string]string{"age": "30", "plan": "free", "birthday": "1991-05-06"}).
+		Value("id").Number().Raw()
+	subIDStr := strconv.FormatInt(int64(subID), 10)
+
+	auth.PUT("/api/subscribers/"+subIDStr).
+		WithFormField("metadata[age]", "31").
+		WithFormField("metadata[birthday]", "yesterday").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"metadata[birthday]": "Must be a date of format: 2006-01-02"})
+
+	auth.PUT("/api/subscribers/"+subIDStr).
+		WithFormField("metadata[age]", "31").
+		WithFormField("metadata[plan]", "pro").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("metadata", map[string]string{"age": "31", "plan": "pro"})
+
+	// test the keys which are not defined are kept
+	auth.PUT("/api/subscribers/"+strconv.FormatInt(legacy.ID, 10)).
+		WithFormField("metadata[age]", "40").
+		WithFormField("metadata[score]", "twenty").
+		WithFormField("metadata[rank]", "2").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("metadata", map[string]string{"age": "40", "plan": "free", "score": "twenty", "rank": "2"})
+
+	// test the imports are validated by the custom fields, the other columns are kept
+	file := "email,age,plan,notes\n" +
+		"ana@example.com,25,,new\n" +
+		"bob@example.com,old,pro,\n" +
+		"jane@example.com,32,,\n"
+	svc := subscribers.New(nil, s, subscribers.EmailValidator(newTestEmailValidator()))
+	res, err := svc.ImportSubscribersFromFile(context.Background(), u.ID, nil, strings.NewReader(file), subscribers.ImportOptions{
+		Mode: subscribers.ImportModeUpdate,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Created)
+	assert.Equal(t, 1, res.Updated)
+	assert.Equal(t, 1, res.Rejected)
+	assert.Equal(t, "Invalid custom fields (age: Must be a number).", res.RejectedRows[0].Reason)
+
+	ana, err := s.GetSubscriberByEmail("ana@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"age":"25","plan":"free","notes":"new"}`, string(ana.MetaJSON))
+
+	jane, err := s.GetSubscriberByEmail("jane@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"age":"32","plan":"pro"}`, string(jane.MetaJSON))
+
+	// test the segment rules use the types of the custom fields
+	auth.POST("/api/segments/preview").
+		WithFormField("rules", `{"field":"metadata","operator":"greater_than","key":"plan","value":"1"}`).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		Value("errors").Object().ContainsKey("rules")
+
+	auth.POST("/api/segments/preview").
+		WithFormField("rules", `{"field":"metadata","operator":"greater_than","key":"age","value":"35"}`).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1)
+
+	// test delete custom field
+	auth.DELETE("/api/custom-fields/" + ageIDStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.DELETE("/api/custom-fields/" + ageIDStr).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.GET("/api/custom-fields").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Length().Equal(2)
+}
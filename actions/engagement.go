@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetSunsetPolicy(c *gin.Context) {
+	p, err := storage.GetSunsetPolicy(c, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Sunset policy not set.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// PutSunsetPolicy creates or updates the sunset policy of the user, which is enforced by the
+// scheduler when the engagement of the subscribers is scored.
+func PutSunsetPolicy(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.PutSunsetPolicy{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	p, err := storage.GetSunsetPolicy(c, u.ID)
+	if err != nil {
+		p = &entities.SunsetPolicy{UserID: u.ID}
+	}
+	p.Enabled = body.Enabled
+	p.UnengagedCampaigns = body.UnengagedCampaigns
+
+	if err := storage.SaveSunsetPolicy(c, p); err != nil {
+		logger.From(c).WithError(err).Error("Unable to save sunset policy.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to save sunset policy. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func DeleteSunsetPolicy(c *gin.Context) {
+	err := storage.DeleteSunsetPolicy(c, middleware.GetUser(c).ID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to delete sunset policy.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to delete sunset policy. Please try again.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
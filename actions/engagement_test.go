@@ -0,0 +1,101 @@
+package actions_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/storage/s3"
+)
+
+func TestSunsetPolicy(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	s3mock := new(s3.MockS3Client)
+
+	e := setup(t, s, s3mock)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e.GET("/api/sunset-policy").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/sunset-policy").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test put sunset policy
+	auth.PUT("/api/sunset-policy").
+		WithFormField("enabled", "true").
+		WithFormField("unengaged_campaigns", "1").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"unengaged_campaigns": "Must be at least 3 character long",
+		})
+
+	auth.PUT("/api/sunset-policy").
+		WithFormField("enabled", "true").
+		WithFormField("unengaged_campaigns", "5").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("enabled", true).
+		ValueEqual("unengaged_campaigns", 5)
+
+	auth.PUT("/api/sunset-policy").
+		WithFormField("enabled", "false").
+		WithFormField("unengaged_campaigns", "10").
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/sunset-policy").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("enabled", false).
+		ValueEqual("unengaged_campaigns", 10)
+
+	// test delete sunset policy
+	auth.DELETE("/api/sunset-policy").
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/sunset-policy").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test the engagement filters of the subscribers
+	auth.POST("/api/subscribers").
+		WithFormField("email", "jane@example.com").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("engagement_score", 0).
+		ValueEqual("last_engaged_at", nil)
+
+	auth.GET("/api/subscribers").
+		WithQuery("min_engagement_score", "101").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.GET("/api/subscribers").
+		WithQuery("min_engagement_score", "1").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 0)
+
+	auth.GET("/api/subscribers").
+		WithQuery("max_engagement_score", "0").
+		WithQuery("last_engaged_before", "2030-01-01T00:00:00Z").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1)
+}
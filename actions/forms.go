@@ -0,0 +1,452 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/ezzarghili/recaptcha-go.v3"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// formHoneypotField is the name of the hidden input of the hosted forms. People don't see it,
+// so the submissions which fill it in are made by bots and are silently dropped.
+const formHoneypotField = "website"
+
+func GetForms(c *gin.Context) {
+	val, ok := c.Get("cursor")
+	if !ok {
+		logger.From(c).Error("Unable to fetch pagination cursor from context.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch forms. Please try again.",
+		})
+		return
+	}
+
+	p, ok := val.(*storage.PaginationCursor)
+	if !ok {
+		logger.From(c).Error("Unable to cast pagination cursor from context value.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch forms. Please try again.",
+		})
+		return
+	}
+
+	err := storage.GetForms(c, middleware.GetUser(c).ID, p)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch forms collection.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch forms. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func GetForm(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	f, err := storage.GetForm(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Form not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+func PostForm(c *gin.Context) {
+	f := &entities.Form{
+		UUID:   uuid.NewString(),
+		UserID: middleware.GetUser(c).ID,
+	}
+
+	if ok := bindForm(c, f); !ok {
+		return
+	}
+
+	if err := storage.CreateForm(c, f); err != nil {
+		logger.From(c).WithError(err).Warn("Unable to create form.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create form.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, f)
+}
+
+func PutForm(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	f, err := storage.GetForm(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Form not found.",
+		})
+		return
+	}
+
+	if ok := bindForm(c, f); !ok {
+		return
+	}
+
+	if err := storage.UpdateForm(c, f); err != nil {
+		logger.From(c).WithError(err).WithField("form_id", id).Warn("Unable to update form.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to update form.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+func DeleteForm(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	_, err = storage.GetForm(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Form not found.",
+		})
+		return
+	}
+
+	err = storage.DeleteForm(c, id, u.ID)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("form_id", id).Error("Unable to delete form.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to delete form.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindForm binds and validates the request body and sets it to the form. It returns false
+// when the body is invalid, in which case the response is already written.
+func bindForm(c *gin.Context, f *entities.Form) bool {
+	body := &params.Form{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return false
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return false
+	}
+
+	segments, err := storage.GetSegmentsByIDs(c, f.UserID, body.SegmentIDs)
+	if err != nil || len(segments) != len(body.SegmentIDs) || hasDynamicSegment(segments) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"segments": "Unable to find the specified segments.",
+			},
+		})
+		return false
+	}
+
+	fields := body.Fields
+	if fields == nil {
+		fields = []string{}
+	}
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"fields": "Invalid fields.",
+			},
+		})
+		return false
+	}
+
+	f.ConfirmationTemplateID = nil
+	if body.DoubleOptIn {
+		_, err = storage.GetSesKeys(c, f.UserID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to send the confirmation emails, please set your AWS Ses keys.",
+			})
+			return false
+		}
+
+		if body.ConfirmationTemplateID != 0 {
+			service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+			_, err = service.ParseTemplate(c, body.ConfirmationTemplateID, f.UserID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "Invalid data",
+					"errors": map[string]string{
+						"confirmation_template_id": "Unable to find or parse the specified template.",
+					},
+				})
+				return false
+			}
+			f.ConfirmationTemplateID = &body.ConfirmationTemplateID
+		}
+	}
+
+	f.Name = body.Name
+	f.Segments = segments
+	f.FieldsJSON = fieldsJSON
+	f.SuccessRedirect = body.SuccessRedirect
+	f.DoubleOptIn = body.DoubleOptIn
+	f.ConfirmationSource = body.ConfirmationSource
+
+	return true
+}
+
+// GetFormPage renders the hosted page of the form by the uuid in the query string.
+func GetFormPage(c *gin.Context) {
+	f, err := storage.GetFormByUUID(c, c.Query("uuid"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "form.html", gin.H{
+			"notFound": true,
+		})
+		return
+	}
+
+	fields, err := f.GetFields()
+	if err != nil {
+		logger.From(c).WithError(err).WithField("form_id", f.ID).Error("Unable to get form fields.")
+	}
+
+	c.HTML(http.StatusOK, "form.html", gin.H{
+		"name":             f.Name,
+		"uuid":             f.UUID,
+		"fields":           fields,
+		"failed":           c.Query("failed"),
+		"honeypot":         formHoneypotField,
+		"recaptchaSiteKey": os.Getenv("RECAPTCHA_SITE_KEY"),
+	})
+}
+
+// PostFormSubscribe creates a subscriber from the submission of a hosted form and redirects to
+// the success page of the form, or back to the form when the submission is not valid.
+func PostFormSubscribe(c *gin.Context) {
+	f, err := storage.GetFormByUUID(c, c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Form not found.",
+		})
+		return
+	}
+
+	success := f.SuccessRedirect
+	if success == "" {
+		success = os.Getenv("APP_URL") + "/form-success.html"
+	}
+
+	q := url.Values{}
+	q.Add("uuid", f.UUID)
+	q.Add("failed", "true")
+	redirWithError := os.Getenv("APP_URL") + "/form.html?" + q.Encode()
+
+	log := logger.From(c).WithFields(logrus.Fields{
+		"form_id": f.ID,
+		"user_id": f.UserID,
+	})
+
+	if c.PostForm(formHoneypotField) != "" {
+		log.Info("Form subscribe: honeypot field is filled in, dropping the submission.")
+		c.Redirect(http.StatusSeeOther, success)
+		return
+	}
+
+	if secret := os.Getenv("RECAPTCHA_SECRET"); secret != "" {
+		captcha, err := recaptcha.NewReCAPTCHA(secret, recaptcha.V2, 10*time.Second)
+		if err != nil {
+			log.WithError(err).Error("Form subscribe: recaptcha initialize error.")
+			c.Redirect(http.StatusSeeOther, redirWithError)
+			return
+		}
+
+		err = captcha.VerifyWithOptions(c.PostForm("g-recaptcha-response"), recaptcha.VerifyOption{RemoteIP: c.ClientIP()})
+		if err != nil {
+			log.WithError(err).Warn("Form subscribe: reCAPTCHA verification failed.")
+			c.Redirect(http.StatusSeeOther, redirWithError)
+			return
+		}
+	}
+
+	body := &params.PostFormSubscribe{}
+	if err := c.ShouldBind(body); err != nil {
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+	body.Metadata = c.PostFormMap("metadata")
+
+	if err := validator.Validate(body); err != nil {
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	owner, err := storage.GetUser(c, f.UserID)
+	if err != nil {
+		log.WithError(err).Warn("Form subscribe: unable to find the owner of the form.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	limitexceeded, _, err := boundaries.New(storage.GetFromContext(c)).SubscribersLimitExceeded(owner)
+	if err != nil || limitexceeded {
+		log.WithError(err).Warn("Form subscribe: the owner has exceeded the subscribers limit.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	// the existing subscribers are not changed, the form doesn't tell whether the email exists.
+	_, err = storage.GetSubscriberByEmail(c, body.Email, owner.ID)
+	if err == nil {
+		c.Redirect(http.StatusSeeOther, success)
+		return
+	}
+
+	// neither are the suppressed emails subscribed nor sent the confirmation email.
+	_, err = storage.GetSuppressionForEmail(c, owner.ID, body.Email)
+	if err == nil {
+		log.Info("Form subscribe: the email is suppressed.")
+		c.Redirect(http.StatusSeeOther, success)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.WithError(err).Error("Form subscribe: unable to check whether the email is suppressed.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	fields, err := f.GetFields()
+	if err != nil {
+		log.WithError(err).Error("Form subscribe: unable to get form fields.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	// only the fields collected by the form are stored as metadata.
+	metadata := make(map[string]string)
+	for _, field := range fields {
+		if v := body.Metadata[field]; v != "" {
+			metadata[field] = v
+		}
+	}
+
+	customFields, err := storage.GetCustomFields(c, owner.ID)
+	if err != nil {
+		log.WithError(err).Error("Form subscribe: unable to get custom fields.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	if errs := customFields.ApplyTo(metadata); len(errs) > 0 {
+		log.WithField("errors", errs).Info("Form subscribe: the metadata doesn't match the custom fields.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	metaJSON, err := json.Marshal(metadata)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	now := time.Now().UTC()
+	s := &entities.Subscriber{
+		UserID:    owner.ID,
+		Name:      body.Name,
+		Email:     entities.NormalizeEmail(body.Email),
+		MetaJSON:  metaJSON,
+		Segments:  f.Segments,
+		Active:    !f.DoubleOptIn,
+		Pending:   f.DoubleOptIn,
+		ConsentAt: &now,
+		ConsentIP: c.ClientIP(),
+	}
+
+	var (
+		sesKeys *entities.SesKeys
+		tmpl    *entities.CampaignTemplateData
+	)
+	if f.DoubleOptIn {
+		sesKeys, err = storage.GetSesKeys(c, owner.ID)
+		if err != nil {
+			log.WithError(err).Error("Form subscribe: unable to get ses keys.")
+			c.Redirect(http.StatusSeeOther, redirWithError)
+			return
+		}
+
+		if f.ConfirmationTemplateID != nil {
+			service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+			tmpl, err = service.ParseTemplate(c, *f.ConfirmationTemplateID, owner.ID)
+			if err != nil {
+				log.WithError(err).Error("Form subscribe: unable to parse confirmation template.")
+				c.Redirect(http.StatusSeeOther, redirWithError)
+				return
+			}
+		}
+	}
+
+	err = storage.CreateSubscriber(c, s)
+	if err != nil {
+		log.WithError(err).Warn("Form subscribe: unable to create subscriber.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	if f.DoubleOptIn {
+		err = publishConfirmationEmail(c, owner, s, sesKeys, tmpl, f.ConfirmationSource)
+		if err != nil {
+			log.WithError(err).
+				WithField("subscriber_id", s.ID).
+				Error("Form subscribe: unable to send confirmation email.")
+		}
+	}
+
+	c.Redirect(http.StatusSeeOther, success)
+}
@@ -0,0 +1,263 @@
+package actions_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestForms(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, blobs.NewS3(new(s3mock.MockS3Client)), producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test post form unauthorized
+	e.POST("/api/forms").WithForm(params.Form{Name: "Newsletter"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test validation on post form
+	auth.POST("/api/forms").WithFormField("fields[]", "company name").WithFormField("success_redirect", "foo").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again").
+		ValueEqual("errors", map[string]string{
+			"name":             "This field is required",
+			"segments[]":       "This field is required",
+			"fields[][0]":      "Must consist only of alphanumeric and hyphen characters",
+			"success_redirect": "Validation failed on condition: url",
+		})
+
+	// test post form with missing segments
+	auth.POST("/api/forms").WithFormField("name", "Newsletter").WithFormField("segments[]", 100).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"segments": "Unable to find the specified segments."})
+
+	segID := int64(auth.POST("/api/segments").WithForm(params.Segment{Name: "newsletter"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("id").Number().Raw())
+
+	// test double opt-in form without ses keys
+	auth.POST("/api/forms").
+		WithFormField("name", "Newsletter").
+		WithFormField("segments[]", segID).
+		WithFormField("double_opt_in", true).
+		WithFormField("confirmation_source", "news@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Unable to send the confirmation emails, please set your AWS Ses keys.")
+
+	// test post form
+	obj := auth.POST("/api/forms").
+		WithFormField("name", "Newsletter").
+		WithFormField("segments[]", segID).
+		WithFormField("fields[]", "company").
+		WithFormField("success_redirect", "https://example.com/thanks").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+
+	obj.ValueEqual("name", "Newsletter").
+		ValueEqual("fields", []string{"company"}).
+		ValueEqual("success_redirect", "https://example.com/thanks")
+	obj.Value("segments").Array().Length().Equal(1)
+
+	id := int64(obj.Value("id").Number().Raw())
+	formUUID := obj.Value("uuid").String().Raw()
+
+	// test get form
+	auth.GET("/api/forms/"+strconv.FormatInt(id, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("uuid", formUUID)
+
+	auth.GET("/api/forms/100").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test get forms
+	auth.GET("/api/forms").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1)
+
+	// test put form
+	auth.PUT("/api/forms/"+strconv.FormatInt(id, 10)).
+		WithFormField("name", "Newsletter signup").
+		WithFormField("segments[]", segID).
+		WithFormField("fields[]", "company").
+		WithFormField("fields[]", "city").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("name", "Newsletter signup").
+		ValueEqual("fields", []string{"company", "city"}).
+		ValueEqual("success_redirect", "")
+
+	// test subscribe to a form which doesn't exist
+	e.POST("/api/forms/foo/subscribe").WithFormField("email", "jo@example.com").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test subscribe with invalid email
+	e.POST("/api/forms/"+formUUID+"/subscribe").WithFormField("email", "jo").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("failed=true")
+
+	// test the honeypot submissions are dropped
+	e.POST("/api/forms/"+formUUID+"/subscribe").
+		WithFormField("email", "bot@example.com").
+		WithFormField("website", "http://spam.example.com").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal("/form-success.html")
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	_, err = s.GetSubscriberByEmail("bot@example.com", u.ID)
+	if err == nil {
+		t.Error("expected the honeypot submission to be dropped")
+	}
+
+	// test subscribe
+	e.POST("/api/forms/"+formUUID+"/subscribe").
+		WithFormField("email", "jo@example.com").
+		WithFormField("name", "Jo").
+		WithFormField("metadata[company]", "Mailbadger").
+		WithFormField("metadata[secret]", "not collected").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal("/form-success.html")
+
+	sub, err := s.GetSubscriberByEmail("jo@example.com", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := sub.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["company"] != "Mailbadger" || !sub.Active || sub.ConsentAt == nil {
+		t.Errorf("unexpected subscriber %+v, metadata %v", sub, m)
+	}
+
+	total, err := s.GetTotalSubscribersBySegment(segID, u.ID)
+	if err != nil || total != 1 {
+		t.Errorf("expected 1 subscriber in segment, got %d (%v)", total, err)
+	}
+
+	// test subscribing again doesn't tell the email exists
+	e.POST("/api/forms/"+formUUID+"/subscribe").WithFormField("email", "jo@example.com").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal("/form-success.html")
+
+	// test the metadata is validated and normalized by the custom fields
+	err = s.CreateCustomField(&entities.CustomField{
+		UserID:      u.ID,
+		Key:         "city",
+		Label:       "City",
+		Type:        entities.CustomFieldTypeEnum,
+		OptionsJSON: entities.JSON(`["Skopje","Ohrid"]`),
+	})
+	if err != nil {
+		t.FailNow()
+	}
+
+	e.POST("/api/forms/"+formUUID+"/subscribe").
+		WithFormField("email", "paris@example.com").
+		WithFormField("metadata[city]", "Paris").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("failed=true")
+
+	_, err = s.GetSubscriberByEmail("paris@example.com", u.ID)
+	assert.NotNil(t, err)
+
+	e.POST("/api/forms/"+formUUID+"/subscribe").
+		WithFormField("email", "ohrid@example.com").
+		WithFormField("metadata[city]", " Ohrid ").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal("/form-success.html")
+
+	sub, err = s.GetSubscriberByEmail("ohrid@example.com", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"city":"Ohrid"}`, string(sub.MetaJSON))
+
+	// test the double opt-in subscribers are pending and their confirmation email is queued
+	err = os.Setenv("UNSUBSCRIBE_SECRET", "secret")
+	if err != nil {
+		t.FailNow()
+	}
+	err = s.CreateSesKeys(&entities.SesKeys{UserID: u.ID, AccessKey: "key", SecretKey: "secret", Region: "eu-west-1"})
+	if err != nil {
+		t.FailNow()
+	}
+	auth.PUT("/api/forms/"+strconv.FormatInt(id, 10)).
+		WithFormField("name", "Newsletter signup").
+		WithFormField("segments[]", segID).
+		WithFormField("double_opt_in", true).
+		WithFormField("confirmation_source", "news@example.com").
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/api/forms/"+formUUID+"/subscribe").WithFormField("email", "pending@example.com").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal("/form-success.html")
+
+	pending, err := s.GetSubscriberByEmail("pending@example.com", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, pending.Pending)
+	assert.False(t, pending.Active)
+
+	messages := producer.Messages(entities.SenderTopic)
+	if !assert.Len(t, messages, 1) {
+		t.FailNow()
+	}
+	msg := new(entities.SenderTopicParams)
+	assert.Nil(t, json.Unmarshal(messages[0], msg))
+	assert.Equal(t, pending.ID, msg.SubscriberID)
+	assert.Equal(t, "news@example.com", msg.Source)
+
+	// test delete form
+	auth.DELETE("/api/forms/" + strconv.FormatInt(id, 10)).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/forms/" + strconv.FormatInt(id, 10)).
+		Expect().
+		Status(http.StatusNotFound)
+}
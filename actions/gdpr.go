@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+)
+
+// GetSubscriberGDPRExport returns everything that is stored about the subscriber as a JSON
+// attachment, for the data subject access requests.
+func GetSubscriberGDPRExport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	s, err := storage.GetSubscriber(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Subscriber not found",
+		})
+		return
+	}
+
+	data, err := storage.GetSubscriberData(c, s)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("subscriber_id", id).Error("Unable to fetch subscriber's data.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to export subscriber's data. Please try again.",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="subscriber-`+strconv.FormatInt(id, 10)+`.json"`)
+	c.JSON(http.StatusOK, data)
+}
+
+// EraseSubscriber erases the subscriber's data, see storage.EraseSubscriber, and responds with
+// the audit record of the erasure.
+func EraseSubscriber(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	s, err := storage.GetSubscriber(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Subscriber not found",
+		})
+		return
+	}
+
+	erasure := &entities.GDPRErasure{
+		ErasedBy:  u.Username,
+		IPAddress: c.ClientIP(),
+	}
+	if err := storage.EraseSubscriber(c, s, erasure); err != nil {
+		logger.From(c).WithError(err).WithField("subscriber_id", id).Error("Unable to erase subscriber.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to erase subscriber. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, erasure)
+}
+
+func GetGDPRErasures(c *gin.Context) {
+	val, ok := c.Get("cursor")
+	if !ok {
+		logger.From(c).Error("Unable to fetch pagination cursor from context.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch erasures. Please try again.",
+		})
+		return
+	}
+
+	p, ok := val.(*storage.PaginationCursor)
+	if !ok {
+		logger.From(c).Error("Unable to cast pagination cursor from context value.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch erasures. Please try again.",
+		})
+		return
+	}
+
+	err := storage.GetGDPRErasures(c, middleware.GetUser(c).ID, p)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch erasures collection.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch erasures. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,91 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/storage/s3"
+)
+
+func TestGDPR(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	s3mock := new(s3.MockS3Client)
+
+	e := setup(t, s, s3mock)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	id := auth.POST("/api/subscribers").
+		WithFormField("email", "jane@example.com").
+		WithFormField("metadata[city]", "Skopje").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		Value("id").Number().Raw()
+	idStr := strconv.FormatInt(int64(id), 10)
+
+	// test gdpr export
+	e.GET("/api/subscribers/" + idStr + "/gdpr-export").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/subscribers/999/gdpr-export").
+		Expect().
+		Status(http.StatusNotFound)
+
+	res := auth.GET("/api/subscribers/" + idStr + "/gdpr-export").
+		Expect().
+		Status(http.StatusOK)
+	res.Header("Content-Disposition").Equal(`attachment; filename="subscriber-` + idStr + `.json"`)
+	obj := res.JSON().Object()
+	obj.Value("subscriber").Object().
+		ValueEqual("email", "jane@example.com").
+		ValueEqual("metadata", map[string]string{"city": "Skopje"})
+	obj.Value("events").Array().Length().Equal(1)
+	obj.Value("events").Array().Element(0).Object().ValueEqual("event_type", "created")
+	obj.Value("opens").Array().Empty()
+
+	// test gdpr erasure
+	auth.POST("/api/subscribers/999/gdpr-erase").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/subscribers/"+idStr+"/gdpr-erase").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("subscriber_id", id).
+		ValueEqual("erased_by", "john").
+		Value("summary").Object().
+		ValueEqual("subscribers", 1).
+		ValueEqual("subscriber_events", 1)
+
+	auth.GET("/api/subscribers/" + idStr).
+		Expect().
+		Status(http.StatusNotFound)
+
+	// the erased subscribers can't be added again
+	auth.POST("/api/subscribers").
+		WithFormField("email", "Jane@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"email": "The email is on the suppression list (erased).",
+		})
+
+	// test get erasures
+	auth.GET("/api/subscribers/gdpr-erasures").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1).
+		Value("collection").Array().Element(0).Object().
+		ValueEqual("subscriber_id", id)
+}
@@ -136,6 +136,8 @@ func HandleHook(c *gin.Context) {
 						"recipient": recipient,
 					}).WithError(err).Error("Unable to blacklist bounced recipient.")
 				}
+
+				suppress(c, u.ID, recipient.EmailAddress, entities.SuppressionReasonBounce, entities.SuppressionSourceSES)
 			}
 		}
 	case emails.ComplaintType:
@@ -162,6 +164,8 @@ func HandleHook(c *gin.Context) {
 					"recipient":   recipient,
 				}).WithError(err).Error("Unable to create complaint record.")
 			}
+
+			suppress(c, u.ID, recipient.EmailAddress, entities.SuppressionReasonComplaint, entities.SuppressionSourceSES)
 		}
 	case emails.DeliveryType:
 		if msg.Delivery == nil {
@@ -0,0 +1,744 @@
+package actions_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/storage"
+)
+
+func TestImportSubscribers(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Setenv("FILES_BUCKET", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("FILES_BUCKET")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, fs, producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	existing := &entities.Subscriber{
+		Email:    "jo@example.com",
+		Name:     "Jo",
+		UserID:   u.ID,
+		Active:   true,
+		MetaJSON: []byte(`{"city":"Skopje","plan":"free"}`),
+	}
+	err = s.CreateSubscriber(existing)
+	if err != nil {
+		t.FailNow()
+	}
+
+	file := "\xEF\xBB\xBFE-mail;Full name;Plan;Notes\n" +
+		"jo@example.com;;pro;\n" +
+		"ana@example.com;Ana;free;\"multi\nline\"\n" +
+		"not-an-email;Bad;free;\n" +
+		"ana@example.com;Ana again;free;\n" +
+		"short@example.com;Short\n"
+	err = fs.Put("files", fmt.Sprintf("subscribers/import/%d/list.csv", u.ID), bytes.NewReader([]byte(file)), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test preview
+	preview := auth.POST("/api/subscribers/import/preview").WithFormField("filename", "list.csv").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preview.ValueEqual("delimiter", ";")
+	preview.ValueEqual("header", []string{"E-mail", "Full name", "Plan", "Notes"})
+	preview.Value("rows").Array().Length().Equal(5)
+	preview.Value("rows").Array().Element(1).Array().Element(3).Equal("multi\nline")
+	preview.ValueEqual("mapping", map[string]string{
+		"E-mail":    "email",
+		"Full name": "Full-name",
+		"Plan":      "Plan",
+		"Notes":     "Notes",
+	})
+
+	auth.POST("/api/subscribers/import/preview").WithFormField("filename", "missing.csv").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test invalid mode and mapping
+	auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.csv").
+		WithFormField("mode", "merge").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"mode": "Must be one of: skip update replace"})
+
+	auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.csv").
+		WithFormField("mapping[E-mail]", "ignore").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"mapping": "Map one of the columns to the email."})
+
+	auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.csv").
+		WithFormField("mapping[Plan]", "email").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"mapping": "more than one column is mapped to the email"})
+
+	// test import
+	id := auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.csv").
+		WithFormField("mode", "update").
+		WithFormField("mapping[Full name]", "name").
+		WithFormField("mapping[Plan]", "plan").
+		WithFormField("mapping[Notes]", "ignore").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusPending).
+		ValueEqual("mode", "update").
+		ValueEqual("total", 5).
+		Value("id").Number().Raw()
+
+	idStr := strconv.FormatInt(int64(id), 10)
+
+	// there is no error report before the import is run
+	auth.GET("/api/subscribers/imports/"+idStr+"/errors").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Error report not found.")
+
+	messages := producer.Messages(entities.SubscriberImportTopic)
+	assert.Len(t, messages, 1)
+	msg := new(entities.SubscriberImportTopicParams)
+	assert.Nil(t, json.Unmarshal(messages[0], msg))
+	assert.Equal(t, int64(id), msg.ImportID)
+	assert.Equal(t, u.ID, msg.UserID)
+
+	// the importer consumer runs the import
+	imp, err := s.GetSubscriberImport(msg.ImportID, msg.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := subscribers.New(fs, s, subscribers.EmailValidator(newTestEmailValidator()))
+	assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+	// the import which is not pending is not run again
+	assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+	obj := auth.GET("/api/subscribers/imports/" + idStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	obj.ValueEqual("status", entities.StatusDone)
+	obj.ValueEqual("total", 5)
+	obj.ValueEqual("processed", 5)
+	obj.ValueEqual("created", 1)
+	obj.ValueEqual("updated", 1)
+	obj.ValueEqual("skipped", 0)
+	obj.ValueEqual("failed", 3)
+	obj.Value("started_at").String().NotEmpty()
+	obj.Value("completed_at").String().NotEmpty()
+
+	auth.GET("/api/subscribers/imports/999").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/subscribers/imports/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "The import is already finished.")
+
+	ana, err := s.GetSubscriberByEmail("ana@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ana", ana.Name)
+	assert.JSONEq(t, `{"plan":"free"}`, string(ana.MetaJSON))
+
+	jo, err := s.GetSubscriberByEmail("jo@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Jo", jo.Name)
+	assert.JSONEq(t, `{"city":"Skopje","plan":"pro"}`, string(jo.MetaJSON))
+
+	_, err = s.GetSubscriberByEmail("short@example.com", u.ID)
+	assert.NotNil(t, err)
+
+	// test download of the error report
+	auth.GET("/api/subscribers/imports/999/errors").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Import not found.")
+
+	signed := auth.GET("/api/subscribers/imports/"+idStr+"/errors").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("url").String().Raw()
+
+	link, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.GET(link.Path).WithQueryString(link.RawQuery).
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal("row,error,E-mail,Full name,Plan,Notes\n" +
+		"4,The email is not valid.,not-an-email,Bad,free,\n" +
+		"5,The email is duplicated in the file.,ana@example.com,Ana again,free,\n" +
+		"6,The row has 2 columns instead of 4.,short@example.com,Short\n")
+
+	// test cancel
+	firstIDStr := idStr
+	id = auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.csv").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("id").Number().Raw()
+	idStr = strconv.FormatInt(int64(id), 10)
+
+	auth.POST("/api/subscribers/imports/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusCancelled).
+		Value("completed_at").String().NotEmpty()
+
+	imp, err = s.GetSubscriberImport(int64(id), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+	auth.GET("/api/subscribers/imports/"+idStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusCancelled).
+		ValueEqual("processed", 0)
+
+	// the import of the same file has a report of its own, the report of the first import is kept
+	auth.GET("/api/subscribers/imports/"+idStr+"/errors").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.GET("/api/subscribers/imports/"+firstIDStr+"/errors").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("url").String().NotEmpty()
+
+	// test the file with only the header
+	err = fs.Put("files", fmt.Sprintf("subscribers/import/%d/empty.csv", u.ID), bytes.NewReader([]byte("E-mail,Full name,Plan,Notes\n")), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id = auth.POST("/api/subscribers/import").
+		WithFormField("filename", "empty.csv").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 0).
+		Value("id").Number().Raw()
+	idStr = strconv.FormatInt(int64(id), 10)
+
+	imp, err = s.GetSubscriberImport(int64(id), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+	auth.GET("/api/subscribers/imports/"+idStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusDone).
+		ValueEqual("processed", 0).
+		Value("completed_at").String().NotEmpty()
+}
+
+func TestImportSubscribersFormats(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Setenv("FILES_BUCKET", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("FILES_BUCKET")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, fs, producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	files := map[string][]byte{
+		"list.json": []byte(`[
+			{"email": "ana@example.com", "name": "Ana", "address": {"city": "Skopje"}, "age": 30},
+			"not an object",
+			{"email": "bob@example.com", "tags": ["a", "b"], "vip": true}
+		]`),
+		"list.ndjson": []byte("{\"email\": \"cy@example.com\", \"name\": \"Cy\"}\n" +
+			"\n" +
+			"{\"email\": \"dee@example.com\", \"name\": \"Dee\", \"plan\": null}\n" +
+			"{\"email\": \"broken@example.com\"\n" +
+			"{\"email\": \"nomx@nowhere.invalid\"}\n" +
+			"{\"email\": \"info@example.com\"}\n"),
+		"list.xlsx": newTestXLSX(t, []string{"Notes", "People"}, [][][]string{
+			{{"nothing to import"}},
+			{{"Email", "Name", "Company"}, {"eve@example.com", "Eve"}, {"fay@example.com", "Fay", "ACME"}},
+		}),
+	}
+	for name, data := range files {
+		err = fs.Put("files", fmt.Sprintf("subscribers/import/%d/%s", u.ID, name), bytes.NewReader(data), blobs.PutOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// test preview of the detected formats
+	preview := auth.POST("/api/subscribers/import/preview").WithFormField("filename", "list.json").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preview.ValueEqual("format", "json")
+	preview.ValueEqual("header", []string{"address.city", "age", "email", "name", "tags", "vip"})
+	preview.ValueEqual("total", 3)
+	preview.ValueEqual("rows", [][]string{
+		{"Skopje", "30", "ana@example.com", "Ana", "", ""},
+		{"", "", "bob@example.com", "", `["a","b"]`, "true"},
+	})
+	preview.ValueEqual("mapping", map[string]string{
+		"address.city": "address-city",
+		"age":          "age",
+		"email":        "email",
+		"name":         "name",
+		"tags":         "tags",
+		"vip":          "vip",
+	})
+
+	preview = auth.POST("/api/subscribers/import/preview").WithFormField("filename", "list.ndjson").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preview.ValueEqual("format", "ndjson")
+	preview.ValueEqual("header", []string{"email", "name", "plan"})
+	preview.ValueEqual("total", 5)
+
+	preview = auth.POST("/api/subscribers/import/preview").
+		WithFormField("filename", "list.xlsx").
+		WithFormField("sheet", "People").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	preview.ValueEqual("format", "xlsx")
+	preview.ValueEqual("sheets", []string{"Notes", "People"})
+	preview.ValueEqual("sheet", "People")
+	preview.ValueEqual("header", []string{"Email", "Name", "Company"})
+	preview.ValueEqual("rows", [][]string{
+		{"eve@example.com", "Eve", ""},
+		{"fay@example.com", "Fay", "ACME"},
+	})
+
+	auth.POST("/api/subscribers/import/preview").
+		WithFormField("filename", "list.xlsx").
+		WithFormField("sheet", "Missing").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"sheet": "Unable to find the sheet."})
+
+	auth.POST("/api/subscribers/import/preview").
+		WithFormField("filename", "list.json").
+		WithFormField("format", "xlsx").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"filename": "Unable to read the file, please check whether it's a valid CSV, NDJSON, JSON or XLSX file.",
+		})
+
+	auth.POST("/api/subscribers/import/preview").
+		WithFormField("filename", "list.json").
+		WithFormField("format", "yaml").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"format": "Must be one of: csv ndjson json xlsx"})
+
+	// the first sheet has no email column
+	auth.POST("/api/subscribers/import").
+		WithFormField("filename", "list.xlsx").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"mapping": "Map one of the columns to the email."})
+
+	svc := subscribers.New(fs, s, subscribers.EmailValidator(newTestEmailValidator()))
+	imports := []struct {
+		filename string
+		sheet    string
+		total    int
+		created  int
+		failed   int
+	}{
+		{"list.json", "", 3, 2, 1},
+		{"list.ndjson", "", 5, 3, 2},
+		{"list.xlsx", "People", 2, 2, 0},
+	}
+	for _, tc := range imports {
+		req := auth.POST("/api/subscribers/import").WithFormField("filename", tc.filename)
+		if tc.sheet != "" {
+			req = req.WithFormField("sheet", tc.sheet)
+		}
+		id := req.Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			ValueEqual("total", tc.total).
+			Value("id").Number().Raw()
+
+		imp, err := s.GetSubscriberImport(int64(id), u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.sheet, imp.Sheet)
+		assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+		obj := auth.GET("/api/subscribers/imports/" + strconv.FormatInt(int64(id), 10)).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object()
+		obj.ValueEqual("status", entities.StatusDone)
+		obj.ValueEqual("created", tc.created)
+		obj.ValueEqual("failed", tc.failed)
+	}
+
+	ana, err := s.GetSubscriberByEmail("ana@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ana", ana.Name)
+	assert.JSONEq(t, `{"address-city":"Skopje","age":"30"}`, string(ana.MetaJSON))
+
+	bob, err := s.GetSubscriberByEmail("bob@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"tags":"[\"a\",\"b\"]","vip":"true"}`, string(bob.MetaJSON))
+
+	fay, err := s.GetSubscriberByEmail("fay@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Fay", fay.Name)
+	assert.JSONEq(t, `{"Company":"ACME"}`, string(fay.MetaJSON))
+
+	_, err = s.GetSubscriberByEmail("broken@example.com", u.ID)
+	assert.NotNil(t, err)
+
+	// the addresses are validated, the domains without mail servers are rejected
+	assert.Equal(t, entities.EmailStatusValid, ana.EmailStatus)
+
+	_, err = s.GetSubscriberByEmail("nomx@nowhere.invalid", u.ID)
+	assert.NotNil(t, err)
+
+	info, err := s.GetSubscriberByEmail("info@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.EmailStatusRisky, info.EmailStatus)
+	assert.Contains(t, string(info.EmailValidationJSON), `"check":"role"`)
+}
+
+// newTestXLSX builds a workbook with the sheets of the rows, the cells are inline strings.
+func newTestXLSX(t *testing.T, names []string, sheets [][][]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	write := func(name, content string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wb, rels strings.Builder
+	wb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range names {
+		fmt.Fprintf(&wb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+
+		var sheet strings.Builder
+		sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+		for r, row := range sheets[i] {
+			fmt.Fprintf(&sheet, `<row r="%d">`, r+1)
+			for c, v := range row {
+				fmt.Fprintf(&sheet, `<c r="%c%d" t="inlineStr"><is><t>%s</t></is></c>`, 'A'+c, r+1, v)
+			}
+			sheet.WriteString(`</row>`)
+		}
+		sheet.WriteString(`</sheetData></worksheet>`)
+		write(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.String())
+	}
+	wb.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+	write("xl/workbook.xml", wb.String())
+	write("xl/_rels/workbook.xml.rels", rels.String())
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBulkUpdateSubscribers(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	dir, err := ioutil.TempDir("", "bulk-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Setenv("FILES_BUCKET", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("FILES_BUCKET")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, fs, producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	vip := &entities.Segment{Name: "VIP", UserID: u.ID}
+	old := &entities.Segment{Name: "Old", UserID: u.ID}
+	dyn := &entities.Segment{Name: "Dynamic", UserID: u.ID, Dynamic: true}
+	for _, seg := range []*entities.Segment{vip, old, dyn} {
+		if err := s.CreateSegment(seg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []*entities.CustomField{
+		{UserID: u.ID, Key: "city", Label: "City", Type: entities.CustomFieldTypeString},
+		{UserID: u.ID, Key: "age", Label: "Age", Type: entities.CustomFieldTypeNumber},
+	} {
+		if err := s.CreateCustomField(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sub := range []*entities.Subscriber{
+		{Email: "jo@example.com", Name: "Jo", UserID: u.ID, Active: true, MetaJSON: []byte(`{"city":"Skopje","plan":"free"}`), Segments: []entities.Segment{*old}},
+		{Email: "ana@example.com", Name: "Ana", UserID: u.ID, Active: true, MetaJSON: []byte(`{"age":"30"}`)},
+		{Email: "bo@example.com", Name: "Bo", UserID: u.ID, Active: true},
+		{Email: "cy@example.com", Name: "Cy", UserID: u.ID, Active: true},
+	} {
+		if err := s.CreateSubscriber(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := fmt.Sprintf("Email,Name,Active,metadata.city,metadata.age,unset_metadata,add_segments,remove_segments\n"+
+		"jo@example.com,Joanna,false,Ohrid,,plan,%d,%d\n"+
+		"ana@example.com,,,,abc,,,\n"+
+		"bo@example.com,,,,,,,\n"+
+		"missing@example.com,Mia,,,,,,\n"+
+		"jo@example.com,Jo,,,,,,\n"+
+		"cy@example.com,,,,,,%d,\n"+
+		"dee@example.com,,maybe,,,,,\n", vip.ID, old.ID, dyn.ID)
+	err = fs.Put("files", fmt.Sprintf("subscribers/import/%d/update.csv", u.ID), bytes.NewReader([]byte(file)), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.Put("files", fmt.Sprintf("subscribers/import/%d/unknown.csv", u.ID), bytes.NewReader([]byte("email,plan\njo@example.com,pro\n")), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test invalid files
+	auth.POST("/api/subscribers/bulk-update").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"filename": "This field is required"})
+
+	auth.POST("/api/subscribers/bulk-update").WithFormField("filename", "missing.csv").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/subscribers/bulk-update").WithFormField("filename", "unknown.csv").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"columns": "unknown column 'plan'"})
+
+	svc := subscribers.New(fs, s, subscribers.EmailValidator(newTestEmailValidator()))
+	report := "row,email,result,details\n" +
+		`2,jo@example.com,updated,"name: ""Jo"" => ""Joanna""; active: true => false; metadata.city: ""Skopje"" => ""Ohrid""; metadata.plan: unset; removed from segment ""Old""; added to segment ""VIP"""` + "\n" +
+		"3,ana@example.com,failed,Invalid custom fields (age: Must be a number).\n" +
+		"4,bo@example.com,unchanged,\n" +
+		"5,missing@example.com,failed,The subscriber was not found.\n" +
+		"6,jo@example.com,failed,The email is duplicated in the file.\n" +
+		fmt.Sprintf(`7,cy@example.com,failed,"The segment %d is dynamic, its members are matched by its rules."`, dyn.ID) + "\n" +
+		"8,dee@example.com,failed,The value of 'active' must be true or false.\n"
+
+	// run queues the bulk update, runs it like the importer consumer, and checks the counters and the report.
+	run := func(dryRun bool) {
+		id := auth.POST("/api/subscribers/bulk-update").
+			WithFormField("filename", "update.csv").
+			WithFormField("dry_run", strconv.FormatBool(dryRun)).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			ValueEqual("kind", entities.SubscriberImportKindBulkUpdate).
+			ValueEqual("dry_run", dryRun).
+			ValueEqual("status", entities.StatusPending).
+			ValueEqual("total", 7).
+			Value("id").Number().Raw()
+
+		idStr := strconv.FormatInt(int64(id), 10)
+
+		// there is no report before the bulk update is run
+		auth.GET("/api/subscribers/imports/"+idStr+"/report").
+			Expect().
+			Status(http.StatusNotFound).
+			JSON().Object().
+			ValueEqual("message", "Report not found.")
+
+		messages := producer.Messages(entities.SubscriberImportTopic)
+		msg := new(entities.SubscriberImportTopicParams)
+		assert.Nil(t, json.Unmarshal(messages[len(messages)-1], msg))
+		assert.Equal(t, int64(id), msg.ImportID)
+
+		imp, err := s.GetSubscriberImport(msg.ImportID, msg.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, svc.RunImport(context.Background(), imp))
+
+		obj := auth.GET("/api/subscribers/imports/" + idStr).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object()
+		obj.ValueEqual("status", entities.StatusDone)
+		obj.ValueEqual("processed", 7)
+		obj.ValueEqual("updated", 1)
+		obj.ValueEqual("skipped", 1)
+		obj.ValueEqual("failed", 5)
+
+		// the bulk update has no error report, its report is served instead
+		auth.GET("/api/subscribers/imports/"+idStr+"/errors").
+			Expect().
+			Status(http.StatusNotFound).
+			JSON().Object().
+			ValueEqual("message", "Import not found.")
+
+		signed := auth.GET("/api/subscribers/imports/"+idStr+"/report").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("url").String().Raw()
+
+		link, err := url.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.GET(link.Path).WithQueryString(link.RawQuery).
+			Expect().
+			Status(http.StatusOK).
+			Body().Equal(report)
+	}
+
+	// test dry run
+	run(true)
+
+	jo, err := s.GetSubscriberByEmail("jo@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Jo", jo.Name)
+	assert.True(t, jo.Active)
+	assert.JSONEq(t, `{"city":"Skopje","plan":"free"}`, string(jo.MetaJSON))
+
+	// test bulk update
+	run(false)
+
+	jo, err = s.GetSubscriber(jo.ID, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Joanna", jo.Name)
+	assert.False(t, jo.Active)
+	assert.JSONEq(t, `{"city":"Ohrid"}`, string(jo.MetaJSON))
+	assert.Len(t, jo.Segments, 1)
+	assert.Equal(t, vip.ID, jo.Segments[0].ID)
+
+	ana, err := s.GetSubscriberByEmail("ana@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"age":"30"}`, string(ana.MetaJSON))
+}
@@ -0,0 +1,370 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// pauseDuration is how long the e-mails are paused from the preference center.
+const pauseDuration = 30 * 24 * time.Hour
+
+var (
+	errInvalidPreferencesToken = errors.New("invalid preferences token")
+	errInvalidMetadata         = errors.New("invalid metadata")
+)
+
+// preferenceSegment is a public segment as listed in the preference center.
+type preferenceSegment struct {
+	ID     int64
+	Name   string
+	Member bool
+}
+
+// preferenceField is a metadata field of the subscriber as listed in the preference center.
+type preferenceField struct {
+	Key   string
+	Value string
+}
+
+// GetPreferencesPage renders the preference center of the subscriber from the signed link.
+func GetPreferencesPage(c *gin.Context) {
+	email, uuid, t := c.Query("email"), c.Query("uuid"), c.Query("t")
+
+	u, sub, err := verifyPreferencesToken(c, email, uuid, t)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"email": email,
+			"uuid":  uuid,
+		}).WithError(err).Warn("Preferences: unable to verify the link.")
+		c.HTML(http.StatusNotFound, "preferences.html", gin.H{
+			"notFound": true,
+		})
+		return
+	}
+
+	public, err := storage.GetPublicSegments(c, u.ID)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("user_id", u.ID).Error("Preferences: unable to get public segments.")
+	}
+
+	member := make(map[int64]bool, len(sub.Segments))
+	for _, s := range sub.Segments {
+		member[s.ID] = true
+	}
+
+	segments := make([]preferenceSegment, 0, len(public))
+	for _, s := range public {
+		segments = append(segments, preferenceSegment{ID: s.ID, Name: s.Name, Member: member[s.ID]})
+	}
+
+	m, err := sub.GetMetadata()
+	if err != nil {
+		logger.From(c).WithError(err).WithField("subscriber_id", sub.ID).Error("Preferences: unable to get metadata.")
+	}
+
+	fields := make([]preferenceField, 0, len(m))
+	for k, v := range m {
+		fields = append(fields, preferenceField{Key: k, Value: v})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+
+	var pausedUntil string
+	if sub.IsPaused(time.Now()) {
+		pausedUntil = sub.PausedUntil.Format("January 2, 2006")
+	}
+
+	c.HTML(http.StatusOK, "preferences.html", gin.H{
+		"email":       email,
+		"uuid":        uuid,
+		"t":           t,
+		"failed":      c.Query("failed"),
+		"saved":       c.Query("saved"),
+		"name":        sub.Name,
+		"fields":      fields,
+		"segments":    segments,
+		"pausedUntil": pausedUntil,
+	})
+}
+
+// PostPreferences stores the preferences of the subscriber from the preference center: the
+// public segments the subscriber is in, the name and the metadata fields, and whether the
+// e-mails are paused. Every change is recorded as a subscriber event.
+func PostPreferences(c *gin.Context) {
+	body := &params.PostPreferences{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+	body.Metadata = c.PostFormMap("metadata")
+
+	q := url.Values{}
+	q.Add("email", body.Email)
+	q.Add("uuid", body.UUID)
+	q.Add("t", body.Token)
+	page := os.Getenv("APP_URL") + "/preferences.html?" + q.Encode()
+	redirWithError := page + "&failed=true"
+
+	if err := validator.Validate(body); err != nil {
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	log := logger.From(c).WithFields(logrus.Fields{
+		"email": body.Email,
+		"uuid":  body.UUID,
+	})
+
+	u, sub, err := verifyPreferencesToken(c, body.Email, body.UUID, body.Token)
+	if err != nil {
+		log.WithError(err).Warn("Preferences: unable to verify the token.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	if body.Unsubscribe {
+		err = storage.DeactivateSubscriber(c, u.ID, sub.Email)
+		if err != nil {
+			log.WithError(err).Warn("Preferences: unable to deactivate subscriber.")
+			c.Redirect(http.StatusSeeOther, redirWithError)
+			return
+		}
+
+		suppress(c, u.ID, sub.Email, entities.SuppressionReasonUnsubscribed, entities.SuppressionSourceUnsubscribe)
+
+		c.Redirect(http.StatusSeeOther, os.Getenv("APP_URL")+"/unsubscribe-success.html")
+		return
+	}
+
+	public, err := storage.GetPublicSegments(c, u.ID)
+	if err != nil {
+		log.WithError(err).Error("Preferences: unable to get public segments.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	fields, err := storage.GetCustomFields(c, u.ID)
+	if err != nil {
+		log.WithError(err).Error("Preferences: unable to get custom fields.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	events, err := applyPreferences(sub, body, public, fields, time.Now().UTC())
+	if errors.Is(err, errInvalidMetadata) {
+		log.WithError(err).Info("Preferences: the metadata doesn't match the custom fields.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Preferences: unable to apply preferences.")
+		c.Redirect(http.StatusSeeOther, redirWithError)
+		return
+	}
+
+	if len(events) > 0 {
+		err = storage.UpdateSubscriberPreferences(c, sub, events)
+		if err != nil {
+			log.WithError(err).Error("Preferences: unable to update subscriber.")
+			c.Redirect(http.StatusSeeOther, redirWithError)
+			return
+		}
+	}
+
+	c.Redirect(http.StatusSeeOther, page+"&saved=true")
+}
+
+// applyPreferences changes the subscriber by the submitted preferences and returns the
+// events of the changes. Only the public segments and the existing metadata fields can
+// be changed, an empty metadata value removes the field. The changed metadata is validated
+// by the custom fields, see entities.CustomFields.ApplyTo.
+func applyPreferences(
+	sub *entities.Subscriber,
+	body *params.PostPreferences,
+	public []entities.Segment,
+	fields entities.CustomFields,
+	now time.Time,
+) ([]entities.SubscriberEvent, error) {
+	var events []entities.SubscriberEvent
+
+	addEvent := func(t entities.EventType, data interface{}) error {
+		var raw entities.JSON
+		if data != nil {
+			b, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			raw = b
+		}
+		events = append(events, entities.SubscriberEvent{EventType: t, Data: raw})
+		return nil
+	}
+
+	selected := make(map[int64]bool, len(body.SegmentIDs))
+	for _, id := range body.SegmentIDs {
+		selected[id] = true
+	}
+	isPublic := make(map[int64]bool, len(public))
+	for _, s := range public {
+		isPublic[s.ID] = true
+	}
+
+	member := make(map[int64]bool, len(sub.Segments))
+	segments := make([]entities.Segment, 0, len(sub.Segments))
+	for _, s := range sub.Segments {
+		member[s.ID] = true
+		if isPublic[s.ID] && !selected[s.ID] {
+			err := addEvent(entities.SubscriberEventTypeSegmentLeave, map[string]interface{}{
+				"segment_id":   s.ID,
+				"segment_name": s.Name,
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segments = append(segments, s)
+	}
+	for _, s := range public {
+		if selected[s.ID] && !member[s.ID] {
+			err := addEvent(entities.SubscriberEventTypeSegmentJoin, map[string]interface{}{
+				"segment_id":   s.ID,
+				"segment_name": s.Name,
+			})
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, s)
+		}
+	}
+	sub.Segments = segments
+
+	type change struct {
+		Old string `json:"old"`
+		New string `json:"new"`
+	}
+	changes := make(map[string]change)
+
+	if body.Name != sub.Name {
+		changes["name"] = change{Old: sub.Name, New: body.Name}
+		sub.Name = body.Name
+	}
+
+	old, err := sub.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(old))
+	for k, v := range old {
+		m[k] = v
+	}
+	for k, v := range body.Metadata {
+		if _, ok := old[k]; !ok {
+			continue
+		}
+		if v == "" {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+	if errs := fields.ApplyTo(m); len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidMetadata, entities.DescribeMetadataErrors(errs))
+	}
+
+	metaChanges := make(map[string]change)
+	for k, v := range m {
+		if old[k] != v {
+			metaChanges[k] = change{Old: old[k], New: v}
+		}
+	}
+	for k, v := range old {
+		if _, ok := m[k]; !ok {
+			metaChanges[k] = change{Old: v, New: ""}
+		}
+	}
+	if len(metaChanges) > 0 {
+		sub.MetaJSON, err = json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changes) > 0 || len(metaChanges) > 0 {
+		data := map[string]interface{}{}
+		for k, v := range changes {
+			data[k] = v
+		}
+		if len(metaChanges) > 0 {
+			data["metadata"] = metaChanges
+		}
+		if err := addEvent(entities.SubscriberEventTypeUpdated, data); err != nil {
+			return nil, err
+		}
+	}
+
+	paused := sub.IsPaused(now)
+	switch {
+	case body.Pause && !paused:
+		until := now.Add(pauseDuration)
+		sub.PausedUntil = &until
+		err = addEvent(entities.SubscriberEventTypePaused, map[string]interface{}{
+			"paused_until": until,
+		})
+	case !body.Pause && paused:
+		sub.PausedUntil = nil
+		err = addEvent(entities.SubscriberEventTypeResumed, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// verifyPreferencesToken finds the subscriber of the preference center link and checks its token.
+func verifyPreferencesToken(c *gin.Context, email, uuid, token string) (*entities.User, *entities.Subscriber, error) {
+	u, err := storage.GetUserByUUID(c, uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := storage.GetSubscriberByEmail(c, email, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := s.GeneratePreferencesToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(hash)) != 1 {
+		return nil, nil, errInvalidPreferencesToken
+	}
+
+	// the segments are loaded for the preferences, the subscriber is looked up by email without them.
+	s, err = storage.GetSubscriber(c, s.ID, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u, s, nil
+}
@@ -0,0 +1,175 @@
+package actions_test
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestPreferences(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3mock.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	err = os.Setenv("UNSUBSCRIBE_SECRET", "secret")
+	if err != nil {
+		t.FailNow()
+	}
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+	u.UUID = "3b6f3c2e-8d4a-4f7e-9a1c-5e2d7b8c9f01"
+	err = s.UpdateUser(u)
+	if err != nil {
+		t.FailNow()
+	}
+
+	publicID := int64(auth.POST("/api/segments").WithForm(params.Segment{Name: "weekly", Public: true}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("public", true).
+		Value("id").Number().Raw())
+
+	private := &entities.Segment{Name: "customers", UserID: u.ID}
+	err = s.CreateSegment(private)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = s.CreateCustomField(&entities.CustomField{UserID: u.ID, Key: "age", Label: "Age", Type: entities.CustomFieldTypeNumber})
+	if err != nil {
+		t.FailNow()
+	}
+
+	sub := &entities.Subscriber{
+		Email:    "jane@example.com",
+		Name:     "Jane",
+		UserID:   u.ID,
+		Active:   true,
+		MetaJSON: []byte(`{"city":"Skopje","age":"30"}`),
+		Segments: []entities.Segment{*private},
+	}
+	err = s.CreateSubscriber(sub)
+	if err != nil {
+		t.FailNow()
+	}
+
+	token, err := sub.GeneratePreferencesToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		t.FailNow()
+	}
+
+	// test the unsubscribe token can't be used for the preferences
+	unsubToken, err := sub.GenerateUnsubscribeToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		t.FailNow()
+	}
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", unsubToken).
+		WithFormField("name", "Jo").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("failed=true")
+
+	// test joining a public segment, pausing and changing the fields
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", token).
+		WithFormField("name", "Jane Doe").
+		WithFormField("metadata[city]", "Ohrid").
+		WithFormField("metadata[plan]", "pro").
+		WithFormField("segments[]", publicID).
+		WithFormField("segments[]", private.ID).
+		WithFormField("pause", true).
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("saved=true")
+
+	obj := auth.GET("/api/subscribers/" + strconv.FormatInt(sub.ID, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	obj.ValueEqual("name", "Jane Doe").
+		ValueEqual("metadata", map[string]string{"city": "Ohrid", "age": "30"})
+	obj.Value("paused_until").NotNull()
+	obj.Value("segments").Array().Length().Equal(2)
+
+	// test leaving the public segment keeps the private one and resumes the e-mails
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", token).
+		WithFormField("name", "Jane Doe").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("saved=true")
+
+	obj = auth.GET("/api/subscribers/" + strconv.FormatInt(sub.ID, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	obj.Value("paused_until").Null()
+	obj.Value("segments").Array().Length().Equal(1)
+	obj.Value("segments").Array().First().Object().ValueEqual("id", private.ID)
+
+	// test the metadata is validated and normalized by the custom fields
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", token).
+		WithFormField("name", "Jane Doe").
+		WithFormField("metadata[age]", "abc").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("failed=true")
+
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", token).
+		WithFormField("name", "Jane Doe").
+		WithFormField("metadata[age]", " 31.0 ").
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Contains("saved=true")
+
+	auth.GET("/api/subscribers/"+strconv.FormatInt(sub.ID, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("metadata", map[string]string{"city": "Ohrid", "age": "31"})
+
+	// test unsubscribing from all
+	e.POST("/api/preferences").
+		WithFormField("email", sub.Email).
+		WithFormField("uuid", u.UUID).
+		WithFormField("t", token).
+		WithFormField("unsubscribe", true).
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").Equal(os.Getenv("APP_URL") + "/unsubscribe-success.html")
+
+	auth.GET("/api/subscribers/"+strconv.FormatInt(sub.ID, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("active", false)
+}
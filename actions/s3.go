@@ -6,14 +6,12 @@ import (
 	"os"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
 
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/logger"
 	"github.com/mailbadger/app/routes/middleware"
-	"github.com/mailbadger/app/s3"
 	"github.com/mailbadger/app/validator"
 )
 
@@ -33,27 +31,17 @@ func GetSignedURL(c *gin.Context) {
 		return
 	}
 
-	client, err := s3.NewS3Client(
-		os.Getenv("AWS_S3_ACCESS_KEY"),
-		os.Getenv("AWS_S3_SECRET_KEY"),
-		os.Getenv("AWS_S3_REGION"),
-	)
-	if err != nil {
-		logger.From(c).WithError(err).Error("Unable to create s3 client.")
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"message": "Unable to sign url.",
-		})
-		return
+	key := fmt.Sprintf("subscribers/%s/%d/%s", body.Action, u.ID, body.Filename)
+	switch body.Action {
+	case "import_template":
+		key = templateImportKey(u.ID, body.Filename)
+	case "import_suppressions":
+		key = suppressionsImportKey(u.ID, body.Filename)
 	}
-	req, _ := client.PutObjectRequest(&awss3.PutObjectInput{
-		Bucket:      aws.String(os.Getenv("FILES_BUCKET")),
-		Key:         aws.String(fmt.Sprintf("subscribers/%s/%d/%s", body.Action, u.ID, body.Filename)),
-		ContentType: aws.String(body.ContentType),
-	})
 
-	url, err := req.Presign(15 * time.Minute)
+	url, err := blobs.GetFromContext(c).SignPut(os.Getenv("FILES_BUCKET"), key, body.ContentType, 15*time.Minute)
 	if err != nil {
-		logger.From(c).WithError(err).Warn("Unable to sign s3 url.")
+		logger.From(c).WithError(err).Warn("Unable to sign url.")
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"message": "Unable to sign url.",
 		})
@@ -62,7 +50,7 @@ func GetSignedURL(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"url":    url,
-		"method": req.Operation.HTTPMethod,
+		"method": http.MethodPut,
 		"headers": map[string]string{
 			"content-type": body.ContentType,
 		},
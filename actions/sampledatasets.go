@@ -0,0 +1,264 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetSampleDataSets(c *gin.Context) {
+	template, ok := sampleDataTemplate(c)
+	if !ok {
+		return
+	}
+
+	sets, err := storage.GetSampleDataSets(c, template.ID, template.UserID)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("template_id", template.ID).Error("Unable to fetch sample data sets.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch sample data sets. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": sets,
+	})
+}
+
+func PostSampleDataSet(c *gin.Context) {
+	template, ok := sampleDataTemplate(c)
+	if !ok {
+		return
+	}
+
+	body := &params.SampleDataSet{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	body.Data = c.PostFormMap("data")
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	_, err := storage.GetSampleDataSetByName(c, body.Name, template.ID, template.UserID)
+	if err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Sample data set with that name already exists.",
+		})
+		return
+	}
+
+	s := &entities.SampleDataSet{
+		UserID:     template.UserID,
+		TemplateID: template.ID,
+		Name:       body.Name,
+		Data:       body.Data,
+	}
+
+	s.DataJSON, err = json.Marshal(body.Data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create sample data set, invalid data.",
+		})
+		return
+	}
+
+	if err := storage.CreateSampleDataSet(c, s); err != nil {
+		logger.From(c).WithError(err).WithField("template_id", template.ID).Warn("Unable to create sample data set.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create sample data set.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+func PutSampleDataSet(c *gin.Context) {
+	template, ok := sampleDataTemplate(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("set_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	s, err := storage.GetSampleDataSet(c, id, template.ID, template.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Sample data set not found.",
+		})
+		return
+	}
+
+	body := &params.SampleDataSet{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	body.Data = c.PostFormMap("data")
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	s2, err := storage.GetSampleDataSetByName(c, body.Name, template.ID, template.UserID)
+	if err == nil && s2.ID != s.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Sample data set with that name already exists.",
+		})
+		return
+	}
+
+	s.Name = body.Name
+	s.Data = body.Data
+	s.DataJSON, err = json.Marshal(body.Data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to update sample data set, invalid data.",
+		})
+		return
+	}
+
+	if err := storage.UpdateSampleDataSet(c, s); err != nil {
+		logger.From(c).WithError(err).WithField("sample_data_set_id", id).Warn("Unable to update sample data set.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to update sample data set.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func DeleteSampleDataSet(c *gin.Context) {
+	template, ok := sampleDataTemplate(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("set_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	_, err = storage.GetSampleDataSet(c, id, template.ID, template.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Sample data set not found.",
+		})
+		return
+	}
+
+	err = storage.DeleteSampleDataSet(c, id, template.ID, template.UserID)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("sample_data_set_id", id).Error("Unable to delete sample data set.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to delete sample data set.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetTemplatePreview renders the template with the data of the sample data set given
+// by the sample_data_set_id query param, or with no data when it is omitted.
+func GetTemplatePreview(c *gin.Context) {
+	template, ok := sampleDataTemplate(c)
+	if !ok {
+		return
+	}
+
+	data := make(map[string]string)
+	if setID := c.Query("sample_data_set_id"); setID != "" {
+		id, err := strconv.ParseInt(setID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Sample data set id must be an integer",
+			})
+			return
+		}
+
+		s, err := storage.GetSampleDataSet(c, id, template.ID, template.UserID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Sample data set not found.",
+			})
+			return
+		}
+
+		data, err = s.GetData()
+		if err != nil {
+			logger.From(c).WithError(err).WithField("sample_data_set_id", id).Error("Unable to get sample data.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to preview template, invalid sample data.",
+			})
+			return
+		}
+	}
+
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+	preview, err := service.PreviewTemplate(c, template.ID, template.UserID, data)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("template_id", template.ID).Error("Unable to preview template.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to preview template.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// sampleDataTemplate fetches the template the sample data sets belong to, it writes
+// the error response and returns false when the template can't be found.
+func sampleDataTemplate(c *gin.Context) (*entities.Template, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return nil, false
+	}
+
+	template, err := storage.GetTemplate(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Template not found.",
+		})
+		return nil, false
+	}
+
+	return template, true
+}
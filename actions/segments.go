@@ -1,10 +1,14 @@
 package actions
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
@@ -98,8 +102,18 @@ func PostSegment(c *gin.Context) {
 	}
 
 	l := &entities.Segment{
-		Name:   body.Name,
-		UserID: middleware.GetUser(c).ID,
+		Name:    body.Name,
+		Public:  body.Public,
+		Dynamic: body.Dynamic,
+		UserID:  middleware.GetUser(c).ID,
+	}
+
+	if l.Dynamic {
+		rules, ok := bindSegmentRules(c, l.UserID, body.Rules)
+		if !ok {
+			return
+		}
+		l.RulesJSON = rules
 	}
 
 	_, err := storage.GetSegmentByName(c, body.Name, middleware.GetUser(c).ID)
@@ -152,6 +166,15 @@ func PutSegment(c *gin.Context) {
 		}
 
 		l.Name = body.Name
+		l.Public = body.Public
+
+		if l.Dynamic && body.Rules != "" {
+			rules, ok := bindSegmentRules(c, l.UserID, body.Rules)
+			if !ok {
+				return
+			}
+			l.RulesJSON = rules
+		}
 
 		if err = storage.UpdateSegment(c, l); err != nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
@@ -172,7 +195,7 @@ func PutSegment(c *gin.Context) {
 func DeleteSegment(c *gin.Context) {
 	if id, err := strconv.ParseInt(c.Param("id"), 10, 64); err == nil {
 		user := middleware.GetUser(c)
-		_, err := storage.GetSegment(c, id, user.ID)
+		seg, err := storage.GetSegment(c, id, user.ID)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"message": "Segment not found",
@@ -180,6 +203,33 @@ func DeleteSegment(c *gin.Context) {
 			return
 		}
 
+		if !seg.Dynamic {
+			dynamic, err := storage.GetDynamicSegments(c, user.ID)
+			if err != nil {
+				logger.From(c).WithError(err).Error("Unable to fetch the dynamic segments.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to delete segment. Please try again.",
+				})
+				return
+			}
+
+			for i := range dynamic {
+				r, err := dynamic[i].GetRules()
+				if err != nil {
+					logger.From(c).WithError(err).WithField("segment_id", dynamic[i].ID).Warn("Unable to decode segment rules.")
+					continue
+				}
+				for _, segID := range r.SegmentIDs() {
+					if segID == id {
+						c.JSON(http.StatusUnprocessableEntity, gin.H{
+							"message": fmt.Sprintf("Unable to delete segment, it is used in the rules of the segment '%s'.", dynamic[i].Name),
+						})
+						return
+					}
+				}
+			}
+		}
+
 		err = storage.DeleteSegment(c, id, user.ID)
 		if err != nil {
 			logger.From(c).WithError(err).Error("Unable to delete segment.")
@@ -209,6 +259,13 @@ func PutSegmentSubscribers(c *gin.Context) {
 			return
 		}
 
+		if l.Dynamic {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "The subscribers of a dynamic segment are resolved by its rules.",
+			})
+			return
+		}
+
 		body := &params.SegmentSubs{}
 		if err := c.ShouldBind(body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -273,6 +330,12 @@ func GetSegmentsubscribers(c *gin.Context) {
 		}
 
 		err := storage.GetSubscribersBySegmentID(c, id, middleware.GetUser(c).ID, p)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Segment not found.",
+			})
+			return
+		}
 		if err != nil {
 			logger.From(c).WithError(err).Error("Unable to fetch subscribers for segment collection.")
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -301,6 +364,13 @@ func DetachSegmentSubscribers(c *gin.Context) {
 			return
 		}
 
+		if l.Dynamic {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "The subscribers of a dynamic segment are resolved by its rules.",
+			})
+			return
+		}
+
 		body := &params.SegmentSubs{}
 		if err := c.ShouldBind(body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -368,6 +438,13 @@ func DetachSubscriber(c *gin.Context) {
 		return
 	}
 
+	if l.Dynamic {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "The subscribers of a dynamic segment are resolved by its rules.",
+		})
+		return
+	}
+
 	s, err := storage.GetSubscriber(c, subID, user.ID)
 	if err != nil {
 		logger.From(c).WithFields(logrus.Fields{"subscriber_id": subID, "segment_id": id}).WithError(err).
@@ -391,3 +468,143 @@ func DetachSubscriber(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// PreviewSegment counts the subscribers who match the rules and returns a sample of them.
+func PreviewSegment(c *gin.Context) {
+	body := &params.SegmentRules{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	userID := middleware.GetUser(c).ID
+	rulesJSON, ok := bindSegmentRules(c, userID, body.Rules)
+	if !ok {
+		return
+	}
+
+	seg := &entities.Segment{Dynamic: true, RulesJSON: rulesJSON}
+	r, err := seg.GetRules()
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to decode segment rules.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to preview the segment. Please try again.",
+		})
+		return
+	}
+
+	total, err := storage.GetTotalSubscribersByRules(c, userID, r)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to count subscribers by segment rules.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to preview the segment. Please try again.",
+		})
+		return
+	}
+
+	subs, err := storage.GetSubscribersByRules(c, userID, r, segmentPreviewSize)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch subscribers by segment rules.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to preview the segment. Please try again.",
+		})
+		return
+	}
+	if subs == nil {
+		subs = []entities.Subscriber{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       total,
+		"subscribers": subs,
+	})
+}
+
+// segmentPreviewSize is the number of subscribers returned by the segment preview.
+const segmentPreviewSize = 10
+
+// bindSegmentRules decodes and validates the rule tree of a dynamic segment. The segment conditions
+// may only refer to the static segments of the user, and the metadata conditions are checked
+// against the custom fields of the user. It writes the error response and returns false
+// when the rules are invalid.
+func bindSegmentRules(c *gin.Context, userID int64, raw string) (entities.JSON, bool) {
+	invalid := func(msg string) (entities.JSON, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+			"errors": map[string]string{
+				"rules": msg,
+			},
+		})
+		return nil, false
+	}
+
+	r := new(entities.SegmentRule)
+	if err := json.Unmarshal([]byte(raw), r); err != nil {
+		return invalid("The rules must be a valid JSON object.")
+	}
+	if err := r.Validate(); err != nil {
+		return invalid(err.Error())
+	}
+
+	fields, err := storage.GetCustomFields(c, userID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch the custom fields of the rules.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to validate the rules. Please try again.",
+		})
+		return nil, false
+	}
+	if err := r.ApplyCustomFields(fields); err != nil {
+		return invalid(err.Error())
+	}
+
+	if ids := r.SegmentIDs(); len(ids) > 0 {
+		segs, err := storage.GetSegmentsByIDs(c, userID, ids)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch the segments of the rules.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to validate the rules. Please try again.",
+			})
+			return nil, false
+		}
+
+		found := make(map[int64]bool, len(segs))
+		for _, seg := range segs {
+			found[seg.ID] = !seg.Dynamic
+		}
+		for _, id := range ids {
+			if !found[id] {
+				return invalid(fmt.Sprintf("The segment %d does not exist or is dynamic.", id))
+			}
+		}
+	}
+
+	rulesJSON, err := json.Marshal(r)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to encode segment rules.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to validate the rules. Please try again.",
+		})
+		return nil, false
+	}
+
+	return rulesJSON, true
+}
+
+// hasDynamicSegment checks whether any of the segments is dynamic, the subscribers can't be
+// added to the dynamic segments directly.
+func hasDynamicSegment(segs []entities.Segment) bool {
+	for _, seg := range segs {
+		if seg.Dynamic {
+			return true
+		}
+	}
+	return false
+}
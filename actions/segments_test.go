@@ -2,8 +2,10 @@ package actions_test
 
 import (
 	"net/http"
+	"strconv"
 	"testing"
 
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/storage"
 	"github.com/mailbadger/app/storage/s3"
@@ -54,3 +56,124 @@ func TestSegments(t *testing.T) {
 		Expect().
 		Status(http.StatusNoContent)
 }
+
+func TestDynamicSegments(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	static := &entities.Segment{Name: "customers", UserID: u.ID}
+	err = s.CreateSegment(static)
+	if err != nil {
+		t.FailNow()
+	}
+
+	for i, email := range []string{"jane@example.com", "john@example.com", "bob@example.org"} {
+		sub := &entities.Subscriber{Email: email, UserID: u.ID, Active: true}
+		if i == 0 {
+			sub.Segments = []entities.Segment{*static}
+		}
+		err = s.CreateSubscriber(sub)
+		if err != nil {
+			t.FailNow()
+		}
+	}
+
+	auth.POST("/api/segments").WithForm(params.Segment{Name: "dyn", Dynamic: true}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{"rules": "This field is required"})
+
+	auth.POST("/api/segments").WithForm(params.Segment{Name: "dyn", Dynamic: true, Rules: `{"field":"password"}`}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		Value("errors").Object().ContainsKey("rules")
+
+	// test the rules can't refer to missing segments
+	auth.POST("/api/segments").WithForm(params.Segment{
+		Name:    "dyn",
+		Dynamic: true,
+		Rules:   `{"field":"segment","operator":"in","segment_id":999}`,
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		Value("errors").Object().ContainsKey("rules")
+
+	rules := `{"combinator":"or","rules":[` +
+		`{"field":"email","operator":"ends_with","value":"@example.org"},` +
+		`{"field":"segment","operator":"in","segment_id":` + strconv.FormatInt(static.ID, 10) + `}]}`
+
+	auth.POST("/api/segments/preview").WithFormField("rules", rules).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 2).
+		Value("subscribers").Array().Length().Equal(2)
+
+	dynID := int64(auth.POST("/api/segments").WithForm(params.Segment{Name: "dyn", Dynamic: true, Rules: rules}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("dynamic", true).
+		Value("id").Number().Raw())
+	path := "/api/segments/" + strconv.FormatInt(dynID, 10)
+
+	auth.GET(path).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("subscribers_in_segment", 2).
+		ValueEqual("total_subscribers", 3)
+
+	auth.GET(path + "/subscribers").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(2)
+
+	// test the segment can't be deleted while the rules refer to it
+	auth.DELETE("/api/segments/"+strconv.FormatInt(static.ID, 10)).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "Unable to delete segment, it is used in the rules of the segment 'dyn'.")
+
+	// test the subscribers of a missing segment
+	auth.GET("/api/segments/999/subscribers").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test the dynamic segments can't be referred to by the rules
+	auth.POST("/api/segments").WithForm(params.Segment{
+		Name:    "nested",
+		Dynamic: true,
+		Rules:   `{"field":"segment","operator":"in","segment_id":` + strconv.FormatInt(dynID, 10) + `}`,
+	}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// test the subscribers can't be added to a dynamic segment
+	auth.PUT(path+"/subscribers").WithFormField("ids[]", 1).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	// test updating the rules
+	auth.PUT(path).WithForm(params.Segment{Name: "dyn", Rules: `{"field":"email","operator":"contains","value":"jane"}`}).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET(path).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("subscribers_in_segment", 1)
+
+	// test the segment can be deleted once the rules don't refer to it
+	auth.DELETE("/api/segments/" + strconv.FormatInt(static.ID, 10)).
+		Expect().
+		Status(http.StatusNoContent)
+}
@@ -1,10 +1,14 @@
 package actions_test
 
 import (
+	"context"
 	"database/sql"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
@@ -14,11 +18,14 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/queue"
 	"github.com/mailbadger/app/routes"
 	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/emailvalidator"
 	"github.com/mailbadger/app/storage"
 	"github.com/mailbadger/app/storage/s3"
 )
@@ -28,6 +35,61 @@ func init() {
 }
 
 func setup(t *testing.T, s storage.Storage, s3Mock *s3.MockS3Client) *httpexpect.Expect {
+	return setupWithBlobs(t, s, blobs.NewS3(s3Mock))
+}
+
+func setupWithBlobs(t *testing.T, s storage.Storage, store blobs.Store) *httpexpect.Expect {
+	return setupWithProducer(t, s, store, new(testProducer))
+}
+
+// testProducer records the published messages instead of publishing them.
+type testProducer struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+}
+
+func (p *testProducer) Publish(topic string, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.messages == nil {
+		p.messages = make(map[string][][]byte)
+	}
+	p.messages[topic] = append(p.messages[topic], body)
+	return nil
+}
+
+func (p *testProducer) Stop() {}
+
+// Messages returns the messages published to the topic.
+func (p *testProducer) Messages(topic string) [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.messages[topic]
+}
+
+// testResolver resolves all of the domains except the ones of the reserved .invalid top level domain.
+type testResolver struct{}
+
+func (testResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if strings.HasSuffix(name, ".invalid") {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return []*net.MX{{Host: "mx." + name + ".", Pref: 10}}, nil
+}
+
+func (testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if strings.HasSuffix(host, ".invalid") {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []string{"127.0.0.1"}, nil
+}
+
+// newTestEmailValidator creates a validator with the default policy which doesn't use the DNS.
+func newTestEmailValidator() emailvalidator.Validator {
+	return emailvalidator.New(testResolver{}, emailvalidator.DefaultPolicy())
+}
+
+func setupWithProducer(t *testing.T, s storage.Storage, store blobs.Store, p queue.Producer) *httpexpect.Expect {
 	err := os.Setenv("SESSION_AUTH_KEY", "foo")
 	if err != nil {
 		t.FailNow()
@@ -51,7 +113,12 @@ func setup(t *testing.T, s storage.Storage, s3Mock *s3.MockS3Client) *httpexpect
 	handler.Use(sessions.Sessions("mbsess", cookiestore))
 	handler.Use(middleware.Storage(s))
 	handler.Use(middleware.SetUser())
-	handler.Use(middleware.S3Client(s3Mock))
+	handler.Use(middleware.Blobs(store))
+	handler.Use(middleware.EmailValidator(newTestEmailValidator()))
+	handler.Use(func(c *gin.Context) {
+		queue.SetToContext(c, p)
+		c.Next()
+	})
 
 	routes.SetGuestRoutes(handler)
 
@@ -65,6 +132,10 @@ func setup(t *testing.T, s storage.Storage, s3Mock *s3.MockS3Client) *httpexpect
 		Client: &http.Client{
 			Transport: httpexpect.NewBinder(handler),
 			Jar:       httpexpect.NewJar(),
+			// the redirects are asserted by the tests instead of followed.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
 		Reporter: httpexpect.NewAssertReporter(t),
 		Printers: []httpexpect.Printer{
@@ -0,0 +1,250 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetSnippets(c *gin.Context) {
+	val, ok := c.Get("cursor")
+	if !ok {
+		logger.From(c).Error("Unable to fetch pagination cursor from context.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch snippets. Please try again.",
+		})
+		return
+	}
+
+	p, ok := val.(*storage.PaginationCursor)
+	if !ok {
+		logger.From(c).Error("Unable to cast pagination cursor from context value.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch snippets. Please try again.",
+		})
+		return
+	}
+
+	scopeMap := c.QueryMap("scopes")
+
+	err := storage.GetSnippets(c, middleware.GetUser(c).ID, p, scopeMap)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch snippets collection.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch snippets. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func GetSnippet(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	s, err := storage.GetSnippet(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Snippet not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func PostSnippet(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.Snippet{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	_, err := storage.GetSnippetByName(c, body.Name, u.ID)
+	if err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Snippet with that name already exists.",
+		})
+		return
+	}
+
+	s := &entities.Snippet{
+		UserID: u.ID,
+		Name:   body.Name,
+		Body:   body.Body,
+	}
+
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+	if ok := validateSnippet(c, service, s); !ok {
+		return
+	}
+
+	if err := storage.CreateSnippet(c, s); err != nil {
+		logger.From(c).WithError(err).Warn("Unable to create snippet.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create snippet.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+func PutSnippet(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	s, err := storage.GetSnippet(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Snippet not found.",
+		})
+		return
+	}
+
+	body := &params.Snippet{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	s2, err := storage.GetSnippetByName(c, body.Name, u.ID)
+	if err == nil && s2.ID != s.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Snippet with that name already exists.",
+		})
+		return
+	}
+
+	s.Name = body.Name
+	s.Body = body.Body
+
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+	if ok := validateSnippet(c, service, s); !ok {
+		return
+	}
+
+	if err := storage.UpdateSnippet(c, s); err != nil {
+		logger.From(c).WithError(err).WithField("snippet_id", id).Warn("Unable to update snippet.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to update snippet.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func DeleteSnippet(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	s, err := storage.GetSnippet(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Snippet not found.",
+		})
+		return
+	}
+
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+	err = service.DeleteSnippet(c, s)
+	if err != nil {
+		if errors.Is(err, templatesvc.ErrSnippetInUse) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete snippet, it is included in other templates or snippets.",
+			})
+			return
+		}
+
+		logger.From(c).WithError(err).WithField("snippet_id", id).Error("Unable to delete snippet.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to delete snippet.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// validateSnippet writes the error response and returns false when the snippet
+// doesn't parse or includes partials which are missing or include the snippet back.
+func validateSnippet(c *gin.Context, service templatesvc.Service, s *entities.Snippet) bool {
+	err := service.ValidateSnippet(c, s)
+	if err == nil {
+		return true
+	}
+
+	switch {
+	case errors.Is(err, templatesvc.ErrParseSnippet):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to save snippet, failed to parse body",
+		})
+	case errors.Is(err, templatesvc.ErrPartialNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to save snippet, one of the included partials does not exist",
+		})
+	case errors.Is(err, templatesvc.ErrPartialCycle):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to save snippet, the included partials include each other",
+		})
+	default:
+		logger.From(c).WithFields(logrus.Fields{
+			"snippet": s.Name,
+			"user_id": s.UserID,
+		}).WithError(err).Error("Unable to validate snippet")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to save snippet, please try again.",
+		})
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestSnippets(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3mock.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test post snippet unauthorized
+	e.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>footer</p>"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test binding on post snippet
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "foo bar", Body: ""}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again").
+		ValueEqual("errors", map[string]string{
+			"name": "Must consist only of alphanumeric and hyphen characters",
+			"body": "This field is required",
+		})
+
+	// test failed to parse snippet body
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>{{{company}}</p>"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to save snippet, failed to parse body")
+
+	// test missing partial
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>{{> address}}</p>"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to save snippet, one of the included partials does not exist")
+
+	// test snippet which includes itself
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>{{#company}}{{> footer}}{{/company}}</p>"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to save snippet, the included partials include each other")
+
+	// test post snippet
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "address", Body: "<p>{{street}}</p>"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	id := auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>{{company}}</p>{{> address}}"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("name", "footer").
+		Value("id").Raw()
+
+	idStr := strconv.FormatFloat(id.(float64), 'f', 0, 64)
+
+	// test post snippet with name that exists
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: "<p>footer</p>"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Snippet with that name already exists.")
+
+	// test get snippet
+	auth.GET("/api/snippets/"+idStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("body", "<p>{{company}}</p>{{> address}}")
+
+	auth.GET("/api/snippets/1000").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test get snippets
+	auth.GET("/api/snippets").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 2)
+
+	// test delete snippet which is included in another snippet
+	auth.DELETE("/api/snippets/1").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Unable to delete snippet, it is included in other templates or snippets.")
+
+	// test put snippet which creates a cycle through another snippet
+	auth.PUT("/api/snippets/1").WithForm(params.Snippet{Name: "address", Body: "<p>{{> footer}}</p>"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to save snippet, the included partials include each other")
+
+	// test put snippet
+	auth.PUT("/api/snippets/"+idStr).WithForm(params.Snippet{Name: "footer", Body: "<p>{{company}} Inc.</p>"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("body", "<p>{{company}} Inc.</p>")
+
+	// test post template which includes a missing partial
+	auth.POST("/api/templates").WithForm(params.PostTemplate{
+		Name:        "template",
+		HTMLPart:    "<span>hello</span>{{> signature}}",
+		TextPart:    "hello",
+		SubjectPart: "hello",
+	}).Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to create template, one of the included partials does not exist")
+
+	// test delete snippet
+	auth.DELETE("/api/snippets/" + idStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.DELETE("/api/snippets/" + idStr).
+		Expect().
+		Status(http.StatusNotFound)
+}
@@ -0,0 +1,253 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/queue"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// PostSubscriberBulkAction queues an action which is applied to all of the subscribers who match
+// the filter, the filter takes the same criteria as the subscribers listing. The bulk action is
+// applied in the background, its status and progress can be polled by its id.
+func PostSubscriberBulkAction(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.PostSubscriberBulkAction{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+	body.Metadata = c.PostFormMap("metadata")
+	body.MetadataContains = c.PostFormMap("metadata_contains")
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	switch body.Action {
+	case entities.BulkActionAddToSegment, entities.BulkActionRemoveFromSegment:
+		if body.SegmentID == 0 {
+			bulkActionParamRequired(c, "segment_id")
+			return
+		}
+		segs, err := storage.GetSegmentsByIDs(c, u.ID, []int64{body.SegmentID})
+		if err != nil || len(segs) == 0 || hasDynamicSegment(segs) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"segment_id": "Unable to find the specified segment.",
+				},
+			})
+			return
+		}
+	case entities.BulkActionSetMetadata:
+		if body.MetadataKey == "" {
+			bulkActionParamRequired(c, "metadata_key")
+			return
+		}
+		if ok := applyBulkActionCustomField(c, u.ID, body); !ok {
+			return
+		}
+	}
+
+	filter := subscriberFilter(&body.GetSubscribers)
+	if body.Action == entities.BulkActionDelete && filter.IsEmpty() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"filter": "Filter the subscribers to delete them in bulk.",
+			},
+		})
+		return
+	}
+
+	total, err := storage.GetTotalSubscribersByFilter(c, u.ID, filter)
+	if err != nil {
+		logger.From(c).WithError(err).Error("bulk action: unable to count the subscribers")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to apply the action. Please try again.",
+		})
+		return
+	}
+
+	a := &entities.SubscriberBulkAction{
+		UserID:        u.ID,
+		Action:        body.Action,
+		MetadataKey:   body.MetadataKey,
+		MetadataValue: body.MetadataValue,
+		Status:        entities.StatusPending,
+		Total:         total,
+	}
+	if body.Action == entities.BulkActionAddToSegment || body.Action == entities.BulkActionRemoveFromSegment {
+		a.SegmentID = body.SegmentID
+	}
+	a.FilterJSON, err = json.Marshal(filter)
+	if err != nil {
+		logger.From(c).WithError(err).Error("bulk action: unable to marshal the filter")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to apply the action. Please try again.",
+		})
+		return
+	}
+
+	err = storage.CreateSubscriberBulkAction(c, a)
+	if err != nil {
+		logger.From(c).WithError(err).Error("bulk action: unable to create the bulk action")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to apply the action. Please try again.",
+		})
+		return
+	}
+
+	msg, err := json.Marshal(entities.SubscriberBulkActionTopicParams{
+		BulkActionID: a.ID,
+		UserID:       u.ID,
+	})
+	if err == nil {
+		err = queue.Publish(c, entities.SubscriberBulkActionTopic, msg)
+	}
+	if err != nil {
+		logger.From(c).WithField("bulk_action_id", a.ID).WithError(err).Error("Unable to queue the subscribers bulk action.")
+
+		a.Status = entities.StatusFailed
+		a.Error = "Unable to queue the bulk action."
+		a.CompletedAt.SetValid(time.Now().UTC())
+		if _, err := storage.UpdateSubscriberBulkAction(c, a, entities.StatusPending); err != nil {
+			logger.From(c).WithField("bulk_action_id", a.ID).WithError(err).Error("Unable to update the subscribers bulk action.")
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to apply the action. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, a)
+}
+
+// GetSubscriberBulkAction returns the bulk action, so its status and progress can be polled.
+func GetSubscriberBulkAction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	a, err := storage.GetSubscriberBulkAction(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Bulk action not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, a)
+}
+
+// CancelSubscriberBulkAction cancels the pending or running bulk action, a running bulk action stops
+// after the batch it's processing, and the changes which were made until then are kept.
+func CancelSubscriberBulkAction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	ok, err := storage.CancelSubscriberBulkAction(c, id, u.ID)
+	if err != nil {
+		logger.From(c).WithField("bulk_action_id", id).WithError(err).Error("Unable to cancel the subscribers bulk action.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to cancel the bulk action. Please try again.",
+		})
+		return
+	}
+
+	a, err := storage.GetSubscriberBulkAction(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Bulk action not found.",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "The bulk action is already finished.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, a)
+}
+
+// bulkActionParamRequired writes the error response of the param which is required by the action.
+func bulkActionParamRequired(c *gin.Context, param string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "Invalid parameters, please try again",
+		"errors": map[string]string{
+			param: "This field is required",
+		},
+	})
+}
+
+// applyBulkActionCustomField validates the metadata value of the bulk action by the custom field of
+// the key, when it's defined, and normalizes the value. The value may be empty unless the field is
+// required and has no default value. The values of the keys which are not defined are set as they
+// are, see entities.CustomFields.ApplyTo. It writes the error response and returns false when the
+// value is invalid.
+func applyBulkActionCustomField(c *gin.Context, userID int64, body *params.PostSubscriberBulkAction) bool {
+	fields, err := storage.GetCustomFields(c, userID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch custom fields.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to apply the action. Please try again.",
+		})
+		return false
+	}
+	f := fields.Find(body.MetadataKey)
+	if f == nil {
+		return true
+	}
+
+	invalid := func(param, msg string) bool {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				param: msg,
+			},
+		})
+		return false
+	}
+	if body.MetadataValue == "" {
+		if f.Required && f.DefaultValue == "" {
+			return invalid("metadata_value", "This field is required")
+		}
+		return true
+	}
+
+	v, err := f.NormalizeValue(body.MetadataValue)
+	if err != nil {
+		return invalid("metadata_value", err.Error())
+	}
+	body.MetadataValue = v
+	return true
+}
@@ -0,0 +1,325 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/storage"
+)
+
+func TestSubscriberBulkActions(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, nil, producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	vip := &entities.Segment{Name: "VIP", UserID: u.ID}
+	dyn := &entities.Segment{Name: "Dynamic", UserID: u.ID, Dynamic: true}
+	for _, seg := range []*entities.Segment{vip, dyn} {
+		if err := s.CreateSegment(seg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []*entities.CustomField{
+		{UserID: u.ID, Key: "city", Label: "City", Type: entities.CustomFieldTypeString},
+		{UserID: u.ID, Key: "age", Label: "Age", Type: entities.CustomFieldTypeNumber},
+	} {
+		if err := s.CreateCustomField(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sub := range []*entities.Subscriber{
+		{Email: "a@example.com", Name: "A", UserID: u.ID, Active: true, MetaJSON: []byte(`{"city":"Skopje"}`)},
+		{Email: "b@example.com", Name: "B", UserID: u.ID, Active: true, MetaJSON: []byte(`{"city":"Skopje"}`)},
+		{Email: "c@example.com", Name: "C", UserID: u.ID, Active: false, MetaJSON: []byte(`{"city":"Ohrid"}`)},
+		{Email: "d@example.com", Name: "D", UserID: u.ID, Active: true, MetaJSON: []byte(`{"city":"Skopje","age":"old"}`)},
+	} {
+		if err := s.CreateSubscriber(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vipID := strconv.FormatInt(vip.ID, 10)
+
+	// test invalid params
+	auth.POST("/api/subscribers/bulk-action").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"action": "This field is required"})
+
+	auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", "archive").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"action": "Must be one of: add_to_segment remove_from_segment set_metadata deactivate blacklist delete"})
+
+	auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", entities.BulkActionAddToSegment).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"segment_id": "This field is required"})
+
+	auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", entities.BulkActionAddToSegment).
+		WithFormField("segment_id", dyn.ID).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"segment_id": "Unable to find the specified segment."})
+
+	auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", entities.BulkActionSetMetadata).
+		WithFormField("metadata_key", "age").
+		WithFormField("metadata_value", "old").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"metadata_value": "Must be a number"})
+
+	auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", entities.BulkActionDelete).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{"filter": "Filter the subscribers to delete them in bulk."})
+
+	svc := subscribers.New(nil, s, subscribers.EmailValidator(newTestEmailValidator()))
+
+	// run queues the bulk action, applies it like the bulk actions consumer, and returns its id.
+	run := func(form map[string]interface{}, total int) string {
+		req := auth.POST("/api/subscribers/bulk-action")
+		for k, v := range form {
+			req = req.WithFormField(k, v)
+		}
+		id := req.Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			ValueEqual("status", entities.StatusPending).
+			ValueEqual("total", total).
+			Value("id").Number().Raw()
+
+		messages := producer.Messages(entities.SubscriberBulkActionTopic)
+		msg := new(entities.SubscriberBulkActionTopicParams)
+		assert.Nil(t, json.Unmarshal(messages[len(messages)-1], msg))
+		assert.Equal(t, int64(id), msg.BulkActionID)
+		assert.Equal(t, u.ID, msg.UserID)
+
+		a, err := s.GetSubscriberBulkAction(msg.BulkActionID, msg.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, svc.RunBulkAction(context.Background(), a))
+
+		// the bulk action which is not pending is not run again
+		assert.Nil(t, svc.RunBulkAction(context.Background(), a))
+
+		return strconv.FormatInt(int64(id), 10)
+	}
+
+	// test add to segment
+	id := run(map[string]interface{}{
+		"action":         entities.BulkActionAddToSegment,
+		"segment_id":     vipID,
+		"metadata[city]": "Skopje",
+	}, 3)
+	obj := auth.GET("/api/subscribers/bulk-actions/" + id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	obj.ValueEqual("action", entities.BulkActionAddToSegment)
+	obj.ValueEqual("segment_id", vip.ID)
+	obj.ValueEqual("status", entities.StatusDone)
+	obj.ValueEqual("processed", 3)
+	obj.ValueEqual("affected", 3)
+	obj.ValueEqual("failed", 0)
+	obj.Value("filter").Object().ValueEqual("metadata", []map[string]string{
+		{"key": "city", "operator": "equals", "value": "Skopje"},
+	})
+	obj.Value("started_at").String().NotEmpty()
+	obj.Value("completed_at").String().NotEmpty()
+
+	total, err := s.GetTotalSubscribersBySegment(vip.ID, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+
+	// test set metadata, the metadata of d doesn't match the custom fields
+	id = run(map[string]interface{}{
+		"action":         entities.BulkActionSetMetadata,
+		"metadata_key":   "city",
+		"metadata_value": "Bitola",
+		"active":         "true",
+	}, 3)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusDone).
+		ValueEqual("processed", 3).
+		ValueEqual("affected", 2).
+		ValueEqual("failed", 1)
+
+	a, err := s.GetSubscriberByEmail("a@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"city":"Bitola"}`, string(a.MetaJSON))
+
+	d, err := s.GetSubscriberByEmail("d@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"city":"Skopje","age":"old"}`, string(d.MetaJSON))
+
+	// test deactivate
+	id = run(map[string]interface{}{
+		"action":         entities.BulkActionDeactivate,
+		"segments[]":     vipID,
+		"metadata[city]": "Bitola",
+	}, 2)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("affected", 2)
+
+	a, err = s.GetSubscriberByEmail("a@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.False(t, a.Active)
+
+	// test remove from segment, c is not in the segment
+	id = run(map[string]interface{}{
+		"action":     entities.BulkActionRemoveFromSegment,
+		"segment_id": vipID,
+		"active":     "false",
+	}, 3)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("processed", 3).
+		ValueEqual("affected", 2)
+
+	total, err = s.GetTotalSubscribersBySegment(vip.ID, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+
+	// test set metadata of the key which is not a custom field
+	id = run(map[string]interface{}{
+		"action":         entities.BulkActionSetMetadata,
+		"metadata_key":   "notes",
+		"metadata_value": " vip ",
+		"email":          "b@",
+	}, 1)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("metadata_value", "vip").
+		ValueEqual("affected", 1)
+
+	b, err := s.GetSubscriberByEmail("b@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"city":"Bitola","notes":"vip"}`, string(b.MetaJSON))
+
+	// test delete
+	id = run(map[string]interface{}{
+		"action": entities.BulkActionDelete,
+		"email":  "a@",
+	}, 1)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("affected", 1)
+
+	_, err = s.GetSubscriberByEmail("a@example.com", u.ID)
+	assert.NotNil(t, err)
+
+	// test blacklist filtered by a dynamic segment, its members are matched by its rules
+	ohrid := &entities.Segment{
+		Name:      "Ohrid",
+		UserID:    u.ID,
+		Dynamic:   true,
+		RulesJSON: entities.JSON(`{"field":"metadata","operator":"equals","key":"city","value":"Ohrid"}`),
+	}
+	if err := s.CreateSegment(ohrid); err != nil {
+		t.Fatal(err)
+	}
+	id = run(map[string]interface{}{
+		"action":     entities.BulkActionBlacklist,
+		"segments[]": strconv.FormatInt(ohrid.ID, 10),
+	}, 1)
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("processed", 1).
+		ValueEqual("affected", 1)
+
+	c, err := s.GetSubscriberByEmail("c@example.com", u.ID)
+	assert.Nil(t, err)
+	assert.True(t, c.Blacklisted)
+
+	auth.GET("/api/subscribers/bulk-actions/999").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// test cancel, the metadata value is normalized by the custom field
+	obj = auth.POST("/api/subscribers/bulk-action").
+		WithFormField("action", entities.BulkActionSetMetadata).
+		WithFormField("metadata_key", "age").
+		WithFormField("metadata_value", " 42.0 ").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	obj.ValueEqual("metadata_value", "42")
+	obj.ValueEqual("total", 3)
+	id = strconv.FormatInt(int64(obj.Value("id").Number().Raw()), 10)
+
+	auth.POST("/api/subscribers/bulk-actions/"+id+"/cancel").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusCancelled).
+		Value("completed_at").String().NotEmpty()
+
+	auth.POST("/api/subscribers/bulk-actions/"+id+"/cancel").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "The bulk action is already finished.")
+
+	auth.POST("/api/subscribers/bulk-actions/999/cancel").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// the cancelled bulk action is not run
+	cancelled, err := s.GetSubscriberBulkAction(int64(obj.Value("id").Number().Raw()), u.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, svc.RunBulkAction(context.Background(), cancelled))
+
+	auth.GET("/api/subscribers/bulk-actions/"+id).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("status", entities.StatusCancelled).
+		ValueEqual("processed", 0)
+}
@@ -12,30 +12,32 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/queue"
 	"github.com/mailbadger/app/routes/middleware"
 	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/emailvalidator"
 	"github.com/mailbadger/app/services/exporters"
 	"github.com/mailbadger/app/services/reports"
 	"github.com/mailbadger/app/services/subscribers"
+	templatesvc "github.com/mailbadger/app/services/templates"
 	"github.com/mailbadger/app/storage"
-	s3storage "github.com/mailbadger/app/storage/s3"
+	"github.com/mailbadger/app/templates"
 	"github.com/mailbadger/app/utils"
 	"github.com/mailbadger/app/validator"
 )
 
-const resource = "subscribers"
-
 var (
 	note = "Started the export process."
 )
@@ -59,8 +61,27 @@ func GetSubscribers(c *gin.Context) {
 		return
 	}
 
-	scopeMap := c.QueryMap("scopes")
-	err := storage.GetSubscribers(c, middleware.GetUser(c).ID, p, scopeMap)
+	query := &params.GetSubscribers{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+	query.Metadata = c.QueryMap("metadata")
+	query.MetadataContains = c.QueryMap("metadata_contains")
+
+	// the email scope is kept for the clients which search by it.
+	if email, ok := c.QueryMap("scopes")["email"]; ok && query.Email == "" {
+		query.Email = email
+	}
+
+	if err := validator.Validate(query); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	err := storage.GetSubscribers(c, middleware.GetUser(c).ID, p, subscriberFilter(query))
 	if err != nil {
 		logger.From(c).WithError(err).Error("Unable to fetch subscribers collection.")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -72,6 +93,47 @@ func GetSubscribers(c *gin.Context) {
 	c.JSON(http.StatusOK, p)
 }
 
+// subscriberFilter creates the filter of the subscribers from the query params.
+func subscriberFilter(query *params.GetSubscribers) *entities.SubscriberFilter {
+	f := &entities.SubscriberFilter{
+		Email:         query.Email,
+		Name:          query.Name,
+		Active:        query.Active,
+		Blacklisted:   query.Blacklisted,
+		SegmentIDs:    query.SegmentIDs,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+
+		MinEngagementScore: query.MinEngagementScore,
+		MaxEngagementScore: query.MaxEngagementScore,
+		LastEngagedAfter:   query.LastEngagedAfter,
+		LastEngagedBefore:  query.LastEngagedBefore,
+	}
+
+	for k, v := range query.Metadata {
+		f.Metadata = append(f.Metadata, entities.MetadataCondition{
+			Key:      k,
+			Operator: entities.MetadataOperatorEquals,
+			Value:    v,
+		})
+	}
+	for k, v := range query.MetadataContains {
+		f.Metadata = append(f.Metadata, entities.MetadataCondition{
+			Key:      k,
+			Operator: entities.MetadataOperatorContains,
+			Value:    v,
+		})
+	}
+	for _, k := range query.MetadataExists {
+		f.Metadata = append(f.Metadata, entities.MetadataCondition{
+			Key:      k,
+			Operator: entities.MetadataOperatorExists,
+		})
+	}
+
+	return f
+}
+
 func GetSubscriber(c *gin.Context) {
 	if id, err := strconv.ParseInt(c.Param("id"), 10, 64); err == nil {
 		if s, err := storage.GetSubscriber(c, id, middleware.GetUser(c).ID); err == nil {
@@ -128,16 +190,66 @@ func PostSubscriber(c *gin.Context) {
 		return
 	}
 
+	validation := emailvalidator.GetFromContext(c).Validate(c.Request.Context(), body.Email)
+	if validation.IsRejected() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"email": validation.Reason(),
+			},
+		})
+		return
+	}
+
+	suppression, err := storage.GetSuppressionForEmail(c, user.ID, body.Email)
+	if err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"email": suppression.Description(),
+			},
+		})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.From(c).WithError(err).Error("Unable to check whether the email is suppressed.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to check the suppression list. Please try again.",
+		})
+		return
+	}
+
+	if ok := applyCustomFields(c, user.ID, body.Metadata); !ok {
+		return
+	}
+
 	s := &entities.Subscriber{
 		Name:     body.Name,
-		Email:    body.Email,
+		Email:    entities.NormalizeEmail(body.Email),
 		Metadata: body.Metadata,
-		Active:   true,
+		Active:   !body.DoubleOptIn,
+		Pending:  body.DoubleOptIn,
 		UserID:   middleware.GetUser(c).ID,
 	}
+	if err = s.SetEmailValidation(validation); err != nil {
+		logger.From(c).WithError(err).Error("Unable to marshal the email validation.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to create subscriber, please try again.",
+		})
+		return
+	}
+
+	if body.DoubleOptIn || body.ConsentIP != "" {
+		now := time.Now().UTC()
+		s.ConsentAt = &now
+		s.ConsentIP = body.ConsentIP
+		if s.ConsentIP == "" {
+			s.ConsentIP = c.ClientIP()
+		}
+	}
 
 	s.Segments, err = storage.GetSegmentsByIDs(c, s.UserID, body.SegmentIDs)
-	if err != nil {
+	if err != nil || hasDynamicSegment(s.Segments) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"message": "Invalid data",
 			"errors": map[string]string{
@@ -163,6 +275,37 @@ func PostSubscriber(c *gin.Context) {
 		return
 	}
 
+	var (
+		sesKeys *entities.SesKeys
+		tmpl    *entities.CampaignTemplateData
+	)
+	if body.DoubleOptIn {
+		sesKeys, err = storage.GetSesKeys(c, user.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to send the confirmation email, please set your AWS Ses keys.",
+			})
+			return
+		}
+
+		if body.ConfirmationTemplateID != 0 {
+			service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+			tmpl, err = service.ParseTemplate(c, body.ConfirmationTemplateID, user.ID)
+			if err != nil {
+				logger.From(c).WithError(err).
+					WithField("template_id", body.ConfirmationTemplateID).
+					Warn("Unable to parse confirmation template.")
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "Invalid data",
+					"errors": map[string]string{
+						"confirmation_template_id": "Unable to find or parse the specified template.",
+					},
+				})
+				return
+			}
+		}
+	}
+
 	if err := storage.CreateSubscriber(c, s); err != nil {
 		logger.From(c).
 			WithError(err).
@@ -173,9 +316,117 @@ func PostSubscriber(c *gin.Context) {
 		return
 	}
 
+	if body.DoubleOptIn {
+		err := publishConfirmationEmail(c, user, s, sesKeys, tmpl, body.ConfirmationSource)
+		if err != nil {
+			logger.From(c).WithError(err).
+				WithField("subscriber_id", s.ID).
+				Error("Unable to send confirmation email.")
+		}
+	}
+
 	c.JSON(http.StatusCreated, s)
 }
 
+// publishConfirmationEmail publishes the email with the confirmation link of the pending subscriber
+// to the sender queue, which sends it like the campaign emails.
+func publishConfirmationEmail(
+	c context.Context,
+	u *entities.User,
+	s *entities.Subscriber,
+	keys *entities.SesKeys,
+	tmpl *entities.CampaignTemplateData,
+	source string,
+) error {
+	html, subject, text, err := renderConfirmationEmail(u, s, tmpl)
+	if err != nil {
+		return err
+	}
+
+	msg, err := json.Marshal(entities.SenderTopicParams{
+		EventID:         ksuid.New(),
+		UserID:          u.ID,
+		UserUUID:        u.UUID,
+		SubscriberID:    s.ID,
+		SubscriberEmail: s.Email,
+		Source:          source,
+		HTMLPart:        html,
+		SubjectPart:     subject,
+		TextPart:        text,
+		SesKeys:         *keys,
+	})
+	if err != nil {
+		return fmt.Errorf("publish confirmation email: marshal params: %w", err)
+	}
+
+	err = queue.Publish(c, entities.SenderTopic, msg)
+	if err != nil {
+		return fmt.Errorf("publish confirmation email: %w", err)
+	}
+
+	return nil
+}
+
+// renderConfirmationEmail renders the email with the confirmation link to the pending subscriber
+// from the user's template when it is given, or from the default confirmation email.
+func renderConfirmationEmail(
+	u *entities.User,
+	s *entities.Subscriber,
+	tmpl *entities.CampaignTemplateData,
+) (html, subject, text []byte, err error) {
+	confirmURL, err := s.GetConfirmationURL(u.UUID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("confirmation email: get confirmation url: %w", err)
+	}
+
+	var htmlBuf, subjectBuf, textBuf bytes.Buffer
+	if tmpl != nil {
+		m, err := s.GetMetadata()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: get metadata: %w", err)
+		}
+		if s.Name != "" {
+			m[entities.TagName] = s.Name
+		}
+		m[entities.TagConfirmURL] = confirmURL
+		m[entities.TagUnsubscribeUrl], err = s.GetUnsubscribeURL(u.UUID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: get unsubscribe url: %w", err)
+		}
+		m[entities.TagPreferencesURL], err = s.GetPreferencesURL(u.UUID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: get preferences url: %w", err)
+		}
+
+		if tmpl.Layout != nil {
+			err = tmpl.HTMLPart.RenderInLayout(&htmlBuf, tmpl.Layout, m)
+		} else {
+			err = tmpl.HTMLPart.Render(&htmlBuf, m)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: render html: %w", err)
+		}
+		err = tmpl.SubjectPart.Render(&subjectBuf, m)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: render subject: %w", err)
+		}
+		err = tmpl.TextPart.Render(&textBuf, m)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: render text: %w", err)
+		}
+	} else {
+		err = templates.GetEmailTemplates().ExecuteTemplate(&htmlBuf, "confirm-subscription.html", map[string]string{
+			"url": confirmURL,
+		})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("confirmation email: exec template: %w", err)
+		}
+		subjectBuf.WriteString("Confirm your subscription")
+	}
+
+	return htmlBuf.Bytes(), subjectBuf.Bytes(), textBuf.Bytes(), nil
+}
+
 func PutSubscriber(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -208,7 +459,7 @@ func PutSubscriber(c *gin.Context) {
 	}
 
 	segments, err := storage.GetSegmentsByIDs(c, s.UserID, body.SegmentIDs)
-	if err != nil {
+	if err != nil || hasDynamicSegment(segments) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"message": "Invalid data",
 			"errors": map[string]string{
@@ -218,6 +469,10 @@ func PutSubscriber(c *gin.Context) {
 		return
 	}
 
+	if ok := applyCustomFields(c, s.UserID, body.Metadata); !ok {
+		return
+	}
+
 	metaJSON, err := json.Marshal(body.Metadata)
 	if err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
@@ -273,6 +528,111 @@ func DeleteSubscriber(c *gin.Context) {
 	})
 }
 
+// applyCustomFields validates the metadata by the custom fields of the user and sets the default
+// values, see entities.CustomFields.ApplyTo. It writes the error response and returns false when
+// the metadata is invalid.
+func applyCustomFields(c *gin.Context, userID int64, meta map[string]string) bool {
+	fields, err := storage.GetCustomFields(c, userID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch custom fields.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to validate the metadata. Please try again.",
+		})
+		return false
+	}
+
+	if errs := fields.ApplyTo(meta); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors":  entities.MetadataErrors(errs),
+		})
+		return false
+	}
+
+	return true
+}
+
+// MergeSubscribers merges the duplicates into the subscriber and deletes them, see
+// entities.Subscriber.Merge. The send logs and the events of the duplicates are moved
+// to the subscriber.
+func MergeSubscribers(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	user := middleware.GetUser(c)
+
+	s, err := storage.GetSubscriber(c, id, user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Subscriber not found",
+		})
+		return
+	}
+
+	body := &params.MergeSubscribers{}
+	if err = c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err = validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	duplicates := make([]entities.Subscriber, 0, len(body.IDs))
+	seen := map[int64]bool{s.ID: true}
+	for _, dupID := range body.IDs {
+		if seen[dupID] {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"ids": "The ids must be unique and must not include the subscriber.",
+				},
+			})
+			return
+		}
+		seen[dupID] = true
+
+		d, err := storage.GetSubscriber(c, dupID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"ids": fmt.Sprintf("Unable to find the subscriber with id %d.", dupID),
+				},
+			})
+			return
+		}
+		duplicates = append(duplicates, *d)
+	}
+
+	if err = s.Merge(duplicates); err != nil {
+		logger.From(c).WithError(err).WithField("subscriber_id", id).Error("Unable to merge subscribers' metadata.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to merge subscribers, please try again.",
+		})
+		return
+	}
+
+	if err = storage.MergeSubscribers(c, s, duplicates); err != nil {
+		logger.From(c).WithError(err).WithField("subscriber_id", id).Error("Unable to merge subscribers.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to merge subscribers, please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
 func PostUnsubscribe(c *gin.Context) {
 	body := &params.PostUnsubscribe{}
 	if err := c.ShouldBind(body); err != nil {
@@ -346,9 +706,83 @@ func PostUnsubscribe(c *gin.Context) {
 		return
 	}
 
+	suppress(c, u.ID, body.Email, entities.SuppressionReasonUnsubscribed, entities.SuppressionSourceUnsubscribe)
+
 	c.Redirect(http.StatusPermanentRedirect, os.Getenv("APP_URL")+"/unsubscribe-success.html")
 }
 
+// PostConfirmSubscription activates the pending subscriber by the signed token from the
+// confirmation email. Confirming an already confirmed subscription is not an error.
+func PostConfirmSubscription(c *gin.Context) {
+	body := &params.PostConfirmSubscription{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	params := url.Values{}
+	params.Add("email", body.Email)
+	params.Add("uuid", body.UUID)
+	params.Add("t", body.Token)
+	params.Add("failed", "true")
+
+	redirWithError := c.Request.Referer() + "?" + params.Encode()
+
+	log := logger.From(c).WithFields(logrus.Fields{
+		"email": body.Email,
+		"uuid":  body.UUID,
+	})
+
+	u, err := storage.GetUserByUUID(c, body.UUID)
+	if err != nil {
+		log.WithError(err).Warn("Confirm subscription: cannot find user by uuid.")
+		c.Redirect(http.StatusPermanentRedirect, redirWithError)
+		return
+	}
+
+	sub, err := storage.GetSubscriberByEmail(c, body.Email, u.ID)
+	if err != nil {
+		log.WithError(err).Warn("Confirm subscription: unable to fetch subscriber by email.")
+		c.Redirect(http.StatusPermanentRedirect, redirWithError)
+		return
+	}
+
+	hash, err := sub.GenerateConfirmationToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		log.WithError(err).Error("Confirm subscription: unable to generate hash.")
+		c.Redirect(http.StatusPermanentRedirect, redirWithError)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(body.Token), []byte(hash)) != 1 {
+		log.Warn("Confirm subscription: hashes don't match.")
+		c.Redirect(http.StatusPermanentRedirect, redirWithError)
+		return
+	}
+
+	if sub.Pending {
+		now := time.Now().UTC()
+		sub.ConfirmedAt = &now
+		sub.ConfirmationIP = c.ClientIP()
+
+		err = storage.ConfirmSubscriber(c, sub)
+		if err != nil {
+			log.WithError(err).Warn("Confirm subscription: unable to confirm subscriber.")
+			c.Redirect(http.StatusPermanentRedirect, redirWithError)
+			return
+		}
+	}
+
+	c.Redirect(http.StatusPermanentRedirect, os.Getenv("APP_URL")+"/confirm-subscription-success.html")
+}
+
 func ImportSubscribers(c *gin.Context) {
 	u := middleware.GetUser(c)
 	boundariesSvc := boundaries.New(storage.GetFromContext(c))
@@ -361,6 +795,7 @@ func ImportSubscribers(c *gin.Context) {
 		})
 		return
 	}
+	reqParams.Mapping = c.PostFormMap("mapping")
 
 	if err := validator.Validate(reqParams); err != nil {
 		c.JSON(http.StatusBadRequest, err)
@@ -370,7 +805,7 @@ func ImportSubscribers(c *gin.Context) {
 	var segs []entities.Segment
 	if len(reqParams.SegmentIDs) > 0 {
 		segs, err = storage.GetSegmentsByIDs(c, u.ID, reqParams.SegmentIDs)
-		if err != nil {
+		if err != nil || hasDynamicSegment(segs) {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"message": "Invalid data",
 				"errors": map[string]string{
@@ -395,58 +830,429 @@ func ImportSubscribers(c *gin.Context) {
 		return
 	}
 
-	s3Client := s3storage.GetFromContext(c)
-	res, err := s3Client.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("FILES_BUCKET")),
-		Key:    aws.String(fmt.Sprintf("subscribers/import/%d/%s", u.ID, reqParams.Filename)),
-	})
+	store := blobs.GetFromContext(c)
+	key := subscribers.ImportFileKey(u.ID, reqParams.Filename)
+
+	res, err := store.Get(os.Getenv("FILES_BUCKET"), key)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"message": "Unable to import subscribers. Please try again.",
 		})
 		return
 	}
+	info, err := subscribers.InspectFile(res.Body, subscribers.FileOptions{
+		Format: reqParams.Format,
+		Sheet:  reqParams.Sheet,
+	}, 0)
+	closeBody(c, res.Body)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors":  invalidImportFile(err),
+		})
+		return
+	}
 
-	defer func() {
-		err = res.Body.Close()
-		if err != nil {
-			logger.From(c).WithError(err).Error("import subscribers: unable to close body")
-		}
-	}()
+	if u.Boundaries.SubscribersLimit > 0 && count+int64(info.Total) > u.Boundaries.SubscribersLimit {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "With this import you will exceed the limit of your subscribers, update your plan or contact the support team.",
+			"total":   count,
+			"count":   info.Total,
+		})
+		return
+	}
 
-	// duplicate stream read.
-	var buf bytes.Buffer
-	tee := io.TeeReader(res.Body, &buf)
+	if msg := invalidImportMapping(info.Header, reqParams.Mapping); msg != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"mapping": msg,
+			},
+		})
+		return
+	}
 
-	csvCount, err := utils.CountLines(tee)
+	imp := &entities.SubscriberImport{
+		UserID:   u.ID,
+		FileName: reqParams.Filename,
+		Kind:     entities.SubscriberImportKindImport,
+		Mode:     reqParams.Mode,
+		Format:   info.Format,
+		Sheet:    info.Sheet,
+		Status:   entities.StatusPending,
+		Total:    int64(info.Total),
+	}
+	if imp.Mode == "" {
+		imp.Mode = subscribers.ImportModeSkip
+	}
+	imp.MappingJSON, err = json.Marshal(reqParams.Mapping)
+	if err == nil {
+		imp.SegmentIDsJSON, err = json.Marshal(reqParams.SegmentIDs)
+	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.From(c).WithError(err).Error("import subscribers: unable to marshal the import")
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Unable to import subscribers. Please try again.",
 		})
 		return
 	}
 
-	if count+int64(csvCount) > u.Boundaries.SubscribersLimit {
-		c.JSON(http.StatusForbidden, gin.H{
-			"message": "With this import you will exceed the limit of your subscribers, update your plan or contact the support team.",
-			"total":   count,
-			"count":   csvCount,
+	err = storage.CreateSubscriberImport(c, imp)
+	if err != nil {
+		logger.From(c).WithError(err).Error("import subscribers: unable to create the import")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to import subscribers. Please try again.",
 		})
 		return
 	}
 
-	go func(ctx context.Context, s3Client s3iface.S3API, storage storage.Storage, userID int64, segs []entities.Segment, r io.Reader) {
-		svc := subscribers.New(s3Client, storage)
-		err := svc.ImportSubscribersFromFile(ctx, u.ID, segs, r)
-		if err != nil {
-			logger.From(ctx).WithFields(logrus.Fields{
-				"segments": segs,
-			}).WithError(err).Warn("Unable to import subscribers.")
+	if ok := queueImport(c, imp, "Unable to import subscribers. Please try again."); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// queueImport publishes the import to the importer consumer, the import is marked as failed when
+// it can't be queued. It writes the error response with the message and returns false when it fails.
+func queueImport(c *gin.Context, imp *entities.SubscriberImport, message string) bool {
+	body, err := json.Marshal(entities.SubscriberImportTopicParams{
+		ImportID: imp.ID,
+		UserID:   imp.UserID,
+	})
+	if err == nil {
+		err = queue.Publish(c, entities.SubscriberImportTopic, body)
+	}
+	if err != nil {
+		logger.From(c).WithField("import_id", imp.ID).WithError(err).Error("Unable to queue the subscribers import.")
+
+		imp.Status = entities.StatusFailed
+		imp.Error = "Unable to queue the import."
+		imp.CompletedAt.SetValid(time.Now().UTC())
+		if _, err := storage.UpdateSubscriberImport(c, imp, entities.StatusPending); err != nil {
+			logger.From(c).WithField("import_id", imp.ID).WithError(err).Error("Unable to update the subscribers import.")
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": message,
+		})
+		return false
+	}
+
+	return true
+}
+
+// GetSubscriberImport returns the import, so its status and progress can be polled.
+func GetSubscriberImport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	imp, err := storage.GetSubscriberImport(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Import not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// CancelSubscriberImport cancels the pending or running import, a running import stops after the
+// batch it's importing, and the subscribers which were imported until then are kept.
+func CancelSubscriberImport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	imp, err := storage.GetSubscriberImport(c, id, middleware.GetUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Import not found.",
+		})
+		return
+	}
+
+	ok, err := storage.CancelSubscriberImport(c, imp.ID, imp.UserID)
+	if err != nil {
+		logger.From(c).WithField("import_id", imp.ID).WithError(err).Error("Unable to cancel the subscribers import.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to cancel the import. Please try again.",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "The import is already finished.",
+		})
+		return
+	}
+
+	imp, err = storage.GetSubscriberImport(c, id, imp.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Import not found.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// closeBody closes the body of the file, the errors are only logged.
+func closeBody(c context.Context, body io.Closer) {
+	if err := body.Close(); err != nil {
+		logger.From(c).WithError(err).Error("unable to close body")
+	}
+}
+
+// invalidImportMapping returns the reason the columns of the header can't be mapped by the
+// mapping, or an empty string when they can.
+func invalidImportMapping(header []string, mapping map[string]string) string {
+	if _, err := subscribers.MapColumns(header, mapping); err != nil {
+		if errors.Is(err, subscribers.ErrInvalidFormat) {
+			return "Map one of the columns to the email."
 		}
-	}(c, s3Client, storage.GetFromContext(c), u.ID, segs, &buf)
+		return strings.TrimPrefix(err.Error(), subscribers.ErrInvalidMapping.Error()+": ")
+	}
+	return ""
+}
+
+// invalidImportFile returns the reasons the imported file can't be read.
+func invalidImportFile(err error) map[string]string {
+	switch {
+	case errors.Is(err, utils.ErrSheetNotFound):
+		return map[string]string{"sheet": "Unable to find the sheet."}
+	case errors.Is(err, subscribers.ErrFileTooLarge):
+		return map[string]string{"filename": "The file is larger than 50MB."}
+	case errors.Is(err, io.EOF):
+		return map[string]string{"filename": "The file is empty."}
+	}
+	return map[string]string{
+		"filename": "Unable to read the file, please check whether it's a valid CSV, NDJSON, JSON or XLSX file.",
+	}
+}
+
+// PreviewImportSubscribers returns the detected format, the header and the first rows of the uploaded
+// file, along with the mapping of the columns which is used when no mapping is given.
+func PreviewImportSubscribers(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.PreviewImportSubscribers{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := blobs.GetFromContext(c).Get(os.Getenv("FILES_BUCKET"), subscribers.ImportFileKey(u.ID, body.Filename))
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "File not found.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).Warn("Preview import: unable to get the file.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to preview the file. Please try again.",
+		})
+		return
+	}
+	defer res.Body.Close()
+
+	preview, err := subscribers.InspectFile(res.Body, subscribers.FileOptions{
+		Format: body.Format,
+		Sheet:  body.Sheet,
+	}, 5)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors":  invalidImportFile(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// DownloadImportErrors returns the url the error report of the import can be downloaded from,
+// the report lists the rejected rows of the file along with the reasons.
+func DownloadImportErrors(c *gin.Context) {
+	downloadSubscriberImportReport(c, entities.SubscriberImportKindImport, subscribers.ImportErrorsKey, "Error report")
+}
+
+// BulkUpdateSubscribers queues a bulk update of the existing subscribers by the uploaded file, see
+// subscribers.MapBulkUpdateColumns for the columns of the file. The bulk update is an import of the
+// bulk_update kind, so it's polled and cancelled like the imports. A dry run only reports the changes
+// it would make, the report lists the results of the rows of the file.
+func BulkUpdateSubscribers(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.BulkUpdateSubscribers{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	store := blobs.GetFromContext(c)
+
+	res, err := store.Get(os.Getenv("FILES_BUCKET"), subscribers.ImportFileKey(u.ID, body.Filename))
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "File not found.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).Warn("Bulk update: unable to get the file.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to update subscribers. Please try again.",
+		})
+		return
+	}
+	info, err := subscribers.InspectFile(res.Body, subscribers.FileOptions{
+		Format: body.Format,
+		Sheet:  body.Sheet,
+	}, 0)
+	closeBody(c, res.Body)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors":  invalidImportFile(err),
+		})
+		return
+	}
+
+	if _, err := subscribers.MapBulkUpdateColumns(info.Header); err != nil {
+		msg := strings.TrimPrefix(err.Error(), subscribers.ErrInvalidMapping.Error()+": ")
+		if errors.Is(err, subscribers.ErrInvalidFormat) {
+			msg = "The file must have an email column."
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"columns": msg,
+			},
+		})
+		return
+	}
+
+	imp := &entities.SubscriberImport{
+		UserID:   u.ID,
+		FileName: body.Filename,
+		Kind:     entities.SubscriberImportKindBulkUpdate,
+		Mode:     subscribers.ImportModeUpdate,
+		DryRun:   body.DryRun,
+		Format:   info.Format,
+		Sheet:    info.Sheet,
+		Status:   entities.StatusPending,
+		Total:    int64(info.Total),
+	}
+
+	err = storage.CreateSubscriberImport(c, imp)
+	if err != nil {
+		logger.From(c).WithError(err).Error("bulk update: unable to create the import")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to update subscribers. Please try again.",
+		})
+		return
+	}
+
+	if ok := queueImport(c, imp, "Unable to update subscribers. Please try again."); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// DownloadBulkUpdateReport returns the url the report of the bulk update can be downloaded from,
+// the report lists the changes of each row of the file, or the reason the row failed.
+func DownloadBulkUpdateReport(c *gin.Context) {
+	downloadSubscriberImportReport(c, entities.SubscriberImportKindBulkUpdate, subscribers.BulkUpdateReportKey, "Report")
+}
+
+// downloadSubscriberImportReport returns the url the report of the import by the id param can be
+// downloaded from, the import must be of the given kind and the key of its report is returned by
+// the given func.
+func downloadSubscriberImportReport(
+	c *gin.Context,
+	kind string,
+	key func(userID, importID int64) string,
+	name string,
+) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	imp, err := storage.GetSubscriberImport(c, id, middleware.GetUser(c).ID)
+	if err != nil || imp.Kind != kind {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Import not found.",
+		})
+		return
+	}
+
+	signImportReport(c, key(imp.UserID, imp.ID), name)
+}
+
+// signImportReport returns the signed url of the report by the key, when the report exists.
+func signImportReport(c *gin.Context, reportKey, name string) {
+	store := blobs.GetFromContext(c)
+
+	res, err := store.Get(os.Getenv("FILES_BUCKET"), reportKey)
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": name + " not found.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).Warn("Import report: unable to get the report.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to get the " + strings.ToLower(name) + ". Please try again.",
+		})
+		return
+	}
+	_ = res.Body.Close()
+
+	pUrl, err := store.SignGet(os.Getenv("FILES_BUCKET"), reportKey, 15*time.Minute)
+	if err != nil {
+		logger.From(c).WithError(err).Warn("Unable to sign url.")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to sign url.",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "We will begin processing the file shortly. As we import the subscribers, you will see them in the dashboard.",
+		"url": pUrl,
 	})
 }
 
@@ -467,12 +1273,9 @@ func BulkRemoveSubscribers(c *gin.Context) {
 		return
 	}
 
-	s3Client := s3storage.GetFromContext(c)
+	store := blobs.GetFromContext(c)
 
-	res, err := s3Client.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("FILES_BUCKET")),
-		Key:    aws.String(fmt.Sprintf("subscribers/remove/%d/%s", u.ID, body.Filename)),
-	})
+	res, err := store.Get(os.Getenv("FILES_BUCKET"), fmt.Sprintf("subscribers/remove/%d/%s", u.ID, body.Filename))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"message": "Unable to remove subscribers. Please try again.",
@@ -480,15 +1283,15 @@ func BulkRemoveSubscribers(c *gin.Context) {
 		return
 	}
 
-	go func(ctx context.Context, client s3iface.S3API, storage storage.Storage, filename string, userID int64, r io.ReadCloser) {
-		svc := subscribers.New(client, storage)
+	go func(ctx context.Context, store blobs.Store, storage storage.Storage, filename string, userID int64, r io.ReadCloser) {
+		svc := subscribers.New(store, storage)
 		err := svc.RemoveSubscribersFromFile(ctx, filename, userID, r)
 		if err != nil {
 			logger.From(ctx).WithFields(logrus.Fields{
 				"filename": filename,
 			}).WithError(err).Warn("Unable to remove subscribers.")
 		}
-	}(c, s3Client, storage.GetFromContext(c), body.Filename, u.ID, res.Body)
+	}(c, store, storage.GetFromContext(c), body.Filename, u.ID, res.Body)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "We will begin processing the file shortly.",
@@ -496,15 +1299,23 @@ func BulkRemoveSubscribers(c *gin.Context) {
 }
 
 func ExportSubscribers(c *gin.Context) {
-	u := middleware.GetUser(c)
+	exportReport(c, entities.SubscribersResource)
+}
+
+func DownloadSubscribersReport(c *gin.Context) {
+	downloadReport(c, entities.SubscribersResource)
+}
 
-	s3Client := s3storage.GetFromContext(c)
+// exportReport starts the export of the resource in the background and responds with the
+// report, the file of the report is downloaded with downloadReport once it's done.
+func exportReport(c *gin.Context, resource string) {
+	u := middleware.GetUser(c)
 
-	exporter, err := exporters.NewExporter("subscribers", s3Client)
+	exporter, err := exporters.NewExporter(resource, blobs.GetFromContext(c))
 	if err != nil {
-		logger.From(c).WithError(err).Error("Unable do create subscribers exporter")
+		logger.From(c).WithError(err).Errorf("Unable do create %s exporter", resource)
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Unable to export subscribers. Please try again.",
+			"message": fmt.Sprintf("Unable to export %s. Please try again.", resource),
 		})
 		return
 	}
@@ -557,13 +1368,14 @@ func ExportSubscribers(c *gin.Context) {
 	c.JSON(http.StatusOK, report)
 }
 
-func DownloadSubscribersReport(c *gin.Context) {
+// downloadReport returns the url the exported file of the resource can be downloaded from.
+func downloadReport(c *gin.Context, resource string) {
 	u := middleware.GetUser(c)
 
 	fileName := c.Query("filename")
 
 	report, err := storage.GetReportByFilename(c, fileName, u.ID)
-	if err != nil {
+	if err != nil || report.Resource != resource {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"message": "Report not found.",
 		})
@@ -587,16 +1399,13 @@ func DownloadSubscribersReport(c *gin.Context) {
 	}
 
 	if report.Status == entities.StatusDone {
-		s3Client := s3storage.GetFromContext(c)
-
-		req, _ := s3Client.GetObjectRequest(&s3.GetObjectInput{
-			Bucket: aws.String(os.Getenv("FILES_BUCKET")),
-			Key:    aws.String(fmt.Sprintf("subscribers/export/%d/%s", u.ID, fileName)),
-		})
-
-		pUrl, err := req.Presign(15 * time.Minute)
+		pUrl, err := blobs.GetFromContext(c).SignGet(
+			os.Getenv("FILES_BUCKET"),
+			exporters.Key(resource, u.ID, fileName),
+			15*time.Minute,
+		)
 		if err != nil {
-			logger.From(c).WithError(err).Warn("Unable to sign s3 url.")
+			logger.From(c).WithError(err).Warn("Unable to sign url.")
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "Unable to sign url.",
 			})
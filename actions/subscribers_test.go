@@ -1,9 +1,15 @@
 package actions_test
 
 import (
+	"encoding/json"
 	"net/http"
+	"os"
+	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/storage"
 	"github.com/mailbadger/app/storage/s3"
@@ -77,6 +83,43 @@ func TestSubscribers(t *testing.T) {
 		ValueEqual("blacklisted", false).
 		ValueEqual("active", true)
 
+	// test search by name and state
+	auth.GET("/api/subscribers").
+		WithQuery("name", "jal").
+		WithQuery("active", true).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("total", 1).
+		Value("collection").Array().Element(0).Object().
+		ValueEqual("email", "djale@email.com")
+
+	auth.GET("/api/subscribers").
+		WithQuery("metadata_contains[test]", "nope").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("total", 0)
+
+	// test the filter is kept in the pagination links
+	auth.GET("/api/subscribers").
+		WithQuery("per_page", 1).
+		WithQuery("email", "email.com").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("total", 2).
+		Value("links").Object().Value("next").String().Contains("email=email.com")
+
+	// test validation of the search params
+	auth.GET("/api/subscribers").
+		WithQuery("created_after", "yesterday").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.GET("/api/subscribers").
+		WithQuery("metadata_exists[]", "foo bar").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().ValueEqual("message", "Invalid parameters, please try again")
+
 	// test get subscriber by id
 	auth.GET("/api/subscribers/2").
 		Expect().
@@ -100,6 +143,75 @@ func TestSubscribers(t *testing.T) {
 		ValueEqual("blacklisted", false).
 		ValueEqual("active", true)
 
+	// test double opt-in requires the confirmation source
+	auth.POST("/api/subscribers").WithForm(params.PostSubscriber{Name: "Pending", Email: "pending@email.com", Metadata: map[string]string{"test": "test"}, DoubleOptIn: true}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{"confirmation_source": "This field is required"})
+
+	// test double opt-in without ses keys
+	auth.POST("/api/subscribers").WithForm(params.PostSubscriber{
+		Name:               "Pending",
+		Email:              "pending@email.com",
+		Metadata:           map[string]string{"test": "test"},
+		DoubleOptIn:        true,
+		ConfirmationSource: "news@example.com",
+	}).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "Unable to send the confirmation email, please set your AWS Ses keys.")
+
+	// test confirm subscription
+	err = os.Setenv("UNSUBSCRIBE_SECRET", "secret")
+	if err != nil {
+		t.FailNow()
+	}
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+	u.UUID = "9f8c9a6e-5b1d-4f0e-8f3a-2c1d7e6b5a40"
+	err = s.UpdateUser(u)
+	if err != nil {
+		t.FailNow()
+	}
+	pending := &entities.Subscriber{
+		Email:   "pending@email.com",
+		UserID:  u.ID,
+		Pending: true,
+	}
+	err = s.CreateSubscriber(pending)
+	if err != nil {
+		t.FailNow()
+	}
+
+	token, err := pending.GenerateConfirmationToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		t.FailNow()
+	}
+
+	e.POST("/api/confirm-subscription").WithForm(params.PostConfirmSubscription{Email: pending.Email, UUID: u.UUID, Token: "invalid"}).
+		Expect().
+		Status(http.StatusPermanentRedirect).
+		Header("Location").Contains("failed=true")
+
+	e.POST("/api/confirm-subscription").WithForm(params.PostConfirmSubscription{Email: pending.Email, UUID: u.UUID, Token: token}).
+		Expect().
+		Status(http.StatusPermanentRedirect).
+		Header("Location").Equal(os.Getenv("APP_URL") + "/confirm-subscription-success.html")
+
+	auth.GET("/api/subscribers/"+strconv.FormatInt(pending.ID, 10)).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("active", true).
+		ValueEqual("pending", false)
+
+	// test confirming again is not an error
+	e.POST("/api/confirm-subscription").WithForm(params.PostConfirmSubscription{Email: pending.Email, UUID: u.UUID, Token: token}).
+		Expect().
+		Status(http.StatusPermanentRedirect).
+		Header("Location").Equal(os.Getenv("APP_URL") + "/confirm-subscription-success.html")
+
 	// delete subscriber by id
 	auth.DELETE("/api/subscribers/1").
 		Expect().
@@ -110,3 +222,183 @@ func TestSubscribers(t *testing.T) {
 		Expect().
 		Status(http.StatusNoContent)
 }
+
+func TestPostSubscriberEmailValidation(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	s3mock := new(s3.MockS3Client)
+
+	e := setup(t, s, s3mock)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// the domains without mail servers are rejected
+	auth.POST("/api/subscribers").WithFormField("email", "jane@nowhere.invalid").
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("errors", map[string]string{"email": "The domain has no mail servers."})
+
+	// the roles, the typos and the disposable domains are flagged
+	obj := auth.POST("/api/subscribers").WithFormField("email", "info@gmial.com").
+		Expect().
+		Status(http.StatusCreated).JSON().Object()
+	obj.ValueEqual("email_status", entities.EmailStatusRisky)
+	validation := obj.Value("email_validation").Object()
+	validation.ValueEqual("status", entities.EmailStatusRisky)
+	validation.ValueEqual("suggestion", "info@gmail.com")
+	validation.Value("issues").Array().Length().Equal(2)
+	validation.Value("issues").Array().Element(0).Object().
+		ValueEqual("check", "typo").
+		ValueEqual("action", entities.EmailActionWarn)
+	validation.Value("issues").Array().Element(1).Object().
+		ValueEqual("check", "role")
+
+	auth.POST("/api/subscribers").WithFormField("email", "jane@mailinator.com").
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email_status", entities.EmailStatusRisky).
+		Value("email_validation").Object().
+		Value("issues").Array().Element(0).Object().
+		ValueEqual("check", "disposable")
+
+	auth.POST("/api/subscribers").WithFormField("email", "jane@bücher.de").
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email_status", entities.EmailStatusValid)
+}
+
+func TestPostSubscriberDoubleOptIn(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	producer := new(testProducer)
+	e := setupWithProducer(t, s, nil, producer)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	err = os.Setenv("UNSUBSCRIBE_SECRET", "secret")
+	if err != nil {
+		t.FailNow()
+	}
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+	err = s.CreateSesKeys(&entities.SesKeys{UserID: u.ID, AccessKey: "key", SecretKey: "secret", Region: "eu-west-1"})
+	if err != nil {
+		t.FailNow()
+	}
+
+	id := auth.POST("/api/subscribers").WithForm(params.PostSubscriber{
+		Name:               "Pending",
+		Email:              "pending@email.com",
+		Metadata:           map[string]string{"test": "test"},
+		DoubleOptIn:        true,
+		ConfirmationSource: "news@example.com",
+	}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("active", false).
+		Value("id").Number().Raw()
+
+	// the confirmation email is queued for the sender
+	messages := producer.Messages(entities.SenderTopic)
+	if !assert.Len(t, messages, 1) {
+		t.FailNow()
+	}
+	msg := new(entities.SenderTopicParams)
+	assert.Nil(t, json.Unmarshal(messages[0], msg))
+	assert.Equal(t, u.ID, msg.UserID)
+	assert.Equal(t, int64(0), msg.CampaignID)
+	assert.Equal(t, int64(id), msg.SubscriberID)
+	assert.Equal(t, "pending@email.com", msg.SubscriberEmail)
+	assert.Equal(t, "news@example.com", msg.Source)
+	assert.Equal(t, "Confirm your subscription", string(msg.SubjectPart))
+	assert.Contains(t, string(msg.HTMLPart), "/confirm")
+	assert.Equal(t, "key", msg.SesKeys.AccessKey)
+}
+
+func TestMergeSubscribers(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	s3mock := new(s3.MockS3Client)
+
+	e := setup(t, s, s3mock)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test the emails are normalized
+	id := auth.POST("/api/subscribers").WithFormField("email", "John@Example.COM").WithFormField("metadata[city]", "Skopje").
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email", "john@example.com").
+		Value("id").Number().Raw()
+
+	auth.POST("/api/subscribers").WithFormField("email", "JOHN@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "Subscriber with that email already exists.")
+
+	// the local part is kept when it's case sensitive
+	err = os.Setenv("EMAIL_CASE_SENSITIVE_LOCAL_PART", "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dupID := auth.POST("/api/subscribers").WithFormField("email", "JOHN@Example.com").WithFormField("name", "John").
+		WithFormField("metadata[city]", "Ohrid").WithFormField("metadata[plan]", "pro").
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email", "JOHN@example.com").
+		Value("id").Number().Raw()
+	os.Unsetenv("EMAIL_CASE_SENSITIVE_LOCAL_PART")
+
+	idStr := strconv.FormatInt(int64(id), 10)
+	dupIDStr := strconv.FormatInt(int64(dupID), 10)
+
+	// test merge subscribers
+	e.POST("/api/subscribers/"+idStr+"/merge").WithFormField("ids[]", dupIDStr).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.POST("/api/subscribers/" + idStr + "/merge").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.POST("/api/subscribers/999/merge").WithFormField("ids[]", dupIDStr).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/subscribers/"+idStr+"/merge").WithFormField("ids[]", idStr).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("errors", map[string]string{"ids": "The ids must be unique and must not include the subscriber."})
+
+	auth.POST("/api/subscribers/"+idStr+"/merge").WithFormField("ids[]", "999").
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("errors", map[string]string{"ids": "Unable to find the subscriber with id 999."})
+
+	auth.POST("/api/subscribers/"+idStr+"/merge").WithFormField("ids[]", dupIDStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("email", "john@example.com").
+		ValueEqual("name", "John").
+		ValueEqual("metadata", map[string]string{"city": "Skopje", "plan": "pro"})
+
+	auth.GET("/api/subscribers/" + dupIDStr).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.GET("/api/subscribers/"+idStr+"/activity").WithQuery("types[]", "merged").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 1)
+}
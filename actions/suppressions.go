@@ -0,0 +1,229 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/suppressions"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetSuppressions(c *gin.Context) {
+	val, ok := c.Get("cursor")
+	if !ok {
+		logger.From(c).Error("Unable to fetch pagination cursor from context.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch suppressions. Please try again.",
+		})
+		return
+	}
+
+	p, ok := val.(*storage.PaginationCursor)
+	if !ok {
+		logger.From(c).Error("Unable to cast pagination cursor from context value.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch suppressions. Please try again.",
+		})
+		return
+	}
+
+	scopeMap := c.QueryMap("scopes")
+
+	err := storage.GetSuppressions(c, middleware.GetUser(c).ID, p, scopeMap)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch suppressions collection.")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch suppressions. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// PostSuppression adds an email or a domain to the suppression list, the entries added by the
+// user are suppressed manually unless the reason is given.
+func PostSuppression(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.PostSuppression{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	typ, value, ok := entities.ParseSuppression(body.Value)
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Invalid data",
+			"errors": map[string]string{
+				"value": "The value is neither an email nor a domain.",
+			},
+		})
+		return
+	}
+
+	_, err := storage.GetSuppressionByValue(c, u.ID, typ, value)
+	if err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "The " + typ + " is already suppressed.",
+		})
+		return
+	}
+
+	s := &entities.Suppression{
+		UserID: u.ID,
+		Type:   typ,
+		Value:  value,
+		Reason: body.Reason,
+		Source: body.Source,
+	}
+	if s.Reason == "" {
+		s.Reason = entities.SuppressionReasonManual
+	}
+	if s.Source == "" {
+		s.Source = entities.SuppressionSourceAPI
+	}
+
+	if err := storage.CreateSuppression(c, s); err != nil {
+		logger.From(c).WithError(err).Warn("Unable to create suppression.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to create suppression.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+func DeleteSuppression(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+
+	_, err = storage.GetSuppression(c, id, u.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Suppression not found.",
+		})
+		return
+	}
+
+	err = storage.DeleteSuppression(c, id, u.ID)
+	if err != nil {
+		logger.From(c).WithError(err).WithField("suppression_id", id).Error("Unable to delete suppression.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to delete suppression.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ImportSuppressions adds the emails and the domains of the uploaded CSV file to the suppression
+// list, see suppressions.Service.ImportFromFile for the format of the file.
+func ImportSuppressions(c *gin.Context) {
+	u := middleware.GetUser(c)
+
+	body := &params.ImportSuppressions{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := blobs.GetFromContext(c).Get(os.Getenv("FILES_BUCKET"), suppressionsImportKey(u.ID, body.Filename))
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) || errors.Is(err, blobs.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Unable to import suppressions, the file was not found.",
+			})
+			return
+		}
+		logger.From(c).WithError(err).Warn("Import suppressions: unable to get the file.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to import suppressions. Please try again.",
+		})
+		return
+	}
+	defer res.Body.Close()
+
+	result, err := suppressions.New(storage.GetFromContext(c)).ImportFromFile(c, u.ID, res.Body)
+	if err != nil {
+		if errors.Is(err, suppressions.ErrInvalidFile) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"filename": "The file is not a valid CSV file.",
+				},
+			})
+			return
+		}
+		logger.From(c).WithFields(logrus.Fields{
+			"filename": body.Filename,
+		}).WithError(err).Error("Unable to import suppressions.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to import suppressions. Please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func ExportSuppressions(c *gin.Context) {
+	exportReport(c, entities.SuppressionsResource)
+}
+
+func DownloadSuppressionsReport(c *gin.Context) {
+	downloadReport(c, entities.SuppressionsResource)
+}
+
+// suppress adds the email to the suppression list of the user, the failures are only logged
+// since the suppressions are added along with the other changes of the hooks.
+func suppress(c *gin.Context, userID int64, email, reason, source string) {
+	err := suppressions.New(storage.GetFromContext(c)).Suppress(userID, email, reason, source)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id": userID,
+			"email":   email,
+			"reason":  reason,
+		}).WithError(err).Error("Unable to suppress email.")
+	}
+}
+
+func suppressionsImportKey(userID int64, filename string) string {
+	return fmt.Sprintf("suppressions/import/%d/%s", userID, filename)
+}
@@ -0,0 +1,228 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/storage"
+)
+
+func TestSuppressions(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	dir, err := ioutil.TempDir("", "suppressions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Setenv("FILES_BUCKET", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("FILES_BUCKET")
+
+	e := setupWithBlobs(t, s, fs)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.FailNow()
+	}
+
+	// test post suppression unauthorized
+	e.POST("/api/suppressions").WithFormField("value", "jo@example.com").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test binding on post suppression
+	auth.POST("/api/suppressions").
+		WithFormField("value", "jo@example.com").
+		WithFormField("reason", "spam").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"reason": "Must be one of: bounce complaint manual unsubscribed",
+		})
+
+	auth.POST("/api/suppressions").WithFormField("value", "not a domain").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"value": "The value is neither an email nor a domain.",
+		})
+
+	// test post suppressions
+	id := auth.POST("/api/suppressions").WithFormField("value", " Jo@Example.com ").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("type", entities.SuppressionTypeEmail).
+		ValueEqual("value", "jo@example.com").
+		ValueEqual("reason", entities.SuppressionReasonManual).
+		ValueEqual("source", entities.SuppressionSourceAPI).
+		Value("id").Number().Raw()
+
+	auth.POST("/api/suppressions").
+		WithFormField("value", "@spam.example.org").
+		WithFormField("reason", "complaint").
+		WithFormField("source", "support ticket").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("type", entities.SuppressionTypeDomain).
+		ValueEqual("value", "spam.example.org").
+		ValueEqual("source", "support ticket")
+
+	auth.POST("/api/suppressions").WithFormField("value", "jo@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "The email is already suppressed.")
+
+	// test get suppressions
+	auth.GET("/api/suppressions").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 2)
+
+	auth.GET("/api/suppressions").WithQuery("scopes[type]", "domain").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1).
+		Value("collection").Array().Element(0).Object().
+		ValueEqual("value", "spam.example.org")
+
+	auth.GET("/api/suppressions").WithQuery("scopes[value]", "JO@").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("total", 1)
+
+	// test the suppressed emails are not created
+	auth.POST("/api/subscribers").
+		WithFormField("email", "jo@example.com").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"email": "The email is on the suppression list (manual).",
+		})
+
+	auth.POST("/api/subscribers").
+		WithFormField("email", "ana@SPAM.example.org").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"email": "The email is on the suppression list (complaint).",
+		})
+
+	// test the suppressed emails are not imported
+	svc := subscribers.New(fs, s, subscribers.EmailValidator(newTestEmailValidator()))
+	res, err := svc.ImportSubscribersFromFile(
+		context.Background(),
+		u.ID,
+		nil,
+		strings.NewReader("email\njo@example.com\nbo@example.com\nana@spam.example.org\n"),
+		subscribers.ImportOptions{},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Created)
+	assert.Equal(t, 2, res.Rejected)
+	assert.Equal(t, "The email is on the suppression list (manual).", res.RejectedRows[0].Reason)
+	assert.Equal(t, 2, res.RejectedRows[0].Row)
+	assert.Equal(t, "The email is on the suppression list (complaint).", res.RejectedRows[1].Reason)
+
+	// test import suppressions
+	file := "Email,Reason\n" +
+		"bo@example.com,bounce\n" +
+		"jo@example.com\n" +
+		"BO@example.com,complaint\n" +
+		"not an email,manual\n" +
+		"@other.example.org,spam\n" +
+		"@other.example.org,unsubscribed\n"
+	err = fs.Put("files", fmt.Sprintf("suppressions/import/%d/list.csv", u.ID), bytes.NewReader([]byte(file)), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth.POST("/api/suppressions/import").WithFormField("filename", "missing.csv").
+		Expect().
+		Status(http.StatusNotFound)
+
+	obj := auth.POST("/api/suppressions/import").WithFormField("filename", "list.csv").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	obj.ValueEqual("created", 2)
+	obj.ValueEqual("skipped", 2)
+	obj.ValueEqual("rejected", 2)
+	obj.Value("rejected_rows").Array().Element(0).Object().
+		ValueEqual("row", 5).
+		ValueEqual("reason", "The value is neither an email nor a domain.")
+	obj.Value("rejected_rows").Array().Element(1).Object().
+		ValueEqual("row", 6).
+		ValueEqual("reason", "The reason must be one of: bounce complaint manual unsubscribed.")
+
+	sup, err := s.GetSuppressionForEmail(u.ID, "bo@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionReasonBounce, sup.Reason)
+	assert.Equal(t, entities.SuppressionSourceImport, sup.Source)
+
+	sup, err = s.GetSuppressionForEmail(u.ID, "jo@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionSourceAPI, sup.Source)
+
+	// test delete suppression
+	idStr := strconv.FormatInt(int64(id), 10)
+	auth.DELETE("/api/suppressions/" + idStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.DELETE("/api/suppressions/" + idStr).
+		Expect().
+		Status(http.StatusNotFound)
+
+	_, err = s.GetSuppressionForEmail(u.ID, "jo@example.com")
+	assert.NotNil(t, err)
+
+	// test export suppressions
+	filename := auth.POST("/api/suppressions/export").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("resource", "suppressions").
+		Value("file_name").String().Raw()
+
+	auth.GET("/api/subscribers/export/download").WithQuery("filename", filename).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Report not found.")
+}
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -9,13 +10,13 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/logger"
 	"github.com/mailbadger/app/routes/middleware"
 	templatesvc "github.com/mailbadger/app/services/templates"
 	"github.com/mailbadger/app/storage"
-	"github.com/mailbadger/app/storage/s3"
 	"github.com/mailbadger/app/validator"
 )
 
@@ -29,7 +30,7 @@ func GetTemplate(c *gin.Context) {
 	}
 
 	u := middleware.GetUser(c)
-	service := templatesvc.New(storage.GetFromContext(c), s3.GetFromContext(c))
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
 
 	template, err := service.GetTemplate(c, id, u.ID)
 	if err != nil {
@@ -61,6 +62,60 @@ func GetTemplate(c *gin.Context) {
 	c.JSON(http.StatusOK, template)
 }
 
+func GetTemplateVariables(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return
+	}
+
+	u := middleware.GetUser(c)
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+
+	template, err := service.GetTemplate(c, id, u.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Template not found.",
+			})
+		case errors.Is(err, templatesvc.ErrHTMLPartNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "HTML part not found.",
+			})
+		case errors.Is(err, templatesvc.ErrHTMLPartInvalidState):
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "The state of the HTML part is invalid.",
+			})
+		default:
+			logger.From(c).WithFields(logrus.Fields{
+				"user_id":     u.ID,
+				"template_id": id,
+			}).WithError(err).Errorf("Unable to get template")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to get template",
+			})
+		}
+		return
+	}
+
+	vars, err := service.GetTemplateVariables(c, template)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id":     u.ID,
+			"template_id": id,
+		}).WithError(err).Error("Unable to get template variables")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to get template variables",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, vars)
+}
+
 func GetTemplates(c *gin.Context) {
 	u := middleware.GetUser(c)
 
@@ -84,7 +139,7 @@ func GetTemplates(c *gin.Context) {
 
 	scopeMap := c.QueryMap("scopes")
 
-	s := templatesvc.New(storage.GetFromContext(c), s3.GetFromContext(c))
+	s := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
 	err := s.GetTemplates(c, u.ID, p, scopeMap)
 	if err != nil {
 		logger.From(c).WithFields(logrus.Fields{
@@ -102,7 +157,7 @@ func GetTemplates(c *gin.Context) {
 
 func PostTemplate(c *gin.Context) {
 	u := middleware.GetUser(c)
-	service := templatesvc.New(storage.GetFromContext(c), s3.GetFromContext(c))
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
 
 	body := &params.PostTemplate{}
 	if err := c.ShouldBind(body); err != nil {
@@ -126,6 +181,11 @@ func PostTemplate(c *gin.Context) {
 		HTMLPart: body.HTMLPart,
 		TextPart: body.TextPart,
 	}
+	template.DisableCSSInlining = body.DisableCSSInlining
+	template.Engine = body.Engine
+	if body.LayoutID != 0 {
+		template.LayoutID = &body.LayoutID
+	}
 
 	_, err := storage.GetTemplateByName(c, template.Name, u.ID)
 	if err == nil {
@@ -135,6 +195,12 @@ func PostTemplate(c *gin.Context) {
 		return
 	}
 
+	if body.Lint {
+		if ok := lintTemplate(c, service, template, "Unable to create template, the template has lint errors"); !ok {
+			return
+		}
+	}
+
 	err = service.AddTemplate(c, template)
 	if err != nil {
 		switch {
@@ -150,6 +216,22 @@ func PostTemplate(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "Unable to create template, failed to parse subject_part",
 			})
+		case errors.Is(err, templatesvc.ErrPartialNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to create template, one of the included partials does not exist",
+			})
+		case errors.Is(err, templatesvc.ErrPartialCycle):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to create template, the included partials include each other",
+			})
+		case errors.Is(err, templatesvc.ErrLayoutNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to create template, layout not found",
+			})
+		case errors.Is(err, templatesvc.ErrInvalidLayout):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to create template, the layout must contain the content tag, use the same engine and cannot be wrapped in another layout",
+			})
 		default:
 			logger.From(c).WithFields(logrus.Fields{
 				"template": template,
@@ -167,7 +249,7 @@ func PostTemplate(c *gin.Context) {
 
 func PutTemplate(c *gin.Context) {
 	u := middleware.GetUser(c)
-	service := templatesvc.New(storage.GetFromContext(c), s3.GetFromContext(c))
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
 
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -209,6 +291,20 @@ func PutTemplate(c *gin.Context) {
 	template.HTMLPart = body.HTMLPart
 	template.TextPart = body.TextPart
 	template.SubjectPart = body.SubjectPart
+	template.DisableCSSInlining = body.DisableCSSInlining
+	if body.Engine != "" {
+		template.Engine = body.Engine
+	}
+	template.LayoutID = nil
+	if body.LayoutID != 0 {
+		template.LayoutID = &body.LayoutID
+	}
+
+	if body.Lint {
+		if ok := lintTemplate(c, service, template, "Unable to update template, the template has lint errors"); !ok {
+			return
+		}
+	}
 
 	err = service.UpdateTemplate(c, template)
 	if err != nil {
@@ -225,6 +321,22 @@ func PutTemplate(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "Unable to update template, failed to parse subject_part",
 			})
+		case errors.Is(err, templatesvc.ErrPartialNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to update template, one of the included partials does not exist",
+			})
+		case errors.Is(err, templatesvc.ErrPartialCycle):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to update template, the included partials include each other",
+			})
+		case errors.Is(err, templatesvc.ErrLayoutNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to update template, layout not found",
+			})
+		case errors.Is(err, templatesvc.ErrInvalidLayout):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to update template, the layout must contain the content tag, use the same engine and cannot be wrapped in another layout",
+			})
 		default:
 			logger.From(c).WithFields(logrus.Fields{
 				"template": template,
@@ -250,10 +362,17 @@ func DeleteTemplate(c *gin.Context) {
 	}
 
 	u := middleware.GetUser(c)
-	service := templatesvc.New(storage.GetFromContext(c), s3.GetFromContext(c))
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
 
 	err = service.DeleteTemplate(c, id, u.ID)
 	if err != nil {
+		if errors.Is(err, templatesvc.ErrLayoutInUse) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete template, it is used as a layout by other templates.",
+			})
+			return
+		}
+
 		logger.From(c).WithFields(logrus.Fields{
 			"user_id":     u.ID,
 			"template_id": id,
@@ -266,3 +385,253 @@ func DeleteTemplate(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+func PostLintTemplate(c *gin.Context) {
+	u := middleware.GetUser(c)
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+
+	body := &params.LintTemplate{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	template := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      u.ID,
+			Name:        body.Name,
+			SubjectPart: body.SubjectPart,
+			Engine:      body.Engine,
+		},
+		HTMLPart: body.HTMLPart,
+		TextPart: body.TextPart,
+	}
+	if body.LayoutID != 0 {
+		template.LayoutID = &body.LayoutID
+	}
+
+	report, err := service.LintTemplate(c, template)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id": u.ID,
+		}).WithError(err).Error("Unable to lint template.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to lint template, please try again.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func PostTextPart(c *gin.Context) {
+	u := middleware.GetUser(c)
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+
+	body := &params.PostTextPart{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	template := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID: u.ID,
+			Name:   body.Name,
+			Engine: body.Engine,
+		},
+		HTMLPart: body.HTMLPart,
+	}
+	if body.LayoutID != 0 {
+		template.LayoutID = &body.LayoutID
+	}
+
+	links := body.Links
+	if links == "" {
+		links = templatesvc.LinksFootnotes
+	}
+
+	text, err := service.GenerateTextPart(c, template, links)
+	if err != nil {
+		switch {
+		case errors.Is(err, templatesvc.ErrPartialNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to generate text part, one of the included partials does not exist",
+			})
+		case errors.Is(err, templatesvc.ErrPartialCycle):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to generate text part, the included partials include each other",
+			})
+		case errors.Is(err, templatesvc.ErrLayoutNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to generate text part, layout not found",
+			})
+		default:
+			logger.From(c).WithFields(logrus.Fields{
+				"user_id": u.ID,
+			}).WithError(err).Error("Unable to generate text part.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to generate text part, please try again.",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"text_part": text,
+	})
+}
+
+func PostImportTemplate(c *gin.Context) {
+	u := middleware.GetUser(c)
+	service := templatesvc.New(storage.GetFromContext(c), blobs.GetFromContext(c))
+
+	body := &params.ImportTemplate{}
+	if err := c.ShouldBind(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again",
+		})
+		return
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	_, err := storage.GetTemplateByName(c, body.Name, u.ID)
+	if err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Template with that name already exists",
+		})
+		return
+	}
+
+	template := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:             u.ID,
+			Name:               body.Name,
+			SubjectPart:        body.SubjectPart,
+			DisableCSSInlining: body.DisableCSSInlining,
+			Engine:             body.Engine,
+		},
+		TextPart: body.TextPart,
+	}
+	if body.LayoutID != 0 {
+		template.LayoutID = &body.LayoutID
+	}
+
+	err = service.ImportTemplate(c, template, templateImportKey(u.ID, body.Filename))
+	if err != nil {
+		switch {
+		case errors.Is(err, templatesvc.ErrBundleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Unable to import template, the file was not found",
+			})
+		case errors.Is(err, templatesvc.ErrInvalidBundle):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the file is not a valid zip archive",
+			})
+		case errors.Is(err, templatesvc.ErrBundleTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the zip archive is too large",
+			})
+		case errors.Is(err, templatesvc.ErrBundleUnsafePath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the zip archive contains files with unsafe paths",
+			})
+		case errors.Is(err, templatesvc.ErrBundleMissingIndex):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the zip archive does not contain index.html",
+			})
+		case errors.Is(err, templatesvc.ErrBundleMissingSubject):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, index.html has no title and subject_part is empty",
+			})
+		case errors.Is(err, templatesvc.ErrParseHTMLPart):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, failed to parse index.html",
+			})
+		case errors.Is(err, templatesvc.ErrParseTextPart):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, failed to parse text_part",
+			})
+		case errors.Is(err, templatesvc.ErrParseSubjectPart):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, failed to parse subject_part",
+			})
+		case errors.Is(err, templatesvc.ErrPartialNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, one of the included partials does not exist",
+			})
+		case errors.Is(err, templatesvc.ErrPartialCycle):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the included partials include each other",
+			})
+		case errors.Is(err, templatesvc.ErrLayoutNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, layout not found",
+			})
+		case errors.Is(err, templatesvc.ErrInvalidLayout):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import template, the layout must contain the content tag, use the same engine and cannot be wrapped in another layout",
+			})
+		default:
+			logger.From(c).WithFields(logrus.Fields{
+				"filename": body.Filename,
+				"user_id":  u.ID,
+			}).WithError(err).Error("Unable to import template")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to import template, please try again.",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// templateImportKey returns the key of the template bundle uploaded to the files bucket.
+func templateImportKey(userID int64, filename string) string {
+	return fmt.Sprintf("templates/import/%d/%s", userID, filename)
+}
+
+// lintTemplate writes the error response and returns false when the linter
+// finds errors in the template or fails to lint it.
+func lintTemplate(c *gin.Context, service templatesvc.Service, template *entities.Template, message string) bool {
+	report, err := service.LintTemplate(c, template)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"template": template.Name,
+			"user_id":  template.UserID,
+		}).WithError(err).Error("Unable to lint template.")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to lint template, please try again.",
+		})
+		return false
+	}
+
+	if report.HasErrors() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": message,
+			"lint":    report,
+		})
+		return false
+	}
+
+	return true
+}
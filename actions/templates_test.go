@@ -1,17 +1,22 @@
 package actions_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/storage"
 	s3mock "github.com/mailbadger/app/storage/s3"
@@ -64,7 +69,6 @@ func TestTemplates(t *testing.T) {
 			"html_part":    "This field is required",
 			"name":         "This field is required",
 			"subject_part": "This field is required",
-			"text_part":    "This field is required",
 		})
 
 	// TODO fix test for html validation
@@ -133,7 +137,9 @@ func TestTemplates(t *testing.T) {
 		SubjectPart: "hello {{.name}}",
 	}).Expect().
 		Status(http.StatusCreated).
-		JSON().Object().Value("id")
+		JSON().Object().
+		ValueEqual("engine", "mustache").
+		Value("id")
 
 	// test post template with name that exists
 	auth.POST("/api/templates").WithForm(params.PostTemplate{
@@ -146,6 +152,18 @@ func TestTemplates(t *testing.T) {
 		JSON().Object().
 		ValueEqual("message", "Template with that name already exists")
 
+	// test failed to parse html part with the go engine
+	auth.POST("/api/templates").WithForm(params.PostTemplate{
+		Name:        "template 4",
+		HTMLPart:    "<span>{{if .vip}}vip</span>",
+		TextPart:    "{{if .vip}}vip{{end}}",
+		SubjectPart: "hello {{.name}}",
+		Engine:      "go",
+	}).Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to create template, failed to parse html_part")
+
 	idStr := strconv.FormatFloat(id.Raw().(float64), 'f', 0, 64)
 
 	// test invalid parameters on put template
@@ -162,7 +180,6 @@ func TestTemplates(t *testing.T) {
 			"html_part":    "This field is required",
 			"name":         "This field is required",
 			"subject_part": "This field is required",
-			"text_part":    "This field is required",
 		})
 
 	// test put template for non existing template
@@ -343,3 +360,498 @@ func TestTemplates(t *testing.T) {
 		Expect().
 		Status(http.StatusNoContent)
 }
+
+func TestLintTemplate(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3mock.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test lint template unauthorized
+	e.POST("/api/templates/lint").WithForm(params.LintTemplate{HTMLPart: "<p>hello</p>"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test binding on lint template
+	auth.POST("/api/templates/lint").WithForm(params.LintTemplate{}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"html_part": "This field is required",
+		})
+
+	// test lint valid template
+	report := auth.POST("/api/templates/lint").WithForm(params.LintTemplate{
+		HTMLPart:    `<div><img src="logo.png" alt="Logo"><p>Hello {{name}}<br><a href="{{unsubscribe_url}}">Unsubscribe</a></div>`,
+		TextPart:    "Hello {{name}}, unsubscribe: {{unsubscribe_url}}",
+		SubjectPart: "Hello {{name}}",
+	}).Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	report.Value("errors").Array().Empty()
+	report.Value("warnings").Array().Empty()
+
+	// test lint template with issues
+	report = auth.POST("/api/templates/lint").WithForm(params.LintTemplate{
+		HTMLPart: `<div><span>Hello</div></b><img src="logo.png"><form action="/"></form>` +
+			`<a href="javascript:alert(1)">click</a><script>alert(1)</script>`,
+		TextPart:    " ",
+		SubjectPart: strings.Repeat("a", 61),
+	}).Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	report.Value("errors").Array().Equal([]map[string]string{
+		{"rule": "unclosed_tag", "part": "html_part", "message": "The <span> tag is not closed."},
+		{"rule": "malformed_html", "part": "html_part", "message": "The closing </b> tag has no matching opening tag."},
+		{"rule": "javascript_link", "part": "html_part", "message": "The <a> tag links to javascript, such links are removed by email clients."},
+		{"rule": "script", "part": "html_part", "message": "Scripts are removed by email clients."},
+		{"rule": "missing_unsubscribe_url", "part": "html_part", "message": "The HTML part does not contain the {{unsubscribe_url}} tag."},
+	})
+	report.Value("warnings").Array().Equal([]map[string]string{
+		{"rule": "image_alt", "part": "html_part", "message": `The image "logo.png" has no alt text.`},
+		{"rule": "form", "part": "html_part", "message": "Forms are removed or disabled by most email clients."},
+		{"rule": "empty_text_part", "part": "text_part", "message": "The text part is empty, it will be generated from the HTML part."},
+		{"rule": "subject_length", "part": "subject_part", "message": "The subject is 61 characters long, most email clients truncate subjects longer than 60 characters."},
+	})
+
+	// test lint html size and unsubscribe url included through a snippet
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: `<a href="{{unsubscribe_url}}">Unsubscribe</a>`}).
+		Expect().
+		Status(http.StatusCreated)
+
+	report = auth.POST("/api/templates/lint").WithForm(params.LintTemplate{
+		HTMLPart:    "<p>" + strings.Repeat("a", 102*1024) + "</p>{{> footer}}",
+		TextPart:    "Hello, unsubscribe: {{unsubscribe_url}}",
+		SubjectPart: "Hello",
+	}).Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	report.Value("errors").Array().Empty()
+	report.Value("warnings").Array().Equal([]map[string]string{
+		{"rule": "html_size", "part": "html_part", "message": "The HTML is 102KB, Gmail clips messages larger than 102KB."},
+	})
+
+	// test post template with lint errors
+	auth.POST("/api/templates").WithForm(params.PostTemplate{
+		Name:        "template",
+		HTMLPart:    "<div>hello</div>",
+		TextPart:    "hello {{unsubscribe_url}}",
+		SubjectPart: "hello",
+		Lint:        true,
+	}).Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to create template, the template has lint errors").
+		Value("lint").Object().
+		Value("errors").Array().Length().Equal(1)
+
+	// test binding on lint template with unknown engine
+	auth.POST("/api/templates/lint").WithForm(params.LintTemplate{HTMLPart: "<p>hello</p>", Engine: "handlebars"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"engine": "Must be one of: mustache go",
+		})
+
+	// test lint go engine template
+	report = auth.POST("/api/templates/lint").WithForm(params.LintTemplate{
+		HTMLPart:    `<p>Hello {{.name | default "there" | upper}}</p><a href="{{.unsubscribe_url}}">Unsubscribe</a>`,
+		TextPart:    "Hello {{.name}}, unsubscribe: {{.unsubscribe_url}}",
+		SubjectPart: "Hello {{.name",
+		Engine:      "go",
+	}).Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	report.Value("errors").Array().Length().Equal(1)
+	report.Value("errors").Array().Element(0).Object().
+		ValueEqual("rule", "template_syntax").
+		ValueEqual("part", "subject_part")
+}
+
+func TestTextPart(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	e := setup(t, s, new(s3mock.MockS3Client))
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test generate text part unauthorized
+	e.POST("/api/templates/text").WithForm(params.PostTextPart{HTMLPart: "<p>hello</p>"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test binding on generate text part
+	auth.POST("/api/templates/text").WithForm(params.PostTextPart{Links: "bottom"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"html_part": "This field is required",
+			"links":     "Must be one of: footnotes inline",
+		})
+
+	// test missing partial
+	auth.POST("/api/templates/text").WithForm(params.PostTextPart{HTMLPart: "<p>hello</p>{{> footer}}"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to generate text part, one of the included partials does not exist")
+
+	auth.POST("/api/snippets").WithForm(params.Snippet{Name: "footer", Body: `<p><a href="{{unsubscribe_url}}">Unsubscribe</a></p>`}).
+		Expect().
+		Status(http.StatusCreated)
+
+	html := `<h1>Hello {{name}}</h1><p>Check out <a href="https://example.com">our site</a>.</p>` +
+		`<ul><li>one</li><li>two</li></ul>{{> footer}}`
+
+	// test generate text part with footnotes
+	auth.POST("/api/templates/text").WithForm(params.PostTextPart{HTMLPart: html}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("text_part", "Hello {{name}}\n==============\n\nCheck out our site [1].\n\n* one\n* two\n\n"+
+			"Unsubscribe [2]\n\nLinks:\n[1] https://example.com\n[2] {{unsubscribe_url}}")
+
+	// test generate text part with inline links
+	auth.POST("/api/templates/text").WithForm(params.PostTextPart{HTMLPart: html, Links: "inline"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("text_part", "Hello {{name}}\n==============\n\nCheck out our site (https://example.com).\n\n* one\n* two\n\n"+
+			"Unsubscribe ({{unsubscribe_url}})")
+}
+
+func TestImportTemplate(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	err := os.Setenv("ASSETS_URL", "https://assets.example.com")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.Unsetenv("ASSETS_URL")
+
+	index := `<html><head><title>Spring   sale</title><style>.hero { background: url('images/bg.png') }</style></head>` +
+		`<body><table background="images/bg.png"><tr><td><img src="images/logo.png" alt="Logo">` +
+		`<img src="https://cdn.example.com/x.png" alt="X"><a href="{{unsubscribe_url}}">Unsubscribe</a></td></tr></table></body></html>`
+
+	valid := zipBundle(t, map[string]string{
+		"spring/index.html":      index,
+		"spring/images/logo.png": "logo",
+		"spring/images/bg.png":   "bg",
+		"spring/notes.txt":       "notes",
+	})
+	traversal := zipBundle(t, map[string]string{
+		"index.html":  index,
+		"../evil.png": "evil",
+	})
+	noIndex := zipBundle(t, map[string]string{
+		"images/logo.png": "logo",
+	})
+
+	bundle := func(data []byte) *s3.GetObjectOutput {
+		return &s3.GetObjectOutput{
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: aws.Int64(int64(len(data))),
+		}
+	}
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(nil, awserr.New(s3.ErrCodeNoSuchKey, "no such key", errors.New("key not found")))
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(bundle([]byte("not a zip")), nil)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(&s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(strings.NewReader("")),
+		ContentLength: aws.Int64(100 << 20),
+	}, nil)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(bundle(traversal), nil)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(bundle(noIndex), nil)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(bundle(valid), nil)
+	// the two images and the html part of the template.
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Times(3).Return(&s3.PutObjectOutput{}, nil)
+
+	e := setup(t, s, mockS3)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// test import template unauthorized
+	e.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test binding on import template
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "../spring.zip"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"filename": `Must not contain any of: /\`,
+			"name":     "This field is required",
+		})
+
+	// test bundle not found
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Unable to import template, the file was not found")
+
+	// test invalid zip archive
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to import template, the file is not a valid zip archive")
+
+	// test oversized zip archive
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to import template, the zip archive is too large")
+
+	// test path traversal entry
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to import template, the zip archive contains files with unsafe paths")
+
+	// test missing index.html
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("message", "Unable to import template, the zip archive does not contain index.html")
+
+	// test import template
+	obj := auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("name", "spring").
+		ValueEqual("subject_part", "Spring sale")
+
+	htmlPart := obj.Value("html_part").String()
+	htmlPart.Contains(`<img src="https://assets.example.com/assets/`)
+	htmlPart.Contains(`/spring/images/logo.png" alt="Logo">`)
+	htmlPart.Contains(`/spring/images/bg.png')`)
+	htmlPart.Contains(`<img src="https://cdn.example.com/x.png" alt="X">`)
+	htmlPart.NotContains(`"images/bg.png"`)
+
+	// test import template with name that exists
+	auth.POST("/api/templates/import").WithForm(params.ImportTemplate{Filename: "spring.zip", Name: "spring"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Template with that name already exists")
+
+	mockS3.AssertExpectations(t)
+}
+
+// zipBundle creates a ZIP archive with the given files.
+func zipBundle(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSampleDataSets(t *testing.T) {
+	s := storage.New("sqlite3", ":memory:")
+
+	html := `<p>Hi {{name}}, use {{discount}}</p><a href="{{unsubscribe_url}}">Unsubscribe</a>`
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(&s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(html)),
+	}, nil)
+	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(&s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(html)),
+	}, nil)
+
+	e := setup(t, s, mockS3)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	u, err := s.GetUserByUsername("john")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	template := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      u.ID,
+			Name:        "discount",
+			SubjectPart: "{{discount}} off",
+		},
+		TextPart: "Hi {{name}}, use {{discount}}",
+	}
+	err = s.CreateTemplate(template)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	subscribers := []entities.Subscriber{
+		{UserID: u.ID, Email: "jo@example.com", Name: "Jo", Active: true, MetaJSON: entities.JSON(`{"discount":"SAVE10"}`)},
+		{UserID: u.ID, Email: "al@example.com", Active: true, MetaJSON: entities.JSON(`{"name":"Al"}`)},
+		{UserID: u.ID, Email: "ex@example.com", Active: false},
+	}
+	for i := range subscribers {
+		err = s.CreateSubscriber(&subscribers[i])
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	id := strconv.FormatInt(template.ID, 10)
+
+	// test get template variables unauthorized
+	e.GET("/api/templates/" + id + "/variables").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// test get template variables
+	vars := auth.GET("/api/templates/" + id + "/variables").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	vars.ValueEqual("total_subscribers", 2)
+	col := vars.Value("collection").Array()
+	col.Length().Equal(3)
+	col.Element(0).Object().
+		ValueEqual("name", "discount").
+		ValueEqual("parts", []string{"subject_part", "html_part", "text_part"}).
+		ValueEqual("built_in", false).
+		ValueEqual("missing_subscribers", 1)
+	col.Element(1).Object().
+		ValueEqual("name", "name").
+		ValueEqual("built_in", true).
+		ValueEqual("missing_subscribers", 0)
+	col.Element(2).Object().
+		ValueEqual("name", "unsubscribe_url").
+		ValueEqual("missing_subscribers", 0)
+
+	// test template not found
+	auth.GET("/api/templates/1000/sample-data-sets").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Template not found.")
+
+	// test binding on post sample data set
+	auth.POST("/api/templates/"+id+"/sample-data-sets").
+		WithFormField("data[bad key]", "foo").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"name":          "This field is required",
+			"data[bad key]": "Must consist only of alphanumeric and hyphen characters",
+		})
+
+	// test post sample data set
+	set := auth.POST("/api/templates/"+id+"/sample-data-sets").
+		WithFormField("name", "with discount").
+		WithFormField("data[discount]", "SAVE10").
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+
+	set.ValueEqual("name", "with discount").
+		ValueEqual("data", map[string]string{"discount": "SAVE10"})
+	setID := strconv.FormatInt(int64(set.Value("id").Number().Raw()), 10)
+
+	auth.POST("/api/templates/"+id+"/sample-data-sets").
+		WithFormField("name", "with discount").
+		Expect().
+		Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		ValueEqual("message", "Sample data set with that name already exists.")
+
+	// test put sample data set
+	auth.PUT("/api/templates/"+id+"/sample-data-sets/"+setID).
+		WithFormField("name", "discount").
+		WithFormField("data[discount]", "SAVE20").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("name", "discount").
+		ValueEqual("data", map[string]string{"discount": "SAVE20"})
+
+	auth.PUT("/api/templates/"+id+"/sample-data-sets/1000").
+		WithFormField("name", "discount").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Sample data set not found.")
+
+	// test preview template with the sample data set
+	auth.GET("/api/templates/"+id+"/preview").
+		WithQuery("sample_data_set_id", setID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("subject_part", "SAVE20 off").
+		ValueEqual("html_part", `<p>Hi , use SAVE20</p><a href="">Unsubscribe</a>`).
+		ValueEqual("text_part", "Hi , use SAVE20")
+
+	auth.GET("/api/templates/"+id+"/preview").
+		WithQuery("sample_data_set_id", 1000).
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Sample data set not found.")
+
+	// test get sample data sets
+	auth.GET("/api/templates/" + id + "/sample-data-sets").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Length().Equal(1)
+
+	// test delete sample data set
+	auth.DELETE("/api/templates/" + id + "/sample-data-sets/" + setID).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/templates/" + id + "/sample-data-sets").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array().Empty()
+}
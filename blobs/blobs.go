@@ -0,0 +1,134 @@
+// Package blobs stores the files of the app, such as the HTML parts of the templates,
+// the imported and exported subscriber lists and the template assets, either in S3
+// or on the local filesystem.
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mailbadger/app/s3"
+)
+
+// Names of the supported backends.
+const (
+	S3         = "s3"
+	Filesystem = "filesystem"
+)
+
+// PublicPrefix is the prefix of the keys which can be read without a signed url,
+// e.g. the images of the imported templates which are linked from the e-mails.
+const PublicPrefix = "assets/"
+
+var (
+	ErrNotFound       = errors.New("blob not found")
+	ErrUnavailable    = errors.New("blob is not available")
+	ErrInvalidKey     = errors.New("invalid blob key")
+	ErrUnknownBackend = errors.New("unknown blob storage backend")
+)
+
+// Object is a stored file.
+type Object struct {
+	Body io.ReadCloser
+	// Size is the size of the body in bytes, or -1 when it is not known.
+	Size int64
+}
+
+// PutOptions are the optional attributes of a stored file.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+}
+
+// Store reads and writes the files by bucket and key.
+type Store interface {
+	// Get opens the file, the caller must close its body.
+	Get(bucket, key string) (*Object, error)
+	// Put creates or replaces the file with the contents of the body.
+	Put(bucket, key string, body io.ReadSeeker, opts PutOptions) error
+	// Delete removes the file, deleting a file which does not exist is not an error.
+	Delete(bucket, key string) error
+	// SignPut returns an url the file can be uploaded to with a PUT request with
+	// the given content type, without credentials until the url expires.
+	SignPut(bucket, key, contentType string, expires time.Duration) (string, error)
+	// SignGet returns an url the file can be downloaded from without credentials until the url expires.
+	SignGet(bucket, key string, expires time.Duration) (string, error)
+}
+
+// Config holds the settings of the backends.
+type Config struct {
+	// S3 credentials, when they are empty the default credential chain is used.
+	AccessKey string
+	SecretKey string
+	Region    string
+
+	// Dir is the root directory of the filesystem backend.
+	Dir string
+	// URL is the base url of the handler serving the signed urls of the filesystem backend.
+	URL string
+	// Secret is the key the urls of the filesystem backend are signed with.
+	Secret string
+	// AssetsBucket is the bucket of the public template assets, which the
+	// filesystem backend serves without a signature.
+	AssetsBucket string
+}
+
+// MakeConfigFromEnv creates the config of the backends from env variables.
+func MakeConfigFromEnv() Config {
+	assetsBucket := os.Getenv("ASSETS_BUCKET")
+	if assetsBucket == "" {
+		assetsBucket = os.Getenv("FILES_BUCKET")
+	}
+
+	return Config{
+		AccessKey:    os.Getenv("AWS_S3_ACCESS_KEY"),
+		SecretKey:    os.Getenv("AWS_S3_SECRET_KEY"),
+		Region:       os.Getenv("AWS_S3_REGION"),
+		Dir:          os.Getenv("BLOB_STORAGE_DIR"),
+		URL:          strings.TrimRight(os.Getenv("APP_URL"), "/") + "/api/blobs",
+		Secret:       os.Getenv("BLOB_STORAGE_SECRET"),
+		AssetsBucket: assetsBucket,
+	}
+}
+
+// New creates the store of the given backend. Files are stored in S3 when the backend is empty.
+func New(backend string, conf Config) (Store, error) {
+	switch backend {
+	case "", S3:
+		client, err := s3.NewS3Client(conf.AccessKey, conf.SecretKey, conf.Region)
+		if err != nil {
+			return nil, fmt.Errorf("new s3 client: %w", err)
+		}
+		return NewS3(client), nil
+	case Filesystem:
+		fs, err := NewFilesystem(conf.Dir, conf.URL, conf.Secret, conf.AssetsBucket)
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	default:
+		return nil, fmt.Errorf("%s: %w", backend, ErrUnknownBackend)
+	}
+}
+
+// validKey checks that the bucket and key can be used as paths without leaving the root directory.
+func validKey(bucket, key string) bool {
+	if bucket == "" || strings.ContainsAny(bucket, "/\\\x00") || bucket == "." || bucket == ".." {
+		return false
+	}
+	if key == "" || strings.ContainsAny(key, "\\\x00") || strings.HasPrefix(key, "/") {
+		return false
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
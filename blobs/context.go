@@ -0,0 +1,19 @@
+package blobs
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+const key = "blobs"
+
+// SetToContext sets the blob store to the context
+func SetToContext(c *gin.Context, store Store) {
+	c.Set(key, store)
+}
+
+// GetFromContext returns the blob store associated with the context
+func GetFromContext(c context.Context) Store {
+	return c.Value(key).(Store)
+}
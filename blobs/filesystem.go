@@ -0,0 +1,225 @@
+package blobs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// FilesystemStore stores the files in a directory, each bucket is a subdirectory of the
+// root and the slashes in the keys are directories, e.g. files-bucket/subscribers/import/1/list.csv.
+// Its signed urls point to the blobs handler of the app, which checks the signature with Verify.
+type FilesystemStore struct {
+	dir    string
+	url    string
+	secret []byte
+	// assetsBucket is the only bucket whose files under the public prefix are served without a signature.
+	assetsBucket string
+	now          func() time.Time
+}
+
+// NewFilesystem creates a store in the given directory, signing the urls of the
+// url prefix with the secret. The files under the public prefix of the assets
+// bucket can be read without a signature.
+func NewFilesystem(dir, url, secret, assetsBucket string) (*FilesystemStore, error) {
+	if dir == "" {
+		return nil, errors.New("blob storage directory not set")
+	}
+	if secret == "" {
+		return nil, errors.New("blob storage secret not set")
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("blob storage directory: %w", err)
+	}
+
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, fmt.Errorf("create blob storage directory: %w", err)
+	}
+
+	return &FilesystemStore{
+		dir:          dir,
+		url:          strings.TrimRight(url, "/"),
+		secret:       []byte(secret),
+		assetsBucket: assetsBucket,
+		now:          time.Now,
+	}, nil
+}
+
+func (s *FilesystemStore) Get(bucket, key string) (*Object, error) {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat file: %w", err)
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+
+	return &Object{Body: f, Size: fi.Size()}, nil
+}
+
+func (s *FilesystemStore) Put(bucket, key string, body io.ReadSeeker, opts PutOptions) error {
+	return s.Write(bucket, key, body)
+}
+
+// Write writes the body to a temporary file next to the destination and renames it,
+// so the readers never see a partially written file. Unlike Put the body is only
+// read once, so it can be streamed from the request of an upload.
+func (s *FilesystemStore) Write(bucket, key string, body io.Reader) error {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0750)
+	if err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+
+	_, err = io.Copy(tmp, body)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync file: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), p)
+	if err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FilesystemStore) Delete(bucket, key string) error {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FilesystemStore) SignPut(bucket, key, contentType string, expires time.Duration) (string, error) {
+	return s.sign(http.MethodPut, bucket, key, contentType, expires)
+}
+
+func (s *FilesystemStore) SignGet(bucket, key string, expires time.Duration) (string, error) {
+	return s.sign(http.MethodGet, bucket, key, "", expires)
+}
+
+// Verify checks the signature of the url the request was made to. The content type
+// is only signed for uploads.
+func (s *FilesystemStore) Verify(method, bucket, key, contentType string, query url.Values) error {
+	if method == http.MethodGet && s.Public(bucket, key) {
+		return nil
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || s.now().Unix() > expires {
+		return ErrInvalidSignature
+	}
+
+	sig, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(sig, s.signature(method, bucket, key, contentType, expires)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Public checks whether the file can be read without a signature.
+func (s *FilesystemStore) Public(bucket, key string) bool {
+	return s.assetsBucket != "" && bucket == s.assetsBucket && strings.HasPrefix(key, PublicPrefix)
+}
+
+func (s *FilesystemStore) sign(method, bucket, key, contentType string, expires time.Duration) (string, error) {
+	if !validKey(bucket, key) {
+		return "", ErrInvalidKey
+	}
+
+	exp := s.now().Add(expires).Unix()
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("signature", hex.EncodeToString(s.signature(method, bucket, key, contentType, exp)))
+
+	return s.URL(bucket, key) + "?" + q.Encode(), nil
+}
+
+// URL returns the url of the file without a signature, which can only be used
+// to read the files under the public prefix.
+func (s *FilesystemStore) URL(bucket, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+
+	return s.url + "/" + strings.Join(segments, "/")
+}
+
+func (s *FilesystemStore) signature(method, bucket, key, contentType string, expires int64) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%d", method, bucket, key, contentType, expires)
+	return mac.Sum(nil)
+}
+
+// path returns the path of the file in the root directory.
+func (s *FilesystemStore) path(bucket, key string) (string, error) {
+	if !validKey(bucket, key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.dir, bucket, filepath.FromSlash(key)), nil
+}
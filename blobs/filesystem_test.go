@@ -0,0 +1,122 @@
+package blobs
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = NewFilesystem(dir, "http://localhost/api/blobs", "", "files")
+	assert.NotNil(t, err)
+
+	fs, err := NewFilesystem(dir, "http://localhost/api/blobs/", "secret", "files")
+	assert.Nil(t, err)
+
+	// test put creates the directories of the key
+	err = fs.Put("files", "subscribers/import/1/list.csv", strings.NewReader("email,name"), PutOptions{})
+	assert.Nil(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "files", "subscribers", "import", "1", "list.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, "email,name", string(data))
+
+	// test put replaces the file without leaving temp files behind
+	err = fs.Put("files", "subscribers/import/1/list.csv", strings.NewReader("email,name\njo@example.com,Jo"), PutOptions{})
+	assert.Nil(t, err)
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "files", "subscribers", "import", "1"))
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// test get
+	obj, err := fs.Get("files", "subscribers/import/1/list.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(28), obj.Size)
+	data, err = ioutil.ReadAll(obj.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "email,name\njo@example.com,Jo", string(data))
+	assert.Nil(t, obj.Body.Close())
+
+	_, err = fs.Get("files", "subscribers/import/1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = fs.Get("files", "missing.csv")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// test keys can't leave the root directory
+	for _, key := range []string{"../secret", "a/../../secret", "/etc/passwd", "a//b", "a\\b", ""} {
+		err = fs.Put("files", key, strings.NewReader("x"), PutOptions{})
+		assert.True(t, errors.Is(err, ErrInvalidKey), key)
+	}
+	_, err = fs.Get("..", "secret")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	// test delete
+	err = fs.Delete("files", "subscribers/import/1/list.csv")
+	assert.Nil(t, err)
+	err = fs.Delete("files", "subscribers/import/1/list.csv")
+	assert.Nil(t, err)
+
+	_, err = fs.Get("files", "subscribers/import/1/list.csv")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestFilesystemSignedURLs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := NewFilesystem(dir, "http://localhost/api/blobs", "secret", "files")
+	assert.Nil(t, err)
+
+	now := time.Unix(1600000000, 0)
+	fs.now = func() time.Time { return now }
+
+	signed, err := fs.SignPut("files", "subscribers/import/1/my list.csv", "text/csv", 15*time.Minute)
+	assert.Nil(t, err)
+
+	u, err := url.Parse(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/blobs/files/subscribers/import/1/my list.csv", u.Path)
+	assert.Equal(t, "1600000900", u.Query().Get("expires"))
+
+	assert.Nil(t, fs.Verify(http.MethodPut, "files", "subscribers/import/1/my list.csv", "text/csv", u.Query()))
+
+	// test the signature is bound to the method, key and content type
+	assert.True(t, errors.Is(fs.Verify(http.MethodGet, "files", "subscribers/import/1/my list.csv", "", u.Query()), ErrInvalidSignature))
+	assert.True(t, errors.Is(fs.Verify(http.MethodPut, "files", "subscribers/import/2/my list.csv", "text/csv", u.Query()), ErrInvalidSignature))
+	assert.True(t, errors.Is(fs.Verify(http.MethodPut, "files", "subscribers/import/1/my list.csv", "text/html", u.Query()), ErrInvalidSignature))
+
+	// test expired signature
+	now = now.Add(16 * time.Minute)
+	assert.True(t, errors.Is(fs.Verify(http.MethodPut, "files", "subscribers/import/1/my list.csv", "text/csv", u.Query()), ErrInvalidSignature))
+
+	signed, err = fs.SignGet("files", "subscribers/export/1/report.csv", time.Minute)
+	assert.Nil(t, err)
+	u, err = url.Parse(signed)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Verify(http.MethodGet, "files", "subscribers/export/1/report.csv", "", u.Query()))
+
+	// test the public files can be read without a signature
+	assert.Nil(t, fs.Verify(http.MethodGet, "files", "assets/1/abc/logo.png", "", url.Values{}))
+	assert.NotNil(t, fs.Verify(http.MethodPut, "files", "assets/1/abc/logo.png", "", url.Values{}))
+	assert.NotNil(t, fs.Verify(http.MethodGet, "templates", "assets/1/abc/logo.png", "", url.Values{}))
+	assert.Equal(t, "http://localhost/api/blobs/files/assets/1/abc/logo.png", fs.URL("files", "assets/1/abc/logo.png"))
+}
@@ -0,0 +1,110 @@
+package blobs
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// s3Store stores the files as objects in the S3 buckets.
+type s3Store struct {
+	client s3iface.S3API
+}
+
+// NewS3 creates a store backed by the S3 client.
+func NewS3(client s3iface.S3API) Store {
+	return &s3Store{client: client}
+}
+
+func (s *s3Store) Get(bucket, key string) (*Object, error) {
+	resp, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case s3.ErrCodeNoSuchKey:
+				return nil, ErrNotFound
+			case s3.ErrCodeInvalidObjectState:
+				// the object is archived and must be restored before it can be read.
+				return nil, ErrUnavailable
+			}
+		}
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+
+	obj := &Object{Body: resp.Body, Size: -1}
+	if resp.ContentLength != nil {
+		obj.Size = *resp.ContentLength
+	}
+
+	return obj, nil
+}
+
+func (s *s3Store) Put(bucket, key string, body io.ReadSeeker, opts PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+
+	_, err := s.client.PutObject(input)
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *s3Store) Delete(bucket, key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *s3Store) SignPut(bucket, key, contentType string, expires time.Duration) (string, error) {
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", fmt.Errorf("presign put object: %w", err)
+	}
+
+	return url, nil
+}
+
+func (s *s3Store) SignGet(bucket, key string, expires time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", fmt.Errorf("presign get object: %w", err)
+	}
+
+	return url, nil
+}
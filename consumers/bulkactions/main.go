@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jinzhu/gorm"
+	"github.com/nsqio/go-nsq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/consumers"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/mode"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/storage"
+)
+
+// MessageHandler implements the nsq handler interface.
+type MessageHandler struct {
+	s   storage.Storage
+	svc subscribers.Service
+}
+
+// HandleMessage is the only requirement needed to fulfill the
+// nsq.Handler interface.
+func (h *MessageHandler) HandleMessage(m *nsq.Message) error {
+	if len(m.Body) == 0 {
+		logrus.Error("Empty message, unable to proceed.")
+		return nil
+	}
+
+	msg := new(entities.SubscriberBulkActionTopicParams)
+	err := json.Unmarshal(m.Body, msg)
+	if err != nil {
+		logrus.WithField("body", string(m.Body)).WithError(err).Error("Malformed JSON message.")
+		return nil
+	}
+
+	logEntry := logrus.WithFields(logrus.Fields{
+		"bulk_action_id": msg.BulkActionID,
+		"user_id":        msg.UserID,
+	})
+
+	a, err := h.s.GetSubscriberBulkAction(msg.BulkActionID, msg.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logEntry.WithError(err).Warn("Unable to find the bulk action.")
+			return nil
+		}
+		logEntry.WithError(err).Error("Unable to find the bulk action.")
+		return err
+	}
+
+	if a.Status != entities.StatusPending {
+		logEntry.WithField("status", a.Status).Info("Bulk action is not pending.")
+		return nil
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go consumers.Touch(m, done)
+
+	err = h.svc.RunBulkAction(context.Background(), a)
+	if err != nil {
+		logEntry.WithError(err).Error("Unable to apply the bulk action.")
+		return nil
+	}
+
+	logEntry.WithFields(logrus.Fields{
+		"action":    a.Action,
+		"status":    a.Status,
+		"processed": a.Processed,
+		"affected":  a.Affected,
+		"failed":    a.Failed,
+	}).Info("Bulk action finished.")
+
+	return nil
+}
+
+func main() {
+	mode.SetModeFromEnv()
+
+	lvl, err := logrus.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		lvl = logrus.InfoLevel
+	}
+
+	logrus.SetLevel(lvl)
+	if mode.IsProd() {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	}
+	logrus.SetOutput(os.Stdout)
+
+	driver := os.Getenv("DATABASE_DRIVER")
+	conf := storage.MakeConfigFromEnv(driver)
+	s := storage.New(driver, conf)
+
+	blobStore, err := blobs.New(os.Getenv("BLOB_STORAGE"), blobs.MakeConfigFromEnv())
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	config := nsq.NewConfig()
+
+	consumer, err := nsq.NewConsumer(entities.SubscriberBulkActionTopic, entities.SubscriberBulkActionTopic, config)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create consumer")
+	}
+
+	consumer.ChangeMaxInFlight(10)
+
+	consumer.SetLogger(
+		&consumers.NoopLogger{},
+		nsq.LogLevelError,
+	)
+
+	consumer.AddConcurrentHandlers(
+		&MessageHandler{
+			s:   s,
+			svc: subscribers.New(blobStore, s),
+		},
+		5,
+	)
+
+	addr := fmt.Sprintf("%s:%s", os.Getenv("NSQLOOKUPD_HOST"), os.Getenv("NSQLOOKUPD_PORT"))
+	nsqlds := []string{addr}
+
+	logrus.Infoln("Connecting to NSQlookup...")
+	if err := consumer.ConnectToNSQLookupds(nsqlds); err != nil {
+		logrus.Fatal(err)
+	}
+
+	logrus.Infoln("Connected to NSQlookup")
+
+	shutdown := make(chan os.Signal, 2)
+	signal.Notify(shutdown, os.Interrupt)
+	signal.Notify(shutdown, syscall.SIGINT)
+	signal.Notify(shutdown, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-consumer.StopChan:
+			return // consumer disconnected. Time to quit.
+		case <-shutdown:
+			// Synchronously drain the queue before falling out of main
+			logrus.Infoln("Stopping consumer...")
+			consumer.Stop()
+		}
+	}
+}
@@ -18,11 +18,11 @@ import (
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/consumers"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/mode"
 	"github.com/mailbadger/app/queue"
-	"github.com/mailbadger/app/s3"
 	"github.com/mailbadger/app/services/campaigns"
 	"github.com/mailbadger/app/services/templates"
 	"github.com/mailbadger/app/storage"
@@ -190,7 +190,7 @@ func processSubscribers(
 		for _, s := range subs {
 			id = id.Next()
 
-			params, err := svc.PrepareSubscriberEmailData(s, id, *msg, campaign.ID, parsedTemplate.HTMLPart, parsedTemplate.SubjectPart, parsedTemplate.TextPart)
+			params, err := svc.PrepareSubscriberEmailData(s, id, *msg, campaign.ID, parsedTemplate)
 			if err != nil {
 				logEntry.WithField("subscriber_id", s.ID).WithError(err).Error("unable to prepare subscriber email data")
 
@@ -342,16 +342,12 @@ func main() {
 		logrus.WithError(err).Fatal("Redis: can't establish connection")
 	}
 
-	s3Client, err := s3.NewS3Client(
-		os.Getenv("AWS_S3_ACCESS_KEY"),
-		os.Getenv("AWS_S3_SECRET_KEY"),
-		os.Getenv("AWS_S3_REGION"),
-	)
+	blobStore, err := blobs.New(os.Getenv("BLOB_STORAGE"), blobs.MakeConfigFromEnv())
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	svc := templates.New(s, s3Client)
+	svc := templates.New(s, blobStore)
 
 	p, err := queue.NewProducer(os.Getenv("NSQD_HOST"), os.Getenv("NSQD_PORT"))
 	if err != nil {
@@ -71,6 +71,11 @@ func (h *MessageHandler) LogFailedMessage(m *nsq.Message) {
 
 	logEntry.Error("Exceeded max attempts for sending the e-mail.")
 
+	// the emails which are not sent for a campaign, such as the confirmation emails, are not logged
+	if msg.CampaignID == 0 {
+		return
+	}
+
 	err = h.storage.CreateSendLog(&entities.SendLog{
 		ID:           ksuid.New(),
 		EventID:      msg.EventID,
@@ -138,7 +143,7 @@ func (h *MessageHandler) HandleMessage(m *nsq.Message) (err error) {
 	}
 
 	defer func() {
-		if err == nil {
+		if err == nil && msg.CampaignID != 0 {
 			err = h.storage.CreateSendLog(sendLog)
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
@@ -315,10 +320,6 @@ func sendEmail(client emails.Sender, msg entities.SenderTopicParams) (*ses.SendE
 					Charset: aws.String(CharSet),
 					Data:    aws.String(string(msg.HTMLPart)),
 				},
-				Text: &ses.Content{
-					Charset: aws.String(CharSet),
-					Data:    aws.String(string(msg.TextPart)),
-				},
 			},
 			Subject: &ses.Content{
 				Charset: aws.String(CharSet),
@@ -327,10 +328,6 @@ func sendEmail(client emails.Sender, msg entities.SenderTopicParams) (*ses.SendE
 		},
 		Source: aws.String(msg.Source),
 		Tags: []*ses.MessageTag{
-			{
-				Name:  aws.String("campaign_id"),
-				Value: aws.String(strconv.FormatInt(msg.CampaignID, 10)),
-			},
 			{
 				Name:  aws.String("user_id"),
 				Value: aws.String(msg.UserUUID),
@@ -338,6 +335,20 @@ func sendEmail(client emails.Sender, msg entities.SenderTopicParams) (*ses.SendE
 		},
 	}
 
+	if len(msg.TextPart) > 0 {
+		input.Message.Body.Text = &ses.Content{
+			Charset: aws.String(CharSet),
+			Data:    aws.String(string(msg.TextPart)),
+		}
+	}
+
+	if msg.CampaignID != 0 {
+		input.Tags = append(input.Tags, &ses.MessageTag{
+			Name:  aws.String("campaign_id"),
+			Value: aws.String(strconv.FormatInt(msg.CampaignID, 10)),
+		})
+	}
+
 	if msg.ConfigurationSetExists {
 		input.ConfigurationSetName = aws.String(emails.ConfigurationSetName)
 	}
@@ -0,0 +1,26 @@
+package consumers
+
+import (
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// TouchInterval is how often the messages of the long running jobs are touched, so they don't
+// time out and get requeued, it's below the default message timeout of nsqd.
+const TouchInterval = 30 * time.Second
+
+// Touch resets the timeout of the message every TouchInterval until done is closed.
+func Touch(m *nsq.Message, done <-chan struct{}) {
+	ticker := time.NewTicker(TouchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			m.Touch()
+		}
+	}
+}
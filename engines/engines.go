@@ -0,0 +1,85 @@
+// Package engines implements the languages the templates can be written in.
+package engines
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Names of the supported engines.
+const (
+	Mustache = "mustache"
+	Go       = "go"
+)
+
+// ContentTag is the tag used in layouts which is replaced by the
+// rendered HTML part of the template wrapped in the layout.
+const ContentTag = "content"
+
+var (
+	ErrUnknownEngine = errors.New("unknown template engine")
+	ErrLayoutEngine  = errors.New("layout is written for a different engine")
+)
+
+// Engine parses the template parts and inspects their tags.
+type Engine interface {
+	// Parse compiles the source with the given partials. When html is set the
+	// source is compiled as an HTML part, otherwise as a text or subject part.
+	Parse(source string, partials map[string]string, html bool) (Template, error)
+	// Tags returns the names of the variables used in the source, including the
+	// ones nested in sections or blocks, without the partials.
+	Tags(source string) ([]string, error)
+	// Partials returns the names of the partials included in the source.
+	Partials(source string) ([]string, error)
+	// Inline replaces the partial tags in the source with the contents of the partials.
+	Inline(source string, partials map[string]string) string
+	// IsLayout checks whether the source contains the content tag.
+	IsLayout(source string) bool
+	// Embed replaces the content tag of the layout with the given content.
+	Embed(layout, content string) string
+}
+
+// Template is a compiled template part.
+type Template interface {
+	// Render renders the template with the given data.
+	Render(w io.Writer, data map[string]string) error
+	// RenderInLayout renders the template wrapped in the layout, the layout must
+	// be parsed with the same engine as the template.
+	RenderInLayout(w io.Writer, layout Template, data map[string]string) error
+}
+
+var engines = map[string]Engine{
+	Mustache: mustacheEngine{},
+	Go:       goEngine{},
+}
+
+// Get returns the engine with the given name. Templates without an engine are written in mustache.
+func Get(name string) (Engine, error) {
+	if name == "" {
+		name = Mustache
+	}
+
+	e, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrUnknownEngine)
+	}
+
+	return e, nil
+}
+
+// Names returns the names of the supported engines.
+func Names() []string {
+	return []string{Mustache, Go}
+}
+
+// appendUnique appends the name to the names if it is not already there.
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+
+	return append(names, name)
+}
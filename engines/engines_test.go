@@ -0,0 +1,128 @@
+package engines
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	e, err := Get("")
+	assert.Nil(t, err)
+	assert.Equal(t, mustacheEngine{}, e)
+
+	e, err = Get(Go)
+	assert.Nil(t, err)
+	assert.Equal(t, goEngine{}, e)
+
+	_, err = Get("handlebars")
+	assert.True(t, errors.Is(err, ErrUnknownEngine))
+}
+
+func TestMustache(t *testing.T) {
+	e, _ := Get(Mustache)
+
+	tags, err := e.Tags("Hi {{name}}{{#vip}}, {{discount}} off{{/vip}}{{^vip}}!{{/vip}}{{> footer}}")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"name", "vip", "discount"}, tags)
+
+	partials, err := e.Partials("{{#vip}}{{> vip_footer}}{{/vip}}{{> footer}}")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"vip_footer", "footer"}, partials)
+
+	_, err = e.Tags("{{{name}}")
+	assert.NotNil(t, err)
+
+	inlined := e.Inline("<p>{{> footer}}</p>", map[string]string{"footer": "{{company}} {{> address}}", "address": "{{street}}"})
+	assert.Equal(t, "<p>{{company}} {{street}}</p>", inlined)
+
+	assert.True(t, e.IsLayout("<div>{{{content}}}</div>"))
+	assert.False(t, e.IsLayout("<div>{{body}}</div>"))
+	assert.False(t, e.IsLayout("<div>{{content}}</div>"))
+	assert.Equal(t, "<div><p>hi</p></div>", e.Embed("<div>{{& content }}</div>", "<p>hi</p>"))
+	assert.Equal(t, "<div><p>hi</p></div>", e.Embed("<div>{{{content}}}</div>", "<p>hi</p>"))
+
+	layout, err := e.Parse("<div>{{{content}}}</div>", nil, true)
+	assert.Nil(t, err)
+	tmpl, err := e.Parse("<p>{{name}}</p>{{> footer}}", map[string]string{"footer": "<i>{{company}}</i>"}, true)
+	assert.Nil(t, err)
+
+	var b strings.Builder
+	err = tmpl.RenderInLayout(&b, layout, map[string]string{"name": "<Djale>", "company": "Mailbadger"})
+	assert.Nil(t, err)
+	assert.Equal(t, "<div><p>&lt;Djale&gt;</p><i>Mailbadger</i></div>", b.String())
+
+	goLayout, _ := Get(Go)
+	l, err := goLayout.Parse("<div>{{content}}</div>", nil, true)
+	assert.Nil(t, err)
+	err = tmpl.RenderInLayout(&b, l, nil)
+	assert.True(t, errors.Is(err, ErrLayoutEngine))
+}
+
+func TestGo(t *testing.T) {
+	e, _ := Get(Go)
+
+	source := `Hi {{.name | default "there"}}{{if .vip}}, {{$.discount}} off{{else}}!{{end}}` +
+		`{{define "sig"}}{{.sender}}{{end}}{{template "sig" .}}{{template "footer" .}}`
+
+	tags, err := e.Tags(source)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"name", "vip", "discount", "sender"}, tags)
+
+	partials, err := e.Partials(source)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"footer"}, partials)
+
+	_, err = e.Tags("{{if .vip}}")
+	assert.NotNil(t, err)
+
+	inlined := e.Inline(`<p>{{template "footer" .}}</p>`, map[string]string{"footer": `{{.company}} {{template "address" .}}`, "address": "{{.street}}"})
+	assert.Equal(t, "<p>{{.company}} {{.street}}</p>", inlined)
+
+	assert.True(t, e.IsLayout("<div>{{content}}</div>"))
+	assert.False(t, e.IsLayout("<div>{{.content}}</div>"))
+	assert.False(t, e.IsLayout("<div>{{content | printf \"%s\"}}</div>"))
+	assert.Equal(t, "<div><p>hi</p></div>", e.Embed("<div>{{ content }}</div>", "<p>hi</p>"))
+
+	layout, err := e.Parse(`<div title="{{.name}}">{{content}}</div>`, nil, true)
+	assert.Nil(t, err)
+	tmpl, err := e.Parse(`<p>{{.name | upper}}</p>{{template "footer" .}}`, map[string]string{"footer": "<i>{{.company}}</i>"}, true)
+	assert.Nil(t, err)
+
+	var b strings.Builder
+	err = tmpl.RenderInLayout(&b, layout, map[string]string{"name": "<Djale>", "company": "Mailbadger"})
+	assert.Nil(t, err)
+	assert.Equal(t, `<div title="&lt;Djale&gt;"><p>&lt;DJALE&gt;</p><i>Mailbadger</i></div>`, b.String())
+
+	// the layout can be used for any number of emails.
+	b.Reset()
+	err = tmpl.RenderInLayout(&b, layout, map[string]string{"name": "Jo"})
+	assert.Nil(t, err)
+	assert.Equal(t, `<div title="Jo"><p>JO</p><i></i></div>`, b.String())
+
+	text, err := e.Parse(`{{.name | default "there"}} & {{.missing}}{{.joined | date "Jan 2, 2006"}} {{.total | number 2}}`, nil, false)
+	assert.Nil(t, err)
+
+	b.Reset()
+	err = text.Render(&b, map[string]string{"joined": "2021-05-19T20:48:50Z", "total": "-1234567.891"})
+	assert.Nil(t, err)
+	assert.Equal(t, "there & May 19, 2021 -1,234,567.89", b.String())
+}
+
+func TestFormatters(t *testing.T) {
+	assert.Equal(t, "May 19, 2021", formatDate("Jan 2, 2006", "2021-05-19"))
+	assert.Equal(t, "2021-05-19 20:48", formatDate("2006-01-02 15:04", "2021-05-19 20:48:50"))
+	assert.Equal(t, "1970-01-02", formatDate("2006-01-02", "86400"))
+	assert.Equal(t, "soon", formatDate("2006-01-02", "soon"))
+
+	assert.Equal(t, "1,000", formatNumber(0, "999.5"))
+	assert.Equal(t, "12.50", formatNumber(2, "12.5"))
+	assert.Equal(t, "100", formatNumber(-1, "100"))
+	assert.Equal(t, "0", formatNumber(0, "-0.1"))
+	assert.Equal(t, "n/a", formatNumber(2, "n/a"))
+
+	assert.Equal(t, "friend", defaultValue("friend", " "))
+	assert.Equal(t, "Jo", defaultValue("friend", "Jo"))
+}
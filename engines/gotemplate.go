@@ -0,0 +1,288 @@
+package engines
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+)
+
+// maxDecimals is the max number of decimals the number function formats.
+const maxDecimals = 10
+
+var (
+	goPartialRe = regexp.MustCompile(`\{\{-?\s*template\s+"([^"]+)"\s*\.?\s*-?\}\}`)
+	goContentRe = regexp.MustCompile(`\{\{-?\s*` + ContentTag + `\s*-?\}\}`)
+)
+
+// dateLayouts are the formats of the dates the date function can parse.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// goEngine parses the HTML parts with html/template, which escapes the values based on
+// their context, and the text and subject parts with text/template. The variables are
+// the fields of the data e.g. `{{.name}}`, partials are included with `{{template "footer" .}}`
+// and layouts embed the content with `{{content}}`.
+type goEngine struct{}
+
+type goTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// goFuncs are the functions available in the templates besides the builtin ones.
+func goFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"default": defaultValue,
+		"upper":   strings.ToUpper,
+		"date":    formatDate,
+		"number":  formatNumber,
+		// content is replaced when the template is rendered as a layout.
+		ContentTag: func() string { return "" },
+	}
+}
+
+func (goEngine) Parse(source string, partials map[string]string, html bool) (Template, error) {
+	if html {
+		funcs := htmltemplate.FuncMap(goFuncs())
+		funcs[ContentTag] = func() htmltemplate.HTML { return "" }
+
+		tmpl, err := htmltemplate.New("").Funcs(funcs).Option("missingkey=zero").Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		for name, body := range partials {
+			if _, err := tmpl.New(name).Parse(body); err != nil {
+				return nil, err
+			}
+		}
+
+		return &goTemplate{html: tmpl}, nil
+	}
+
+	tmpl, err := texttemplate.New("").Funcs(goFuncs()).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	for name, body := range partials {
+		if _, err := tmpl.New(name).Parse(body); err != nil {
+			return nil, err
+		}
+	}
+
+	return &goTemplate{text: tmpl}, nil
+}
+
+func (goEngine) Tags(source string) ([]string, error) {
+	tmpl, err := parseGo(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, t := range tmpl.Templates() {
+		walkGo(t.Root, func(n parse.Node) {
+			switch n := n.(type) {
+			case *parse.FieldNode:
+				names = appendUnique(names, n.Ident[0])
+			case *parse.VariableNode:
+				// $ is the data the template is rendered with.
+				if len(n.Ident) > 1 && n.Ident[0] == "$" {
+					names = appendUnique(names, n.Ident[1])
+				}
+			}
+		})
+	}
+
+	return names, nil
+}
+
+func (goEngine) Partials(source string) ([]string, error) {
+	tmpl, err := parseGo(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, t := range tmpl.Templates() {
+		walkGo(t.Root, func(n parse.Node) {
+			// the templates defined in the source are not partials.
+			if tn, ok := n.(*parse.TemplateNode); ok && tmpl.Lookup(tn.Name) == nil {
+				names = appendUnique(names, tn.Name)
+			}
+		})
+	}
+
+	return names, nil
+}
+
+func (e goEngine) Inline(source string, partials map[string]string) string {
+	return goPartialRe.ReplaceAllStringFunc(source, func(tag string) string {
+		name := goPartialRe.FindStringSubmatch(tag)[1]
+		body, ok := partials[name]
+		if !ok {
+			return tag
+		}
+		return e.Inline(body, partials)
+	})
+}
+
+func (goEngine) IsLayout(source string) bool {
+	if _, err := parseGo(source); err != nil {
+		return false
+	}
+
+	// the content tag must be a bare action, Embed doesn't replace it
+	// inside pipelines such as {{content | upper}}.
+	return goContentRe.MatchString(source)
+}
+
+func (goEngine) Embed(layout, content string) string {
+	return goContentRe.ReplaceAllLiteralString(layout, content)
+}
+
+func (t *goTemplate) Render(w io.Writer, data map[string]string) error {
+	if t.html != nil {
+		return t.html.Execute(w, data)
+	}
+
+	return t.text.Execute(w, data)
+}
+
+func (t *goTemplate) RenderInLayout(w io.Writer, layout Template, data map[string]string) error {
+	l, ok := layout.(*goTemplate)
+	if !ok || l.html == nil {
+		return ErrLayoutEngine
+	}
+
+	var content strings.Builder
+	if err := t.Render(&content, data); err != nil {
+		return err
+	}
+
+	// the layout is cloned so the parsed layout can be shared between the rendered emails.
+	tmpl, err := l.html.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(htmltemplate.FuncMap{
+		ContentTag: func() htmltemplate.HTML { return htmltemplate.HTML(content.String()) },
+	})
+
+	return tmpl.Execute(w, data)
+}
+
+// parseGo parses the source for inspecting its nodes.
+func parseGo(source string) (*texttemplate.Template, error) {
+	return texttemplate.New("").Funcs(goFuncs()).Parse(source)
+}
+
+// walkGo calls fn for each node of the tree.
+func walkGo(n parse.Node, fn func(parse.Node)) {
+	fn(n)
+
+	switch n := n.(type) {
+	case *parse.ListNode:
+		for _, c := range n.Nodes {
+			walkGo(c, fn)
+		}
+	case *parse.ActionNode:
+		walkGo(n.Pipe, fn)
+	case *parse.PipeNode:
+		for _, c := range n.Cmds {
+			walkGo(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, c := range n.Args {
+			walkGo(c, fn)
+		}
+	case *parse.ChainNode:
+		walkGo(n.Node, fn)
+	case *parse.IfNode:
+		walkGoBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkGoBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkGoBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walkGo(n.Pipe, fn)
+		}
+	}
+}
+
+func walkGoBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walkGo(n.Pipe, fn)
+	walkGo(n.List, fn)
+	if n.ElseList != nil {
+		walkGo(n.ElseList, fn)
+	}
+}
+
+// defaultValue returns the default when the value is empty, e.g. `{{.name | default "there"}}`.
+func defaultValue(def, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return def
+	}
+
+	return value
+}
+
+// formatDate formats the value with the Go layout, e.g. `{{.signed_up | date "Jan 2, 2006"}}`.
+// The value can be an RFC 3339 timestamp, a date and time, a date or unix seconds. Values
+// which are not dates are returned as they are.
+func formatDate(layout, value string) string {
+	value = strings.TrimSpace(value)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return t.Format(layout)
+		}
+	}
+
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC().Format(layout)
+	}
+
+	return value
+}
+
+// formatNumber formats the value with the given number of decimals and with commas
+// separating the thousands, e.g. `{{.total | number 2}}`. Values which are not numbers
+// are returned as they are.
+func formatNumber(decimals int, value string) string {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return value
+	}
+
+	if decimals < 0 {
+		decimals = 0
+	}
+	if decimals > maxDecimals {
+		decimals = maxDecimals
+	}
+
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+
+	var b strings.Builder
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteString(frac)
+
+	return b.String()
+}
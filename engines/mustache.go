@@ -0,0 +1,116 @@
+package engines
+
+import (
+	"io"
+	"regexp"
+
+	"github.com/cbroglie/mustache"
+)
+
+var (
+	mustachePartialRe = regexp.MustCompile(`\{\{>\s*([^{}\s]+)\s*\}\}`)
+	mustacheContentRe = regexp.MustCompile(`\{\{\{\s*` + ContentTag + `\s*\}\}\}|\{\{&\s*` + ContentTag + `\s*\}\}`)
+)
+
+// mustacheEngine parses the templates with mustache. The values are always
+// HTML escaped unless they are written with the triple mustache tag.
+type mustacheEngine struct{}
+
+type mustacheTemplate struct {
+	tmpl *mustache.Template
+}
+
+func (mustacheEngine) Parse(source string, partials map[string]string, html bool) (Template, error) {
+	tmpl, err := mustache.ParseStringPartials(source, &mustache.StaticProvider{Partials: partials})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mustacheTemplate{tmpl: tmpl}, nil
+}
+
+func (mustacheEngine) Tags(source string) ([]string, error) {
+	tmpl, err := mustache.ParseString(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return mustacheTags(tmpl.Tags(), nil), nil
+}
+
+func (mustacheEngine) Partials(source string) ([]string, error) {
+	tmpl, err := mustache.ParseString(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return mustachePartials(tmpl.Tags(), nil), nil
+}
+
+func (e mustacheEngine) Inline(source string, partials map[string]string) string {
+	return mustachePartialRe.ReplaceAllStringFunc(source, func(tag string) string {
+		name := mustachePartialRe.FindStringSubmatch(tag)[1]
+		body, ok := partials[name]
+		if !ok {
+			return tag
+		}
+		return e.Inline(body, partials)
+	})
+}
+
+func (mustacheEngine) IsLayout(source string) bool {
+	if _, err := mustache.ParseString(source); err != nil {
+		return false
+	}
+
+	// only the unescaped content tag is a layout, Embed replaces just that
+	// one and an escaped {{content}} would print the HTML of the template.
+	return mustacheContentRe.MatchString(source)
+}
+
+func (mustacheEngine) Embed(layout, content string) string {
+	return mustacheContentRe.ReplaceAllLiteralString(layout, content)
+}
+
+func (t *mustacheTemplate) Render(w io.Writer, data map[string]string) error {
+	return t.tmpl.FRender(w, data)
+}
+
+func (t *mustacheTemplate) RenderInLayout(w io.Writer, layout Template, data map[string]string) error {
+	l, ok := layout.(*mustacheTemplate)
+	if !ok {
+		return ErrLayoutEngine
+	}
+
+	return t.tmpl.FRenderInLayout(w, l.tmpl, data)
+}
+
+// mustacheTags returns the names of the variables, sections and inverted sections.
+func mustacheTags(tags []mustache.Tag, names []string) []string {
+	for _, tag := range tags {
+		switch tag.Type() {
+		case mustache.Variable:
+			names = appendUnique(names, tag.Name())
+		case mustache.Section, mustache.InvertedSection:
+			names = appendUnique(names, tag.Name())
+			names = mustacheTags(tag.Tags(), names)
+		}
+	}
+
+	return names
+}
+
+// mustachePartials returns the names of the partial tags, including the ones
+// nested in sections and inverted sections.
+func mustachePartials(tags []mustache.Tag, names []string) []string {
+	for _, tag := range tags {
+		switch tag.Type() {
+		case mustache.Partial:
+			names = appendUnique(names, tag.Name())
+		case mustache.Section, mustache.InvertedSection:
+			names = mustachePartials(tag.Tags(), names)
+		}
+	}
+
+	return names
+}
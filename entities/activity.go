@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Types of the activities of the campaigns, the activities of the subscriber
+// events are of the type of the event.
+const (
+	ActivityTypeSendLog   = "send_log"
+	ActivityTypeSend      = "send"
+	ActivityTypeDelivery  = "delivery"
+	ActivityTypeOpen      = "open"
+	ActivityTypeClick     = "click"
+	ActivityTypeBounce    = "bounce"
+	ActivityTypeComplaint = "complaint"
+)
+
+// ErrInvalidActivityCursor is returned when the activity cursor token can't be decoded.
+var ErrInvalidActivityCursor = errors.New("invalid activity cursor")
+
+// Activity is an entry of the activity timeline of a subscriber, it's either something that
+// happened with a campaign sent to the subscriber or a subscriber event.
+type Activity struct {
+	ID           string  `json:"id"`
+	Type         string  `json:"type"`
+	CampaignID   *int64  `json:"campaign_id"`
+	CampaignName *string `json:"campaign_name"`
+	// Status is the status of the send logs and the type of the bounces and complaints.
+	Status *string `json:"status,omitempty"`
+	// Detail is the description of the send logs, the message id of the sends, the SMTP response
+	// of the deliveries, the user agent of the opens, the link of the clicks and the diagnostic
+	// code of the bounces.
+	Detail *string `json:"detail,omitempty"`
+	// Data is the data of the subscriber events.
+	Data      JSON      `json:"data,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Cursor is the position of the activity in the timeline.
+	Cursor ActivityCursor `json:"-"`
+}
+
+// ActivityCursor is the position of an activity in the timeline, the activities are ordered by
+// their time, type and id. The id of the campaign activities is numeric, and the id of the
+// subscriber events is a ksuid.
+type ActivityCursor struct {
+	CreatedAt time.Time `json:"t"`
+	Type      string    `json:"k"`
+	NumID     int64     `json:"n,omitempty"`
+	StrID     string    `json:"s,omitempty"`
+}
+
+// Encode encodes the cursor to an opaque token.
+func (c ActivityCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeActivityCursor decodes the cursor from the token.
+func DecodeActivityCursor(token string) (*ActivityCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidActivityCursor
+	}
+
+	c := new(ActivityCursor)
+	if err := json.Unmarshal(b, c); err != nil || c.Type == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidActivityCursor
+	}
+
+	return c, nil
+}
@@ -4,8 +4,9 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ses"
-	"github.com/cbroglie/mustache"
 	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/engines"
 )
 
 const (
@@ -83,9 +84,11 @@ type SenderTopicParams struct {
 
 type CampaignTemplateData struct {
 	Template    *Template
-	HTMLPart    *mustache.Template
-	SubjectPart *mustache.Template
-	TextPart    *mustache.Template
+	HTMLPart    engines.Template
+	SubjectPart engines.Template
+	TextPart    engines.Template
+	// Layout is the optional layout in which the HTML part is wrapped.
+	Layout engines.Template
 }
 
 // CampaignClicksStats represents clicks stats by campaign, total number of links and stats for each link
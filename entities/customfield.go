@@ -0,0 +1,180 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of the custom fields.
+const (
+	CustomFieldTypeString = "string"
+	CustomFieldTypeNumber = "number"
+	CustomFieldTypeDate   = "date"
+	CustomFieldTypeBool   = "bool"
+	CustomFieldTypeEnum   = "enum"
+)
+
+// CustomFieldDateLayout is the format the values of the date fields are stored in,
+// so that they can be compared as strings.
+const CustomFieldDateLayout = "2006-01-02"
+
+// CustomField defines the type of a metadata key of the user's subscribers. Once the user
+// defines any fields, the metadata of the subscribers may only contain the defined keys, and
+// the values are validated and normalized by the types of the fields, see CustomFields.ApplyTo.
+type CustomField struct {
+	Model
+	UserID   int64  `json:"-" gorm:"column:user_id; index"`
+	Key      string `json:"key" gorm:"column:field_key"`
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	// DefaultValue is set to the subscribers whose metadata doesn't contain the field.
+	DefaultValue string `json:"default_value"`
+	// OptionsJSON are the allowed values of the enum fields.
+	OptionsJSON JSON `json:"options" gorm:"column:options; type:json"`
+}
+
+func (f CustomField) GetID() int64 {
+	return f.Model.ID
+}
+
+func (f CustomField) GetCreatedAt() time.Time {
+	return f.Model.CreatedAt
+}
+
+func (f CustomField) GetUpdatedAt() time.Time {
+	return f.Model.UpdatedAt
+}
+
+// GetOptions returns the allowed values of the enum field.
+func (f *CustomField) GetOptions() ([]string, error) {
+	var opts []string
+	if f.OptionsJSON.IsNull() {
+		return opts, nil
+	}
+	err := json.Unmarshal(f.OptionsJSON, &opts)
+	return opts, err
+}
+
+// NormalizeValue validates the value by the type of the field and returns it in the format it's
+// stored in. The numbers are formatted without trailing zeros, the dates in CustomFieldDateLayout
+// (RFC 3339 times are accepted as well) and the booleans as true or false.
+func (f *CustomField) NormalizeValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+
+	switch f.Type {
+	case CustomFieldTypeNumber:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return "", errors.New("Must be a number")
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case CustomFieldTypeDate:
+		if t, err := time.Parse(CustomFieldDateLayout, v); err == nil {
+			return t.Format(CustomFieldDateLayout), nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.Format(CustomFieldDateLayout), nil
+		}
+		return "", errors.New("Must be a date of format: " + CustomFieldDateLayout)
+	case CustomFieldTypeBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", errors.New("Must be true or false")
+		}
+		return strconv.FormatBool(b), nil
+	case CustomFieldTypeEnum:
+		opts, err := f.GetOptions()
+		if err != nil {
+			return "", fmt.Errorf("get options: %w", err)
+		}
+		for _, o := range opts {
+			if o == v {
+				return v, nil
+			}
+		}
+		return "", errors.New("Must be one of: " + strings.Join(opts, " "))
+	}
+
+	return v, nil
+}
+
+// CustomFields are the custom fields of a user.
+type CustomFields []CustomField
+
+// Find returns the field with the key, or nil when it's not defined.
+func (fields CustomFields) Find(key string) *CustomField {
+	for i := range fields {
+		if fields[i].Key == key {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// ApplyTo validates the metadata of a subscriber by the fields, the values are normalized and the
+// default values are set to the missing fields in place. It returns the errors by the metadata keys.
+// Only the defined keys are validated, the other keys are kept as they are, e.g. the keys which were
+// stored before the fields were defined. The empty values are treated as missing.
+func (fields CustomFields) ApplyTo(meta map[string]string) map[string]string {
+	errs := make(map[string]string)
+	if len(fields) == 0 {
+		return errs
+	}
+
+	for i := range fields {
+		f := &fields[i]
+
+		v := strings.TrimSpace(meta[f.Key])
+		if v == "" {
+			delete(meta, f.Key)
+			if f.DefaultValue != "" {
+				meta[f.Key] = f.DefaultValue
+			} else if f.Required {
+				errs[f.Key] = "This field is required"
+			}
+			continue
+		}
+
+		v, err := f.NormalizeValue(v)
+		if err != nil {
+			errs[f.Key] = err.Error()
+			continue
+		}
+		meta[f.Key] = v
+	}
+
+	return errs
+}
+
+// MetadataErrors returns the errors of CustomFields.ApplyTo keyed by the metadata form fields,
+// e.g. metadata[age], as they are returned by the API.
+func MetadataErrors(errs map[string]string) map[string]string {
+	res := make(map[string]string, len(errs))
+	for k, msg := range errs {
+		res["metadata["+k+"]"] = msg
+	}
+	return res
+}
+
+// DescribeMetadataErrors describes the errors of CustomFields.ApplyTo in a sentence, ordered by
+// the keys, e.g. for the rejected rows of the imports.
+func DescribeMetadataErrors(errs map[string]string) string {
+	keys := make([]string, 0, len(errs))
+	for k := range errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = fmt.Sprintf("%s: %s", k, errs[k])
+	}
+	return "Invalid custom fields (" + strings.Join(msgs, "; ") + ")."
+}
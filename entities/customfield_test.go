@@ -0,0 +1,82 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomFieldNormalizeValue(t *testing.T) {
+	enum := CustomField{Type: CustomFieldTypeEnum, OptionsJSON: JSON(`["free","pro"]`)}
+
+	tests := []struct {
+		field CustomField
+		value string
+		want  string
+		err   string
+	}{
+		{CustomField{Type: CustomFieldTypeString}, " Skopje ", "Skopje", ""},
+		{CustomField{Type: CustomFieldTypeNumber}, "42.50", "42.5", ""},
+		{CustomField{Type: CustomFieldTypeNumber}, "-1e3", "-1000", ""},
+		{CustomField{Type: CustomFieldTypeNumber}, "forty", "", "Must be a number"},
+		{CustomField{Type: CustomFieldTypeNumber}, "NaN", "", "Must be a number"},
+		{CustomField{Type: CustomFieldTypeDate}, "2021-03-04", "2021-03-04", ""},
+		{CustomField{Type: CustomFieldTypeDate}, "2021-03-04T23:00:00Z", "2021-03-04", ""},
+		{CustomField{Type: CustomFieldTypeDate}, "04.03.2021", "", "Must be a date of format: 2006-01-02"},
+		{CustomField{Type: CustomFieldTypeBool}, "1", "true", ""},
+		{CustomField{Type: CustomFieldTypeBool}, "FALSE", "false", ""},
+		{CustomField{Type: CustomFieldTypeBool}, "yes", "", "Must be true or false"},
+		{enum, "pro", "pro", ""},
+		{enum, "Pro", "", "Must be one of: free pro"},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.field.NormalizeValue(tt.value)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.value)
+			continue
+		}
+		assert.Nil(t, err, tt.value)
+		assert.Equal(t, tt.want, v, tt.value)
+	}
+}
+
+func TestCustomFieldsApplyTo(t *testing.T) {
+	fields := CustomFields{
+		{Key: "age", Type: CustomFieldTypeNumber, Required: true},
+		{Key: "plan", Type: CustomFieldTypeEnum, DefaultValue: "free", OptionsJSON: JSON(`["free","pro"]`)},
+		{Key: "city", Type: CustomFieldTypeString},
+	}
+
+	meta := map[string]string{"age": "30.0", "city": ""}
+	errs := fields.ApplyTo(meta)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{"age": "30", "plan": "free"}, meta)
+
+	meta = map[string]string{"plan": "gold", "city": "twenty"}
+	errs = fields.ApplyTo(meta)
+	assert.Equal(t, map[string]string{
+		"age":  "This field is required",
+		"plan": "Must be one of: free pro",
+	}, errs)
+	assert.Equal(t, map[string]string{
+		"metadata[age]":  "This field is required",
+		"metadata[plan]": "Must be one of: free pro",
+	}, MetadataErrors(errs))
+	assert.Equal(
+		t,
+		"Invalid custom fields (age: This field is required; plan: Must be one of: free pro).",
+		DescribeMetadataErrors(errs),
+	)
+
+	// the keys which are not defined are kept as they are
+	meta = map[string]string{"age": "30", "ciy": " Skopje "}
+	errs = fields.ApplyTo(meta)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{"age": "30", "plan": "free", "ciy": " Skopje "}, meta)
+
+	// the metadata is free-form without custom fields
+	meta = map[string]string{"anything": "goes"}
+	assert.Empty(t, CustomFields{}.ApplyTo(meta))
+	assert.Equal(t, map[string]string{"anything": "goes"}, meta)
+}
@@ -0,0 +1,54 @@
+package entities
+
+import "time"
+
+// Statuses of the validated e-mail addresses.
+const (
+	// EmailStatusValid indicates an address which passed all of the checks.
+	EmailStatusValid = "valid"
+	// EmailStatusRisky indicates an address which failed checks that only warn.
+	EmailStatusRisky = "risky"
+	// EmailStatusInvalid indicates an address which failed a check that rejects it,
+	// such addresses are not stored.
+	EmailStatusInvalid = "invalid"
+)
+
+// Actions which are taken when an address fails a check.
+const (
+	EmailActionReject = "reject"
+	EmailActionWarn   = "warn"
+	EmailActionAccept = "accept"
+)
+
+// EmailValidation is the outcome of the validation of an e-mail address.
+type EmailValidation struct {
+	Status string       `json:"status"`
+	Issues []EmailIssue `json:"issues"`
+	// Suggestion is the address with the typo in the domain fixed, e.g. jane@gmail.com for jane@gmial.com.
+	Suggestion string    `json:"suggestion,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// EmailIssue is a check the address failed, along with the action which was taken.
+type EmailIssue struct {
+	Check  string `json:"check"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// IsRejected returns whether the address failed a check that rejects it.
+func (v *EmailValidation) IsRejected() bool {
+	return v.Status == EmailStatusInvalid
+}
+
+// Reason returns the reason of the first issue which rejected the address, or of the first
+// issue when none of them rejected it.
+func (v *EmailValidation) Reason() string {
+	for _, i := range v.Issues {
+		if v.IsRejected() && i.Action != EmailActionReject {
+			continue
+		}
+		return i.Reason
+	}
+	return ""
+}
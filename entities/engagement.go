@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// EngagementWindow is the period of the campaigns the engagement scores are computed from.
+const EngagementWindow = 180 * 24 * time.Hour
+
+// EngagementStats holds the number of the campaigns in the engagement window which were sent to
+// a subscriber, and which were opened and clicked by the subscriber.
+type EngagementStats struct {
+	Received      int64
+	Opened        int64
+	Clicked       int64
+	LastEngagedAt *time.Time
+}
+
+// Score rates the engagement of the subscriber from 0 to 100 by the recency and the frequency of
+// the opens and the clicks. The recency decreases linearly from the last open or click to the end
+// of the engagement window, and the frequency is the rate of the received campaigns which were
+// opened or clicked, with the clicks weighed in once more on their own.
+func (s EngagementStats) Score(now time.Time) int64 {
+	var recency, frequency, clicks float64
+	if s.LastEngagedAt != nil {
+		recency = 1 - float64(now.Sub(*s.LastEngagedAt))/float64(EngagementWindow)
+		recency = math.Max(0, math.Min(1, recency))
+	}
+	if s.Received > 0 {
+		engaged := s.Opened
+		if s.Clicked > engaged {
+			engaged = s.Clicked
+		}
+		frequency = math.Min(1, float64(engaged)/float64(s.Received))
+		clicks = math.Min(1, float64(s.Clicked)/float64(s.Received))
+	}
+
+	return int64(math.Round(100 * (0.4*recency + 0.4*frequency + 0.2*clicks)))
+}
+
+// SunsetPolicy deactivates the subscribers of the user who haven't opened or clicked any of the
+// last UnengagedCampaigns campaigns they received, when it's enabled. It's enforced by the
+// scheduler along with the scoring of the engagement.
+type SunsetPolicy struct {
+	Model
+	UserID             int64 `json:"-" gorm:"column:user_id; index"`
+	Enabled            bool  `json:"enabled"`
+	UnengagedCampaigns int64 `json:"unengaged_campaigns"`
+}
@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngagementScore(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name  string
+		stats EngagementStats
+		want  int64
+	}{
+		{name: "never engaged", stats: EngagementStats{Received: 10}, want: 0},
+		{name: "no campaigns", stats: EngagementStats{}, want: 0},
+		{name: "opens and clicks everything now", stats: EngagementStats{Received: 4, Opened: 4, Clicked: 4, LastEngagedAt: &now}, want: 100},
+		{name: "opens everything now", stats: EngagementStats{Received: 4, Opened: 4, LastEngagedAt: &now}, want: 80},
+		{name: "opens half", stats: EngagementStats{Received: 4, Opened: 2, Clicked: 1, LastEngagedAt: &now}, want: 65},
+		{name: "clicks without opens", stats: EngagementStats{Received: 2, Clicked: 2, LastEngagedAt: &now}, want: 100},
+		{name: "half the window ago", stats: EngagementStats{Received: 4, Opened: 4, LastEngagedAt: ago(EngagementWindow / 2)}, want: 60},
+		{name: "out of the window", stats: EngagementStats{LastEngagedAt: ago(2 * EngagementWindow)}, want: 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.stats.Score(now), tt.name)
+	}
+}
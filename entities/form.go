@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Form represents a hosted subscription form owned by a user. The subscribers who
+// sign up through the form are added to its segments, along with the metadata
+// fields the form collects.
+type Form struct {
+	Model
+	UUID            string    `json:"uuid"`
+	UserID          int64     `json:"-" gorm:"column:user_id; index"`
+	Name            string    `json:"name"`
+	Segments        []Segment `json:"segments" gorm:"many2many:forms_segments;"`
+	FieldsJSON      JSON      `json:"fields" gorm:"column:fields; type:json"`
+	SuccessRedirect string    `json:"success_redirect"`
+	// DoubleOptIn creates the subscribers as pending and sends them a confirmation email
+	// from the ConfirmationSource address, using the optional ConfirmationTemplateID.
+	DoubleOptIn            bool   `json:"double_opt_in"`
+	ConfirmationSource     string `json:"confirmation_source"`
+	ConfirmationTemplateID *int64 `json:"confirmation_template_id"`
+}
+
+// GetFields returns the names of the metadata fields the form collects.
+func (f *Form) GetFields() ([]string, error) {
+	var fields []string
+	if f.FieldsJSON.IsNull() {
+		return fields, nil
+	}
+
+	err := json.Unmarshal(f.FieldsJSON, &fields)
+	return fields, err
+}
+
+func (f Form) GetID() int64 {
+	return f.Model.ID
+}
+
+func (f Form) GetCreatedAt() time.Time {
+	return f.Model.CreatedAt
+}
+
+func (f Form) GetUpdatedAt() time.Time {
+	return f.Model.UpdatedAt
+}
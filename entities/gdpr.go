@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"time"
+)
+
+// SubscriberData is everything that is stored about a subscriber, it's the archive returned
+// to the data subject access requests.
+type SubscriberData struct {
+	ExportedAt   time.Time         `json:"exported_at"`
+	Subscriber   *Subscriber       `json:"subscriber"`
+	Events       []SubscriberEvent `json:"events"`
+	SendLogs     []SendLog         `json:"send_logs"`
+	Sends        []Send            `json:"sends"`
+	Deliveries   []Delivery        `json:"deliveries"`
+	Opens        []Open            `json:"opens"`
+	Clicks       []Click           `json:"clicks"`
+	Bounces      []Bounce          `json:"bounces"`
+	Complaints   []Complaint       `json:"complaints"`
+	Suppressions []Suppression     `json:"suppressions"`
+}
+
+// GDPRErasure is the audit record of the erasure of a subscriber's data. The subscriber is
+// identified only by the id and the hash of the email, see HashEmail, since the email itself
+// is erased.
+type GDPRErasure struct {
+	Model
+	UserID       int64  `json:"-" gorm:"column:user_id; index"`
+	SubscriberID int64  `json:"subscriber_id"`
+	EmailHash    string `json:"email_hash"`
+	// ErasedBy is the username of the user who erased the subscriber, and IPAddress is
+	// the address the request came from.
+	ErasedBy  string `json:"erased_by"`
+	IPAddress string `json:"ip_address"`
+	// Summary holds the number of the deleted or anonymised rows by table.
+	Summary JSON `json:"summary" gorm:"type:json"`
+}
+
+func (GDPRErasure) TableName() string {
+	return "gdpr_erasures"
+}
+
+func (e GDPRErasure) GetID() int64 {
+	return e.Model.ID
+}
+
+func (e GDPRErasure) GetCreatedAt() time.Time {
+	return e.Model.CreatedAt
+}
+
+func (e GDPRErasure) GetUpdatedAt() time.Time {
+	return e.Model.UpdatedAt
+}
@@ -0,0 +1,32 @@
+package params
+
+import "strings"
+
+// PutCustomField represents request body for PUT /api/custom-fields/{id}, the key
+// and the type of the fields can't be changed.
+type PutCustomField struct {
+	Label        string   `form:"label" validate:"required,max=191"`
+	Required     bool     `form:"required"`
+	DefaultValue string   `form:"default_value" validate:"omitempty,max=191"`
+	Options      []string `form:"options[]" validate:"omitempty,max=100,dive,required,max=191"`
+}
+
+func (p *PutCustomField) TrimSpaces() {
+	p.Label = strings.TrimSpace(p.Label)
+	p.DefaultValue = strings.TrimSpace(p.DefaultValue)
+	for i := range p.Options {
+		p.Options[i] = strings.TrimSpace(p.Options[i])
+	}
+}
+
+// PostCustomField represents request body for POST /api/custom-fields
+type PostCustomField struct {
+	Key  string `form:"key" validate:"required,max=191,alphanumhyphen"`
+	Type string `form:"type" validate:"required,oneof=string number date bool enum"`
+	PutCustomField
+}
+
+func (p *PostCustomField) TrimSpaces() {
+	p.Key = strings.TrimSpace(p.Key)
+	p.PutCustomField.TrimSpaces()
+}
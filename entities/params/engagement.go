@@ -0,0 +1,13 @@
+package params
+
+// PutSunsetPolicy represents request body for PUT /api/sunset-policy
+type PutSunsetPolicy struct {
+	Enabled bool `form:"enabled"`
+	// UnengagedCampaigns is the number of the campaigns without opens or clicks after which
+	// the subscribers are deactivated.
+	UnengagedCampaigns int64 `form:"unengaged_campaigns" validate:"required,min=3,max=1000"`
+}
+
+func (p *PutSunsetPolicy) TrimSpaces() {
+	// no-op
+}
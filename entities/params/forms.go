@@ -0,0 +1,40 @@
+package params
+
+import "strings"
+
+// Form represents request body for POST /api/forms & PUT /api/forms/{id}
+type Form struct {
+	Name            string   `form:"name" validate:"required,max=191"`
+	SegmentIDs      []int64  `form:"segments[]" validate:"required"`
+	Fields          []string `form:"fields[]" validate:"omitempty,max=50,dive,required,max=191,alphanumhyphen"`
+	SuccessRedirect string   `form:"success_redirect" validate:"omitempty,url,max=191"`
+	// DoubleOptIn sends a confirmation email to the subscribers who sign up through
+	// the form, from the ConfirmationSource address.
+	DoubleOptIn            bool   `form:"double_opt_in"`
+	ConfirmationTemplateID int64  `form:"confirmation_template_id" validate:"omitempty,min=1"`
+	ConfirmationSource     string `form:"confirmation_source" validate:"required_if=DoubleOptIn true,omitempty,email,max=191"`
+}
+
+func (p *Form) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.SuccessRedirect = strings.TrimSpace(p.SuccessRedirect)
+	p.ConfirmationSource = strings.TrimSpace(p.ConfirmationSource)
+	for i := range p.Fields {
+		p.Fields[i] = strings.TrimSpace(p.Fields[i])
+	}
+}
+
+// PostFormSubscribe represents request body for POST /api/forms/{uuid}/subscribe
+type PostFormSubscribe struct {
+	Email    string            `form:"email" validate:"required,email,max=191"`
+	Name     string            `form:"name" validate:"omitempty,max=191"`
+	Metadata map[string]string `form:"metadata" validate:"omitempty,dive,keys,required,alphanumhyphen,endkeys,max=191"`
+}
+
+func (p *PostFormSubscribe) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+	p.Name = strings.TrimSpace(p.Name)
+	for k, v := range p.Metadata {
+		p.Metadata[k] = strings.TrimSpace(v)
+	}
+}
@@ -0,0 +1,28 @@
+package params
+
+import "strings"
+
+// PostPreferences represents request body for POST /api/preferences
+type PostPreferences struct {
+	Email      string            `form:"email" validate:"required,email"`
+	UUID       string            `form:"uuid" validate:"required,uuid"`
+	Token      string            `form:"t" validate:"required"`
+	Name       string            `form:"name" validate:"omitempty,max=191"`
+	Metadata   map[string]string `form:"metadata" validate:"omitempty,dive,keys,required,alphanumhyphen,endkeys,max=191"`
+	SegmentIDs []int64           `form:"segments[]"`
+	// Pause pauses the e-mails to the subscriber for 30 days, the e-mails are resumed
+	// when an already paused subscriber saves the preferences without it.
+	Pause bool `form:"pause"`
+	// Unsubscribe deactivates the subscriber, the rest of the preferences are ignored.
+	Unsubscribe bool `form:"unsubscribe"`
+}
+
+func (p *PostPreferences) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+	p.UUID = strings.TrimSpace(p.UUID)
+	p.Token = strings.TrimSpace(p.Token)
+	p.Name = strings.TrimSpace(p.Name)
+	for k, v := range p.Metadata {
+		p.Metadata[k] = strings.TrimSpace(v)
+	}
+}
@@ -6,7 +6,7 @@ import "strings"
 type GetSignedURL struct {
 	Filename    string `form:"filename" validate:"required,max=191"`
 	ContentType string `form:"content_type" validate:"required,max=191"`
-	Action      string `form:"action" validate:"required,oneof=import export remove"`
+	Action      string `form:"action" validate:"required,oneof=import export remove import_template import_suppressions"`
 }
 
 func (p *GetSignedURL) TrimSpaces() {
@@ -7,10 +7,26 @@ import (
 // Segment represents request body for POST /api/segments & PUT /api/segments/{id}
 type Segment struct {
 	Name string `form:"name" validate:"required,max=191"`
+	// Public segments are listed in the preference center of the subscribers.
+	Public bool `form:"public"`
+	// Dynamic segments resolve their subscribers by the rule tree, the rules are
+	// a JSON encoded entities.SegmentRule. A segment can't be changed to or from dynamic.
+	Dynamic bool   `form:"dynamic"`
+	Rules   string `form:"rules" validate:"required_if=Dynamic true"`
 }
 
 func (p *Segment) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
+	p.Rules = strings.TrimSpace(p.Rules)
+}
+
+// SegmentRules represents request body for POST /api/segments/preview
+type SegmentRules struct {
+	Rules string `form:"rules" validate:"required"`
+}
+
+func (p *SegmentRules) TrimSpaces() {
+	p.Rules = strings.TrimSpace(p.Rules)
 }
 
 // SegmentSubs represents request body for PUT /api/segments/{id}/subscribers
@@ -0,0 +1,13 @@
+package params
+
+import "strings"
+
+// Snippet represents request body for POST /api/snippets & PUT /api/snippets/{id}
+type Snippet struct {
+	Name string `form:"name" validate:"required,max=191,alphanumhyphen"`
+	Body string `form:"body" validate:"required"`
+}
+
+func (p *Snippet) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
@@ -1,6 +1,9 @@
 package params
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // PostSubscriber represents request body for POST /api/subscribers
 type PostSubscriber struct {
@@ -8,10 +11,60 @@ type PostSubscriber struct {
 	Email      string            `form:"email" validate:"required,email"`
 	SegmentIDs []int64           `form:"segments[]" validate:"omitempty"`
 	Metadata   map[string]string `form:"metadata" validate:"omitempty,dive,keys,required,alphanumhyphen,endkeys,required"`
+	// DoubleOptIn creates a pending subscriber and sends a confirmation email from
+	// the ConfirmationSource address, using the optional ConfirmationTemplateID.
+	DoubleOptIn            bool   `form:"double_opt_in"`
+	ConfirmationTemplateID int64  `form:"confirmation_template_id" validate:"omitempty,min=1"`
+	ConfirmationSource     string `form:"confirmation_source" validate:"required_if=DoubleOptIn true,omitempty,email,max=191"`
+	// ConsentIP is the ip the subscriber signed up from, e.g. when the subscriber is added
+	// by a signup form on another site. It defaults to the ip of the request.
+	ConsentIP string `form:"consent_ip" validate:"omitempty,ip"`
 }
 
 func (p *PostSubscriber) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
+	p.ConfirmationSource = strings.TrimSpace(p.ConfirmationSource)
+	p.ConsentIP = strings.TrimSpace(p.ConsentIP)
+}
+
+// GetSubscribers represents the query params of GET /api/subscribers. The metadata conditions
+// are bound from the metadata[key], metadata_contains[key] and metadata_exists[] params.
+type GetSubscribers struct {
+	Email            string            `form:"email" validate:"omitempty,max=191"`
+	Name             string            `form:"name" validate:"omitempty,max=191"`
+	Active           *bool             `form:"active"`
+	Blacklisted      *bool             `form:"blacklisted"`
+	SegmentIDs       []int64           `form:"segments[]" validate:"omitempty,max=50,dive,min=1"`
+	CreatedAfter     *time.Time        `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore    *time.Time        `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Metadata         map[string]string `form:"-" validate:"omitempty,max=20,dive,keys,required,alphanumhyphen,max=191,endkeys,max=191"`
+	MetadataContains map[string]string `form:"-" validate:"omitempty,max=20,dive,keys,required,alphanumhyphen,max=191,endkeys,required,max=191"`
+	MetadataExists   []string          `form:"metadata_exists[]" validate:"omitempty,max=20,dive,required,alphanumhyphen,max=191"`
+	// MinEngagementScore and MaxEngagementScore bound the engagement scores of the subscribers, and
+	// LastEngagedAfter and LastEngagedBefore the times of their last opens or clicks.
+	MinEngagementScore *int64     `form:"min_engagement_score" validate:"omitempty,min=0,max=100"`
+	MaxEngagementScore *int64     `form:"max_engagement_score" validate:"omitempty,min=0,max=100"`
+	LastEngagedAfter   *time.Time `form:"last_engaged_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastEngagedBefore  *time.Time `form:"last_engaged_before" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+func (p *GetSubscribers) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+	p.Name = strings.TrimSpace(p.Name)
+	for i := range p.MetadataExists {
+		p.MetadataExists[i] = strings.TrimSpace(p.MetadataExists[i])
+	}
+}
+
+// GetSubscriberActivity represents the query params of GET /api/subscribers/:id/activity.
+type GetSubscriberActivity struct {
+	PerPage       int64    `form:"per_page" validate:"omitempty,min=1,max=100"`
+	StartingAfter string   `form:"starting_after" validate:"omitempty,max=191"`
+	Types         []string `form:"types[]" validate:"omitempty,max=20,dive,oneof=send_log send delivery open click bounce complaint created deleted unsubscribed confirmed updated segment_joined segment_left paused resumed merged sunset"`
+}
+
+func (p *GetSubscriberActivity) TrimSpaces() {
+	p.StartingAfter = strings.TrimSpace(p.StartingAfter)
 }
 
 // PutSubscriber represents request body for PUT /api/subscribers/:id
@@ -25,6 +78,16 @@ func (p *PutSubscriber) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
 }
 
+// MergeSubscribers represents request body for POST /api/subscribers/:id/merge, the ids are
+// the duplicates which are merged into the subscriber.
+type MergeSubscribers struct {
+	IDs []int64 `form:"ids[]" validate:"required,max=100,dive,min=1"`
+}
+
+func (p *MergeSubscribers) TrimSpaces() {
+	// no-op
+}
+
 // PostUnsubscribe represents request body for POST /api/unsubscribe
 type PostUnsubscribe struct {
 	Email string `form:"email" validate:"required,email"`
@@ -38,14 +101,77 @@ func (p *PostUnsubscribe) TrimSpaces() {
 	p.Token = strings.TrimSpace(p.Token)
 }
 
-// ImportSubscribers represents request body for POST /api/subscribers/import
+// PostConfirmSubscription represents request body for POST /api/confirm-subscription
+type PostConfirmSubscription struct {
+	Email string `form:"email" validate:"required,email"`
+	UUID  string `form:"uuid" validate:"required,uuid"`
+	Token string `form:"t" validate:"required"`
+}
+
+func (p *PostConfirmSubscription) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+	p.UUID = strings.TrimSpace(p.UUID)
+	p.Token = strings.TrimSpace(p.Token)
+}
+
+// ImportSubscribers represents request body for POST /api/subscribers/import. The mapping
+// of the columns is bound from the mapping[header] params.
 type ImportSubscribers struct {
-	Filename   string  `form:"filename" validate:"required"`
-	SegmentIDs []int64 `form:"segments[]" validate:"omitempty"`
+	Filename   string            `form:"filename" validate:"required"`
+	SegmentIDs []int64           `form:"segments[]" validate:"omitempty"`
+	Mode       string            `form:"mode" validate:"omitempty,oneof=skip update replace"`
+	Format     string            `form:"format" validate:"omitempty,oneof=csv ndjson json xlsx"`
+	Sheet      string            `form:"sheet" validate:"omitempty,max=191"`
+	Mapping    map[string]string `form:"-" validate:"omitempty,max=100,dive,keys,required,max=191,endkeys,required,max=191,alphanumhyphen"`
 }
 
 func (p *ImportSubscribers) TrimSpaces() {
 	p.Filename = strings.TrimSpace(p.Filename)
+	p.Format = strings.TrimSpace(p.Format)
+}
+
+// PreviewImportSubscribers represents request body for POST /api/subscribers/import/preview.
+// The format of the file is detected when it's not given.
+type PreviewImportSubscribers struct {
+	Filename string `form:"filename" validate:"required"`
+	Format   string `form:"format" validate:"omitempty,oneof=csv ndjson json xlsx"`
+	Sheet    string `form:"sheet" validate:"omitempty,max=191"`
+}
+
+func (p *PreviewImportSubscribers) TrimSpaces() {
+	p.Filename = strings.TrimSpace(p.Filename)
+	p.Format = strings.TrimSpace(p.Format)
+}
+
+// BulkUpdateSubscribers represents request body for POST /api/subscribers/bulk-update.
+// The format of the file is detected when it's not given.
+type BulkUpdateSubscribers struct {
+	Filename string `form:"filename" validate:"required"`
+	Format   string `form:"format" validate:"omitempty,oneof=csv ndjson json xlsx"`
+	Sheet    string `form:"sheet" validate:"omitempty,max=191"`
+	DryRun   bool   `form:"dry_run"`
+}
+
+func (p *BulkUpdateSubscribers) TrimSpaces() {
+	p.Filename = strings.TrimSpace(p.Filename)
+	p.Format = strings.TrimSpace(p.Format)
+}
+
+// PostSubscriberBulkAction represents request body for POST /api/subscribers/bulk-action. The
+// subscribers are filtered by the same criteria as GET /api/subscribers, the metadata conditions
+// are bound from the metadata[key] and metadata_contains[key] params.
+type PostSubscriberBulkAction struct {
+	GetSubscribers
+	Action        string `form:"action" validate:"required,oneof=add_to_segment remove_from_segment set_metadata deactivate blacklist delete"`
+	SegmentID     int64  `form:"segment_id" validate:"omitempty,min=1"`
+	MetadataKey   string `form:"metadata_key" validate:"omitempty,alphanumhyphen,max=191"`
+	MetadataValue string `form:"metadata_value" validate:"omitempty,max=191"`
+}
+
+func (p *PostSubscriberBulkAction) TrimSpaces() {
+	p.GetSubscribers.TrimSpaces()
+	p.MetadataKey = strings.TrimSpace(p.MetadataKey)
+	p.MetadataValue = strings.TrimSpace(p.MetadataValue)
 }
 
 // BulkRemoveSubscribers represents request body for POST /api/subscribers/bulk-remove
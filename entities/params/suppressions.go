@@ -0,0 +1,26 @@
+package params
+
+import "strings"
+
+// PostSuppression represents request body for POST /api/suppressions
+type PostSuppression struct {
+	// Value is the email, e.g. jane@example.com, or the domain, e.g. example.com, to suppress.
+	Value  string `form:"value" validate:"required,max=191"`
+	Reason string `form:"reason" validate:"omitempty,oneof=bounce complaint manual unsubscribed"`
+	Source string `form:"source" validate:"omitempty,max=191"`
+}
+
+func (p *PostSuppression) TrimSpaces() {
+	p.Value = strings.TrimSpace(p.Value)
+	p.Reason = strings.TrimSpace(p.Reason)
+	p.Source = strings.TrimSpace(p.Source)
+}
+
+// ImportSuppressions represents request body for POST /api/suppressions/import
+type ImportSuppressions struct {
+	Filename string `form:"filename" validate:"required,max=191"`
+}
+
+func (p *ImportSuppressions) TrimSpaces() {
+	p.Filename = strings.TrimSpace(p.Filename)
+}
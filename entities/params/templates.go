@@ -4,10 +4,14 @@ import "strings"
 
 // PostTemplate represents request body for POST /api/templates
 type PostTemplate struct {
-	Name        string `form:"name" validate:"required,max=191"`
-	HTMLPart    string `form:"html_part" validate:"required,html"`
-	TextPart    string `form:"text_part" validate:"required"`
-	SubjectPart string `form:"subject_part" validate:"required,max=191"`
+	Name               string `form:"name" validate:"required,max=191"`
+	HTMLPart           string `form:"html_part" validate:"required,html"`
+	TextPart           string `form:"text_part"`
+	SubjectPart        string `form:"subject_part" validate:"required,max=191"`
+	LayoutID           int64  `form:"layout_id" validate:"omitempty,min=0"`
+	Lint               bool   `form:"lint"`
+	DisableCSSInlining bool   `form:"disable_css_inlining"`
+	Engine             string `form:"engine" validate:"omitempty,oneof=mustache go"`
 }
 
 func (p *PostTemplate) TrimSpaces() {
@@ -17,13 +21,74 @@ func (p *PostTemplate) TrimSpaces() {
 
 // PutTemplate represents request body for PUT /api/templates
 type PutTemplate struct {
-	HTMLPart    string `form:"html_part" validate:"required,html"`
-	TextPart    string `form:"text_part" validate:"required"`
-	SubjectPart string `form:"subject_part" validate:"required,max=191"`
-	Name        string `form:"name" validate:"required,max=191"`
+	HTMLPart           string `form:"html_part" validate:"required,html"`
+	TextPart           string `form:"text_part"`
+	SubjectPart        string `form:"subject_part" validate:"required,max=191"`
+	Name               string `form:"name" validate:"required,max=191"`
+	LayoutID           int64  `form:"layout_id" validate:"omitempty,min=0"`
+	Lint               bool   `form:"lint"`
+	DisableCSSInlining bool   `form:"disable_css_inlining"`
+	Engine             string `form:"engine" validate:"omitempty,oneof=mustache go"`
 }
 
 func (p *PutTemplate) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
 	p.SubjectPart = strings.TrimSpace(p.SubjectPart)
 }
+
+// LintTemplate represents request body for POST /api/templates/lint
+type LintTemplate struct {
+	Name        string `form:"name" validate:"max=191"`
+	HTMLPart    string `form:"html_part" validate:"required"`
+	TextPart    string `form:"text_part"`
+	SubjectPart string `form:"subject_part" validate:"max=191"`
+	LayoutID    int64  `form:"layout_id" validate:"omitempty,min=0"`
+	Engine      string `form:"engine" validate:"omitempty,oneof=mustache go"`
+}
+
+func (p *LintTemplate) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.SubjectPart = strings.TrimSpace(p.SubjectPart)
+}
+
+// PostTextPart represents request body for POST /api/templates/text
+type PostTextPart struct {
+	Name     string `form:"name" validate:"max=191"`
+	HTMLPart string `form:"html_part" validate:"required"`
+	LayoutID int64  `form:"layout_id" validate:"omitempty,min=0"`
+	Links    string `form:"links" validate:"omitempty,oneof=footnotes inline"`
+	Engine   string `form:"engine" validate:"omitempty,oneof=mustache go"`
+}
+
+func (p *PostTextPart) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Links = strings.TrimSpace(p.Links)
+}
+
+// ImportTemplate represents request body for POST /api/templates/import
+type ImportTemplate struct {
+	Filename           string `form:"filename" validate:"required,max=191,excludesall=/\\"`
+	Name               string `form:"name" validate:"required,max=191"`
+	SubjectPart        string `form:"subject_part" validate:"max=191"`
+	TextPart           string `form:"text_part"`
+	LayoutID           int64  `form:"layout_id" validate:"omitempty,min=0"`
+	DisableCSSInlining bool   `form:"disable_css_inlining"`
+	Engine             string `form:"engine" validate:"omitempty,oneof=mustache go"`
+}
+
+func (p *ImportTemplate) TrimSpaces() {
+	p.Filename = strings.TrimSpace(p.Filename)
+	p.Name = strings.TrimSpace(p.Name)
+	p.SubjectPart = strings.TrimSpace(p.SubjectPart)
+}
+
+// SampleDataSet represents request body for POST /api/templates/{id}/sample-data-sets
+// & PUT /api/templates/{id}/sample-data-sets/{set_id}
+type SampleDataSet struct {
+	Name string            `form:"name" validate:"required,max=191"`
+	Data map[string]string `form:"data" validate:"omitempty,dive,keys,required,alphanumhyphen,endkeys"`
+}
+
+func (p *SampleDataSet) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
@@ -5,7 +5,8 @@ const (
 	StatusDone       = "done"
 	StatusInProgress = "in_progress"
 
-	SubscribersResource = "subscribers"
+	SubscribersResource  = "subscribers"
+	SuppressionsResource = "suppressions"
 )
 
 // Report represents the Report entity
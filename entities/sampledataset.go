@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SampleDataSet represents a named set of template data which is stored on a template
+// to preview it with, e.g. a subscriber with and without a discount code.
+type SampleDataSet struct {
+	Model
+	UserID     int64             `json:"-" gorm:"column:user_id; index"`
+	TemplateID int64             `json:"template_id" gorm:"column:template_id; index"`
+	Name       string            `json:"name"`
+	DataJSON   JSON              `json:"data" gorm:"column:data; type:json"`
+	Data       map[string]string `json:"-" sql:"-"`
+}
+
+// GetData returns the sample data.
+func (s *SampleDataSet) GetData() (map[string]string, error) {
+	m := make(map[string]string)
+
+	if !s.DataJSON.IsNull() {
+		err := json.Unmarshal(s.DataJSON, &m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.Data = m
+
+	return m, nil
+}
+
+func (s SampleDataSet) GetID() int64 {
+	return s.Model.ID
+}
+
+func (s SampleDataSet) GetCreatedAt() time.Time {
+	return s.Model.CreatedAt
+}
+
+func (s SampleDataSet) GetUpdatedAt() time.Time {
+	return s.Model.UpdatedAt
+}
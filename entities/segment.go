@@ -1,17 +1,40 @@
 package entities
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // Segment represents the group of subscribers entity
 type Segment struct {
 	Model
-	Name        string       `json:"name" gorm:"not null" valid:"required,stringlength(1|191)"`
-	UserID      int64        `json:"-" gorm:"column:user_id; index"`
+	Name   string `json:"name" gorm:"not null" valid:"required,stringlength(1|191)"`
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	// Public segments are listed in the preference center, where the subscribers
+	// can join and leave them.
+	Public bool `json:"public"`
+	// Dynamic segments have no members of their own, the subscribers who match the
+	// rules are resolved when the segment is used.
+	Dynamic     bool         `json:"dynamic"`
+	RulesJSON   JSON         `json:"rules,omitempty" gorm:"column:rules; type:json"`
 	Subscribers []Subscriber `json:"-" gorm:"many2many:subscribers_segments;"`
 }
 
+// GetRules returns the rule tree of the dynamic segment.
+func (s *Segment) GetRules() (*SegmentRule, error) {
+	r := new(SegmentRule)
+	if s.RulesJSON.IsNull() {
+		return r, nil
+	}
+
+	err := json.Unmarshal(s.RulesJSON, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
 // SegmentWithTotalSubs represents the segment entity with
 // extra information regarding the total count of subscribers.
 // this entity is needed because we run a custom query for the paginated
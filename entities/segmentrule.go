@@ -0,0 +1,256 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Combinators of the rule groups.
+const (
+	RuleCombinatorAnd = "and"
+	RuleCombinatorOr  = "or"
+)
+
+// Subscriber fields the rules of the dynamic segments can match.
+const (
+	RuleFieldEmail       = "email"
+	RuleFieldName        = "name"
+	RuleFieldActive      = "active"
+	RuleFieldBlacklisted = "blacklisted"
+	RuleFieldMetadata    = "metadata"
+	RuleFieldCreatedAt   = "created_at"
+	RuleFieldSegment     = "segment"
+	// The engagement fields match the subscribers by their opens, clicks, bounces
+	// and complaints, optionally of one campaign or of the last days.
+	RuleFieldOpened     = "opened"
+	RuleFieldClicked    = "clicked"
+	RuleFieldBounced    = "bounced"
+	RuleFieldComplained = "complained"
+)
+
+// Operators of the rule conditions.
+const (
+	RuleOperatorEquals        = "equals"
+	RuleOperatorNotEquals     = "not_equals"
+	RuleOperatorContains      = "contains"
+	RuleOperatorNotContains   = "not_contains"
+	RuleOperatorStartsWith    = "starts_with"
+	RuleOperatorEndsWith      = "ends_with"
+	RuleOperatorExists        = "exists"
+	RuleOperatorNotExists     = "not_exists"
+	RuleOperatorBefore        = "before"
+	RuleOperatorAfter         = "after"
+	RuleOperatorWithinDays    = "within_days"
+	RuleOperatorOlderThanDays = "older_than_days"
+	RuleOperatorIn            = "in"
+	RuleOperatorNotIn         = "not_in"
+	RuleOperatorAny           = "any"
+	RuleOperatorNone          = "none"
+	// The greater_than and less_than operators compare the metadata of the number custom fields,
+	// and the before and after operators the metadata of the date custom fields.
+	RuleOperatorGreaterThan = "greater_than"
+	RuleOperatorLessThan    = "less_than"
+)
+
+const (
+	maxRuleDepth = 5
+	maxRules     = 50
+)
+
+var (
+	ErrInvalidSegmentRule = errors.New("invalid segment rule")
+
+	ruleMetadataKey = regexp.MustCompile(`^[\w-]+$`)
+
+	// ruleOperators are the operators supported by each field.
+	ruleOperators = map[string][]string{
+		RuleFieldEmail:       {RuleOperatorEquals, RuleOperatorNotEquals, RuleOperatorContains, RuleOperatorNotContains, RuleOperatorStartsWith, RuleOperatorEndsWith},
+		RuleFieldName:        {RuleOperatorEquals, RuleOperatorNotEquals, RuleOperatorContains, RuleOperatorNotContains, RuleOperatorStartsWith, RuleOperatorEndsWith},
+		RuleFieldActive:      {RuleOperatorEquals},
+		RuleFieldBlacklisted: {RuleOperatorEquals},
+		RuleFieldMetadata:    {RuleOperatorEquals, RuleOperatorNotEquals, RuleOperatorContains, RuleOperatorNotContains, RuleOperatorStartsWith, RuleOperatorEndsWith, RuleOperatorExists, RuleOperatorNotExists, RuleOperatorGreaterThan, RuleOperatorLessThan, RuleOperatorBefore, RuleOperatorAfter},
+		RuleFieldCreatedAt:   {RuleOperatorBefore, RuleOperatorAfter, RuleOperatorWithinDays, RuleOperatorOlderThanDays},
+		RuleFieldSegment:     {RuleOperatorIn, RuleOperatorNotIn},
+		RuleFieldOpened:      {RuleOperatorAny, RuleOperatorNone},
+		RuleFieldClicked:     {RuleOperatorAny, RuleOperatorNone},
+		RuleFieldBounced:     {RuleOperatorAny, RuleOperatorNone},
+		RuleFieldComplained:  {RuleOperatorAny, RuleOperatorNone},
+	}
+)
+
+// SegmentRule is a node of the rule tree of a dynamic segment. A node is either a group,
+// which joins its rules with the combinator, or a condition on a field of the subscribers.
+type SegmentRule struct {
+	Combinator string        `json:"combinator,omitempty"`
+	Rules      []SegmentRule `json:"rules,omitempty"`
+
+	Field    string `json:"field,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	// Key is the name of the metadata field.
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	// SegmentID is the static segment of the segment conditions.
+	SegmentID int64 `json:"segment_id,omitempty"`
+	// CampaignID and Days narrow down the engagement conditions to one campaign
+	// and to the last days, and Days is the number of days of the created_at conditions.
+	CampaignID int64 `json:"campaign_id,omitempty"`
+	Days       int   `json:"days,omitempty"`
+}
+
+// IsGroup checks whether the rule is a group of rules.
+func (r *SegmentRule) IsGroup() bool {
+	return r.Combinator != ""
+}
+
+// Validate checks the rule tree, the returned errors wrap ErrInvalidSegmentRule.
+func (r *SegmentRule) Validate() error {
+	count := 0
+	return r.validate(1, &count)
+}
+
+func (r *SegmentRule) validate(depth int, count *int) error {
+	*count++
+	if *count > maxRules {
+		return fmt.Errorf("%w: a segment can't have more than %d rules", ErrInvalidSegmentRule, maxRules)
+	}
+
+	if r.IsGroup() {
+		if r.Combinator != RuleCombinatorAnd && r.Combinator != RuleCombinatorOr {
+			return fmt.Errorf("%w: unknown combinator '%s'", ErrInvalidSegmentRule, r.Combinator)
+		}
+		if depth >= maxRuleDepth {
+			return fmt.Errorf("%w: the groups can't be nested more than %d levels deep", ErrInvalidSegmentRule, maxRuleDepth)
+		}
+		if len(r.Rules) == 0 {
+			return fmt.Errorf("%w: a group must have at least one rule", ErrInvalidSegmentRule)
+		}
+		for i := range r.Rules {
+			if err := r.Rules[i].validate(depth+1, count); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ops, ok := ruleOperators[r.Field]
+	if !ok {
+		return fmt.Errorf("%w: unknown field '%s'", ErrInvalidSegmentRule, r.Field)
+	}
+	if !contains(ops, r.Operator) {
+		return fmt.Errorf("%w: the field '%s' does not support the operator '%s'", ErrInvalidSegmentRule, r.Field, r.Operator)
+	}
+
+	switch r.Field {
+	case RuleFieldEmail, RuleFieldName:
+		if r.Value == "" {
+			return fmt.Errorf("%w: the field '%s' requires a value", ErrInvalidSegmentRule, r.Field)
+		}
+	case RuleFieldActive, RuleFieldBlacklisted:
+		if r.Value != "true" && r.Value != "false" {
+			return fmt.Errorf("%w: the value of the field '%s' must be 'true' or 'false'", ErrInvalidSegmentRule, r.Field)
+		}
+	case RuleFieldMetadata:
+		if !ruleMetadataKey.MatchString(r.Key) || len(r.Key) > 191 {
+			return fmt.Errorf("%w: the metadata key must consist only of alphanumeric and hyphen characters", ErrInvalidSegmentRule)
+		}
+		switch r.Operator {
+		case RuleOperatorGreaterThan, RuleOperatorLessThan:
+			if _, err := (&CustomField{Type: CustomFieldTypeNumber}).NormalizeValue(r.Value); err != nil {
+				return fmt.Errorf("%w: the operator '%s' requires a number", ErrInvalidSegmentRule, r.Operator)
+			}
+		case RuleOperatorBefore, RuleOperatorAfter:
+			if _, err := (&CustomField{Type: CustomFieldTypeDate}).NormalizeValue(r.Value); err != nil {
+				return fmt.Errorf("%w: the operator '%s' requires a date of format %s", ErrInvalidSegmentRule, r.Operator, CustomFieldDateLayout)
+			}
+		}
+	case RuleFieldCreatedAt:
+		switch r.Operator {
+		case RuleOperatorBefore, RuleOperatorAfter:
+			if _, err := time.Parse(time.RFC3339, r.Value); err != nil {
+				return fmt.Errorf("%w: the value of the field '%s' must be a RFC 3339 time", ErrInvalidSegmentRule, r.Field)
+			}
+		default:
+			if r.Days <= 0 {
+				return fmt.Errorf("%w: the operator '%s' requires a positive number of days", ErrInvalidSegmentRule, r.Operator)
+			}
+		}
+	case RuleFieldSegment:
+		if r.SegmentID <= 0 {
+			return fmt.Errorf("%w: the field '%s' requires a segment id", ErrInvalidSegmentRule, r.Field)
+		}
+	default:
+		if r.Days < 0 {
+			return fmt.Errorf("%w: the number of days can't be negative", ErrInvalidSegmentRule)
+		}
+	}
+
+	if len(r.Value) > 191 {
+		return fmt.Errorf("%w: the value can't be longer than 191 characters", ErrInvalidSegmentRule)
+	}
+
+	return nil
+}
+
+// ApplyCustomFields checks the metadata conditions of the validated rule tree against the custom
+// fields of the user. The number and date operators may only be used on the fields of those types,
+// and the values of the conditions on the fields are normalized by their types, see
+// CustomField.NormalizeValue. The returned errors wrap ErrInvalidSegmentRule.
+func (r *SegmentRule) ApplyCustomFields(fields CustomFields) error {
+	for i := range r.Rules {
+		if err := r.Rules[i].ApplyCustomFields(fields); err != nil {
+			return err
+		}
+	}
+	if r.IsGroup() || r.Field != RuleFieldMetadata {
+		return nil
+	}
+
+	f := fields.Find(r.Key)
+
+	var typ string
+	switch r.Operator {
+	case RuleOperatorGreaterThan, RuleOperatorLessThan:
+		typ = CustomFieldTypeNumber
+	case RuleOperatorBefore, RuleOperatorAfter:
+		typ = CustomFieldTypeDate
+	case RuleOperatorEquals, RuleOperatorNotEquals:
+		if f == nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if typ != "" && (f == nil || f.Type != typ) {
+		return fmt.Errorf("%w: the operator '%s' requires a %s custom field, '%s' is not one", ErrInvalidSegmentRule, r.Operator, typ, r.Key)
+	}
+
+	v, err := f.NormalizeValue(r.Value)
+	if err != nil {
+		return fmt.Errorf("%w: the value of the custom field '%s': %s", ErrInvalidSegmentRule, r.Key, err)
+	}
+	r.Value = v
+	return nil
+}
+
+// SegmentIDs returns the ids of the segments the rule tree refers to.
+func (r *SegmentRule) SegmentIDs() []int64 {
+	var ids []int64
+	if r.Field == RuleFieldSegment {
+		ids = append(ids, r.SegmentID)
+	}
+	for i := range r.Rules {
+		ids = append(ids, r.Rules[i].SegmentIDs()...)
+	}
+	return ids
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
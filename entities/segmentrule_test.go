@@ -0,0 +1,112 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentRuleValidate(t *testing.T) {
+	valid := &SegmentRule{
+		Combinator: RuleCombinatorAnd,
+		Rules: []SegmentRule{
+			{Field: RuleFieldOpened, Operator: RuleOperatorAny, Days: 30},
+			{
+				Combinator: RuleCombinatorOr,
+				Rules: []SegmentRule{
+					{Field: RuleFieldMetadata, Operator: RuleOperatorEquals, Key: "plan-name", Value: "pro"},
+					{Field: RuleFieldSegment, Operator: RuleOperatorIn, SegmentID: 3},
+					{Field: RuleFieldSegment, Operator: RuleOperatorNotIn, SegmentID: 4},
+				},
+			},
+			{Field: RuleFieldCreatedAt, Operator: RuleOperatorBefore, Value: "2021-01-02T15:04:05Z"},
+		},
+	}
+	assert.Nil(t, valid.Validate())
+	assert.Equal(t, []int64{3, 4}, valid.SegmentIDs())
+
+	deep := &SegmentRule{Field: RuleFieldActive, Operator: RuleOperatorEquals, Value: "true"}
+	for i := 0; i < maxRuleDepth; i++ {
+		deep = &SegmentRule{Combinator: RuleCombinatorAnd, Rules: []SegmentRule{*deep}}
+	}
+
+	tooMany := &SegmentRule{Combinator: RuleCombinatorOr}
+	for i := 0; i < maxRules; i++ {
+		tooMany.Rules = append(tooMany.Rules, SegmentRule{Field: RuleFieldBounced, Operator: RuleOperatorAny})
+	}
+
+	invalid := []*SegmentRule{
+		{Combinator: "xor", Rules: []SegmentRule{{Field: RuleFieldBounced, Operator: RuleOperatorAny}}},
+		{Combinator: RuleCombinatorAnd},
+		{Field: "password", Operator: RuleOperatorEquals, Value: "x"},
+		{Field: RuleFieldEmail, Operator: RuleOperatorAny, Value: "x"},
+		{Field: RuleFieldEmail, Operator: RuleOperatorContains},
+		{Field: RuleFieldActive, Operator: RuleOperatorEquals, Value: "yes"},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorExists, Key: "a.b"},
+		{Field: RuleFieldCreatedAt, Operator: RuleOperatorAfter, Value: "yesterday"},
+		{Field: RuleFieldCreatedAt, Operator: RuleOperatorWithinDays},
+		{Field: RuleFieldSegment, Operator: RuleOperatorIn},
+		{Field: RuleFieldClicked, Operator: RuleOperatorNone, Days: -1},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorGreaterThan, Key: "age", Value: "ten"},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorBefore, Key: "birthday", Value: "01/02/2021"},
+		deep,
+		tooMany,
+	}
+	for i, r := range invalid {
+		err := r.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidSegmentRule), "rule %d: %v", i, err)
+	}
+}
+
+func TestSegmentRuleApplyCustomFields(t *testing.T) {
+	fields := CustomFields{
+		{Key: "age", Type: CustomFieldTypeNumber},
+		{Key: "birthday", Type: CustomFieldTypeDate},
+		{Key: "vip", Type: CustomFieldTypeBool},
+	}
+
+	r := &SegmentRule{
+		Combinator: RuleCombinatorAnd,
+		Rules: []SegmentRule{
+			{Field: RuleFieldMetadata, Operator: RuleOperatorGreaterThan, Key: "age", Value: "18.0"},
+			{Field: RuleFieldMetadata, Operator: RuleOperatorAfter, Key: "birthday", Value: "2000-01-02T15:04:05Z"},
+			{Field: RuleFieldMetadata, Operator: RuleOperatorEquals, Key: "vip", Value: "1"},
+			{Field: RuleFieldMetadata, Operator: RuleOperatorEquals, Key: "city", Value: "Skopje"},
+		},
+	}
+	assert.Nil(t, r.Validate())
+	assert.Nil(t, r.ApplyCustomFields(fields))
+	assert.Equal(t, "18", r.Rules[0].Value)
+	assert.Equal(t, "2000-01-02", r.Rules[1].Value)
+	assert.Equal(t, "true", r.Rules[2].Value)
+	assert.Equal(t, "Skopje", r.Rules[3].Value)
+
+	invalid := []*SegmentRule{
+		{Field: RuleFieldMetadata, Operator: RuleOperatorGreaterThan, Key: "birthday", Value: "1"},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorLessThan, Key: "city", Value: "1"},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorBefore, Key: "age", Value: "2000-01-02"},
+		{Field: RuleFieldMetadata, Operator: RuleOperatorEquals, Key: "vip", Value: "maybe"},
+	}
+	for i, r := range invalid {
+		err := r.ApplyCustomFields(fields)
+		assert.True(t, errors.Is(err, ErrInvalidSegmentRule), "rule %d: %v", i, err)
+	}
+}
+
+func TestSegmentGetRules(t *testing.T) {
+	s := &Segment{}
+	r, err := s.GetRules()
+	assert.Nil(t, err)
+	assert.Equal(t, &SegmentRule{}, r)
+
+	s.RulesJSON = JSON(`{"combinator":"or","rules":[{"field":"bounced","operator":"any"}]}`)
+	r, err = s.GetRules()
+	assert.Nil(t, err)
+	assert.True(t, r.IsGroup())
+	assert.Len(t, r.Rules, 1)
+
+	s.RulesJSON = JSON(`{`)
+	_, err = s.GetRules()
+	assert.NotNil(t, err)
+}
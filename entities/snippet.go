@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+// Snippet represents a reusable piece of markup owned by a user, which can be
+// included in any of the user's templates as a mustache partial, e.g. {{> footer}}.
+type Snippet struct {
+	Model
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	Name   string `json:"name"`
+	Body   string `json:"body"`
+}
+
+func (s Snippet) GetID() int64 {
+	return s.Model.ID
+}
+
+func (s Snippet) GetCreatedAt() time.Time {
+	return s.Model.CreatedAt
+}
+
+func (s Snippet) GetUpdatedAt() time.Time {
+	return s.Model.UpdatedAt
+}
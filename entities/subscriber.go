@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mailbadger/app/utils"
@@ -14,14 +15,47 @@ import (
 // Subscriber represents the subscriber entity
 type Subscriber struct {
 	Model
-	UserID      int64             `json:"-" gorm:"column:user_id; index"`
-	Name        string            `json:"name"`
-	Email       string            `json:"email" gorm:"not null"`
-	MetaJSON    JSON              `json:"metadata" gorm:"column:metadata; type:json"`
-	Segments    []Segment         `json:"segments" gorm:"many2many:subscribers_segments;"`
-	Blacklisted bool              `json:"blacklisted"`
-	Active      bool              `json:"active"`
-	Metadata    map[string]string `json:"-" sql:"-"`
+	UserID      int64     `json:"-" gorm:"column:user_id; index"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email" gorm:"not null"`
+	MetaJSON    JSON      `json:"metadata" gorm:"column:metadata; type:json"`
+	Segments    []Segment `json:"segments" gorm:"many2many:subscribers_segments;"`
+	Blacklisted bool      `json:"blacklisted"`
+	Active      bool      `json:"active"`
+	// Pending is set for the subscribers who signed up with double opt-in and
+	// haven't confirmed their subscription yet, they are not active until they do.
+	Pending bool `json:"pending"`
+	// ConsentAt and ConsentIP record when and from where the subscriber signed up,
+	// ConfirmedAt and ConfirmationIP when and from where the subscription was confirmed.
+	ConsentAt      *time.Time `json:"consent_at"`
+	ConsentIP      string     `json:"consent_ip"`
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+	ConfirmationIP string     `json:"confirmation_ip"`
+	// PausedUntil is set when the subscriber paused the e-mails from the preference center,
+	// campaigns are not sent to the subscriber until then.
+	PausedUntil *time.Time `json:"paused_until"`
+	// EmailStatus is the status of the validation of the address, it's empty for the
+	// subscribers which were not validated, see EmailValidation.
+	EmailStatus         string `json:"email_status"`
+	EmailValidationJSON JSON   `json:"email_validation" gorm:"column:email_validation; type:json"`
+	// EngagementScore rates from 0 to 100 how much the subscriber engages with the campaigns, see
+	// EngagementStats.Score, and LastEngagedAt is the time of the last open or click. They are updated
+	// periodically by the scheduler, EngagementScoredAt is when they were last updated.
+	EngagementScore    int64             `json:"engagement_score"`
+	LastEngagedAt      *time.Time        `json:"last_engaged_at"`
+	EngagementScoredAt *time.Time        `json:"-"`
+	Metadata           map[string]string `json:"-" sql:"-"`
+}
+
+// SetEmailValidation stores the outcome of the validation of the address.
+func (s *Subscriber) SetEmailValidation(v *EmailValidation) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.EmailStatus = v.Status
+	s.EmailValidationJSON = b
+	return nil
 }
 
 // GetMetadata returns the subscriber's metadata fields.
@@ -69,6 +103,137 @@ func (s *Subscriber) GenerateUnsubscribeToken(key string) (string, error) {
 	return utils.SignData(strconv.FormatInt(s.ID, 10), key)
 }
 
+// GetConfirmationURL generates and signs a token based on the subscriber ID and creates
+// the url of the page where the subscriber confirms the subscription.
+func (s *Subscriber) GetConfirmationURL(uuid string) (string, error) {
+	t, err := s.GenerateConfirmationToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Add("email", s.Email)
+	params.Add("uuid", uuid)
+	params.Add("t", t)
+
+	return os.Getenv("APP_URL") + "/confirm-subscription.html?" + params.Encode(), nil
+}
+
+// GenerateConfirmationToken generates and signs a new confirmation token with the given key, from
+// the ID of the subscriber. The signed data differs from the unsubscribe token's, so one token
+// can't be used in place of the other.
+func (s *Subscriber) GenerateConfirmationToken(key string) (string, error) {
+	if s.ID == 0 {
+		return "", errors.New("entities: unable to generate confirmation token: subscriber ID is 0")
+	}
+
+	if key == "" {
+		return "", errors.New("entities: unable to generate confirmation token: key is empty")
+	}
+
+	return utils.SignData("confirm:"+strconv.FormatInt(s.ID, 10), key)
+}
+
+// GetPreferencesURL generates and signs a token based on the subscriber ID and creates
+// the url of the preference center of the subscriber.
+func (s *Subscriber) GetPreferencesURL(uuid string) (string, error) {
+	t, err := s.GeneratePreferencesToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Add("email", s.Email)
+	params.Add("uuid", uuid)
+	params.Add("t", t)
+
+	return os.Getenv("APP_URL") + "/preferences.html?" + params.Encode(), nil
+}
+
+// GeneratePreferencesToken generates and signs a new preference center token with the given key,
+// from the ID of the subscriber.
+func (s *Subscriber) GeneratePreferencesToken(key string) (string, error) {
+	if s.ID == 0 {
+		return "", errors.New("entities: unable to generate preferences token: subscriber ID is 0")
+	}
+
+	if key == "" {
+		return "", errors.New("entities: unable to generate preferences token: key is empty")
+	}
+
+	return utils.SignData("preferences:"+strconv.FormatInt(s.ID, 10), key)
+}
+
+// Merge combines the duplicates into the subscriber. The metadata fields and the segments of the
+// duplicates are added to the ones of the subscriber, whose values win, the name is taken from the
+// first duplicate which has one when the subscriber has none, and the subscriber is blacklisted
+// when any of the duplicates is.
+func (s *Subscriber) Merge(duplicates []Subscriber) error {
+	meta, err := s.GetMetadata()
+	if err != nil {
+		return err
+	}
+
+	member := make(map[int64]bool, len(s.Segments))
+	for _, seg := range s.Segments {
+		member[seg.ID] = true
+	}
+
+	for i := range duplicates {
+		d := &duplicates[i]
+
+		dm, err := d.GetMetadata()
+		if err != nil {
+			return err
+		}
+		for k, v := range dm {
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+
+		for _, seg := range d.Segments {
+			if !member[seg.ID] {
+				member[seg.ID] = true
+				s.Segments = append(s.Segments, seg)
+			}
+		}
+
+		if s.Name == "" {
+			s.Name = d.Name
+		}
+		s.Blacklisted = s.Blacklisted || d.Blacklisted
+	}
+
+	s.MetaJSON, err = json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	s.Metadata = meta
+
+	return nil
+}
+
+// NormalizeEmail trims the email and lower cases its domain. The local part is lower cased as well,
+// since the mailbox providers treat it as case insensitive, unless EMAIL_CASE_SENSITIVE_LOCAL_PART is
+// set to true.
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+	if os.Getenv("EMAIL_CASE_SENSITIVE_LOCAL_PART") != "true" {
+		local = strings.ToLower(local)
+	}
+	return local + "@" + domain
+}
+
+// IsPaused checks whether the subscriber paused the e-mails at the given time.
+func (s *Subscriber) IsPaused(now time.Time) bool {
+	return s.PausedUntil != nil && s.PausedUntil.After(now)
+}
+
 func (s Subscriber) GetID() int64 {
 	return s.Model.ID
 }
@@ -47,6 +47,30 @@ func TestSubscriberEntity(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, tt, "77de38e4b50e618a0ebb95db61e2f42697391659d82c064a5f81b9f48d85ccd5")
 
+	url, err = sub.GetConfirmationURL("foobar")
+	assert.Nil(t, err)
+	assert.Equal(t, url, "https://mailbadger.io/confirm-subscription.html?email=john.doe%40example.com&t=f354d78a3775cb0379297ab1cac449a697d0ec4427bf9f6332a57abd07e7b7c5&uuid=foobar")
+
+	tt, err = sub.GenerateConfirmationToken(os.Getenv("UNSUBSCRIBE_SECRET"))
+	assert.Nil(t, err)
+	assert.Equal(t, tt, "f354d78a3775cb0379297ab1cac449a697d0ec4427bf9f6332a57abd07e7b7c5")
+
+	_, err = (&Subscriber{}).GenerateConfirmationToken("secret")
+	assert.NotNil(t, err)
+
+	url, err = sub.GetPreferencesURL("foobar")
+	assert.Nil(t, err)
+	assert.Equal(t, url, "https://mailbadger.io/preferences.html?email=john.doe%40example.com&t=76618a31e8990824634387a38983faa24e557e320f8c3264a11c560cbe10663f&uuid=foobar")
+
+	_, err = (&Subscriber{}).GeneratePreferencesToken("secret")
+	assert.NotNil(t, err)
+
+	assert.False(t, sub.IsPaused(now))
+	pausedUntil := now.Add(time.Hour)
+	sub.PausedUntil = &pausedUntil
+	assert.True(t, sub.IsPaused(now))
+	assert.False(t, sub.IsPaused(pausedUntil.Add(time.Second)))
+
 	id := sub.GetID()
 	assert.Equal(t, subID, id)
 
@@ -56,3 +80,47 @@ func TestSubscriberEntity(t *testing.T) {
 	updatedAt := sub.GetUpdatedAt()
 	assert.Equal(t, now, updatedAt)
 }
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "john.doe@example.com", NormalizeEmail(" John.Doe@Example.COM "))
+
+	err := os.Setenv("EMAIL_CASE_SENSITIVE_LOCAL_PART", "true")
+	if err != nil {
+		assert.FailNow(t, "unable to set os env.")
+	}
+	defer os.Unsetenv("EMAIL_CASE_SENSITIVE_LOCAL_PART")
+
+	assert.Equal(t, "John.Doe@example.com", NormalizeEmail(" John.Doe@Example.COM "))
+	assert.Equal(t, "not an email", NormalizeEmail("not an email"))
+}
+
+func TestSubscriberMerge(t *testing.T) {
+	sub := &Subscriber{
+		Email:    "john@example.com",
+		MetaJSON: []byte(`{"city": "Skopje"}`),
+		Segments: []Segment{{Model: Model{ID: 1}}},
+	}
+
+	err := sub.Merge([]Subscriber{
+		{
+			Name:     "John",
+			MetaJSON: []byte(`{"city": "Ohrid", "plan": "pro"}`),
+			Segments: []Segment{{Model: Model{ID: 1}}, {Model: Model{ID: 2}}},
+		},
+		{
+			Name:        "Johnny",
+			Blacklisted: true,
+			Segments:    []Segment{{Model: Model{ID: 3}}},
+		},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "John", sub.Name)
+	assert.True(t, sub.Blacklisted)
+	assert.Equal(t, map[string]string{"city": "Skopje", "plan": "pro"}, sub.Metadata)
+	assert.JSONEq(t, `{"city": "Skopje", "plan": "pro"}`, string(sub.MetaJSON))
+	assert.Len(t, sub.Segments, 3)
+
+	err = sub.Merge([]Subscriber{{MetaJSON: []byte(`not json`)}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,82 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Actions which are applied to the subscribers in bulk.
+const (
+	BulkActionAddToSegment      = "add_to_segment"
+	BulkActionRemoveFromSegment = "remove_from_segment"
+	// BulkActionSetMetadata sets the value of the metadata key, an empty value removes the key.
+	BulkActionSetMetadata = "set_metadata"
+	BulkActionDeactivate  = "deactivate"
+	BulkActionBlacklist   = "blacklist"
+	BulkActionDelete      = "delete"
+)
+
+// SubscriberBulkActionTopic is the topic used by the bulk actions consumer.
+const SubscriberBulkActionTopic = "subscriber_bulk_action"
+
+// SubscriberBulkAction represents an action which is applied to all of the subscribers who match
+// the filter. The bulk action is pending until the bulk actions consumer picks it up, then it's in
+// progress until it's done, failed or cancelled.
+type SubscriberBulkAction struct {
+	Model
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	Action string `json:"action" gorm:"not null"`
+	// SegmentID is the segment the subscribers are added to or removed from.
+	SegmentID     int64  `json:"segment_id,omitempty"`
+	MetadataKey   string `json:"metadata_key,omitempty"`
+	MetadataValue string `json:"metadata_value,omitempty"`
+	FilterJSON    JSON   `json:"filter" gorm:"column:filter; type:json"`
+	Status        string `json:"status" gorm:"not null"`
+	// Total is the number of the subscribers who matched the filter when the action was queued.
+	Total     int64 `json:"total"`
+	Processed int64 `json:"processed"`
+	// Affected is the number of the subscribers who were changed, e.g. the subscribers who already
+	// were in the segment are processed but not affected.
+	Affected int64 `json:"affected"`
+	// Failed is the number of the subscribers whose metadata doesn't match the custom fields once
+	// the value is set.
+	Failed int64 `json:"failed"`
+	// Error is the reason the bulk action failed.
+	Error       string   `json:"error,omitempty"`
+	StartedAt   NullTime `json:"started_at" gorm:"column:started_at"`
+	CompletedAt NullTime `json:"completed_at" gorm:"column:completed_at"`
+}
+
+// SubscriberBulkActionTopicParams represent the message the bulk actions consumer receives.
+type SubscriberBulkActionTopicParams struct {
+	BulkActionID int64 `json:"bulk_action_id"`
+	UserID       int64 `json:"user_id"`
+}
+
+// GetFilter returns the filter of the subscribers the action is applied to.
+func (a *SubscriberBulkAction) GetFilter() (*SubscriberFilter, error) {
+	f := new(SubscriberFilter)
+	if a.FilterJSON.IsNull() {
+		return f, nil
+	}
+
+	err := json.Unmarshal(a.FilterJSON, f)
+	return f, err
+}
+
+// IsFinished returns whether the bulk action is done, failed or cancelled.
+func (a *SubscriberBulkAction) IsFinished() bool {
+	return a.Status == StatusDone || a.Status == StatusFailed || a.Status == StatusCancelled
+}
+
+func (a SubscriberBulkAction) GetID() int64 {
+	return a.Model.ID
+}
+
+func (a SubscriberBulkAction) GetCreatedAt() time.Time {
+	return a.Model.CreatedAt
+}
+
+func (a SubscriberBulkAction) GetUpdatedAt() time.Time {
+	return a.Model.UpdatedAt
+}
@@ -10,6 +10,14 @@ const (
 	SubscriberEventTypeCreated      EventType = "created"
 	SubscriberEventTypeDeleted      EventType = "deleted"
 	SubscriberEventTypeUnsubscribed EventType = "unsubscribed"
+	SubscriberEventTypeConfirmed    EventType = "confirmed"
+	SubscriberEventTypeUpdated      EventType = "updated"
+	SubscriberEventTypeSegmentJoin  EventType = "segment_joined"
+	SubscriberEventTypeSegmentLeave EventType = "segment_left"
+	SubscriberEventTypePaused       EventType = "paused"
+	SubscriberEventTypeResumed      EventType = "resumed"
+	SubscriberEventTypeMerged       EventType = "merged"
+	SubscriberEventTypeSunset       EventType = "sunset"
 )
 
 // SubscriberEvent represents an event saved on subscriber's change
@@ -18,5 +26,8 @@ type SubscriberEvent struct {
 	UserID          int64       `json:"user_id"`
 	SubscriberEmail string      `json:"subscriber_email"`
 	EventType       EventType   `json:"event_type"`
-	CreatedAt       time.Time   `json:"created_at"`
+	// Data holds the details of the change, e.g. the segment which was joined or
+	// the fields which were updated.
+	Data      JSON      `json:"data,omitempty" gorm:"type:json"`
+	CreatedAt time.Time `json:"created_at"`
 }
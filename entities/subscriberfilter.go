@@ -0,0 +1,55 @@
+package entities
+
+import "time"
+
+// Operators of the metadata conditions.
+const (
+	MetadataOperatorEquals   = "equals"
+	MetadataOperatorContains = "contains"
+	MetadataOperatorExists   = "exists"
+)
+
+// SubscriberFilter holds the criteria the subscribers are searched by, the subscribers
+// have to match all of the set criteria.
+type SubscriberFilter struct {
+	// Email and Name match the subscribers whose email or name contains the value.
+	Email       string `json:"email,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Active      *bool  `json:"active,omitempty"`
+	Blacklisted *bool  `json:"blacklisted,omitempty"`
+	// SegmentIDs matches the subscribers who are in any of the segments.
+	SegmentIDs    []int64             `json:"segment_ids,omitempty"`
+	CreatedAfter  *time.Time          `json:"created_after,omitempty"`
+	CreatedBefore *time.Time          `json:"created_before,omitempty"`
+	Metadata      []MetadataCondition `json:"metadata,omitempty"`
+	// MinEngagementScore and MaxEngagementScore match the subscribers whose engagement scores are
+	// within the range, inclusive.
+	MinEngagementScore *int64 `json:"min_engagement_score,omitempty"`
+	MaxEngagementScore *int64 `json:"max_engagement_score,omitempty"`
+	// LastEngagedBefore matches the subscribers who never engaged as well.
+	LastEngagedAfter  *time.Time `json:"last_engaged_after,omitempty"`
+	LastEngagedBefore *time.Time `json:"last_engaged_before,omitempty"`
+}
+
+// MetadataCondition is a condition on a metadata field of the subscribers.
+type MetadataCondition struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+// IsEmpty checks whether the filter matches all subscribers.
+func (f *SubscriberFilter) IsEmpty() bool {
+	return f == nil || (f.Email == "" &&
+		f.Name == "" &&
+		f.Active == nil &&
+		f.Blacklisted == nil &&
+		len(f.SegmentIDs) == 0 &&
+		f.CreatedAfter == nil &&
+		f.CreatedBefore == nil &&
+		len(f.Metadata) == 0 &&
+		f.MinEngagementScore == nil &&
+		f.MaxEngagementScore == nil &&
+		f.LastEngagedAfter == nil &&
+		f.LastEngagedBefore == nil)
+}
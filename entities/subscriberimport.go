@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// StatusPending indicates an import which is queued and hasn't started yet.
+	StatusPending = "pending"
+	// StatusCancelled indicates an import which was cancelled, the subscribers which were
+	// imported before the cancellation are kept.
+	StatusCancelled = "cancelled"
+	// SubscriberImportTopic is the topic used by the importer consumer.
+	SubscriberImportTopic = "subscriber_import"
+)
+
+// Kinds of the subscriber imports.
+const (
+	// SubscriberImportKindImport creates the subscribers of the file, and skips, updates
+	// or replaces the existing ones by the mode.
+	SubscriberImportKindImport = "import"
+	// SubscriberImportKindBulkUpdate updates the existing subscribers by the columns of the file,
+	// the subscribers which are not found are reported as failed.
+	SubscriberImportKindBulkUpdate = "bulk_update"
+)
+
+// SubscriberImport represents an import or a bulk update of the subscribers of an uploaded file.
+// The import is pending until the importer consumer picks it up, then it's in progress until it's
+// done, failed or cancelled.
+type SubscriberImport struct {
+	Model
+	UserID   int64  `json:"-" gorm:"column:user_id; index"`
+	FileName string `json:"file_name" gorm:"not null"`
+	Kind     string `json:"kind" gorm:"not null"`
+	Mode     string `json:"mode" gorm:"not null"`
+	// DryRun is set to the bulk updates which only report the changes they would make.
+	DryRun bool `json:"dry_run"`
+	// Format is the format of the file, csv, ndjson, json or xlsx.
+	Format string `json:"format" gorm:"not null"`
+	// Sheet is the name of the sheet of the XLSX file which is imported, the first sheet when it's empty.
+	Sheet          string `json:"sheet,omitempty"`
+	MappingJSON    JSON   `json:"mapping" gorm:"column:mapping; type:json"`
+	SegmentIDsJSON JSON   `json:"segment_ids" gorm:"column:segment_ids; type:json"`
+	Status         string `json:"status" gorm:"not null"`
+	// Total is the number of the rows of the file, without the header.
+	Total     int64 `json:"total"`
+	Processed int64 `json:"processed"`
+	Created   int64 `json:"created"`
+	Updated   int64 `json:"updated"`
+	Skipped   int64 `json:"skipped"`
+	Failed    int64 `json:"failed"`
+	// Error is the reason the import failed.
+	Error       string   `json:"error,omitempty"`
+	StartedAt   NullTime `json:"started_at" gorm:"column:started_at"`
+	CompletedAt NullTime `json:"completed_at" gorm:"column:completed_at"`
+}
+
+// SubscriberImportTopicParams represent the message the importer consumer receives.
+type SubscriberImportTopicParams struct {
+	ImportID int64 `json:"import_id"`
+	UserID   int64 `json:"user_id"`
+}
+
+// GetMapping returns the mapping of the columns of the file.
+func (i *SubscriberImport) GetMapping() (map[string]string, error) {
+	mapping := make(map[string]string)
+	if i.MappingJSON.IsNull() {
+		return mapping, nil
+	}
+
+	err := json.Unmarshal(i.MappingJSON, &mapping)
+	return mapping, err
+}
+
+// GetSegmentIDs returns the ids of the segments the subscribers are added to.
+func (i *SubscriberImport) GetSegmentIDs() ([]int64, error) {
+	var ids []int64
+	if i.SegmentIDsJSON.IsNull() {
+		return ids, nil
+	}
+
+	err := json.Unmarshal(i.SegmentIDsJSON, &ids)
+	return ids, err
+}
+
+// IsBulkUpdate returns whether the import updates the existing subscribers only.
+func (i *SubscriberImport) IsBulkUpdate() bool {
+	return i.Kind == SubscriberImportKindBulkUpdate
+}
+
+// IsFinished returns whether the import is done, failed or cancelled.
+func (i *SubscriberImport) IsFinished() bool {
+	return i.Status == StatusDone || i.Status == StatusFailed || i.Status == StatusCancelled
+}
+
+func (i SubscriberImport) GetID() int64 {
+	return i.Model.ID
+}
+
+func (i SubscriberImport) GetCreatedAt() time.Time {
+	return i.Model.CreatedAt
+}
+
+func (i SubscriberImport) GetUpdatedAt() time.Time {
+	return i.Model.UpdatedAt
+}
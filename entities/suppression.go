@@ -0,0 +1,126 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Types of the suppressions.
+const (
+	// SuppressionTypeEmail suppresses a single address.
+	SuppressionTypeEmail = "email"
+	// SuppressionTypeDomain suppresses every address of the domain.
+	SuppressionTypeDomain = "domain"
+	// SuppressionTypeEmailHash suppresses the address whose hash is the value, see HashEmail.
+	// It's the tombstone of the erased subscribers, which keeps them from being added again
+	// without storing their addresses.
+	SuppressionTypeEmailHash = "email_hash"
+)
+
+// Reasons of the suppressions.
+const (
+	SuppressionReasonBounce       = "bounce"
+	SuppressionReasonComplaint    = "complaint"
+	SuppressionReasonManual       = "manual"
+	SuppressionReasonUnsubscribed = "unsubscribed"
+	SuppressionReasonErased       = "erased"
+)
+
+// Sources of the suppressions.
+const (
+	// SuppressionSourceSES is a permanent bounce or a complaint notified by SES.
+	SuppressionSourceSES = "ses"
+	// SuppressionSourceUnsubscribe is the unsubscribe link or the preference center.
+	SuppressionSourceUnsubscribe = "unsubscribe"
+	// SuppressionSourceAPI is an entry added by the user.
+	SuppressionSourceAPI = "api"
+	// SuppressionSourceImport is an entry imported from a file.
+	SuppressionSourceImport = "import"
+	// SuppressionSourceErasure is the erasure of the subscriber's data.
+	SuppressionSourceErasure = "erasure"
+)
+
+// Suppression is an email or a domain of the user's account which must not be sent to. Unlike the
+// blacklisted flag of the subscribers, it outlives the subscribers, so the addresses which bounced,
+// complained or unsubscribed are not mailed again after they are deleted and imported back.
+type Suppression struct {
+	Model
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+	Source string `json:"source"`
+}
+
+func (s Suppression) GetID() int64 {
+	return s.Model.ID
+}
+
+func (s Suppression) GetCreatedAt() time.Time {
+	return s.Model.CreatedAt
+}
+
+func (s Suppression) GetUpdatedAt() time.Time {
+	return s.Model.UpdatedAt
+}
+
+// Matches returns whether the suppression applies to the email.
+func (s Suppression) Matches(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	switch s.Type {
+	case SuppressionTypeDomain:
+		return EmailDomain(email) == s.Value
+	case SuppressionTypeEmailHash:
+		return HashEmail(email) == s.Value
+	}
+	return email == s.Value
+}
+
+// Description returns the reason the email is not allowed, e.g. for the rejected rows of the imports.
+func (s Suppression) Description() string {
+	return "The email is on the suppression list (" + s.Reason + ")."
+}
+
+// ParseSuppression returns the type and the normalized value of the email or the domain, e.g.
+// Jane@Example.com is an email and @example.com is a domain. It reports false for the values
+// which are neither.
+func ParseSuppression(value string) (string, string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) > 191 {
+		return "", "", false
+	}
+	at := strings.LastIndex(value, "@")
+	switch {
+	case at > 0:
+		if !isSuppressedDomain(value[at+1:]) {
+			return "", "", false
+		}
+		return SuppressionTypeEmail, value, true
+	case at == 0:
+		value = value[1:]
+	}
+	if !isSuppressedDomain(value) {
+		return "", "", false
+	}
+	return SuppressionTypeDomain, value, true
+}
+
+// HashEmail returns the hex encoded SHA-256 hash of the lower cased email.
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// EmailDomain returns the lower cased domain of the email.
+func EmailDomain(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+func isSuppressedDomain(domain string) bool {
+	return strings.Contains(domain, ".") &&
+		!strings.HasPrefix(domain, ".") &&
+		!strings.HasSuffix(domain, ".") &&
+		!strings.ContainsAny(domain, "@ \t")
+}
@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSuppression(t *testing.T) {
+	tests := []struct {
+		value string
+		typ   string
+		want  string
+		ok    bool
+	}{
+		{value: " Jane@Example.com ", typ: SuppressionTypeEmail, want: "jane@example.com", ok: true},
+		{value: "@Example.com", typ: SuppressionTypeDomain, want: "example.com", ok: true},
+		{value: "mail.example.com", typ: SuppressionTypeDomain, want: "mail.example.com", ok: true},
+		{value: "jane@", ok: false},
+		{value: "jane@localhost", ok: false},
+		{value: "example", ok: false},
+		{value: "exa mple.com", ok: false},
+		{value: ".example.com", ok: false},
+		{value: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		typ, value, ok := ParseSuppression(tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.typ, typ, tt.value)
+		assert.Equal(t, tt.want, value, tt.value)
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	email := Suppression{Type: SuppressionTypeEmail, Value: "jane@example.com", Reason: SuppressionReasonBounce}
+	assert.True(t, email.Matches("Jane@Example.com"))
+	assert.False(t, email.Matches("john@example.com"))
+	assert.Equal(t, "The email is on the suppression list (bounce).", email.Description())
+
+	domain := Suppression{Type: SuppressionTypeDomain, Value: "example.com"}
+	assert.True(t, domain.Matches("john@EXAMPLE.com"))
+	assert.False(t, domain.Matches("john@mail.example.com"))
+
+	hash := Suppression{Type: SuppressionTypeEmailHash, Value: HashEmail("jane@example.com")}
+	assert.Len(t, hash.Value, 64)
+	assert.True(t, hash.Matches(" JANE@example.com"))
+	assert.False(t, hash.Matches("john@example.com"))
+}
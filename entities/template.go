@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/cbroglie/mustache"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/mailbadger/app/engines"
 )
 
 var (
@@ -17,6 +18,14 @@ var (
 const (
 	TagName           = "name"
 	TagUnsubscribeUrl = "unsubscribe_url"
+	// TagPreferencesURL is the tag of the link to the preference center of the subscriber.
+	TagPreferencesURL = "preferences_url"
+	// TagConfirmURL is the tag of the subscription confirmation link in the
+	// e-mails sent to the pending subscribers.
+	TagConfirmURL = "confirm_url"
+	// TagContent is the tag used in layouts which is replaced by the
+	// rendered HTML part of the template wrapped in the layout.
+	TagContent = engines.ContentTag
 )
 
 // BaseTemplate represents the base params of each template
@@ -25,6 +34,12 @@ type BaseTemplate struct {
 	UserID      int64  `json:"user_id"`
 	Name        string `json:"name"`
 	SubjectPart string `json:"subject_part"`
+	LayoutID    *int64 `json:"layout_id" gorm:"column:layout_id"`
+	// DisableCSSInlining opts the template out of inlining the style blocks
+	// of the HTML part when the campaign emails are rendered.
+	DisableCSSInlining bool `json:"disable_css_inlining" gorm:"column:disable_css_inlining"`
+	// Engine is the name of the engine the template parts are written for.
+	Engine string `json:"engine" gorm:"column:engine"`
 }
 
 // GetID returns the id of the template
@@ -52,18 +67,26 @@ func (t Template) GetBase() *BaseTemplate {
 			CreatedAt: t.CreatedAt,
 			UpdatedAt: t.UpdatedAt,
 		},
-		UserID:      t.UserID,
-		Name:        t.Name,
-		SubjectPart: t.SubjectPart,
+		UserID:             t.UserID,
+		Name:               t.Name,
+		SubjectPart:        t.SubjectPart,
+		LayoutID:           t.LayoutID,
+		DisableCSSInlining: t.DisableCSSInlining,
+		Engine:             t.Engine,
 	}
 }
 
 // ValidateData checks if all template tags are covered with provided data
 func (t Template) ValidateData(data map[string]string) error {
+	engine, err := engines.Get(t.Engine)
+	if err != nil {
+		return fmt.Errorf("get engine: %w", err)
+	}
+
 	g, _ := errgroup.WithContext(context.Background())
 
 	g.Go(func() error {
-		err := validateData(t.SubjectPart, data)
+		err := validateData(engine, t.SubjectPart, data)
 		if err != nil {
 			return fmt.Errorf("validate subject part: %w", err)
 		}
@@ -72,7 +95,7 @@ func (t Template) ValidateData(data map[string]string) error {
 	})
 
 	g.Go(func() error {
-		err := validateData(t.TextPart, data)
+		err := validateData(engine, t.TextPart, data)
 		if err != nil {
 			return fmt.Errorf("validate text part: %w", err)
 		}
@@ -81,7 +104,7 @@ func (t Template) ValidateData(data map[string]string) error {
 	})
 
 	g.Go(func() error {
-		err := validateData(t.HTMLPart, data)
+		err := validateData(engine, t.HTMLPart, data)
 		if err != nil {
 			return fmt.Errorf("validate html part: %w", err)
 		}
@@ -92,26 +115,100 @@ func (t Template) ValidateData(data map[string]string) error {
 	return g.Wait()
 }
 
-func validateData(templateString string, data map[string]string) error {
-	template, err := mustache.ParseString(templateString)
+func validateData(engine engines.Engine, templateString string, data map[string]string) error {
+	// partials are resolved from snippets and templates, not from the data.
+	tags, err := engine.Tags(templateString)
 	if err != nil {
 		return fmt.Errorf("parse string: %w", err)
 	}
 
-	for _, tag := range template.Tags() {
-		if tag.Name() == TagName || tag.Name() == TagUnsubscribeUrl {
+	for _, tag := range tags {
+		if IsBuiltInTag(tag) {
 			continue
 		}
 
-		_, exist := data[tag.Name()]
+		_, exist := data[tag]
 		if !exist {
-			return fmt.Errorf("%s tag: %w", tag.Name(), ErrMissingDefaultData)
+			return fmt.Errorf("%s tag: %w", tag, ErrMissingDefaultData)
 		}
 	}
 
 	return nil
 }
 
+// IsBuiltInTag checks whether the tag is filled in for every subscriber when the campaign is sent.
+func IsBuiltInTag(name string) bool {
+	return name == TagName || name == TagUnsubscribeUrl || name == TagPreferencesURL
+}
+
+// TemplateVariable represents a tag used in the template parts.
+type TemplateVariable struct {
+	Name  string   `json:"name"`
+	Parts []string `json:"parts"`
+	// BuiltIn is set for the tags which are filled in when the campaign is sent, the
+	// rest must come from the subscriber's metadata or the campaign's default data.
+	BuiltIn bool `json:"built_in"`
+	// MissingSubscribers is the number of active subscribers whose metadata does not contain the variable.
+	MissingSubscribers int64 `json:"missing_subscribers"`
+	// FieldType is the type of the custom field of the variable, it's empty when the variable
+	// is not a custom field.
+	FieldType string `json:"field_type,omitempty"`
+}
+
+// TemplatePreview represents the parts of a template rendered with sample data.
+type TemplatePreview struct {
+	SubjectPart string `json:"subject_part"`
+	HTMLPart    string `json:"html_part"`
+	TextPart    string `json:"text_part"`
+}
+
+// TemplateVariables represents the variables of a template.
+type TemplateVariables struct {
+	TotalSubscribers int64              `json:"total_subscribers"`
+	Collection       []TemplateVariable `json:"collection"`
+}
+
+// Variables returns the tags used in the subject, HTML and text parts, including the ones
+// nested in sections, along with the parts each of them is used in. Partials are not included.
+func (t Template) Variables() ([]TemplateVariable, error) {
+	engine, err := engines.Get(t.Engine)
+	if err != nil {
+		return nil, fmt.Errorf("get engine: %w", err)
+	}
+
+	parts := []struct {
+		name   string
+		source string
+	}{
+		{LintPartSubject, t.SubjectPart},
+		{LintPartHTML, t.HTMLPart},
+		{LintPartText, t.TextPart},
+	}
+
+	var (
+		vars  []TemplateVariable
+		index = make(map[string]int)
+	)
+	for _, p := range parts {
+		tags, err := engine.Tags(p.source)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", p.name, err)
+		}
+
+		for _, tag := range tags {
+			i, ok := index[tag]
+			if !ok {
+				i = len(vars)
+				index[tag] = i
+				vars = append(vars, TemplateVariable{Name: tag, BuiltIn: IsBuiltInTag(tag)})
+			}
+			vars[i].Parts = append(vars[i].Parts, p.name)
+		}
+	}
+
+	return vars, nil
+}
+
 type TemplateCollection struct {
 	NextToken  string         `json:"next_token"`
 	Collection []TemplateMeta `json:"collection"`
@@ -30,6 +30,15 @@ func TestValidateData(t *testing.T) {
 	})
 	assert.Nil(t, err)
 
+	// partials are not part of the template data
+	template.HTMLPart = "<h1>My favourite animal is {{fave_animal}}<h1>{{> footer}}"
+
+	err = template.ValidateData(map[string]string{
+		"name":        "Djale",
+		"fave_animal": "Dog",
+	})
+	assert.Nil(t, err)
+
 	// test failed to parse subject part
 	template.SubjectPart = "Hello {{{name}}"
 
@@ -56,6 +65,33 @@ func TestValidateData(t *testing.T) {
 		"fave_animal": "Dog",
 	})
 	assert.NotNil(t, err)
+
+	// test go engine tags
+	template = Template{
+		BaseTemplate: BaseTemplate{
+			Name:        "test-template",
+			SubjectPart: `Hello {{.name | default "there"}}`,
+			Engine:      "go",
+		},
+		HTMLPart: `<h1>{{if .fave_animal}}My favourite animal is {{.fave_animal}}{{end}}<h1>{{template "footer" .}}`,
+		TextPart: `{{with .fave_animal}}My favourite animal is {{.}}{{end}}`,
+	}
+
+	err = template.ValidateData(map[string]string{})
+	assert.True(t, errors.Is(err, ErrMissingDefaultData))
+
+	err = template.ValidateData(map[string]string{
+		"fave_animal": "Dog",
+	})
+	assert.Nil(t, err)
+
+	// test unknown engine
+	template.Engine = "handlebars"
+
+	err = template.ValidateData(map[string]string{
+		"fave_animal": "Dog",
+	})
+	assert.NotNil(t, err)
 }
 
 func TestGetters(t *testing.T) {
@@ -86,3 +122,25 @@ func TestGetters(t *testing.T) {
 	tableName := template.BaseTemplate.TableName()
 	assert.Equal(t, "templates", tableName)
 }
+
+func TestVariables(t *testing.T) {
+	template := Template{
+		BaseTemplate: BaseTemplate{
+			SubjectPart: "Hello {{name}}",
+		},
+		HTMLPart: "<h1>{{name}}, use {{discount}}</h1><a href=\"{{unsubscribe_url}}\">unsubscribe</a>",
+		TextPart: "{{name}}, use {{discount}}",
+	}
+
+	vars, err := template.Variables()
+	assert.Nil(t, err)
+	assert.Equal(t, []TemplateVariable{
+		{Name: "name", Parts: []string{LintPartSubject, LintPartHTML, LintPartText}, BuiltIn: true},
+		{Name: "discount", Parts: []string{LintPartHTML, LintPartText}},
+		{Name: "unsubscribe_url", Parts: []string{LintPartHTML}, BuiltIn: true},
+	}, vars)
+
+	template.HTMLPart = "{{#discount}}"
+	_, err = template.Variables()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,62 @@
+package entities
+
+// Rules reported by the template linter.
+const (
+	LintRuleTemplateSyntax     = "template_syntax"
+	LintRuleMalformedHTML      = "malformed_html"
+	LintRuleUnclosedTag        = "unclosed_tag"
+	LintRuleMissingUnsubscribe = "missing_unsubscribe_url"
+	LintRuleHTMLSize           = "html_size"
+	LintRuleImageAlt           = "image_alt"
+	LintRuleJavascriptLink     = "javascript_link"
+	LintRuleScript             = "script"
+	LintRuleForm               = "form"
+	LintRuleEmptyTextPart      = "empty_text_part"
+	LintRuleSubjectLength      = "subject_length"
+	LintRuleUnknownVariable    = "unknown_variable"
+)
+
+// Template parts a lint issue can be found in.
+const (
+	LintPartHTML    = "html_part"
+	LintPartText    = "text_part"
+	LintPartSubject = "subject_part"
+)
+
+// LintIssue represents a single problem found in one of the template parts.
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Part    string `json:"part"`
+	Message string `json:"message"`
+}
+
+// TemplateLint holds the issues found by the template linter. Errors break the email
+// or its deliverability and should be fixed before the template is used, warnings
+// affect how the email is displayed in some of the email clients.
+type TemplateLint struct {
+	Errors   []LintIssue `json:"errors"`
+	Warnings []LintIssue `json:"warnings"`
+}
+
+// NewTemplateLint returns an empty lint report.
+func NewTemplateLint() *TemplateLint {
+	return &TemplateLint{
+		Errors:   []LintIssue{},
+		Warnings: []LintIssue{},
+	}
+}
+
+// AddError adds an error for the given rule and template part.
+func (l *TemplateLint) AddError(rule, part, message string) {
+	l.Errors = append(l.Errors, LintIssue{Rule: rule, Part: part, Message: message})
+}
+
+// AddWarning adds a warning for the given rule and template part.
+func (l *TemplateLint) AddWarning(rule, part, message string) {
+	l.Warnings = append(l.Warnings, LintIssue{Rule: rule, Part: part, Message: message})
+}
+
+// HasErrors checks whether the linter found any errors.
+func (l *TemplateLint) HasErrors() bool {
+	return len(l.Errors) > 0
+}
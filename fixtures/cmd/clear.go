@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/jinzhu/gorm"
 	"github.com/mailbadger/app/entities"
@@ -89,6 +90,27 @@ func clear() error {
 
 	fmt.Println()
 
+	err = db.DeleteAllSubscriberImportsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all subscriber imports for user: %w", err)
+	}
+
+	fmt.Printf("deleted all subscriber imports\n\n")
+
+	err = db.DeleteAllSubscriberBulkActionsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all subscriber bulk actions for user: %w", err)
+	}
+
+	fmt.Printf("deleted all subscriber bulk actions\n\n")
+
+	err = db.DeleteAllFormsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all forms for user: %w", err)
+	}
+
+	fmt.Printf("deleted all forms\n\n")
+
 	err = db.DeleteAllSegmentsForUser(u.ID)
 	if err != nil {
 		return fmt.Errorf("failed to delete all segments for user: %w", err)
@@ -164,13 +186,53 @@ func clear() error {
 		return fmt.Errorf("failed to fetch all tmeplates for user: %w", err)
 	}
 
+	// templates wrapped in layouts are deleted before the layouts themselves.
+	sort.SliceStable(allTemplates, func(i, j int) bool {
+		return allTemplates[i].LayoutID != nil && allTemplates[j].LayoutID == nil
+	})
+
 	for _, t := range allTemplates {
-		err = templates.New(db, s3Client, templates.TemplateBucket(viper.GetString("TEMPLATES_BUCKET"))).DeleteTemplate(context.Background(), t.ID, u.ID)
+		err = templates.New(db, blobStore, templates.TemplateBucket(viper.GetString("TEMPLATES_BUCKET"))).DeleteTemplate(context.Background(), t.ID, u.ID)
 		if err != nil {
 			return fmt.Errorf("failed to delete template	: %w", err)
 		}
 	}
 
+	err = db.DeleteAllSnippetsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all snippets for user: %w", err)
+	}
+
+	fmt.Printf("deleted all snippets\n\n")
+
+	err = db.DeleteAllSuppressionsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all suppressions for user: %w", err)
+	}
+
+	fmt.Printf("deleted all suppressions\n\n")
+
+	err = db.DeleteAllGDPRErasuresForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all gdpr erasures for user: %w", err)
+	}
+
+	fmt.Printf("deleted all gdpr erasures\n\n")
+
+	err = db.DeleteSunsetPolicy(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sunset policy: %w", err)
+	}
+
+	fmt.Printf("deleted sunset policy\n\n")
+
+	err = db.DeleteAllCustomFieldsForUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete all custom fields for user: %w", err)
+	}
+
+	fmt.Printf("deleted all custom fields\n\n")
+
 	err = db.DeleteAllReportsForUser(u.ID)
 	if err != nil {
 		return fmt.Errorf("failed to delete all reports for user: %w", err)
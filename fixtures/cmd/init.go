@@ -176,7 +176,7 @@ func createCampaignsAndTemplates(userID int64) error {
 	Unsubscribe => {{unsubscribe_url}}`,
 	}
 
-	err := templates.New(db, s3Client, templates.TemplateBucket(viper.GetString("TEMPLATES_BUCKET"))).AddTemplate(context.Background(), template)
+	err := templates.New(db, blobStore, templates.TemplateBucket(viper.GetString("TEMPLATES_BUCKET"))).AddTemplate(context.Background(), template)
 	if err != nil {
 		return fmt.Errorf("failed to create 'init-template' template: %w", err)
 	}
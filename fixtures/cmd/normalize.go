@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// dryRun reports the changes of the normalization without making them
+var dryRun bool
+
+// normalizeEmailsCmd represents the normalize-emails command
+var normalizeEmailsCmd = &cobra.Command{
+	Use:   "normalize-emails",
+	Short: "normalize-emails normalizes the emails of the existing subscribers",
+	Long: `normalize-emails normalizes the emails of the subscribers of all users which were created before the
+emails were normalized. The subscribers whose normalized emails are taken by other subscribers are listed
+as duplicates and left as they are, they can be merged with POST /api/subscribers/{id}/merge.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return normalizeEmails()
+	},
+}
+
+func init() {
+	normalizeEmailsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report the emails which would be normalized and the duplicates without changing them")
+	rootCmd.AddCommand(normalizeEmailsCmd)
+}
+
+func normalizeEmails() error {
+	normalized, duplicates, err := db.NormalizeSubscriberEmails(dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to normalize the emails: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("emails to normalize: %d\n", normalized)
+	} else {
+		fmt.Printf("normalized emails: %d\n", normalized)
+	}
+
+	fmt.Printf("duplicates: %d\n", len(duplicates))
+	for _, d := range duplicates {
+		fmt.Printf(" user: %d subscriber: %d email: %s duplicate of: %d\n", d.UserID, d.SubscriberID, d.Email, d.DuplicateOf)
+	}
+
+	return nil
+}
@@ -4,12 +4,11 @@ import (
 	"fmt"
 	"os"
 
-	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"github.com/mailbadger/app/s3"
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/storage"
 )
 
@@ -36,8 +35,8 @@ func Execute() {
 var (
 	// db represents the connection to the database
 	db storage.Storage
-	// s3Client represents the s3 client
-	s3Client *awss3.S3
+	// blobStore represents the store of the templates' HTML parts
+	blobStore blobs.Store
 	// username for the user with fixtures
 	username string
 	// password for the user with fixtures
@@ -65,14 +64,22 @@ func init() {
 	conf := storage.MakeConfigFromEnv(driver)
 	db = storage.New(driver, conf)
 
-	// Creating s3 client
-	s3Client, err = s3.NewS3Client(
-		viper.GetString("AWS_S3_ACCESS_KEY"),
-		viper.GetString("AWS_S3_SECRET_KEY"),
-		viper.GetString("AWS_S3_REGION"),
-	)
+	// Creating blob store
+	assetsBucket := viper.GetString("ASSETS_BUCKET")
+	if assetsBucket == "" {
+		assetsBucket = viper.GetString("FILES_BUCKET")
+	}
+	blobStore, err = blobs.New(viper.GetString("BLOB_STORAGE"), blobs.Config{
+		AccessKey:    viper.GetString("AWS_S3_ACCESS_KEY"),
+		SecretKey:    viper.GetString("AWS_S3_SECRET_KEY"),
+		Region:       viper.GetString("AWS_S3_REGION"),
+		Dir:          viper.GetString("BLOB_STORAGE_DIR"),
+		URL:          viper.GetString("APP_URL") + "/api/blobs",
+		Secret:       viper.GetString("BLOB_STORAGE_SECRET"),
+		AssetsBucket: assetsBucket,
+	})
 	if err != nil {
-		fmt.Printf("[ERROR %s] failed to create s3 client", err.Error())
+		fmt.Printf("[ERROR %s] failed to create blob store", err.Error())
 		os.Exit(1)
 	}
 }
@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/andybalholm/brotli v1.0.1 // indirect
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/aws/aws-sdk-go v1.38.70
 	github.com/cbroglie/mustache v1.2.2
 	github.com/didip/tollbooth v4.0.2+incompatible
@@ -27,7 +28,7 @@ require (
 	github.com/jinzhu/gorm v1.9.16
 	github.com/klauspost/compress v1.11.1 // indirect
 	github.com/lib/pq v1.10.2
-	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/nsqio/go-nsq v1.0.8
 	github.com/open-policy-agent/opa v0.30.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
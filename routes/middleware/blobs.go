@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/blobs"
+)
+
+// Blobs is a middleware that attaches the blob store to the context.
+func Blobs(store blobs.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blobs.SetToContext(c, store)
+		c.Next()
+	}
+}
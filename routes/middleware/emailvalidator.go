@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/services/emailvalidator"
+)
+
+// EmailValidator is a middleware that attaches the e-mail validator to the context.
+func EmailValidator(v emailvalidator.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		emailvalidator.SetToContext(c, v)
+		c.Next()
+	}
+}
@@ -14,6 +14,13 @@ func PaginateWithCursor() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		p := storage.NewPaginationCursor(c.Request.URL.Path, storage.DefaultPerPage)
 
+		// the rest of the query params, e.g. the filters, are kept in the links.
+		params := c.Request.URL.Query()
+		params.Del("per_page")
+		params.Del("ending_before")
+		params.Del("starting_after")
+		p.SetParams(params)
+
 		if len(c.Query("per_page")) > 0 {
 			perpage, err := strconv.ParseInt(c.Query("per_page"), 10, 64)
 			if err != nil {
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -19,10 +20,11 @@ import (
 	"github.com/unrolled/secure"
 
 	"github.com/mailbadger/app/actions"
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/mode"
 	"github.com/mailbadger/app/opa"
 	"github.com/mailbadger/app/routes/middleware"
-	"github.com/mailbadger/app/s3"
+	"github.com/mailbadger/app/services/emailvalidator"
 	"github.com/mailbadger/app/storage"
 	"github.com/mailbadger/app/templates"
 )
@@ -41,11 +43,7 @@ func New() http.Handler {
 		log.SetFormatter(&logrus.JSONFormatter{})
 	}
 
-	s3Client, err := s3.NewS3Client(
-		os.Getenv("AWS_S3_ACCESS_KEY"),
-		os.Getenv("AWS_S3_SECRET_KEY"),
-		os.Getenv("AWS_S3_REGION"),
-	)
+	blobStore, err := blobs.New(os.Getenv("BLOB_STORAGE"), blobs.MakeConfigFromEnv())
 	if err != nil {
 		panic(err)
 	}
@@ -75,9 +73,15 @@ func New() http.Handler {
 	handler.Use(middleware.SetUser())
 	handler.Use(middleware.RequestID())
 	handler.Use(middleware.SetLoggerEntry())
-	handler.Use(middleware.S3Client(s3Client))
+	handler.Use(middleware.Blobs(blobStore))
+	handler.Use(middleware.EmailValidator(emailvalidator.New(net.DefaultResolver, emailvalidator.PolicyFromEnv())))
 
 	// Security headers
+	csp := "default-src 'self';style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; script-src 'self' 'unsafe-inline'"
+	if os.Getenv("RECAPTCHA_SITE_KEY") != "" {
+		// the hosted forms load the reCAPTCHA widget.
+		csp += " https://www.google.com/recaptcha/ https://www.gstatic.com/recaptcha/; frame-src https://www.google.com/recaptcha/"
+	}
 	secureMiddleware := secure.New(secure.Options{
 		FrameDeny:             true,
 		ContentTypeNosniff:    true,
@@ -87,7 +91,7 @@ func New() http.Handler {
 		STSSeconds:            31536000,
 		STSIncludeSubdomains:  true,
 		STSPreload:            true,
-		ContentSecurityPolicy: "default-src 'self';style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; script-src 'self' 'unsafe-inline'",
+		ContentSecurityPolicy: csp,
 
 		IsDevelopment: !mode.IsProd(),
 	})
@@ -149,6 +153,36 @@ func New() http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(c.Request.URL.Path, "/preferences.html") {
+			actions.GetPreferencesPage(c)
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/form.html") {
+			actions.GetFormPage(c)
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/form-success.html") {
+			c.HTML(http.StatusOK, "form-success.html", nil)
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/confirm-subscription.html") {
+			c.HTML(http.StatusOK, "confirm-subscription.html", gin.H{
+				"email":  c.Query("email"),
+				"t":      c.Query("t"),
+				"uuid":   c.Query("uuid"),
+				"failed": c.Query("failed"),
+			})
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/confirm-subscription-success.html") {
+			c.HTML(http.StatusOK, "confirm-subscription-success.html", nil)
+			return
+		}
+
 		c.File(appDir + "/index.html")
 	})
 
@@ -202,6 +236,22 @@ func SetGuestRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc) {
 	guest.POST("/signup", actions.PostSignup)
 	guest.POST("/hooks/:uuid", actions.HandleHook)
 	guest.POST("/unsubscribe", actions.PostUnsubscribe)
+	guest.POST("/preferences", actions.PostPreferences)
+	guest.POST("/confirm-subscription", actions.PostConfirmSubscription)
+	guest.POST("/forms/:uuid/subscribe", tollbooth_gin.LimitHandler(formLimiter()), actions.PostFormSubscribe)
+	guest.GET("/blobs/:bucket/*key", actions.GetBlob)
+	guest.PUT("/blobs/:bucket/*key", actions.PutBlob)
+}
+
+// formLimiter limits the submissions of the hosted forms per ip further than the rest of
+// the guest routes, since the forms are public and create subscribers.
+func formLimiter() *limiter.Limiter {
+	lmt := tollbooth.NewLimiter(0.1, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour})
+	lmt.SetBurst(5)
+	lmt.SetMessage(`{"message": "You have reached the maximum request limit."}`)
+	lmt.SetMessageContentType("application/json; charset=utf-8")
+
+	return lmt
 }
 
 // SetAuthorizedRoutes sets the authorized routes to the gin engine handler along with
@@ -224,11 +274,29 @@ func SetAuthorizedRoutes(handler *gin.Engine, opacompiler *ast.Compiler, middlew
 		{
 			templates.GET("", middleware.PaginateWithCursor(), actions.GetTemplates)
 			templates.GET("/:id", actions.GetTemplate)
+			templates.GET("/:id/variables", actions.GetTemplateVariables)
+			templates.GET("/:id/preview", actions.GetTemplatePreview)
+			templates.GET("/:id/sample-data-sets", actions.GetSampleDataSets)
+			templates.POST("/:id/sample-data-sets", actions.PostSampleDataSet)
+			templates.PUT("/:id/sample-data-sets/:set_id", actions.PutSampleDataSet)
+			templates.DELETE("/:id/sample-data-sets/:set_id", actions.DeleteSampleDataSet)
 			templates.POST("", actions.PostTemplate)
+			templates.POST("/lint", actions.PostLintTemplate)
+			templates.POST("/text", actions.PostTextPart)
+			templates.POST("/import", actions.PostImportTemplate)
 			templates.PUT("/:id", actions.PutTemplate)
 			templates.DELETE("/:id", actions.DeleteTemplate)
 		}
 
+		snippets := authorized.Group("/snippets")
+		{
+			snippets.GET("", middleware.PaginateWithCursor(), actions.GetSnippets)
+			snippets.GET("/:id", actions.GetSnippet)
+			snippets.POST("", actions.PostSnippet)
+			snippets.PUT("/:id", actions.PutSnippet)
+			snippets.DELETE("/:id", actions.DeleteSnippet)
+		}
+
 		campaigns := authorized.Group("/campaigns")
 		{
 			campaigns.GET("", middleware.PaginateWithCursor(), actions.GetCampaigns)
@@ -251,6 +319,7 @@ func SetAuthorizedRoutes(handler *gin.Engine, opacompiler *ast.Compiler, middlew
 			segments.GET("", middleware.PaginateWithCursor(), actions.GetSegments)
 			segments.GET("/:id", actions.GetSegment)
 			segments.POST("", actions.PostSegment)
+			segments.POST("/preview", actions.PreviewSegment)
 			segments.PUT("/:id", actions.PutSegment)
 			segments.DELETE("/:id", actions.DeleteSegment)
 			segments.PUT("/:id/subscribers", actions.PutSegmentSubscribers)
@@ -263,15 +332,64 @@ func SetAuthorizedRoutes(handler *gin.Engine, opacompiler *ast.Compiler, middlew
 		{
 			subscribers.GET("", middleware.PaginateWithCursor(), actions.GetSubscribers)
 			subscribers.GET("/:id", actions.GetSubscriber)
+			subscribers.GET("/:id/activity", actions.GetSubscriberActivity)
+			subscribers.GET("/:id/gdpr-export", actions.GetSubscriberGDPRExport)
+			subscribers.GET("/gdpr-erasures", middleware.PaginateWithCursor(), actions.GetGDPRErasures)
 			subscribers.GET("/export/download", actions.DownloadSubscribersReport)
 			subscribers.POST("", actions.PostSubscriber)
 			subscribers.PUT("/:id", actions.PutSubscriber)
 			subscribers.DELETE("/:id", actions.DeleteSubscriber)
+			subscribers.POST("/:id/merge", actions.MergeSubscribers)
+			subscribers.POST("/:id/gdpr-erase", actions.EraseSubscriber)
 			subscribers.POST("/import", actions.ImportSubscribers)
+			subscribers.POST("/import/preview", actions.PreviewImportSubscribers)
+			subscribers.GET("/imports/:id", actions.GetSubscriberImport)
+			subscribers.GET("/imports/:id/errors", actions.DownloadImportErrors)
+			subscribers.GET("/imports/:id/report", actions.DownloadBulkUpdateReport)
+			subscribers.POST("/imports/:id/cancel", actions.CancelSubscriberImport)
 			subscribers.POST("/bulk-remove", actions.BulkRemoveSubscribers)
+			subscribers.POST("/bulk-update", actions.BulkUpdateSubscribers)
+			subscribers.POST("/bulk-action", actions.PostSubscriberBulkAction)
+			subscribers.GET("/bulk-actions/:id", actions.GetSubscriberBulkAction)
+			subscribers.POST("/bulk-actions/:id/cancel", actions.CancelSubscriberBulkAction)
 			subscribers.POST("/export", actions.ExportSubscribers)
 		}
 
+		suppressions := authorized.Group("/suppressions")
+		{
+			suppressions.GET("", middleware.PaginateWithCursor(), actions.GetSuppressions)
+			suppressions.POST("", actions.PostSuppression)
+			suppressions.DELETE("/:id", actions.DeleteSuppression)
+			suppressions.POST("/import", actions.ImportSuppressions)
+			suppressions.POST("/export", actions.ExportSuppressions)
+			suppressions.GET("/export/download", actions.DownloadSuppressionsReport)
+		}
+
+		sunsetPolicy := authorized.Group("/sunset-policy")
+		{
+			sunsetPolicy.GET("", actions.GetSunsetPolicy)
+			sunsetPolicy.PUT("", actions.PutSunsetPolicy)
+			sunsetPolicy.DELETE("", actions.DeleteSunsetPolicy)
+		}
+
+		customFields := authorized.Group("/custom-fields")
+		{
+			customFields.GET("", actions.GetCustomFields)
+			customFields.GET("/:id", actions.GetCustomField)
+			customFields.POST("", actions.PostCustomField)
+			customFields.PUT("/:id", actions.PutCustomField)
+			customFields.DELETE("/:id", actions.DeleteCustomField)
+		}
+
+		forms := authorized.Group("/forms")
+		{
+			forms.GET("", middleware.PaginateWithCursor(), actions.GetForms)
+			forms.GET("/:id", actions.GetForm)
+			forms.POST("", actions.PostForm)
+			forms.PUT("/:id", actions.PutForm)
+			forms.DELETE("/:id", actions.DeleteForm)
+		}
+
 		ses := authorized.Group(("/ses"))
 		{
 			ses.GET("/keys", actions.GetSESKeys)
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,6 +13,7 @@ import (
 	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/queue"
+	"github.com/mailbadger/app/services/engagement"
 	"github.com/mailbadger/app/storage"
 	"github.com/sirupsen/logrus"
 )
@@ -30,6 +33,16 @@ func main() {
 	if err != nil {
 		logrus.WithField("time", now).WithError(err).Error("failed to start campaign scheduler job")
 	}
+
+	err = expirePendingSubscribers(s, now)
+	if err != nil {
+		logrus.WithField("time", now).WithError(err).Error("failed to delete expired pending subscribers")
+	}
+
+	err = scoreEngagement(s, now)
+	if err != nil {
+		logrus.WithField("time", now).WithError(err).Error("failed to score the engagement of subscribers")
+	}
 	end := time.Since(now)
 
 	logrus.Infof("Scheduler started at %v and took %v to finish", now, end)
@@ -140,3 +153,54 @@ func job(s storage.Storage, p queue.Producer, time time.Time) error {
 	return nil
 
 }
+
+// expirePendingSubscribers deletes the double opt-in subscribers which haven't confirmed
+// their subscription in SUBSCRIPTION_CONFIRMATION_DAYS days (7 by default).
+func expirePendingSubscribers(s storage.Storage, now time.Time) error {
+	days := 7
+	if v := os.Getenv("SUBSCRIPTION_CONFIRMATION_DAYS"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid SUBSCRIPTION_CONFIRMATION_DAYS value %q", v)
+		}
+		days = d
+	}
+
+	n, err := s.DeleteExpiredPendingSubscribers(now.AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		logrus.WithField("count", n).Info("deleted expired pending subscribers")
+	}
+
+	return nil
+}
+
+// scoreEngagement updates the engagement scores of the subscribers which weren't scored in
+// ENGAGEMENT_SCORING_INTERVAL_HOURS hours (24 by default), and enforces the sunset policies.
+func scoreEngagement(s storage.Storage, now time.Time) error {
+	hours := 24
+	if v := os.Getenv("ENGAGEMENT_SCORING_INTERVAL_HOURS"); v != "" {
+		h, err := strconv.Atoi(v)
+		if err != nil || h <= 0 {
+			return fmt.Errorf("invalid ENGAGEMENT_SCORING_INTERVAL_HOURS value %q", v)
+		}
+		hours = h
+	}
+
+	res, err := engagement.New(s, time.Duration(hours)*time.Hour).Run(context.Background(), now)
+	if err != nil {
+		return err
+	}
+
+	if res.Scored > 0 {
+		logrus.WithFields(logrus.Fields{
+			"scored": res.Scored,
+			"sunset": res.Sunset,
+		}).Info("scored the engagement of subscribers")
+	}
+
+	return nil
+}
@@ -5,4 +5,6 @@ set -euxo pipefail
 export $(egrep -v '^#' .env.local | xargs)
 trap 'kill 0' SIGINT; go run mailbadger.go & \
   go run consumers/campaigner/main.go & \
+  go run consumers/importer/main.go & \
+  go run consumers/bulkactions/main.go & \
   go run consumers/sender/main.go
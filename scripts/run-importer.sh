@@ -0,0 +1,7 @@
+#!/usr/bin/env bash
+
+set -euxo pipefail
+
+export $(egrep -v '^#' .env.local | xargs)
+
+go run consumers/importer/main.go
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/cbroglie/mustache"
 	"github.com/segmentio/ksuid"
 
 	"github.com/mailbadger/app/entities"
@@ -19,9 +18,7 @@ type Service interface {
 		id ksuid.KSUID,
 		msg entities.CampaignerTopicParams,
 		campaignID int64,
-		html *mustache.Template,
-		sub *mustache.Template,
-		text *mustache.Template,
+		tmpl *entities.CampaignTemplateData,
 	) (*entities.SenderTopicParams, error)
 	PublishSubscriberEmailParams(params *entities.SenderTopicParams) error
 }
@@ -44,9 +41,7 @@ func (svc *service) PrepareSubscriberEmailData(
 	eventID ksuid.KSUID,
 	msg entities.CampaignerTopicParams,
 	campaignID int64,
-	html *mustache.Template,
-	sub *mustache.Template,
-	text *mustache.Template,
+	tmpl *entities.CampaignTemplateData,
 ) (*entities.SenderTopicParams, error) {
 
 	var (
@@ -77,15 +72,24 @@ func (svc *service) PrepareSubscriberEmailData(
 		m[entities.TagUnsubscribeUrl] = url
 	}
 
-	err = html.FRender(&htmlBuf, m)
+	m[entities.TagPreferencesURL], err = s.GetPreferencesURL(msg.UserUUID)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: get preferences url: %w", err)
+	}
+
+	if tmpl.Layout != nil {
+		err = tmpl.HTMLPart.RenderInLayout(&htmlBuf, tmpl.Layout, m)
+	} else {
+		err = tmpl.HTMLPart.Render(&htmlBuf, m)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render html: %w", err)
 	}
-	err = sub.FRender(&subBuf, m)
+	err = tmpl.SubjectPart.Render(&subBuf, m)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render subject: %w", err)
 	}
-	err = text.FRender(&textBuf, m)
+	err = tmpl.TextPart.Render(&textBuf, m)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render text: %w", err)
 	}
@@ -0,0 +1,19 @@
+package emailvalidator
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+const key = "emailvalidator"
+
+// SetToContext sets the validator to the context
+func SetToContext(c *gin.Context, v Validator) {
+	c.Set(key, v)
+}
+
+// GetFromContext returns the validator associated with the context
+func GetFromContext(c context.Context) Validator {
+	return c.Value(key).(Validator)
+}
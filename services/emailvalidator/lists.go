@@ -0,0 +1,155 @@
+package emailvalidator
+
+import "strings"
+
+// disposableDomains are the domains of the well known disposable e-mail providers.
+var disposableDomains = toSet(
+	"0-mail.com", "10minutemail.com", "10minutemail.net", "20minutemail.com", "33mail.com",
+	"anonbox.net", "burnermail.io", "discard.email", "dispostable.com", "dropmail.me",
+	"emailondeck.com", "fakeinbox.com", "fakemail.net", "getairmail.com", "getnada.com",
+	"guerrillamail.biz", "guerrillamail.com", "guerrillamail.de", "guerrillamail.info",
+	"guerrillamail.net", "guerrillamail.org", "guerrillamailblock.com", "harakirimail.com",
+	"inboxbear.com", "incognitomail.org", "jetable.org", "mailcatch.com", "maildrop.cc",
+	"mailinator.com", "mailinator.net", "mailinator2.com", "mailnesia.com", "mailsac.com",
+	"mailtothis.com", "mintemail.com", "moakt.com", "mohmal.com", "mytemp.email",
+	"mytrashmail.com", "nada.email", "sharklasers.com", "spam4.me", "spambog.com",
+	"spamgourmet.com", "spamex.com", "temp-mail.io", "temp-mail.org", "tempail.com",
+	"tempinbox.com", "tempmail.com", "tempmail.net", "tempmailo.com", "tempr.email",
+	"throwawaymail.com", "trash-mail.com", "trashmail.com", "trashmail.de", "trashmail.net",
+	"trbvm.com", "yopmail.com", "yopmail.fr", "yopmail.net", "emailfake.com",
+)
+
+// roleLocalParts are the local parts of the addresses of roles rather than of people.
+var roleLocalParts = toSet(
+	"abuse", "accounting", "accounts", "admin", "administrator", "billing", "careers",
+	"contact", "customerservice", "enquiries", "feedback", "help", "helpdesk", "hostmaster",
+	"hr", "info", "inquiries", "jobs", "mail", "mailer-daemon", "marketing", "media",
+	"newsletter", "no-reply", "noc", "noreply", "office", "orders", "postmaster", "press",
+	"privacy", "root", "sales", "security", "service", "support", "team", "webmaster",
+)
+
+// domainTypos are the common typos of the popular domains.
+var domainTypos = map[string]string{
+	"gmial.com":     "gmail.com",
+	"gmai.com":      "gmail.com",
+	"gamil.com":     "gmail.com",
+	"gmal.com":      "gmail.com",
+	"gmil.com":      "gmail.com",
+	"gnail.com":     "gmail.com",
+	"gmaill.com":    "gmail.com",
+	"gmail.co":      "gmail.com",
+	"gmail.cm":      "gmail.com",
+	"gmail.con":     "gmail.com",
+	"gmail.cmo":     "gmail.com",
+	"gmail.om":      "gmail.com",
+	"googlemail.co": "googlemail.com",
+	"hotmial.com":   "hotmail.com",
+	"hotmai.com":    "hotmail.com",
+	"hotmal.com":    "hotmail.com",
+	"hotmil.com":    "hotmail.com",
+	"hotamil.com":   "hotmail.com",
+	"hotmail.co":    "hotmail.com",
+	"hotmail.con":   "hotmail.com",
+	"yaho.com":      "yahoo.com",
+	"yahooo.com":    "yahoo.com",
+	"yhaoo.com":     "yahoo.com",
+	"yahoo.con":     "yahoo.com",
+	"outlok.com":    "outlook.com",
+	"outllok.com":   "outlook.com",
+	"outlook.co":    "outlook.com",
+	"outlook.con":   "outlook.com",
+	"iclod.com":     "icloud.com",
+	"icloud.co":     "icloud.com",
+	"icloud.con":    "icloud.com",
+	"aol.co":        "aol.com",
+	"aol.con":       "aol.com",
+}
+
+// popularDomains are the domains the typos are looked for, the domains which differ from them
+// by a single edit are considered typos unless they are popular themselves.
+var popularDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "hotmail.com", "outlook.com", "live.com",
+	"icloud.com", "aol.com", "protonmail.com", "gmx.com", "yandex.com", "mail.com",
+	"email.com", "ymail.com", "gmx.net",
+}
+
+func toSet(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, i := range items {
+		set[i] = true
+	}
+	return set
+}
+
+// isDisposable checks the domain and its parent domains.
+func isDisposable(domain string) bool {
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// isRole checks the local part without the sub-address, e.g. info+news is info.
+func isRole(local string) bool {
+	local = strings.ToLower(local)
+	if i := strings.IndexByte(local, '+'); i > 0 {
+		local = local[:i]
+	}
+	return roleLocalParts[local]
+}
+
+// typoFix returns the popular domain the domain is a typo of, or an empty string.
+func typoFix(domain string) string {
+	if fixed, ok := domainTypos[domain]; ok {
+		return fixed
+	}
+	if len(domain) < 8 {
+		return ""
+	}
+	for _, p := range popularDomains {
+		if domain == p {
+			return ""
+		}
+	}
+	for _, p := range popularDomains {
+		if isSingleEdit(domain, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// isSingleEdit returns whether a differs from b by a single insertion, deletion, substitution
+// or transposition of adjacent characters.
+func isSingleEdit(a, b string) bool {
+	if a == b {
+		return false
+	}
+	la, lb := len(a), len(b)
+	if la-lb > 1 || lb-la > 1 {
+		return false
+	}
+
+	i := 0
+	for i < la && i < lb && a[i] == b[i] {
+		i++
+	}
+
+	switch {
+	case la == lb:
+		if a[i+1:] == b[i+1:] {
+			return true
+		}
+		return i+1 < la && a[i] == b[i+1] && a[i+1] == b[i] && a[i+2:] == b[i+2:]
+	case la > lb:
+		return a[i+1:] == b[i:]
+	default:
+		return a[i:] == b[i+1:]
+	}
+}
@@ -0,0 +1,340 @@
+package emailvalidator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// Checks of the e-mail addresses.
+const (
+	// CheckSyntax checks the address by RFC 5322, the domains can be internationalized.
+	CheckSyntax = "syntax"
+	// CheckDomain checks whether the domain has MX or A records.
+	CheckDomain = "domain"
+	// CheckDisposable checks whether the domain is of a disposable e-mail provider.
+	CheckDisposable = "disposable"
+	// CheckRole checks whether the address is of a role rather than of a person, e.g. info@.
+	CheckRole = "role"
+	// CheckTypo checks whether the domain is a common typo of a popular domain, e.g. gmial.com.
+	CheckTypo = "typo"
+)
+
+// Checks are all of the checks in the order they are run.
+var Checks = []string{CheckSyntax, CheckTypo, CheckRole, CheckDisposable, CheckDomain}
+
+const (
+	// lookupTimeout is how long the lookups of a domain can take, the domain check is
+	// skipped when they time out.
+	lookupTimeout = 5 * time.Second
+	// cacheTTL is how long the outcomes of the lookups are cached.
+	cacheTTL = time.Hour
+	// maxCachedDomains is the max number of the cached domains, the cache is cleared when it's full.
+	maxCachedDomains = 10000
+)
+
+// Policy maps the checks to the actions which are taken when the addresses fail them.
+type Policy map[string]string
+
+// DefaultPolicy rejects the addresses which are not valid or whose domains don't accept
+// e-mails, and warns about the rest of the checks.
+func DefaultPolicy() Policy {
+	return Policy{
+		CheckSyntax:     entities.EmailActionReject,
+		CheckDomain:     entities.EmailActionReject,
+		CheckDisposable: entities.EmailActionWarn,
+		CheckRole:       entities.EmailActionWarn,
+		CheckTypo:       entities.EmailActionWarn,
+	}
+}
+
+// PolicyFromEnv returns the default policy with the actions of the checks which are set by the
+// EMAIL_VALIDATION_<CHECK> env variables, e.g. EMAIL_VALIDATION_ROLE=reject.
+func PolicyFromEnv() Policy {
+	p := DefaultPolicy()
+	for _, check := range Checks {
+		action := strings.ToLower(strings.TrimSpace(os.Getenv("EMAIL_VALIDATION_" + strings.ToUpper(check))))
+		if IsAction(action) {
+			p[check] = action
+		}
+	}
+	return p
+}
+
+// IsAction returns whether the action is reject, warn or accept.
+func IsAction(action string) bool {
+	switch action {
+	case entities.EmailActionReject, entities.EmailActionWarn, entities.EmailActionAccept:
+		return true
+	}
+	return false
+}
+
+// action returns the action of the check, the checks which are not in the policy are accepted.
+func (p Policy) action(check string) string {
+	if a, ok := p[check]; ok && IsAction(a) {
+		return a
+	}
+	return entities.EmailActionAccept
+}
+
+// Resolver looks up the DNS records of the domains, net.DefaultResolver is used in production
+// and the tests use a stub.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Validator validates the e-mail addresses of the subscribers.
+type Validator interface {
+	Validate(ctx context.Context, email string) *entities.EmailValidation
+}
+
+type validator struct {
+	resolver Resolver
+	policy   Policy
+
+	mu    sync.Mutex
+	cache map[string]domainLookup
+}
+
+// domainLookup is the cached outcome of the lookups of a domain.
+type domainLookup struct {
+	reason  string
+	expires time.Time
+}
+
+// New creates a validator which looks up the domains with the resolver and takes the
+// actions of the policy.
+func New(resolver Resolver, policy Policy) Validator {
+	return &validator{
+		resolver: resolver,
+		policy:   policy,
+		cache:    make(map[string]domainLookup),
+	}
+}
+
+// Validate runs the checks of the policy on the address. The domain is not looked up
+// when the address is already rejected.
+func (v *validator) Validate(ctx context.Context, email string) *entities.EmailValidation {
+	res := &entities.EmailValidation{
+		Status:    entities.EmailStatusValid,
+		Issues:    []entities.EmailIssue{},
+		CheckedAt: time.Now().UTC(),
+	}
+
+	local, domain, reason := parseAddress(email)
+	if reason != "" {
+		v.fail(res, CheckSyntax, reason)
+		// the rest of the checks need a valid address
+		return res
+	}
+
+	if v.policy.action(CheckTypo) != entities.EmailActionAccept {
+		if fixed := typoFix(domain); fixed != "" {
+			v.fail(res, CheckTypo, "The domain looks like a typo of "+fixed+".")
+			res.Suggestion = email[:strings.LastIndex(email, "@")+1] + fixed
+		}
+	}
+
+	if v.policy.action(CheckRole) != entities.EmailActionAccept && isRole(local) {
+		v.fail(res, CheckRole, "The address belongs to a role rather than a person.")
+	}
+
+	if v.policy.action(CheckDisposable) != entities.EmailActionAccept && isDisposable(domain) {
+		v.fail(res, CheckDisposable, "The domain is of a disposable e-mail provider.")
+	}
+
+	if v.policy.action(CheckDomain) != entities.EmailActionAccept && !res.IsRejected() {
+		if reason := v.lookupDomain(ctx, domain); reason != "" {
+			v.fail(res, CheckDomain, reason)
+		}
+	}
+
+	return res
+}
+
+// fail adds the issue of the failed check by the action of the policy.
+func (v *validator) fail(res *entities.EmailValidation, check, reason string) {
+	action := v.policy.action(check)
+	switch action {
+	case entities.EmailActionAccept:
+		return
+	case entities.EmailActionReject:
+		res.Status = entities.EmailStatusInvalid
+	case entities.EmailActionWarn:
+		if res.Status == entities.EmailStatusValid {
+			res.Status = entities.EmailStatusRisky
+		}
+	}
+
+	res.Issues = append(res.Issues, entities.EmailIssue{
+		Check:  check,
+		Action: action,
+		Reason: reason,
+	})
+}
+
+// lookupDomain returns the reason the domain doesn't accept e-mails, or an empty string when it
+// does or when the lookups failed temporarily. The domains without MX records fall back to their
+// A records, by RFC 5321.
+func (v *validator) lookupDomain(ctx context.Context, domain string) string {
+	now := time.Now()
+
+	v.mu.Lock()
+	cached, ok := v.cache[domain]
+	v.mu.Unlock()
+	if ok && cached.expires.After(now) {
+		return cached.reason
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	reason, ok := v.resolve(ctx, domain)
+	if !ok {
+		return ""
+	}
+
+	v.mu.Lock()
+	if len(v.cache) >= maxCachedDomains {
+		v.cache = make(map[string]domainLookup)
+	}
+	v.cache[domain] = domainLookup{reason: reason, expires: now.Add(cacheTTL)}
+	v.mu.Unlock()
+
+	return reason
+}
+
+// resolve looks up the domain, it reports false when the lookups failed temporarily.
+func (v *validator) resolve(ctx context.Context, domain string) (string, bool) {
+	mxs, err := v.resolver.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		// the null MX record of RFC 7505 means the domain doesn't accept e-mails
+		if len(mxs) == 1 && strings.TrimSuffix(mxs[0].Host, ".") == "" {
+			return "The domain doesn't accept e-mails.", true
+		}
+		return "", true
+	}
+	if err != nil && !isNotFound(err) {
+		return "", false
+	}
+
+	hosts, err := v.resolver.LookupHost(ctx, domain)
+	if err == nil && len(hosts) > 0 {
+		return "", true
+	}
+	if err != nil && !isNotFound(err) {
+		return "", false
+	}
+
+	return "The domain has no mail servers.", true
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// parseAddress splits the address into the local part and the ASCII form of the domain, or
+// returns the reason the address is not valid. The local part is a dot-atom or a quoted string
+// of RFC 5322, and the domain literals are not allowed.
+func parseAddress(email string) (string, string, string) {
+	const invalid = "The email is not valid."
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || len(email) > 254 {
+		return "", "", invalid
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if len(local) > 64 || !(isDotAtom(local) || isQuotedString(local)) {
+		return "", "", invalid
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || !isHostname(ascii) {
+		return "", "", "The domain of the email is not valid."
+	}
+
+	return local, strings.ToLower(ascii), ""
+}
+
+// isDotAtom checks the atoms separated by dots, the UTF-8 characters are allowed by RFC 6531.
+func isDotAtom(s string) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if r >= 0x80 {
+				continue
+			}
+			if !isAtext(byte(r)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isAtext(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isQuotedString checks the quoted local part, e.g. "john doe", the quotes and the backslashes
+// inside of it must be escaped.
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	escaped := false
+	for _, r := range s[1 : len(s)-1] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"', r < 0x20 && r != '\t', r == 0x7f:
+			return false
+		}
+	}
+	return !escaped
+}
+
+// isHostname checks the labels of the domain, the domain must have a top level domain
+// which is not numeric.
+func isHostname(domain string) bool {
+	if len(domain) > 253 {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(l); i++ {
+			c := l[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	tld := labels[len(labels)-1]
+	return strings.Trim(tld, "0123456789") != ""
+}
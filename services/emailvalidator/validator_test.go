@@ -0,0 +1,251 @@
+package emailvalidator
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// stubResolver resolves the domains by its records and counts the lookups, the domains
+// without records are not found.
+type stubResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	errs    map[string]error
+	lookups int
+}
+
+func (r *stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	if mxs, ok := r.mx[name]; ok {
+		return mxs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err, ok := r.errs[host]; ok {
+		return nil, err
+	}
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestParseAddress(t *testing.T) {
+	const (
+		invalid       = "The email is not valid."
+		invalidDomain = "The domain of the email is not valid."
+	)
+
+	// the longest domain which is valid, 195 characters
+	longDomain := strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + ".com"
+
+	tests := []struct {
+		name   string
+		email  string
+		local  string
+		domain string
+		reason string
+	}{
+		{name: "simple", email: "jane@example.com", local: "jane", domain: "example.com"},
+		{name: "domain lowercased", email: "Jane@Example.COM", local: "Jane", domain: "example.com"},
+		{name: "trailing dot of the domain", email: "jane@example.com.", local: "jane", domain: "example.com"},
+		{name: "sub-address", email: "jane+news@example.com", local: "jane+news", domain: "example.com"},
+		{name: "special characters", email: "j!#$%&'*/=?^_`{|}~-e@example.com", local: "j!#$%&'*/=?^_`{|}~-e", domain: "example.com"},
+		{name: "dots", email: "jane.doe@example.com", local: "jane.doe", domain: "example.com"},
+		{name: "leading dot", email: ".jane@example.com", reason: invalid},
+		{name: "trailing dot", email: "jane.@example.com", reason: invalid},
+		{name: "consecutive dots", email: "jane..doe@example.com", reason: invalid},
+		{name: "space", email: "jane doe@example.com", reason: invalid},
+		{name: "quoted", email: `"jane doe"@example.com`, local: `"jane doe"`, domain: "example.com"},
+		{name: "quoted with at sign", email: `"jane@home"@example.com`, local: `"jane@home"`, domain: "example.com"},
+		{name: "quoted with escaped quote", email: `"jane\"doe"@example.com`, local: `"jane\"doe"`, domain: "example.com"},
+		{name: "quoted with unescaped quote", email: `"jane"doe"@example.com`, reason: invalid},
+		{name: "quoted with escaped closing quote", email: `"jane\"@example.com`, reason: invalid},
+		{name: "empty quotes", email: `""@example.com`, local: `""`, domain: "example.com"},
+		{name: "utf-8 local part", email: "јана@example.com", local: "јана", domain: "example.com"},
+		{name: "idn", email: "jane@bücher.de", local: "jane", domain: "xn--bcher-kva.de"},
+		{name: "idn uppercased", email: "jane@BÜCHER.de", local: "jane", domain: "xn--bcher-kva.de"},
+		{name: "punycode", email: "jane@xn--bcher-kva.de", local: "jane", domain: "xn--bcher-kva.de"},
+		{name: "missing at sign", email: "jane.example.com", reason: invalid},
+		{name: "missing local part", email: "@example.com", reason: invalid},
+		{name: "missing domain", email: "jane@", reason: invalid},
+		{name: "domain without tld", email: "jane@localhost", reason: invalidDomain},
+		{name: "numeric tld", email: "jane@127.0.0.1", reason: invalidDomain},
+		{name: "domain literal", email: "jane@[127.0.0.1]", reason: invalidDomain},
+		{name: "label with leading hyphen", email: "jane@-example.com", reason: invalidDomain},
+		{name: "empty label", email: "jane@example..com", reason: invalidDomain},
+		{name: "underscore in domain", email: "jane@exa_mple.com", reason: invalidDomain},
+		{name: "local part of 64 characters", email: strings.Repeat("a", 64) + "@example.com", local: strings.Repeat("a", 64), domain: "example.com"},
+		{name: "local part of 65 characters", email: strings.Repeat("a", 65) + "@example.com", reason: invalid},
+		{name: "label of 64 characters", email: "jane@" + strings.Repeat("a", 64) + ".com", reason: invalidDomain},
+		{name: "address of 254 characters", email: strings.Repeat("a", 58) + "@" + longDomain, local: strings.Repeat("a", 58), domain: longDomain},
+		{name: "address of 255 characters", email: strings.Repeat("a", 59) + "@" + longDomain, reason: invalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, domain, reason := parseAddress(tt.email)
+			assert.Equal(t, tt.reason, reason)
+			assert.Equal(t, tt.local, local)
+			assert.Equal(t, tt.domain, domain)
+		})
+	}
+}
+
+func TestIsSingleEdit(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect bool
+	}{
+		{a: "gmail.com", b: "gmail.com", expect: false},
+		{a: "gmaik.com", b: "gmail.com", expect: true},
+		{a: "gmaill.com", b: "gmail.com", expect: true},
+		{a: "gmai.com", b: "gmail.com", expect: true},
+		{a: "mail.com", b: "gmail.com", expect: true},
+		{a: "gmail.comm", b: "gmail.com", expect: true},
+		{a: "gmali.com", b: "gmail.com", expect: true},
+		{a: "gmail.cmo", b: "gmail.com", expect: true},
+		{a: "mgail.com", b: "gmail.com", expect: true},
+		{a: "gmial.con", b: "gmail.com", expect: false},
+		{a: "gmal.co", b: "gmail.com", expect: false},
+		{a: "gmailcom.", b: "gmail.com", expect: false},
+		{a: "", b: "a", expect: true},
+		{a: "ab", b: "ba", expect: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expect, isSingleEdit(tt.a, tt.b))
+			assert.Equal(t, tt.expect, isSingleEdit(tt.b, tt.a))
+		})
+	}
+}
+
+func TestTypoFix(t *testing.T) {
+	tests := []struct {
+		domain string
+		expect string
+	}{
+		{domain: "gmial.com", expect: "gmail.com"},
+		{domain: "gmail.co", expect: "gmail.com"},
+		{domain: "aol.con", expect: "aol.com"},
+		{domain: "gmaik.com", expect: "gmail.com"},
+		{domain: "hotmaill.com", expect: "hotmail.com"},
+		{domain: "yahoo.cmo", expect: "yahoo.com"},
+		{domain: "outloo.com", expect: "outlook.com"},
+		{domain: "gmx.nett", expect: "gmx.net"},
+		// the popular domains are not typos of each other
+		{domain: "gmail.com", expect: ""},
+		{domain: "gmx.com", expect: ""},
+		{domain: "mail.com", expect: ""},
+		// the short domains are too close to too many domains
+		{domain: "aol.cm", expect: ""},
+		{domain: "example.com", expect: ""},
+		{domain: "gmail.example.com", expect: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			assert.Equal(t, tt.expect, typoFix(tt.domain))
+		})
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	resolver := &stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com":   {{Host: "mx.example.com.", Pref: 10}},
+			"null-mx.com":   {{Host: ".", Pref: 0}},
+			"backup-mx.com": {{Host: "mx1.backup-mx.com.", Pref: 10}, {Host: "mx2.backup-mx.com.", Pref: 20}},
+		},
+		hosts: map[string][]string{
+			"a-record.com": {"192.0.2.1"},
+		},
+		errs: map[string]error{
+			"timeout.com":  &net.DNSError{Err: "i/o timeout", Name: "timeout.com", IsTimeout: true, IsTemporary: true},
+			"servfail.com": &net.DNSError{Err: "server misbehaving", Name: "servfail.com", IsTemporary: true},
+		},
+	}
+	v := New(resolver, DefaultPolicy())
+
+	tests := []struct {
+		name   string
+		email  string
+		status string
+		reason string
+	}{
+		{name: "mx records", email: "jane@example.com", status: entities.EmailStatusValid},
+		{name: "multiple mx records", email: "jane@backup-mx.com", status: entities.EmailStatusValid},
+		{name: "fallback to a records", email: "jane@a-record.com", status: entities.EmailStatusValid},
+		{name: "null mx", email: "jane@null-mx.com", status: entities.EmailStatusInvalid, reason: "The domain doesn't accept e-mails."},
+		{name: "nxdomain", email: "jane@nowhere.com", status: entities.EmailStatusInvalid, reason: "The domain has no mail servers."},
+		{name: "timeout", email: "jane@timeout.com", status: entities.EmailStatusValid},
+		{name: "temporary failure", email: "jane@servfail.com", status: entities.EmailStatusValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := v.Validate(context.Background(), tt.email)
+			assert.Equal(t, tt.status, res.Status)
+			if tt.reason == "" {
+				assert.Empty(t, res.Issues)
+				return
+			}
+			if assert.Len(t, res.Issues, 1) {
+				assert.Equal(t, CheckDomain, res.Issues[0].Check)
+				assert.Equal(t, entities.EmailActionReject, res.Issues[0].Action)
+				assert.Equal(t, tt.reason, res.Issues[0].Reason)
+			}
+		})
+	}
+}
+
+func TestLookupDomainCache(t *testing.T) {
+	resolver := &stubResolver{
+		errs: map[string]error{
+			"servfail.com": &net.DNSError{Err: "server misbehaving", Name: "servfail.com", IsTemporary: true},
+		},
+	}
+	v := New(resolver, DefaultPolicy()).(*validator)
+	ctx := context.Background()
+
+	// test the outcome is cached
+	assert.Equal(t, "The domain has no mail servers.", v.lookupDomain(ctx, "nowhere.com"))
+	assert.Equal(t, 1, resolver.lookups)
+
+	resolver.mx = map[string][]*net.MX{"nowhere.com": {{Host: "mx.nowhere.com.", Pref: 10}}}
+	assert.Equal(t, "The domain has no mail servers.", v.lookupDomain(ctx, "nowhere.com"))
+	assert.Equal(t, 1, resolver.lookups)
+
+	// test the expired outcome is looked up again
+	v.cache["nowhere.com"] = domainLookup{
+		reason:  v.cache["nowhere.com"].reason,
+		expires: time.Now().Add(-time.Second),
+	}
+	assert.Equal(t, "", v.lookupDomain(ctx, "nowhere.com"))
+	assert.Equal(t, 2, resolver.lookups)
+	assert.True(t, v.cache["nowhere.com"].expires.After(time.Now().Add(cacheTTL-time.Minute)))
+
+	// test the temporary failures are not cached
+	assert.Equal(t, "", v.lookupDomain(ctx, "servfail.com"))
+	assert.Equal(t, "", v.lookupDomain(ctx, "servfail.com"))
+	assert.Equal(t, 4, resolver.lookups)
+	_, ok := v.cache["servfail.com"]
+	assert.False(t, ok)
+
+	// test the rejected addresses are not looked up
+	v.Validate(ctx, "jane@")
+	assert.Equal(t, 4, resolver.lookups)
+}
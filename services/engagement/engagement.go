@@ -0,0 +1,178 @@
+package engagement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+)
+
+// batchSize is the number of the subscribers which are scored at once.
+const batchSize = 1000
+
+// Service scores the engagement of the subscribers and enforces the sunset policies of the users.
+type Service interface {
+	Run(ctx context.Context, now time.Time) (*Result, error)
+}
+
+type service struct {
+	db       storage.Storage
+	interval time.Duration
+}
+
+// New returns a new engagement service, the subscribers are scored again once the interval
+// passes since they were last scored.
+func New(db storage.Storage, interval time.Duration) Service {
+	return &service{db, interval}
+}
+
+// Result is the outcome of a run of the scoring.
+type Result struct {
+	Scored int `json:"scored"`
+	Sunset int `json:"sunset"`
+}
+
+// Run scores the engagement of the subscribers who weren't scored in the interval, see
+// entities.EngagementStats.Score, and deactivates the active subscribers whose number of
+// the campaigns they didn't open or click reaches the sunset policy of their user.
+func (s *service) Run(ctx context.Context, now time.Time) (*Result, error) {
+	var (
+		res      = &Result{}
+		nextID   int64
+		policies = make(map[int64]*entities.SunsetPolicy)
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("engagement: %w", err)
+		}
+
+		subs, err := s.db.SeekSubscribersToScore(now.Add(-s.interval), nextID, batchSize)
+		if err != nil {
+			return res, fmt.Errorf("engagement: get subscribers: %w", err)
+		}
+		if len(subs) == 0 {
+			return res, nil
+		}
+		nextID = subs[len(subs)-1].ID
+
+		byUser := make(map[int64][]entities.Subscriber)
+		for _, sub := range subs {
+			byUser[sub.UserID] = append(byUser[sub.UserID], sub)
+		}
+
+		for userID, userSubs := range byUser {
+			if err := s.score(userID, userSubs, now); err != nil {
+				return res, err
+			}
+			res.Scored += len(userSubs)
+
+			policy, ok := policies[userID]
+			if !ok {
+				policy, err = s.db.GetSunsetPolicy(userID)
+				if err != nil {
+					if !errors.Is(err, gorm.ErrRecordNotFound) {
+						return res, fmt.Errorf("engagement: get sunset policy: %w", err)
+					}
+					policy = nil
+				}
+				policies[userID] = policy
+			}
+			if policy == nil || !policy.Enabled {
+				continue
+			}
+
+			n, err := s.sunset(userID, userSubs, policy)
+			if err != nil {
+				return res, err
+			}
+			res.Sunset += n
+		}
+
+		if len(subs) < batchSize {
+			return res, nil
+		}
+	}
+}
+
+// score updates the engagement of the subscribers of the user.
+func (s *service) score(userID int64, subs []entities.Subscriber, now time.Time) error {
+	emails := make([]string, len(subs))
+	for i, sub := range subs {
+		emails[i] = sub.Email
+	}
+
+	stats, err := s.db.GetEngagementStats(userID, emails, now.Add(-entities.EngagementWindow))
+	if err != nil {
+		return fmt.Errorf("engagement: %w", err)
+	}
+
+	for i := range subs {
+		st, ok := stats[subs[i].Email]
+		if !ok {
+			st = &entities.EngagementStats{}
+		}
+		// the last engagement is kept once it's out of the window.
+		if st.LastEngagedAt == nil {
+			st.LastEngagedAt = subs[i].LastEngagedAt
+		}
+		subs[i].EngagementScore = st.Score(now)
+		subs[i].LastEngagedAt = st.LastEngagedAt
+	}
+
+	if err := s.db.UpdateSubscribersEngagement(subs, now); err != nil {
+		return fmt.Errorf("engagement: %w", err)
+	}
+	return nil
+}
+
+// sunset deactivates the active subscribers of the user who didn't engage with the number of the
+// campaigns of the policy, and returns the number of the deactivated subscribers.
+func (s *service) sunset(userID int64, subs []entities.Subscriber, policy *entities.SunsetPolicy) (int, error) {
+	ids := make([]int64, 0, len(subs))
+	for _, sub := range subs {
+		if sub.Active {
+			ids = append(ids, sub.ID)
+		}
+	}
+
+	counts, err := s.db.GetUnengagedCampaigns(userID, ids)
+	if err != nil {
+		return 0, fmt.Errorf("engagement: %w", err)
+	}
+
+	var (
+		sunset []entities.Subscriber
+		events []entities.SubscriberEvent
+	)
+	for _, sub := range subs {
+		n := counts[sub.ID]
+		if !sub.Active || n < policy.UnengagedCampaigns {
+			continue
+		}
+
+		data, err := json.Marshal(map[string]int64{
+			"unengaged_campaigns": n,
+			"policy":              policy.UnengagedCampaigns,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("engagement: marshal sunset event: %w", err)
+		}
+		sunset = append(sunset, sub)
+		events = append(events, entities.SubscriberEvent{Data: data})
+	}
+
+	if len(sunset) == 0 {
+		return 0, nil
+	}
+	if err := s.db.SunsetSubscribers(sunset, events); err != nil {
+		return 0, fmt.Errorf("engagement: %w", err)
+	}
+	return len(sunset), nil
+}
@@ -3,9 +3,9 @@ package exporters
 import (
 	"context"
 	"errors"
+	"fmt"
 
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
 )
 
@@ -18,11 +18,18 @@ type Exporter interface {
 	Export(c context.Context, userID int64, report *entities.Report) error
 }
 
-func NewExporter(resource string, s3 s3iface.S3API) (Exporter, error) {
+func NewExporter(resource string, store blobs.Store) (Exporter, error) {
 	switch resource {
 	case "subscribers":
-		return NewSubscribersExporter(s3), nil
+		return NewSubscribersExporter(store), nil
+	case "suppressions":
+		return NewSuppressionsExporter(store), nil
 	default:
 		return nil, ErrUnknownResource
 	}
 }
+
+// Key returns the key of the file the report of the resource is exported to.
+func Key(resource string, userID int64, fileName string) string {
+	return fmt.Sprintf("%s/export/%d/%s", resource, userID, fileName)
+}
@@ -9,21 +9,18 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/storage"
 )
 
 type SubscribersExporter struct {
-	S3 s3iface.S3API
+	Blobs blobs.Store
 }
 
-func NewSubscribersExporter(s3 s3iface.S3API) *SubscribersExporter {
+func NewSubscribersExporter(store blobs.Store) *SubscribersExporter {
 	return &SubscribersExporter{
-		S3: s3,
+		Blobs: store,
 	}
 }
 
@@ -38,9 +35,14 @@ func (se *SubscribersExporter) Export(c context.Context, userID int64, report *e
 
 	writer := csv.NewWriter(&buf)
 
+	fields, err := storage.GetCustomFields(c, userID)
+	if err != nil {
+		return fmt.Errorf("get custom fields: %w", err)
+	}
+
 	// writing headers
 	// change this function to change the headers
-	err = writeHeaders(writer)
+	err = writeHeaders(writer, fields)
 	if err != nil {
 		return fmt.Errorf("write headers: %w", err)
 	}
@@ -52,7 +54,7 @@ func (se *SubscribersExporter) Export(c context.Context, userID int64, report *e
 		}
 
 		// writing subscribers
-		err = writeSubscribers(writer, subscribers)
+		err = writeSubscribers(writer, subscribers, fields)
 		if err != nil {
 			return fmt.Errorf("write %d subscribers with id greater than %d: %w", limit, nextID, err)
 		}
@@ -66,21 +68,23 @@ func (se *SubscribersExporter) Export(c context.Context, userID int64, report *e
 
 	writer.Flush()
 
-	_, err = se.S3.PutObject(&s3.PutObjectInput{
-		Bucket: aws.String(os.Getenv("FILES_BUCKET")),
-		Key:    aws.String(fmt.Sprintf("subscribers/export/%d/%s", userID, report.FileName)),
-		Body:   bytes.NewReader(buf.Bytes()),
-	})
+	err = se.Blobs.Put(
+		os.Getenv("FILES_BUCKET"),
+		Key("subscribers", userID, report.FileName),
+		bytes.NewReader(buf.Bytes()),
+		blobs.PutOptions{ContentType: "text/csv"},
+	)
 	if err != nil {
-		return fmt.Errorf("put object: %w", err)
+		return fmt.Errorf("put export: %w", err)
 	}
 
 	return nil
 }
 
-// writeHeaders writes headers to csv
-func writeHeaders(writer *csv.Writer) error {
-	return writer.Write([]string{
+// writeHeaders writes headers to csv, the custom fields are written
+// in columns of their own after the rest, headed by their keys.
+func writeHeaders(writer *csv.Writer, fields entities.CustomFields) error {
+	headers := []string{
 		"Name",
 		"Email",
 		"User ID",
@@ -89,11 +93,15 @@ func writeHeaders(writer *csv.Writer) error {
 		"Metadata",
 		"Blacklisted",
 		"Created At",
-	})
+	}
+	for _, f := range fields {
+		headers = append(headers, f.Key)
+	}
+	return writer.Write(headers)
 }
 
 // writeSubscribers writes the given subscribers into the csv
-func writeSubscribers(writer *csv.Writer, subscribers []entities.Subscriber) error {
+func writeSubscribers(writer *csv.Writer, subscribers []entities.Subscriber, fields entities.CustomFields) error {
 	for _, s := range subscribers {
 		_, err := s.GetMetadata()
 		if err != nil {
@@ -110,7 +118,7 @@ func writeSubscribers(writer *csv.Writer, subscribers []entities.Subscriber) err
 			return fmt.Errorf("format metadata: %w", err)
 		}
 
-		err = writer.Write([]string{
+		record := []string{
 			s.Name,
 			s.Email,
 			strconv.FormatInt(s.UserID, 10),
@@ -119,7 +127,12 @@ func writeSubscribers(writer *csv.Writer, subscribers []entities.Subscriber) err
 			formatMetadata,
 			strconv.FormatBool(s.Blacklisted),
 			s.GetCreatedAt().Format("2006-01-02 15:04:05"),
-		})
+		}
+		for i := range fields {
+			record = append(record, formatCustomField(&fields[i], s.Metadata))
+		}
+
+		err = writer.Write(record)
 		if err != nil {
 			return fmt.Errorf("write: %w", err)
 		}
@@ -167,3 +180,17 @@ func formatMetadata(metadata map[string]string) (string, error) {
 	s = s[:b.Len()-2] // remove trailing "; "
 	return s, nil
 }
+
+// formatCustomField returns the value of the custom field in the format of its type, the values
+// which were stored before the field was defined are returned as they are when they don't match it.
+func formatCustomField(f *entities.CustomField, metadata map[string]string) string {
+	v, ok := metadata[f.Key]
+	if !ok {
+		return ""
+	}
+
+	if nv, err := f.NormalizeValue(v); err == nil {
+		return nv
+	}
+	return v
+}
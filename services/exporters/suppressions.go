@@ -0,0 +1,83 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+)
+
+type SuppressionsExporter struct {
+	Blobs blobs.Store
+}
+
+func NewSuppressionsExporter(store blobs.Store) *SuppressionsExporter {
+	return &SuppressionsExporter{
+		Blobs: store,
+	}
+}
+
+// Export writes the suppression list of the user to a CSV file, which can be imported back.
+func (se *SuppressionsExporter) Export(c context.Context, userID int64, report *entities.Report) error {
+	var (
+		nextID int64
+		limit  int64 = 1000
+
+		buf bytes.Buffer
+	)
+
+	writer := csv.NewWriter(&buf)
+
+	err := writer.Write([]string{"Value", "Reason", "Type", "Source", "Created At"})
+	if err != nil {
+		return fmt.Errorf("write headers: %w", err)
+	}
+
+	for {
+		suppressions, err := storage.SeekSuppressionsByUserID(c, userID, nextID, limit)
+		if err != nil {
+			return fmt.Errorf("get suppressions: %w", err)
+		}
+
+		for _, s := range suppressions {
+			err = writer.Write([]string{
+				s.Value,
+				s.Reason,
+				s.Type,
+				s.Source,
+				s.GetCreatedAt().Format("2006-01-02 15:04:05"),
+			})
+			if err != nil {
+				return fmt.Errorf("write suppression %d: %w", s.ID, err)
+			}
+		}
+
+		if len(suppressions) < int(limit) {
+			break
+		}
+
+		nextID = suppressions[len(suppressions)-1].ID
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	err = se.Blobs.Put(
+		os.Getenv("FILES_BUCKET"),
+		Key("suppressions", userID, report.FileName),
+		bytes.NewReader(buf.Bytes()),
+		blobs.PutOptions{ContentType: "text/csv"},
+	)
+	if err != nil {
+		return fmt.Errorf("put export: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package subscribers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+)
+
+var (
+	ErrBulkActionCancelled = errors.New("bulk action: cancelled")
+	// ErrBulkActionSegment is returned when the segment of the bulk action was deleted, or it's dynamic.
+	ErrBulkActionSegment = errors.New("bulk action: segment not found")
+)
+
+// RunBulkAction applies the action of the pending bulk action to the subscribers who match its filter,
+// and keeps the status and the counters of the bulk action up to date. The subscribers are processed in
+// batches ordered by their ids, each batch in a transaction, and the bulk action stops after the current
+// batch when it's cancelled. The bulk actions which aren't pending are not run again.
+func (s *service) RunBulkAction(ctx context.Context, a *entities.SubscriberBulkAction) error {
+	a.Status = entities.StatusInProgress
+	a.StartedAt.SetValid(time.Now().UTC())
+	ok, err := s.db.UpdateSubscriberBulkAction(a, entities.StatusPending)
+	if err != nil {
+		return fmt.Errorf("bulk action: start: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	err = s.applyBulkAction(ctx, a)
+
+	switch {
+	case errors.Is(err, ErrBulkActionCancelled):
+		return nil
+	case errors.Is(err, ErrBulkActionSegment):
+		a.Status = entities.StatusFailed
+		a.Error = "The segment was not found."
+	case err != nil:
+		a.Status = entities.StatusFailed
+		a.Error = "Unable to apply the action."
+	default:
+		a.Status = entities.StatusDone
+	}
+	a.CompletedAt.SetValid(time.Now().UTC())
+
+	if _, uerr := s.db.UpdateSubscriberBulkAction(a, entities.StatusInProgress); uerr != nil {
+		return fmt.Errorf("bulk action: finish: %w", uerr)
+	}
+
+	return err
+}
+
+// applyBulkAction applies the action to the batches of the subscribers who match the filter, the
+// counters of the bulk action are updated after each batch.
+func (s *service) applyBulkAction(ctx context.Context, a *entities.SubscriberBulkAction) error {
+	filter, err := a.GetFilter()
+	if err != nil {
+		return fmt.Errorf("bulk action: get filter: %w", err)
+	}
+
+	var segment *entities.Segment
+	if a.Action == entities.BulkActionAddToSegment || a.Action == entities.BulkActionRemoveFromSegment {
+		segs, err := s.db.GetSegmentsByIDs(a.UserID, []int64{a.SegmentID})
+		if err != nil {
+			return fmt.Errorf("bulk action: get segment: %w", err)
+		}
+		if len(segs) == 0 || segs[0].Dynamic {
+			return ErrBulkActionSegment
+		}
+		segment = &segs[0]
+	}
+
+	var fields entities.CustomFields
+	if a.Action == entities.BulkActionSetMetadata {
+		fields, err = s.db.GetCustomFields(a.UserID)
+		if err != nil {
+			return fmt.Errorf("bulk action: get custom fields: %w", err)
+		}
+	}
+
+	var nextID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("bulk action: %w", err)
+		}
+
+		subs, err := s.db.SeekSubscribersByFilter(a.UserID, filter, nextID, defaultBatchSize)
+		if err != nil {
+			return fmt.Errorf("bulk action: seek subscribers: %w", err)
+		}
+		if len(subs) == 0 {
+			return nil
+		}
+		nextID = subs[len(subs)-1].ID
+
+		affected, failed, err := s.bulkActionBatch(a, segment, fields, subs)
+		if err != nil {
+			return err
+		}
+		a.Processed += int64(len(subs))
+		a.Affected += int64(affected)
+		a.Failed += int64(failed)
+
+		ok, err := s.db.UpdateSubscriberBulkAction(a, entities.StatusInProgress)
+		if err != nil {
+			return fmt.Errorf("bulk action: update progress: %w", err)
+		}
+		if !ok {
+			return ErrBulkActionCancelled
+		}
+
+		if len(subs) < defaultBatchSize {
+			return nil
+		}
+	}
+}
+
+// bulkActionBatch applies the action to the batch of the subscribers. It returns the number of the
+// subscribers who were changed, and of the ones whose metadata doesn't match the custom fields once
+// the metadata value is set.
+func (s *service) bulkActionBatch(
+	a *entities.SubscriberBulkAction,
+	segment *entities.Segment,
+	fields entities.CustomFields,
+	subs []entities.Subscriber,
+) (int, int, error) {
+	if a.Action == entities.BulkActionDelete {
+		if err := s.db.DeleteSubscribers(a.UserID, subs); err != nil {
+			return 0, 0, fmt.Errorf("bulk action: delete subscribers: %w", err)
+		}
+		return len(subs), 0, nil
+	}
+
+	var (
+		updated []*entities.Subscriber
+		events  []entities.SubscriberEvent
+		failed  int
+	)
+	for i := range subs {
+		sub := &subs[i]
+
+		evs, ok, err := applySubscriberBulkAction(a, segment, fields, sub)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bulk action: apply action: %w", err)
+		}
+		if !ok {
+			failed++
+			continue
+		}
+		if len(evs) == 0 {
+			continue
+		}
+		updated = append(updated, sub)
+		events = append(events, evs...)
+	}
+
+	if len(updated) > 0 {
+		if err := s.db.SaveBulkUpdatedSubscribers(updated, events); err != nil {
+			return 0, 0, fmt.Errorf("bulk action: save subscribers: %w", err)
+		}
+	}
+
+	return len(updated), failed, nil
+}
+
+// applySubscriberBulkAction applies the action to the subscriber and returns the events of the changes,
+// there are none when the subscriber is left as it is. It reports false when the metadata of the
+// subscriber doesn't match the custom fields once the metadata value is set.
+func applySubscriberBulkAction(
+	a *entities.SubscriberBulkAction,
+	segment *entities.Segment,
+	fields entities.CustomFields,
+	sub *entities.Subscriber,
+) ([]entities.SubscriberEvent, bool, error) {
+	var (
+		t    entities.EventType
+		data interface{}
+	)
+
+	switch a.Action {
+	case entities.BulkActionAddToSegment, entities.BulkActionRemoveFromSegment:
+		kept := make([]entities.Segment, 0, len(sub.Segments)+1)
+		member := false
+		for _, seg := range sub.Segments {
+			if seg.ID == segment.ID {
+				member = true
+				continue
+			}
+			kept = append(kept, seg)
+		}
+
+		add := a.Action == entities.BulkActionAddToSegment
+		if member == add {
+			return nil, true, nil
+		}
+		t = entities.SubscriberEventTypeSegmentLeave
+		if add {
+			t = entities.SubscriberEventTypeSegmentJoin
+			kept = append(kept, *segment)
+		}
+		sub.Segments = kept
+		data = map[string]interface{}{
+			"segment_id":   segment.ID,
+			"segment_name": segment.Name,
+		}
+	case entities.BulkActionSetMetadata:
+		var (
+			set   map[string]string
+			unset []string
+		)
+		if a.MetadataValue != "" {
+			set = map[string]string{a.MetadataKey: a.MetadataValue}
+		} else {
+			unset = []string{a.MetadataKey}
+		}
+
+		mu, err := updateMetadata(sub, fields, set, unset)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(mu.Errors) > 0 {
+			return nil, false, nil
+		}
+		if len(mu.Keys) == 0 {
+			return nil, true, nil
+		}
+		t = entities.SubscriberEventTypeUpdated
+		data = map[string]interface{}{
+			"metadata": mu.Changes,
+		}
+	case entities.BulkActionDeactivate:
+		if !sub.Active {
+			return nil, true, nil
+		}
+		sub.Active = false
+		t = entities.SubscriberEventTypeUpdated
+		data = map[string]interface{}{
+			"active": fieldChange{Old: strconv.FormatBool(true), New: strconv.FormatBool(false)},
+		}
+	case entities.BulkActionBlacklist:
+		if sub.Blacklisted {
+			return nil, true, nil
+		}
+		sub.Blacklisted = true
+		t = entities.SubscriberEventTypeUpdated
+		data = map[string]interface{}{
+			"blacklisted": fieldChange{Old: strconv.FormatBool(false), New: strconv.FormatBool(true)},
+		}
+	default:
+		return nil, false, fmt.Errorf("unknown action '%s'", a.Action)
+	}
+
+	ev, err := subscriberEvent(sub, t, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return []entities.SubscriberEvent{ev}, true, nil
+}
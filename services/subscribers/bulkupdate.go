@@ -0,0 +1,758 @@
+package subscribers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// Columns of the bulk update files. The headers are case insensitive, and the metadata values
+// are set by the columns prefixed by BulkColumnMetadataPrefix, e.g. metadata.city.
+const (
+	BulkColumnEmail       = "email"
+	BulkColumnName        = "name"
+	BulkColumnActive      = "active"
+	BulkColumnBlacklisted = "blacklisted"
+	// BulkColumnUnsetMetadata lists the metadata keys which are removed.
+	BulkColumnUnsetMetadata = "unset_metadata"
+	// BulkColumnAddSegments and BulkColumnRemoveSegments list the ids of the static segments
+	// the subscriber is added to or removed from.
+	BulkColumnAddSegments    = "add_segments"
+	BulkColumnRemoveSegments = "remove_segments"
+	BulkColumnMetadataPrefix = "metadata."
+)
+
+// Results of the rows of the bulk update report.
+const (
+	BulkResultUpdated   = "updated"
+	BulkResultUnchanged = "unchanged"
+	BulkResultFailed    = "failed"
+)
+
+// maxReportRows is the max number of the rows which are kept for the bulk update report.
+const maxReportRows = 10000
+
+// BulkUpdateOptions are the options of the bulk update.
+type BulkUpdateOptions struct {
+	FileOptions
+	// DryRun only reports the changes, the subscribers are not updated.
+	DryRun bool
+	// BatchSize is the number of the rows which are updated in a transaction, 500 by default.
+	BatchSize int
+	// Progress is called with the result so far after each batch, the bulk update stops with
+	// the returned error.
+	Progress func(*BulkUpdateResult) error
+}
+
+// BulkUpdateResult is the outcome of the bulk update.
+type BulkUpdateResult struct {
+	Updated   int
+	Unchanged int
+	Rejected  int
+	// Rows are the results of the rows of the file, up to 10000 of them.
+	Rows []BulkUpdateRow
+}
+
+// BulkUpdateRow is the result of a row of the bulk update file.
+type BulkUpdateRow struct {
+	// Row is the number of the row in the file, see RejectedRow.
+	Row    int
+	Email  string
+	Result string
+	// Details are the changes of the updated rows, or the reason the row failed.
+	Details string
+}
+
+func (r *BulkUpdateResult) add(row BulkUpdateRow) {
+	switch row.Result {
+	case BulkResultUpdated:
+		r.Updated++
+	case BulkResultUnchanged:
+		r.Unchanged++
+	default:
+		r.Rejected++
+	}
+	if len(r.Rows) < maxReportRows {
+		r.Rows = append(r.Rows, row)
+	}
+}
+
+// Processed returns the number of the rows which were updated, unchanged or rejected.
+func (r *BulkUpdateResult) Processed() int {
+	return r.Updated + r.Unchanged + r.Rejected
+}
+
+// WriteReport writes the results of the rows as CSV, ordered by the rows of the file.
+func (r *BulkUpdateResult) WriteReport(w io.Writer) error {
+	sort.SliceStable(r.Rows, func(i, j int) bool {
+		return r.Rows[i].Row < r.Rows[j].Row
+	})
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"row", "email", "result", "details"}); err != nil {
+		return err
+	}
+	for _, rr := range r.Rows {
+		if err := cw.Write([]string{strconv.Itoa(rr.Row), rr.Email, rr.Result, rr.Details}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// BulkUpdateReportKey returns the key of the report of the bulk update, see ImportErrorsKey.
+func BulkUpdateReportKey(userID, importID int64) string {
+	return fmt.Sprintf("subscribers/bulk-update-reports/%d/%d", userID, importID)
+}
+
+// setBulkUpdateResult sets the counters of the bulk update to the result so far, the unchanged
+// rows are counted as skipped.
+func setBulkUpdateResult(imp *entities.SubscriberImport, res *BulkUpdateResult) {
+	imp.Processed = int64(res.Processed())
+	imp.Updated = int64(res.Updated)
+	imp.Skipped = int64(res.Unchanged)
+	imp.Failed = int64(res.Rejected)
+}
+
+// bulkColumns are the positions of the columns of the bulk update file.
+type bulkColumns struct {
+	columns     int
+	email       int
+	name        int
+	active      int
+	blacklisted int
+	unset       int
+	add         int
+	remove      int
+	metadata    map[int]string
+}
+
+// bulkUpdate are the changes of a row of the bulk update file, the empty values are left as they are.
+type bulkUpdate struct {
+	Email       string
+	Name        string
+	Active      *bool
+	Blacklisted *bool
+	Metadata    map[string]string
+	Unset       []string
+	Add         []int64
+	Remove      []int64
+	Row         int
+}
+
+// MapBulkUpdateColumns maps the columns of the header of the bulk update file, see the BulkColumn
+// constants. The errors wrap ErrInvalidMapping, or ErrInvalidFormat when there is no email column.
+func MapBulkUpdateColumns(header []string) (*bulkColumns, error) {
+	m := &bulkColumns{
+		columns:     len(header),
+		email:       -1,
+		name:        -1,
+		active:      -1,
+		blacklisted: -1,
+		unset:       -1,
+		add:         -1,
+		remove:      -1,
+		metadata:    make(map[int]string),
+	}
+	positions := map[string]*int{
+		BulkColumnEmail:          &m.email,
+		BulkColumnName:           &m.name,
+		BulkColumnActive:         &m.active,
+		BulkColumnBlacklisted:    &m.blacklisted,
+		BulkColumnUnsetMetadata:  &m.unset,
+		BulkColumnAddSegments:    &m.add,
+		BulkColumnRemoveSegments: &m.remove,
+	}
+
+	keys := make(map[string]bool)
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		if pos, ok := positions[strings.ToLower(h)]; ok {
+			if *pos >= 0 {
+				return nil, fmt.Errorf("%w: the column '%s' is duplicated", ErrInvalidMapping, h)
+			}
+			*pos = i
+			continue
+		}
+
+		prefix := len(BulkColumnMetadataPrefix)
+		if len(h) <= prefix || !strings.EqualFold(h[:prefix], BulkColumnMetadataPrefix) {
+			return nil, fmt.Errorf("%w: unknown column '%s'", ErrInvalidMapping, h)
+		}
+		key := h[prefix:]
+		if !metadataKeyPattern.MatchString(key) || len(key) > 191 {
+			return nil, fmt.Errorf("%w: the metadata key '%s' must consist only of alphanumeric and hyphen characters", ErrInvalidMapping, key)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("%w: the column '%s' is duplicated", ErrInvalidMapping, h)
+		}
+		keys[key] = true
+		m.metadata[i] = key
+	}
+
+	if m.email < 0 {
+		return nil, fmt.Errorf("%w: there is no email column", ErrInvalidFormat)
+	}
+
+	return m, nil
+}
+
+// emailOf returns the email of the record for the report, when the record has the email column.
+func (m *bulkColumns) emailOf(record []string) string {
+	if m.email < len(record) {
+		return entities.NormalizeEmail(record[m.email])
+	}
+	return ""
+}
+
+// update returns the changes of the record, or the reason the record is rejected.
+func (m *bulkColumns) update(record []string) (*bulkUpdate, string) {
+	if len(record) != m.columns {
+		return nil, fmt.Sprintf("The row has %d columns instead of %d.", len(record), m.columns)
+	}
+
+	upd := &bulkUpdate{
+		Email:    entities.NormalizeEmail(record[m.email]),
+		Metadata: make(map[string]string, len(m.metadata)),
+	}
+	if upd.Email == "" {
+		return nil, "The email is missing."
+	}
+
+	if m.name >= 0 {
+		upd.Name = strings.TrimSpace(record[m.name])
+		if len(upd.Name) > 191 {
+			return nil, "The name is longer than 191 characters."
+		}
+	}
+
+	var reason string
+	if upd.Active, reason = bulkFlag(record, m.active, BulkColumnActive); reason != "" {
+		return nil, reason
+	}
+	if upd.Blacklisted, reason = bulkFlag(record, m.blacklisted, BulkColumnBlacklisted); reason != "" {
+		return nil, reason
+	}
+
+	for i, key := range m.metadata {
+		v := strings.TrimSpace(record[i])
+		if v == "" {
+			continue
+		}
+		if len(v) > 191 {
+			return nil, fmt.Sprintf("The value of '%s' is longer than 191 characters.", key)
+		}
+		upd.Metadata[key] = v
+	}
+
+	if m.unset >= 0 {
+		for _, key := range splitBulkList(record[m.unset]) {
+			if !metadataKeyPattern.MatchString(key) {
+				return nil, fmt.Sprintf("The metadata key '%s' is not valid.", key)
+			}
+			if _, ok := upd.Metadata[key]; ok {
+				return nil, fmt.Sprintf("The metadata key '%s' is both set and unset.", key)
+			}
+			upd.Unset = append(upd.Unset, key)
+		}
+	}
+
+	if upd.Add, reason = bulkSegmentIDs(record, m.add); reason != "" {
+		return nil, reason
+	}
+	if upd.Remove, reason = bulkSegmentIDs(record, m.remove); reason != "" {
+		return nil, reason
+	}
+	for _, id := range upd.Add {
+		for _, rid := range upd.Remove {
+			if id == rid {
+				return nil, fmt.Sprintf("The segment %d is both added and removed.", id)
+			}
+		}
+	}
+
+	return upd, ""
+}
+
+// bulkFlag parses the true or false value of the column, it returns nil when the value is empty.
+func bulkFlag(record []string, pos int, column string) (*bool, string) {
+	if pos < 0 {
+		return nil, ""
+	}
+	v := strings.TrimSpace(record[pos])
+	if v == "" {
+		return nil, ""
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Sprintf("The value of '%s' must be true or false.", column)
+	}
+	return &b, ""
+}
+
+// bulkSegmentIDs parses the list of the segment ids of the column.
+func bulkSegmentIDs(record []string, pos int) ([]int64, string) {
+	if pos < 0 {
+		return nil, ""
+	}
+	var ids []int64
+	for _, v := range splitBulkList(record[pos]) {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Sprintf("The segment id '%s' is not valid.", v)
+		}
+		ids = append(ids, id)
+	}
+	return ids, ""
+}
+
+// splitBulkList splits the values of the list columns, which are separated by commas, semicolons,
+// pipes or spaces.
+func splitBulkList(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ';' || r == '|' || unicode.IsSpace(r)
+	})
+}
+
+// BulkUpdateSubscribersFromFile updates the existing subscribers of the CSV, NDJSON, JSON or XLSX file
+// by its columns, see MapBulkUpdateColumns. The names, the flags and the metadata values are set when
+// they are not empty in the file, and the metadata is validated by the custom fields of the user.
+// The result lists the changes of each row, or the reason the row was rejected, and in a dry run the
+// subscribers are not updated. The rows are updated in batches, each batch in a transaction, and the
+// bulk update stops when the progress callback returns an error.
+func (s *service) BulkUpdateSubscribersFromFile(
+	ctx context.Context,
+	userID int64,
+	r io.Reader,
+	opts BulkUpdateOptions,
+) (*BulkUpdateResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	rows, _, err := openRows(r, opts.FileOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := MapBulkUpdateColumns(rows.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := s.db.GetCustomFields(userID)
+	if err != nil {
+		return nil, fmt.Errorf("bulk update: get custom fields: %w", err)
+	}
+
+	res := &BulkUpdateResult{}
+	segments := make(map[int64]*entities.Segment)
+	seen := make(map[string]bool)
+	batch := make([]*bulkUpdate, 0, opts.BatchSize)
+	reported := 0
+
+	// flush updates the batch and reports the progress, unless no rows were processed since the
+	// progress was last reported.
+	flush := func() error {
+		if err := s.bulkUpdateBatch(userID, fields, segments, batch, opts.DryRun, res); err != nil {
+			return err
+		}
+		batch = batch[:0]
+
+		if opts.Progress == nil || res.Processed() == reported {
+			return nil
+		}
+		reported = res.Processed()
+		return opts.Progress(res)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("bulk update: %w", err)
+		}
+
+		row, record, reason, err := rows.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return res, err
+		}
+		if reason != "" {
+			res.add(BulkUpdateRow{Row: row, Result: BulkResultFailed, Details: reason})
+			continue
+		}
+
+		upd, reason := columns.update(record)
+		if reason != "" {
+			res.add(BulkUpdateRow{Row: row, Email: columns.emailOf(record), Result: BulkResultFailed, Details: reason})
+			continue
+		}
+
+		if seen[upd.Email] {
+			res.add(BulkUpdateRow{Row: row, Email: upd.Email, Result: BulkResultFailed, Details: "The email is duplicated in the file."})
+			continue
+		}
+		seen[upd.Email] = true
+		upd.Row = row
+
+		batch = append(batch, upd)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// bulkUpdateBatch applies the changes of the batch to the subscribers and stores them in a single
+// transaction, unless it's a dry run. The segments are cached by their ids, the ones which don't
+// exist are cached as nil.
+func (s *service) bulkUpdateBatch(
+	userID int64,
+	fields entities.CustomFields,
+	segments map[int64]*entities.Segment,
+	batch []*bulkUpdate,
+	dryRun bool,
+	res *BulkUpdateResult,
+) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	emails := make([]string, len(batch))
+	var ids []int64
+	for i, upd := range batch {
+		emails[i] = upd.Email
+		for _, id := range append(append([]int64{}, upd.Add...), upd.Remove...) {
+			if _, ok := segments[id]; !ok {
+				segments[id] = nil
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		segs, err := s.db.GetSegmentsByIDs(userID, ids)
+		if err != nil {
+			return fmt.Errorf("bulk update: get segments: %w", err)
+		}
+		for i := range segs {
+			segments[segs[i].ID] = &segs[i]
+		}
+	}
+
+	subs, err := s.db.GetSubscribersByEmails(emails, userID)
+	if err != nil {
+		return fmt.Errorf("bulk update: get subscribers by emails: %w", err)
+	}
+	existing := make(map[string]*entities.Subscriber, len(subs))
+	for i := range subs {
+		existing[entities.NormalizeEmail(subs[i].Email)] = &subs[i]
+	}
+
+	var (
+		rows    []BulkUpdateRow
+		updated []*entities.Subscriber
+		events  []entities.SubscriberEvent
+	)
+	for _, upd := range batch {
+		row := BulkUpdateRow{Row: upd.Row, Email: upd.Email, Result: BulkResultFailed}
+
+		sub, ok := existing[upd.Email]
+		if !ok {
+			row.Details = "The subscriber was not found."
+			rows = append(rows, row)
+			continue
+		}
+
+		changes, evs, reason, err := applyBulkUpdate(sub, upd, fields, segments)
+		if err != nil {
+			return fmt.Errorf("bulk update: apply changes: %w", err)
+		}
+		switch {
+		case reason != "":
+			row.Details = reason
+		case len(changes) == 0:
+			row.Result = BulkResultUnchanged
+		default:
+			row.Result = BulkResultUpdated
+			row.Details = strings.Join(changes, "; ")
+			updated = append(updated, sub)
+			events = append(events, evs...)
+		}
+		rows = append(rows, row)
+	}
+
+	if !dryRun && len(updated) > 0 {
+		if err := s.db.SaveBulkUpdatedSubscribers(updated, events); err != nil {
+			return fmt.Errorf("bulk update: save subscribers: %w", err)
+		}
+	}
+
+	for _, row := range rows {
+		res.add(row)
+	}
+	return nil
+}
+
+// applyBulkUpdate applies the changes of the row to the subscriber. It returns the descriptions
+// of the changes along with the events of the subscriber, or the reason the row is rejected.
+func applyBulkUpdate(
+	sub *entities.Subscriber,
+	upd *bulkUpdate,
+	fields entities.CustomFields,
+	segments map[int64]*entities.Segment,
+) ([]string, []entities.SubscriberEvent, string, error) {
+	for _, id := range append(append([]int64{}, upd.Add...), upd.Remove...) {
+		seg := segments[id]
+		if seg == nil {
+			return nil, nil, fmt.Sprintf("The segment %d was not found.", id), nil
+		}
+		if seg.Dynamic {
+			return nil, nil, fmt.Sprintf("The segment %d is dynamic, its members are matched by its rules.", id), nil
+		}
+	}
+
+	var (
+		changes []string
+		events  []entities.SubscriberEvent
+	)
+	data := make(map[string]interface{})
+
+	if upd.Name != "" && upd.Name != sub.Name {
+		data["name"] = fieldChange{Old: sub.Name, New: upd.Name}
+		changes = append(changes, fmt.Sprintf("name: %q => %q", sub.Name, upd.Name))
+		sub.Name = upd.Name
+	}
+	if upd.Active != nil && *upd.Active != sub.Active {
+		data["active"] = fieldChange{Old: strconv.FormatBool(sub.Active), New: strconv.FormatBool(*upd.Active)}
+		changes = append(changes, fmt.Sprintf("active: %t => %t", sub.Active, *upd.Active))
+		sub.Active = *upd.Active
+	}
+	if upd.Blacklisted != nil && *upd.Blacklisted != sub.Blacklisted {
+		data["blacklisted"] = fieldChange{Old: strconv.FormatBool(sub.Blacklisted), New: strconv.FormatBool(*upd.Blacklisted)}
+		changes = append(changes, fmt.Sprintf("blacklisted: %t => %t", sub.Blacklisted, *upd.Blacklisted))
+		sub.Blacklisted = *upd.Blacklisted
+	}
+
+	mu, err := updateMetadata(sub, fields, upd.Metadata, upd.Unset)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(mu.Errors) > 0 {
+		return nil, nil, entities.DescribeMetadataErrors(mu.Errors), nil
+	}
+	for _, k := range mu.Keys {
+		c := mu.Changes[k]
+		if c.Unset {
+			changes = append(changes, fmt.Sprintf("%s%s: unset", BulkColumnMetadataPrefix, k))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s%s: %q => %q", BulkColumnMetadataPrefix, k, c.Old, c.New))
+		}
+	}
+	if len(mu.Keys) > 0 {
+		data["metadata"] = mu.Changes
+	}
+
+	addEvent := func(t entities.EventType, data interface{}) error {
+		ev, err := subscriberEvent(sub, t, data)
+		if err != nil {
+			return err
+		}
+		events = append(events, ev)
+		return nil
+	}
+	if len(data) > 0 {
+		if err := addEvent(entities.SubscriberEventTypeUpdated, data); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	removed := make(map[int64]bool, len(upd.Remove))
+	for _, id := range upd.Remove {
+		removed[id] = true
+	}
+	member := make(map[int64]bool, len(sub.Segments))
+	kept := make([]entities.Segment, 0, len(sub.Segments)+len(upd.Add))
+	for _, seg := range sub.Segments {
+		member[seg.ID] = true
+		if !removed[seg.ID] {
+			kept = append(kept, seg)
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("removed from segment %q", seg.Name))
+		err := addEvent(entities.SubscriberEventTypeSegmentLeave, map[string]interface{}{
+			"segment_id":   seg.ID,
+			"segment_name": seg.Name,
+		})
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	for _, id := range upd.Add {
+		seg := segments[id]
+		if member[id] {
+			continue
+		}
+		member[id] = true
+		kept = append(kept, *seg)
+		changes = append(changes, fmt.Sprintf("added to segment %q", seg.Name))
+		err := addEvent(entities.SubscriberEventTypeSegmentJoin, map[string]interface{}{
+			"segment_id":   seg.ID,
+			"segment_name": seg.Name,
+		})
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	sub.Segments = kept
+
+	return changes, events, "", nil
+}
+
+// fieldChange is a change of a field of the subscriber in the data of the updated events.
+type fieldChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+	// Unset is set when the metadata key was removed.
+	Unset bool `json:"-"`
+}
+
+// metadataUpdate is the outcome of updating the metadata of a subscriber.
+type metadataUpdate struct {
+	// Keys are the changed metadata keys in order.
+	Keys    []string
+	Changes map[string]fieldChange
+	// Errors are the errors of the custom fields by the metadata keys, the metadata is not
+	// changed when there are any.
+	Errors map[string]string
+}
+
+// updateMetadata sets and unsets the metadata keys of the subscriber, and validates the metadata by
+// the custom fields, see entities.CustomFields.ApplyTo. The metadata of the subscriber is changed
+// only when it's valid.
+func updateMetadata(
+	sub *entities.Subscriber,
+	fields entities.CustomFields,
+	set map[string]string,
+	unset []string,
+) (*metadataUpdate, error) {
+	old, err := sub.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	meta := make(map[string]string, len(old))
+	for k, v := range old {
+		meta[k] = v
+	}
+	for k, v := range set {
+		meta[k] = v
+	}
+	for _, k := range unset {
+		delete(meta, k)
+	}
+
+	mu := &metadataUpdate{Changes: make(map[string]fieldChange)}
+	mu.Errors = fields.ApplyTo(meta)
+	if len(mu.Errors) > 0 {
+		return mu, nil
+	}
+
+	for k := range old {
+		mu.Keys = append(mu.Keys, k)
+	}
+	for k := range meta {
+		if _, ok := old[k]; !ok {
+			mu.Keys = append(mu.Keys, k)
+		}
+	}
+	sort.Strings(mu.Keys)
+
+	changed := mu.Keys[:0]
+	for _, k := range mu.Keys {
+		o, hadOld := old[k]
+		n, hasNew := meta[k]
+		if hadOld == hasNew && o == n {
+			continue
+		}
+		changed = append(changed, k)
+		mu.Changes[k] = fieldChange{Old: o, New: n, Unset: !hasNew}
+	}
+	mu.Keys = changed
+
+	if len(mu.Keys) > 0 {
+		sub.MetaJSON, err = json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mu, nil
+}
+
+// subscriberEvent returns the event of the subscriber with the data.
+func subscriberEvent(sub *entities.Subscriber, t entities.EventType, data interface{}) (entities.SubscriberEvent, error) {
+	ev := entities.SubscriberEvent{
+		UserID:          sub.UserID,
+		SubscriberEmail: sub.Email,
+		EventType:       t,
+	}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return ev, err
+		}
+		ev.Data = b
+	}
+	return ev, nil
+}
+
+// bulkUpdateFile streams the file of the bulk update from the storage and updates the subscribers,
+// the counters of the import are updated after each batch.
+func (s *service) bulkUpdateFile(ctx context.Context, imp *entities.SubscriberImport) (*BulkUpdateResult, error) {
+	obj, err := s.blobs.Get(os.Getenv("FILES_BUCKET"), ImportFileKey(imp.UserID, imp.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("bulk update: get file: %w", err)
+	}
+	defer obj.Body.Close()
+
+	return s.BulkUpdateSubscribersFromFile(ctx, imp.UserID, obj.Body, BulkUpdateOptions{
+		FileOptions: FileOptions{
+			Format: imp.Format,
+			Sheet:  imp.Sheet,
+		},
+		DryRun: imp.DryRun,
+		Progress: func(res *BulkUpdateResult) error {
+			setBulkUpdateResult(imp, res)
+			ok, err := s.db.UpdateSubscriberImport(imp, entities.StatusInProgress)
+			if err != nil {
+				return fmt.Errorf("bulk update: update progress: %w", err)
+			}
+			if !ok {
+				return ErrImportCancelled
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,430 @@
+package subscribers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/mailbadger/app/utils"
+)
+
+// Formats of the imported files.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	FormatXLSX   = "xlsx"
+)
+
+// maxBufferedFileSize is the max size of the JSON and XLSX files, unlike the CSV files
+// they are read in memory.
+const maxBufferedFileSize = 50 << 20
+
+// xlsxSignature are the first bytes of the XLSX files, which are zip archives.
+var xlsxSignature = []byte("PK\x03\x04")
+
+// FileOptions are the options of reading the imported file.
+type FileOptions struct {
+	// Format is the format of the file, it's detected by the content of the file when it's empty.
+	Format string
+	// Sheet is the name of the sheet of the XLSX file, the first sheet is read when it's empty.
+	Sheet string
+}
+
+// rowReader reads the rows of the imported file as records of the columns of the header.
+type rowReader interface {
+	// Header returns the columns of the file.
+	Header() []string
+	// Read returns the number of the next row in the file and its record, or the reason the
+	// row is rejected. It returns io.EOF when there are no more rows.
+	Read() (int, []string, string, error)
+	Close() error
+}
+
+// openRows opens the file by its format and reads the header. The JSON objects are flattened,
+// see utils.FlattenJSON, and the header of the JSON files are the keys of all of the objects.
+func openRows(r io.Reader, opts FileOptions) (rowReader, string, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	format := opts.Format
+	if format == "" {
+		head, err := br.Peek(512)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, "", fmt.Errorf("importer: read file: %w", err)
+		}
+		format = detectFormat(head)
+	}
+
+	var (
+		rows rowReader
+		err  error
+	)
+	switch format {
+	case FormatCSV:
+		rows, err = newCSVRows(br)
+	case FormatNDJSON:
+		rows, err = newNDJSONRows(br)
+	case FormatJSON:
+		rows, err = newJSONRows(br)
+	case FormatXLSX:
+		rows, err = newXLSXRows(br, opts.Sheet)
+	default:
+		return nil, "", fmt.Errorf("%w: unknown format '%s'", ErrInvalidFile, format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rows, format, nil
+}
+
+// detectFormat returns the format of the file by its first bytes, the files which are not
+// XLSX or JSON are read as CSV.
+func detectFormat(head []byte) string {
+	if bytes.HasPrefix(head, xlsxSignature) {
+		return FormatXLSX
+	}
+
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(head, []byte("{")):
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// readBuffered reads the whole file, up to maxBufferedFileSize, without the byte order mark.
+func readBuffered(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBufferedFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("importer: read file: %w", err)
+	}
+	if len(data) > maxBufferedFileSize {
+		return nil, ErrFileTooLarge
+	}
+	return bytes.TrimPrefix(data, utf8BOM), nil
+}
+
+type csvRows struct {
+	reader *csv.Reader
+	header []string
+	row    int
+}
+
+func newCSVRows(r io.Reader) (*csvRows, error) {
+	reader, err := utils.NewCSVReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("importer: open file: %w", err)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("importer: empty file : %w", err)
+		}
+		return nil, fmt.Errorf("importer: read header: %w", err)
+	}
+
+	return &csvRows{reader: reader, header: header, row: 1}, nil
+}
+
+func (c *csvRows) Header() []string {
+	return c.header
+}
+
+func (c *csvRows) Read() (int, []string, string, error) {
+	for {
+		c.row++
+		record, err := c.reader.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return c.row, record, perr.Err.Error(), nil
+			}
+			if err != io.EOF {
+				err = fmt.Errorf("importer: read line: %w", err)
+			}
+			return c.row, nil, "", err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		return c.row, record, "", nil
+	}
+}
+
+func (c *csvRows) Close() error {
+	return nil
+}
+
+// jsonRows are the objects of the NDJSON and JSON files, along with the reasons the rows which
+// are not objects are rejected.
+type jsonRows struct {
+	header []string
+	items  []jsonItem
+	next   int
+}
+
+type jsonItem struct {
+	row    int
+	values map[string]string
+	reason string
+}
+
+// newNDJSONRows reads the NDJSON file, a JSON object per line, the rows are the lines of the file.
+func newNDJSONRows(r io.Reader) (*jsonRows, error) {
+	data, err := readBuffered(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []jsonItem
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		item := jsonItem{row: i + 1}
+		item.values, item.reason = decodeJSONObject(line)
+		items = append(items, item)
+	}
+
+	return newJSONItems(items)
+}
+
+// newJSONRows reads the JSON file, an array of objects, the rows are the positions of the
+// objects in the array.
+func newJSONRows(r io.Reader) (*jsonRows, error) {
+	data, err := readBuffered(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("importer: empty file : %w", err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("%w: the file must contain an array of objects", ErrInvalidFile)
+	}
+
+	var items []jsonItem
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+		}
+		item := jsonItem{row: len(items) + 1}
+		item.values, item.reason = decodeJSONObject(raw)
+		items = append(items, item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	return newJSONItems(items)
+}
+
+// newJSONItems returns the rows of the items, the header are the sorted keys of all of the objects.
+func newJSONItems(items []jsonItem) (*jsonRows, error) {
+	keys := make(map[string]bool)
+	for _, item := range items {
+		for k := range item.values {
+			keys[k] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("importer: empty file : %w", io.EOF)
+	}
+
+	header := make([]string, 0, len(keys))
+	for k := range keys {
+		header = append(header, k)
+	}
+	sort.Strings(header)
+
+	return &jsonRows{header: header, items: items}, nil
+}
+
+// decodeJSONObject returns the flattened values of the object, or the reason it's rejected.
+func decodeJSONObject(data []byte) (map[string]string, string) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return nil, "The row is not a valid JSON object."
+	}
+	if dec.More() {
+		return nil, "The row is not a valid JSON object."
+	}
+
+	values, err := utils.FlattenJSON(obj)
+	if err != nil {
+		return nil, "The row is not a valid JSON object."
+	}
+	return values, ""
+}
+
+func (j *jsonRows) Header() []string {
+	return j.header
+}
+
+func (j *jsonRows) Read() (int, []string, string, error) {
+	if j.next >= len(j.items) {
+		return 0, nil, "", io.EOF
+	}
+	item := j.items[j.next]
+	j.next++
+
+	if item.reason != "" {
+		return item.row, nil, item.reason, nil
+	}
+
+	record := make([]string, len(j.header))
+	for i, h := range j.header {
+		record[i] = item.values[h]
+	}
+	return item.row, record, "", nil
+}
+
+func (j *jsonRows) Close() error {
+	return nil
+}
+
+type xlsxRows struct {
+	reader *utils.XLSXReader
+	sheets []string
+	header []string
+}
+
+// newXLSXRows opens the sheet of the XLSX file, the header is the first row which is not empty.
+func newXLSXRows(r io.Reader, sheet string) (*xlsxRows, error) {
+	data, err := readBuffered(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets, err := utils.XLSXSheets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	reader, err := utils.NewXLSXReader(data, sheet)
+	if err != nil {
+		if errors.Is(err, utils.ErrSheetNotFound) {
+			return nil, fmt.Errorf("importer: %w", err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		_ = reader.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("importer: empty file : %w", err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	return &xlsxRows{reader: reader, sheets: sheets, header: header}, nil
+}
+
+func (x *xlsxRows) Header() []string {
+	return x.header
+}
+
+func (x *xlsxRows) Read() (int, []string, string, error) {
+	record, err := x.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("%w: %s", ErrInvalidFile, err)
+		}
+		return x.reader.Row, nil, "", err
+	}
+
+	// the trailing empty cells are not stored
+	for len(record) < len(x.header) {
+		record = append(record, "")
+	}
+	return x.reader.Row, record, "", nil
+}
+
+func (x *xlsxRows) Close() error {
+	return x.reader.Close()
+}
+
+// ImportPreview is the beginning of a file which is about to be imported.
+type ImportPreview struct {
+	Format    string `json:"format"`
+	Delimiter string `json:"delimiter,omitempty"`
+	// Sheets are the names of the sheets of the XLSX file, Sheet is the one which was read.
+	Sheets []string   `json:"sheets,omitempty"`
+	Sheet  string     `json:"sheet,omitempty"`
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+	// Total is the number of the rows of the file, without the header.
+	Total int `json:"total"`
+	// Mapping is the mapping of the columns when no mapping is given.
+	Mapping map[string]string `json:"mapping"`
+}
+
+// InspectFile detects the format and the columns of the file so they can be mapped, and counts
+// its rows. The first valid rows are returned as a sample.
+func InspectFile(r io.Reader, opts FileOptions, sample int) (*ImportPreview, error) {
+	rows, format, err := openRows(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	header := rows.Header()
+	p := &ImportPreview{
+		Format:  format,
+		Header:  header,
+		Rows:    [][]string{},
+		Mapping: make(map[string]string, len(header)),
+	}
+	for _, h := range header {
+		p.Mapping[strings.TrimSpace(h)] = defaultColumnTarget(h)
+	}
+
+	switch rr := rows.(type) {
+	case *csvRows:
+		p.Delimiter = string(rr.reader.Comma)
+	case *xlsxRows:
+		p.Sheets = rr.sheets
+		p.Sheet = rr.reader.Sheet
+	}
+
+	for {
+		_, record, reason, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.Total++
+		if reason == "" && len(p.Rows) < sample {
+			p.Rows = append(p.Rows, record)
+		}
+	}
+
+	return p, nil
+}
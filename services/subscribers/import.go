@@ -0,0 +1,246 @@
+package subscribers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// Modes of the import for the subscribers which already exist.
+const (
+	// ImportModeSkip leaves the existing subscribers as they are.
+	ImportModeSkip = "skip"
+	// ImportModeUpdate sets the names and the metadata values which are not empty in the file.
+	ImportModeUpdate = "update"
+	// ImportModeReplace replaces the names and the metadata with the ones in the file.
+	ImportModeReplace = "replace"
+)
+
+// Fields the columns of the imported files can be mapped to, the columns can
+// also be mapped to metadata keys.
+const (
+	ColumnEmail  = "email"
+	ColumnName   = "name"
+	ColumnIgnore = "ignore"
+)
+
+const (
+	// maxRejectedRows is the max number of the rejected rows which are kept for the error report.
+	maxRejectedRows = 10000
+	// defaultBatchSize is the number of the rows which are imported in a transaction by default.
+	defaultBatchSize = 500
+)
+
+var (
+	metadataKeyInvalidChars = regexp.MustCompile(`[^\w-]+`)
+	metadataKeyPattern      = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// ImportOptions are the options of the import.
+type ImportOptions struct {
+	FileOptions
+	// Mode is the mode of the import for the existing subscribers, it's skip by default.
+	Mode string
+	// Mapping maps the headers of the columns to the email or name fields, to metadata keys, or
+	// the columns are ignored. The columns which are not mapped are mapped by their headers,
+	// the email and name columns to the fields and the rest of them to metadata keys.
+	Mapping map[string]string
+	// BatchSize is the number of the rows which are imported in a transaction, 500 by default.
+	BatchSize int
+	// Progress is called with the result so far after each batch, the import stops with
+	// the returned error.
+	Progress func(*ImportResult) error
+}
+
+// ImportResult is the outcome of the import.
+type ImportResult struct {
+	Header   []string
+	Created  int
+	Updated  int
+	Skipped  int
+	Rejected int
+	// RejectedRows are the first rejected rows along with the reasons.
+	RejectedRows []RejectedRow
+}
+
+// RejectedRow is a row of the file which was not imported.
+type RejectedRow struct {
+	// Row is the number of the row in the file, the header is the first row of the CSV and XLSX
+	// files. It's the line of the NDJSON files and the position of the object in the JSON files.
+	Row    int
+	Record []string
+	Reason string
+}
+
+func (r *ImportResult) reject(row int, record []string, reason string) {
+	r.Rejected++
+	if len(r.RejectedRows) < maxRejectedRows {
+		r.RejectedRows = append(r.RejectedRows, RejectedRow{Row: row, Record: record, Reason: reason})
+	}
+}
+
+// Processed returns the number of the rows which were imported or rejected.
+func (r *ImportResult) Processed() int {
+	return r.Created + r.Updated + r.Skipped + r.Rejected
+}
+
+// WriteErrors writes the rejected rows as CSV, the row number and the reason are followed by
+// the columns of the imported file.
+func (r *ImportResult) WriteErrors(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"row", "error"}, r.Header...)); err != nil {
+		return err
+	}
+	for _, rr := range r.RejectedRows {
+		if err := cw.Write(append([]string{strconv.Itoa(rr.Row), rr.Reason}, rr.Record...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportFileKey returns the key of the uploaded file which is imported.
+func ImportFileKey(userID int64, filename string) string {
+	return fmt.Sprintf("subscribers/import/%d/%s", userID, filename)
+}
+
+// ImportErrorsKey returns the key of the error report of the import, the reports are kept by the
+// imports so that the imports of the same file don't overwrite each other's reports.
+func ImportErrorsKey(userID, importID int64) string {
+	return fmt.Sprintf("subscribers/import-errors/%d/%d", userID, importID)
+}
+
+// setImportResult sets the counters of the import to the result so far.
+func setImportResult(imp *entities.SubscriberImport, res *ImportResult) {
+	imp.Processed = int64(res.Processed())
+	imp.Created = int64(res.Created)
+	imp.Updated = int64(res.Updated)
+	imp.Skipped = int64(res.Skipped)
+	imp.Failed = int64(res.Rejected)
+}
+
+// columnMapping are the positions of the fields in the records.
+type columnMapping struct {
+	columns  int
+	email    int
+	name     int
+	metadata map[int]string
+}
+
+// importedSubscriber are the fields of a row of the file.
+type importedSubscriber struct {
+	Email      string
+	Name       string
+	Metadata   map[string]string
+	Validation *entities.EmailValidation
+	// Row and Record are the row of the file, the rows of the suppressed emails are rejected
+	// when the batch is imported.
+	Row    int
+	Record []string
+}
+
+// MapColumns maps the columns of the header by the mapping, see ImportOptions. The errors
+// wrap ErrInvalidMapping, or ErrInvalidFormat when there is no email column.
+func MapColumns(header []string, mapping map[string]string) (*columnMapping, error) {
+	m := &columnMapping{
+		columns:  len(header),
+		email:    -1,
+		name:     -1,
+		metadata: make(map[int]string),
+	}
+
+	keys := make(map[string]bool)
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		target, ok := mapping[h]
+		if !ok {
+			target = defaultColumnTarget(h)
+		}
+
+		switch target {
+		case ColumnIgnore, "":
+		case ColumnEmail:
+			if m.email >= 0 {
+				return nil, fmt.Errorf("%w: more than one column is mapped to the email", ErrInvalidMapping)
+			}
+			m.email = i
+		case ColumnName:
+			if m.name >= 0 {
+				return nil, fmt.Errorf("%w: more than one column is mapped to the name", ErrInvalidMapping)
+			}
+			m.name = i
+		default:
+			if !metadataKeyPattern.MatchString(target) || len(target) > 191 {
+				return nil, fmt.Errorf("%w: the metadata key '%s' must consist only of alphanumeric and hyphen characters", ErrInvalidMapping, target)
+			}
+			if keys[target] {
+				return nil, fmt.Errorf("%w: more than one column is mapped to the metadata key '%s'", ErrInvalidMapping, target)
+			}
+			keys[target] = true
+			m.metadata[i] = target
+		}
+	}
+
+	if m.email < 0 {
+		return nil, fmt.Errorf("%w: no column is mapped to the email", ErrInvalidFormat)
+	}
+
+	return m, nil
+}
+
+// defaultColumnTarget returns the target of the column when it's not mapped, the email and name
+// columns are mapped to the fields and the rest to the metadata keys by their headers.
+func defaultColumnTarget(header string) string {
+	h := strings.TrimSpace(header)
+	switch strings.ToLower(h) {
+	case ColumnEmail, "e-mail", "email address":
+		return ColumnEmail
+	case ColumnName:
+		return ColumnName
+	}
+	return strings.Trim(metadataKeyInvalidChars.ReplaceAllString(h, "-"), "-")
+}
+
+// subscriber returns the fields of the record, or the reason the record is rejected.
+func (m *columnMapping) subscriber(record []string) (*importedSubscriber, string) {
+	if len(record) != m.columns {
+		return nil, fmt.Sprintf("The row has %d columns instead of %d.", len(record), m.columns)
+	}
+
+	sub := &importedSubscriber{
+		Email:    entities.NormalizeEmail(record[m.email]),
+		Metadata: make(map[string]string, len(m.metadata)),
+	}
+	if sub.Email == "" {
+		return nil, "The email is missing."
+	}
+	if len(sub.Email) > 191 {
+		return nil, "The email is not valid."
+	}
+
+	if m.name >= 0 {
+		sub.Name = strings.TrimSpace(record[m.name])
+		if len(sub.Name) > 191 {
+			return nil, "The name is longer than 191 characters."
+		}
+	}
+
+	for i, key := range m.metadata {
+		v := strings.TrimSpace(record[i])
+		if v == "" {
+			continue
+		}
+		if len(v) > 191 {
+			return nil, fmt.Sprintf("The value of '%s' is longer than 191 characters.", key)
+		}
+		sub.Metadata[key] = v
+	}
+
+	return sub, ""
+}
@@ -1,114 +1,500 @@
 package subscribers
 
 import (
+	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"os"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"github.com/jinzhu/gorm"
-
+	"github.com/mailbadger/app/blobs"
 	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/services/emailvalidator"
 	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
 )
 
 type Service interface {
-	ImportSubscribersFromFile(ctx context.Context, filename string, userID int64, segments []entities.Segment) error
-	RemoveSubscribersFromFile(ctx context.Context, filename string, userID int64) error
+	ImportSubscribersFromFile(
+		ctx context.Context,
+		userID int64,
+		segments []entities.Segment,
+		r io.Reader,
+		opts ImportOptions,
+	) (*ImportResult, error)
+	BulkUpdateSubscribersFromFile(
+		ctx context.Context,
+		userID int64,
+		r io.Reader,
+		opts BulkUpdateOptions,
+	) (*BulkUpdateResult, error)
+	RunImport(ctx context.Context, imp *entities.SubscriberImport) error
+	RunBulkAction(ctx context.Context, a *entities.SubscriberBulkAction) error
+	RemoveSubscribersFromFile(ctx context.Context, filename string, userID int64, r io.ReadCloser) error
 }
 
 type service struct {
-	client s3iface.S3API
+	blobs  blobs.Store
 	db     storage.Storage
+	emails emailvalidator.Validator
+}
+
+type Opts func(s *service)
+
+// EmailValidator sets the validator of the addresses of the imported subscribers, the addresses are
+// validated by the policy of the env with the DNS of the system by default.
+func EmailValidator(v emailvalidator.Validator) Opts {
+	return func(s *service) {
+		s.emails = v
+	}
 }
 
 var (
 	ErrInvalidColumnsNum = errors.New("importer: invalid number of columns")
 	ErrInvalidFormat     = errors.New("importer: csv file not formatted properly")
+	ErrInvalidMapping    = errors.New("importer: invalid column mapping")
+	ErrImportCancelled   = errors.New("importer: import cancelled")
+	ErrInvalidFile       = errors.New("importer: unable to read the file")
+	ErrFileTooLarge      = errors.New("importer: the file is too large")
 )
 
-func New(client s3iface.S3API, db storage.Storage) *service {
-	return &service{client, db}
+func New(store blobs.Store, db storage.Storage, opts ...Opts) *service {
+	s := &service{
+		blobs: store,
+		db:    db,
+	}
+	for _, option := range opts {
+		option(s)
+	}
+	if s.emails == nil {
+		s.emails = emailvalidator.New(net.DefaultResolver, emailvalidator.PolicyFromEnv())
+	}
+	return s
 }
 
+// ImportSubscribersFromFile creates the subscribers of the CSV, NDJSON, JSON or XLSX file and adds them
+// to the segments. The columns are mapped to the fields by the options, and the existing subscribers are
+// skipped, updated or replaced by the mode. The invalid rows, including the ones whose metadata doesn't match
+// the custom fields of the user, are rejected and listed in the result along with the reasons.
+// The rows are imported in batches, each batch in a transaction, and the import stops when the progress
+// callback returns an error.
 func (s *service) ImportSubscribersFromFile(
 	ctx context.Context,
 	userID int64,
 	segments []entities.Segment,
 	r io.Reader,
-) (err error) {
+	opts ImportOptions,
+) (*ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportModeSkip
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
 
-	reader := csv.NewReader(r)
-	header, err := reader.Read()
+	rows, _, err := openRows(r, opts.FileOptions)
 	if err != nil {
-		if err == io.EOF {
-			return fmt.Errorf("importer: empty file : %w", err)
-		}
+		return nil, err
+	}
+	defer rows.Close()
 
-		return fmt.Errorf("importer: read header: %w", err)
+	header := rows.Header()
+	columns, err := MapColumns(header, opts.Mapping)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(header) < 2 {
-		return ErrInvalidColumnsNum
+	fields, err := s.db.GetCustomFields(userID)
+	if err != nil {
+		return nil, fmt.Errorf("importer: get custom fields: %w", err)
 	}
 
-	if strings.ToLower(header[0]) != "email" || strings.ToLower(header[1]) != "name" {
-		return ErrInvalidFormat
+	res := &ImportResult{Header: header}
+	seen := make(map[string]bool)
+	batch := make([]*importedSubscriber, 0, opts.BatchSize)
+	reported := 0
+
+	// flush imports the batch and reports the progress, unless no rows were processed since the
+	// progress was last reported, e.g. the file has only the header.
+	flush := func() error {
+		if err := s.importBatch(userID, segments, fields, batch, opts.Mode, res); err != nil {
+			return err
+		}
+		batch = batch[:0]
+
+		if opts.Progress == nil || res.Processed() == reported {
+			return nil
+		}
+		reported = res.Processed()
+		return opts.Progress(res)
 	}
 
 	for {
-		line, err := reader.Read()
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("importer: %w", err)
+		}
+
+		row, record, reason, err := rows.Read()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return fmt.Errorf("importer: read line: %w", err)
+			return res, err
 		}
-		if len(line) < 2 {
+		if reason != "" {
+			res.reject(row, record, reason)
+			continue
+		}
+
+		sub, reason := columns.subscriber(record)
+		if reason != "" {
+			res.reject(row, record, reason)
 			continue
 		}
-		email := strings.TrimSpace(line[0])
-		name := strings.TrimSpace(line[1])
 
-		_, err = storage.GetSubscriberByEmail(ctx, email, userID)
-		if err == nil {
+		if seen[sub.Email] {
+			res.reject(row, record, "The email is duplicated in the file.")
 			continue
-		} else if !gorm.IsRecordNotFoundError(err) {
-			return fmt.Errorf("importer: get subscriber by email: %w", err)
 		}
+		seen[sub.Email] = true
 
-		sub := &entities.Subscriber{
-			UserID:   userID,
-			Email:    email,
-			Name:     name,
-			Segments: segments,
-			Active:   true,
+		sub.Validation = s.emails.Validate(ctx, sub.Email)
+		if sub.Validation.IsRejected() {
+			res.reject(row, record, sub.Validation.Reason())
+			continue
 		}
+		sub.Row, sub.Record = row, record
 
-		if len(line) > 2 {
-			meta := make(map[string]string, len(line)-2)
-			keys := header[2:]
-			for i, m := range line[2:] {
-				meta[keys[i]] = m
+		batch = append(batch, sub)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return res, err
 			}
-			metaJSON, err := json.Marshal(meta)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// importBatch creates the new subscribers of the batch, and skips, updates or replaces the existing
+// ones by the mode, all of them in a single transaction. The metadata of the created subscribers, and
+// the metadata of the existing ones once it's updated, is validated by the custom fields.
+func (s *service) importBatch(
+	userID int64,
+	segments []entities.Segment,
+	fields entities.CustomFields,
+	batch []*importedSubscriber,
+	mode string,
+	res *ImportResult,
+) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	emails := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i] = row.Email
+	}
+
+	batch, err := s.rejectSuppressed(userID, batch, emails, res)
+	if err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	emails = emails[:0]
+	for _, row := range batch {
+		emails = append(emails, row.Email)
+	}
+	subs, err := s.db.GetSubscribersByEmails(emails, userID)
+	if err != nil {
+		return fmt.Errorf("importer: get subscribers by emails: %w", err)
+	}
+	existing := make(map[string]*entities.Subscriber, len(subs))
+	for i := range subs {
+		existing[entities.NormalizeEmail(subs[i].Email)] = &subs[i]
+	}
+
+	var (
+		created []*entities.Subscriber
+		updated []*entities.Subscriber
+		skipped int
+	)
+	for _, row := range batch {
+		sub, ok := existing[row.Email]
+		if !ok {
+			sub = &entities.Subscriber{
+				UserID:   userID,
+				Email:    row.Email,
+				Name:     row.Name,
+				Segments: segments,
+				Active:   true,
+			}
+			if err := sub.SetEmailValidation(row.Validation); err != nil {
+				return fmt.Errorf("importer: marshal email validation: %w", err)
+			}
+			if errs := fields.ApplyTo(row.Metadata); len(errs) > 0 {
+				res.reject(row.Row, row.Record, entities.DescribeMetadataErrors(errs))
+				continue
+			}
+			if len(row.Metadata) > 0 {
+				sub.MetaJSON, err = json.Marshal(row.Metadata)
+				if err != nil {
+					return fmt.Errorf("importer: marshal metadata: %w", err)
+				}
+			}
+			created = append(created, sub)
+			continue
+		}
+
+		if mode == ImportModeSkip {
+			skipped++
+			continue
+		}
+
+		meta := make(map[string]string)
+		if mode == ImportModeUpdate {
+			meta, err = sub.GetMetadata()
 			if err != nil {
-				return fmt.Errorf("importer: marshal metadata: %w", err)
+				return fmt.Errorf("importer: get metadata: %w", err)
+			}
+			if row.Name != "" {
+				sub.Name = row.Name
+			}
+		} else {
+			sub.Name = row.Name
+		}
+		for k, v := range row.Metadata {
+			meta[k] = v
+		}
+		if errs := fields.ApplyTo(meta); len(errs) > 0 {
+			res.reject(row.Row, row.Record, entities.DescribeMetadataErrors(errs))
+			continue
+		}
+		sub.MetaJSON, err = json.Marshal(meta)
+		if err != nil {
+			return fmt.Errorf("importer: marshal metadata: %w", err)
+		}
+		if err := sub.SetEmailValidation(row.Validation); err != nil {
+			return fmt.Errorf("importer: marshal email validation: %w", err)
+		}
+
+		member := make(map[int64]bool, len(sub.Segments))
+		for _, seg := range sub.Segments {
+			member[seg.ID] = true
+		}
+		for _, seg := range segments {
+			if !member[seg.ID] {
+				sub.Segments = append(sub.Segments, seg)
+			}
+		}
+		updated = append(updated, sub)
+	}
+
+	if err := s.db.SaveImportedSubscribers(created, updated); err != nil {
+		return fmt.Errorf("importer: save subscribers: %w", err)
+	}
+
+	res.Created += len(created)
+	res.Updated += len(updated)
+	res.Skipped += skipped
+	return nil
+}
+
+// rejectSuppressed rejects the rows of the batch whose emails or domains are suppressed, and
+// returns the rest of them.
+func (s *service) rejectSuppressed(
+	userID int64,
+	batch []*importedSubscriber,
+	emails []string,
+	res *ImportResult,
+) ([]*importedSubscriber, error) {
+	suppressions, err := s.db.GetSuppressionsForEmails(userID, emails)
+	if err != nil {
+		return nil, fmt.Errorf("importer: get suppressions: %w", err)
+	}
+	if len(suppressions) == 0 {
+		return batch, nil
+	}
+
+	allowed := make([]*importedSubscriber, 0, len(batch))
+	for _, row := range batch {
+		var suppressed *entities.Suppression
+		for i := range suppressions {
+			if suppressions[i].Matches(row.Email) {
+				suppressed = &suppressions[i]
+				break
 			}
-			sub.MetaJSON = metaJSON
 		}
+		if suppressed != nil {
+			res.reject(row.Row, row.Record, suppressed.Description())
+			continue
+		}
+		allowed = append(allowed, row)
+	}
+	return allowed, nil
+}
+
+// RunImport imports the file of the pending import, or updates the subscribers by it when it's a bulk
+// update, and keeps the status and the counters of the import up to date. The import stops after the
+// current batch when it's cancelled. The rejected rows of the imports are stored as an error report,
+// see ImportResult.WriteErrors, and the results of the bulk updates as a report of all of the rows,
+// see BulkUpdateResult.WriteReport. The imports which aren't pending are not run again.
+func (s *service) RunImport(ctx context.Context, imp *entities.SubscriberImport) error {
+	imp.Status = entities.StatusInProgress
+	imp.StartedAt.SetValid(time.Now().UTC())
+	ok, err := s.db.UpdateSubscriberImport(imp, entities.StatusPending)
+	if err != nil {
+		return fmt.Errorf("importer: start import: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	run := s.runImportFile
+	if imp.IsBulkUpdate() {
+		run = s.runBulkUpdate
+	}
+	err = run(ctx, imp)
+
+	switch {
+	case errors.Is(err, ErrImportCancelled):
+		return nil
+	case err != nil:
+		imp.Status = entities.StatusFailed
+		imp.Error = importFailureReason(err)
+	default:
+		imp.Status = entities.StatusDone
+	}
+	imp.CompletedAt.SetValid(time.Now().UTC())
 
-		err = s.db.CreateSubscriber(sub)
+	if _, uerr := s.db.UpdateSubscriberImport(imp, entities.StatusInProgress); uerr != nil {
+		return fmt.Errorf("importer: finish import: %w", uerr)
+	}
+
+	return err
+}
+
+// importFile streams the file of the import from the storage and imports it, the counters of the
+// import are updated after each batch.
+func (s *service) importFile(ctx context.Context, imp *entities.SubscriberImport) (*ImportResult, error) {
+	mapping, err := imp.GetMapping()
+	if err != nil {
+		return nil, fmt.Errorf("importer: get mapping: %w", err)
+	}
+	ids, err := imp.GetSegmentIDs()
+	if err != nil {
+		return nil, fmt.Errorf("importer: get segment ids: %w", err)
+	}
+
+	var segments []entities.Segment
+	if len(ids) > 0 {
+		segments, err = s.db.GetSegmentsByIDs(imp.UserID, ids)
 		if err != nil {
-			return fmt.Errorf("importer: create subscriber: %w", err)
+			return nil, fmt.Errorf("importer: get segments: %w", err)
 		}
 	}
 
-	return
+	obj, err := s.blobs.Get(os.Getenv("FILES_BUCKET"), ImportFileKey(imp.UserID, imp.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("importer: get file: %w", err)
+	}
+	defer obj.Body.Close()
+
+	return s.ImportSubscribersFromFile(ctx, imp.UserID, segments, obj.Body, ImportOptions{
+		FileOptions: FileOptions{
+			Format: imp.Format,
+			Sheet:  imp.Sheet,
+		},
+		Mode:    imp.Mode,
+		Mapping: mapping,
+		Progress: func(res *ImportResult) error {
+			setImportResult(imp, res)
+			ok, err := s.db.UpdateSubscriberImport(imp, entities.StatusInProgress)
+			if err != nil {
+				return fmt.Errorf("importer: update progress: %w", err)
+			}
+			if !ok {
+				return ErrImportCancelled
+			}
+			return nil
+		},
+	})
+}
+
+// runImportFile imports the file of the import, and stores the error report when any rows are rejected.
+func (s *service) runImportFile(ctx context.Context, imp *entities.SubscriberImport) error {
+	res, err := s.importFile(ctx, imp)
+	if res != nil {
+		setImportResult(imp, res)
+		if res.Rejected > 0 {
+			if rerr := s.putReport(ImportErrorsKey(imp.UserID, imp.ID), res.WriteErrors); rerr != nil {
+				logger.From(ctx).WithField("import_id", imp.ID).WithError(rerr).Warn("importer: unable to store the error report")
+			}
+		}
+	}
+	return err
+}
+
+// runBulkUpdate updates the subscribers by the file of the import, and stores the report of the rows.
+func (s *service) runBulkUpdate(ctx context.Context, imp *entities.SubscriberImport) error {
+	res, err := s.bulkUpdateFile(ctx, imp)
+	if res != nil {
+		setBulkUpdateResult(imp, res)
+		if len(res.Rows) > 0 {
+			if rerr := s.putReport(BulkUpdateReportKey(imp.UserID, imp.ID), res.WriteReport); rerr != nil {
+				logger.From(ctx).WithField("import_id", imp.ID).WithError(rerr).Warn("bulk update: unable to store the report")
+			}
+		}
+	}
+	return err
+}
+
+// putReport stores the CSV report which is written by the given func.
+func (s *service) putReport(key string, write func(io.Writer) error) error {
+	var report bytes.Buffer
+	if err := write(&report); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	return s.blobs.Put(
+		os.Getenv("FILES_BUCKET"),
+		key,
+		bytes.NewReader(report.Bytes()),
+		blobs.PutOptions{ContentType: "text/csv"},
+	)
+}
+
+// importFailureReason returns the reason of the failure which is shown to the user.
+func importFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidMapping):
+		return "The columns of the file don't match the mapping."
+	case errors.Is(err, blobs.ErrNotFound):
+		return "The file was not found."
+	case errors.Is(err, utils.ErrSheetNotFound):
+		return "The sheet was not found."
+	case errors.Is(err, ErrFileTooLarge):
+		return "The file is larger than 50MB."
+	case errors.Is(err, ErrInvalidFile):
+		return "Unable to read the file."
+	case errors.Is(err, io.EOF):
+		return "The file is empty."
+	}
+	return "Unable to import the file."
 }
 
 func (s *service) RemoveSubscribersFromFile(
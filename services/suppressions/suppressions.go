@@ -0,0 +1,191 @@
+package suppressions
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+)
+
+const (
+	// batchSize is the number of the suppressions which are stored in a single transaction.
+	batchSize = 1000
+	// maxRejectedRows is the max number of the rejected rows which are listed in the result.
+	maxRejectedRows = 100
+)
+
+var (
+	ErrInvalidValue = errors.New("suppressions: invalid email or domain")
+	ErrInvalidFile  = errors.New("suppressions: unable to read the file")
+)
+
+// Reasons are the reasons of the suppressions.
+var Reasons = []string{
+	entities.SuppressionReasonBounce,
+	entities.SuppressionReasonComplaint,
+	entities.SuppressionReasonManual,
+	entities.SuppressionReasonUnsubscribed,
+}
+
+// Service manages the suppression list of the users.
+type Service interface {
+	Suppress(userID int64, value, reason, source string) error
+	ImportFromFile(ctx context.Context, userID int64, r io.Reader) (*ImportResult, error)
+}
+
+type service struct {
+	db storage.Storage
+}
+
+// New returns a new suppressions service.
+func New(db storage.Storage) Service {
+	return &service{db}
+}
+
+// ImportResult is the outcome of the import of the suppressions.
+type ImportResult struct {
+	Created int `json:"created"`
+	// Skipped is the number of the rows which are already suppressed or duplicated in the file.
+	Skipped      int           `json:"skipped"`
+	Rejected     int           `json:"rejected"`
+	RejectedRows []RejectedRow `json:"rejected_rows"`
+}
+
+// RejectedRow is a row of the file which was not imported.
+type RejectedRow struct {
+	Row    int    `json:"row"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (r *ImportResult) reject(row int, value, reason string) {
+	r.Rejected++
+	if len(r.RejectedRows) < maxRejectedRows {
+		r.RejectedRows = append(r.RejectedRows, RejectedRow{Row: row, Value: value, Reason: reason})
+	}
+}
+
+// Suppress adds the email or the domain to the suppression list of the user, it does nothing
+// when the value is already suppressed.
+func (s *service) Suppress(userID int64, value, reason, source string) error {
+	typ, value, ok := entities.ParseSuppression(value)
+	if !ok {
+		return ErrInvalidValue
+	}
+
+	_, err := s.db.AddSuppressions(userID, []*entities.Suppression{{
+		UserID: userID,
+		Type:   typ,
+		Value:  value,
+		Reason: reason,
+		Source: source,
+	}})
+	if err != nil {
+		return fmt.Errorf("suppressions: add: %w", err)
+	}
+	return nil
+}
+
+// ImportFromFile adds the emails and the domains of the CSV file to the suppression list of the
+// user. The first column of the rows is the email or the domain, and the optional second column
+// is the reason, the entries are suppressed manually by default. The header row is optional,
+// it's skipped when its first column is neither an email nor a domain.
+func (s *service) ImportFromFile(ctx context.Context, userID int64, r io.Reader) (*ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	res := &ImportResult{RejectedRows: []RejectedRow{}}
+	seen := make(map[string]bool)
+	batch := make([]*entities.Suppression, 0, batchSize)
+
+	flush := func() error {
+		n, err := s.db.AddSuppressions(userID, batch)
+		if err != nil {
+			return fmt.Errorf("suppressions: add: %w", err)
+		}
+		res.Created += n
+		res.Skipped += len(batch) - n
+		batch = batch[:0]
+		return nil
+	}
+
+	for row := 1; ; row++ {
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("suppressions: %w", err)
+		}
+
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return res, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			return res, fmt.Errorf("suppressions: read: %w", err)
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		typ, value, ok := entities.ParseSuppression(record[0])
+		if !ok {
+			if row > 1 {
+				res.reject(row, record[0], "The value is neither an email nor a domain.")
+			}
+			continue
+		}
+
+		reason := entities.SuppressionReasonManual
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			reason = strings.ToLower(strings.TrimSpace(record[1]))
+			if !IsReason(reason) {
+				res.reject(row, record[0], "The reason must be one of: "+strings.Join(Reasons, " ")+".")
+				continue
+			}
+		}
+
+		key := typ + ":" + value
+		if seen[key] {
+			res.Skipped++
+			continue
+		}
+		seen[key] = true
+
+		batch = append(batch, &entities.Suppression{
+			UserID: userID,
+			Type:   typ,
+			Value:  value,
+			Reason: reason,
+			Source: entities.SuppressionSourceImport,
+		})
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// IsReason returns whether the reason is one of the reasons of the suppressions.
+func IsReason(reason string) bool {
+	for _, r := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
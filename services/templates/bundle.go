@@ -0,0 +1,393 @@
+package templates
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/utils"
+)
+
+const (
+	// maxBundleSize is the max size of the uploaded ZIP archive.
+	maxBundleSize = 10 << 20
+	// maxBundleUncompressedSize is the max size of all the files in the archive once they are extracted.
+	maxBundleUncompressedSize = 50 << 20
+	// maxBundleFiles is the max number of files in the archive.
+	maxBundleFiles = 1000
+	// maxSubjectPartLength is the max length of the subject extracted from the title.
+	maxSubjectPartLength = 191
+)
+
+var (
+	ErrBundleNotFound       = errors.New("bundle not found")
+	ErrInvalidBundle        = errors.New("bundle is not a valid zip archive")
+	ErrBundleTooLarge       = errors.New("bundle is too large")
+	ErrBundleUnsafePath     = errors.New("bundle contains an unsafe path")
+	ErrBundleMissingIndex   = errors.New("bundle does not contain index.html")
+	ErrBundleMissingSubject = errors.New("bundle index.html has no title")
+)
+
+var cssURLRe = regexp.MustCompile(`(?i)url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// bundleAssetTypes are the extensions of the files which are uploaded as assets.
+var bundleAssetTypes = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".bmp": true, ".ico": true,
+}
+
+// bundle is an extracted template bundle.
+type bundle struct {
+	files map[string]*zip.File
+	// dir is the directory of index.html, the references in the HTML are resolved relative to it.
+	dir string
+	// assets are the paths of the referenced files mapped to their hosted urls.
+	assets map[string]string
+	prefix string
+}
+
+// ImportTemplate creates a template from the ZIP bundle uploaded to the files bucket under the given key.
+// The bundle must contain an index.html file whose content is used as the HTML part, and the images it
+// references through relative urls are uploaded to the public assets prefix, with the urls rewritten to
+// the hosted ones. When the template has no subject the title of index.html is used instead.
+func (s *service) ImportTemplate(c context.Context, template *entities.Template, key string) error {
+	obj, err := s.blobs.Get(s.filesBucket, key)
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) {
+			return ErrBundleNotFound
+		}
+		return fmt.Errorf("get bundle: %w", err)
+	}
+	defer obj.Body.Close()
+
+	if obj.Size > maxBundleSize {
+		return ErrBundleTooLarge
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(obj.Body, maxBundleSize+1))
+	if err != nil {
+		return fmt.Errorf("read bundle: %w", err)
+	}
+	if len(data) > maxBundleSize {
+		return ErrBundleTooLarge
+	}
+
+	importID, err := utils.GenerateRandomString(12)
+	if err != nil {
+		return fmt.Errorf("generate import id: %w", err)
+	}
+
+	b, err := openBundle(data)
+	if err != nil {
+		return err
+	}
+	b.prefix = fmt.Sprintf("%s%d/%s", blobs.PublicPrefix, template.UserID, importID)
+
+	index, err := b.read(path.Join(b.dir, "index.html"))
+	if err != nil {
+		return fmt.Errorf("read index.html: %w", err)
+	}
+
+	source, title := b.rewriteHTML(string(index), s.assetsURL)
+	template.HTMLPart = source
+
+	if template.SubjectPart == "" {
+		template.SubjectPart = title
+	}
+	if template.SubjectPart == "" {
+		return ErrBundleMissingSubject
+	}
+
+	err = s.validateTemplate(c, template)
+	if err != nil {
+		return err
+	}
+
+	uploaded := make([]string, 0, len(b.assets))
+	for name := range b.assets {
+		err := s.uploadAsset(b, name)
+		if err != nil {
+			s.deleteAssets(c, b, uploaded)
+			return fmt.Errorf("upload %s: %w", name, err)
+		}
+		uploaded = append(uploaded, name)
+	}
+
+	err = s.createTemplate(template)
+	if err != nil {
+		s.deleteAssets(c, b, uploaded)
+		return err
+	}
+
+	return nil
+}
+
+// deleteAssets deletes the uploaded assets of the bundle whose template failed to import.
+func (s *service) deleteAssets(c context.Context, b *bundle, names []string) {
+	for _, name := range names {
+		err := s.blobs.Delete(s.assetsBucket, b.prefix+"/"+name)
+		if err != nil {
+			logger.From(c).WithFields(logrus.Fields{
+				"bucket": s.assetsBucket,
+				"key":    b.prefix + "/" + name,
+			}).WithError(err).Warn("Unable to delete the asset of the failed import.")
+		}
+	}
+}
+
+// uploadAsset uploads the bundle file to the assets prefix.
+func (s *service) uploadAsset(b *bundle, name string) error {
+	data, err := b.read(name)
+	if err != nil {
+		return err
+	}
+
+	err = s.blobs.Put(s.assetsBucket, b.prefix+"/"+name, bytes.NewReader(data), blobs.PutOptions{
+		ContentType:  mime.TypeByExtension(path.Ext(name)),
+		CacheControl: "public, max-age=31536000",
+	})
+	if err != nil {
+		return fmt.Errorf("put asset: %w", err)
+	}
+
+	return nil
+}
+
+// openBundle opens the ZIP archive, checks the paths and sizes of its files and finds index.html.
+func openBundle(data []byte) (*bundle, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidBundle
+	}
+
+	if len(r.File) > maxBundleFiles {
+		return nil, ErrBundleTooLarge
+	}
+
+	b := &bundle{
+		files:  make(map[string]*zip.File),
+		assets: make(map[string]string),
+	}
+
+	var (
+		total uint64
+		index string
+	)
+	for _, f := range r.File {
+		if !safeBundlePath(f.Name) || f.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("%s: %w", f.Name, ErrBundleUnsafePath)
+		}
+
+		total += f.UncompressedSize64
+		if total > maxBundleUncompressedSize {
+			return nil, ErrBundleTooLarge
+		}
+
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+
+		name := path.Clean(f.Name)
+		b.files[name] = f
+
+		// designers often zip the folder with the template, so index.html
+		// is the one closest to the root of the archive.
+		if strings.EqualFold(path.Base(name), "index.html") &&
+			(index == "" || strings.Count(name, "/") < strings.Count(index, "/")) {
+			index = name
+		}
+	}
+
+	if index == "" {
+		return nil, ErrBundleMissingIndex
+	}
+
+	b.dir = path.Dir(index)
+	if path.Base(index) != "index.html" {
+		b.files[path.Join(b.dir, "index.html")] = b.files[index]
+	}
+
+	return b, nil
+}
+
+// safeBundlePath checks that the path of the archive entry can't be used to
+// write or read outside of the archive.
+func safeBundlePath(name string) bool {
+	if name == "" || strings.ContainsAny(name, "\\:\x00") || strings.HasPrefix(name, "/") {
+		return false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// read reads the file from the archive, making sure it is not larger than it claims to be.
+func (b *bundle) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found: %w", name, ErrInvalidBundle)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, ErrInvalidBundle
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return nil, ErrInvalidBundle
+	}
+	if uint64(len(data)) > f.UncompressedSize64 {
+		return nil, ErrBundleTooLarge
+	}
+
+	return data, nil
+}
+
+// rewriteHTML replaces the relative urls of the images in the src and background attributes
+// and in the css with their hosted urls, and returns the title of the document. The rest of
+// the source is written back as it is.
+func (b *bundle) rewriteHTML(source, assetsURL string) (string, string) {
+	var (
+		out     strings.Builder
+		title   strings.Builder
+		inTitle bool
+		inStyle bool
+	)
+
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		raw := string(z.Raw())
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			inTitle = tok.Data == "title" && tt == html.StartTagToken
+			inStyle = tok.Data == "style" && tt == html.StartTagToken
+
+			for _, a := range tok.Attr {
+				var value string
+				switch a.Key {
+				case "src", "background":
+					hosted, ok := b.asset(a.Val, assetsURL)
+					if !ok {
+						continue
+					}
+					value = hosted
+				case "style":
+					value = b.rewriteCSS(a.Val, assetsURL)
+					if value == a.Val {
+						continue
+					}
+				default:
+					continue
+				}
+
+				raw = replaceAttr(raw, a.Key, value)
+			}
+		case html.EndTagToken:
+			inTitle = false
+			inStyle = false
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+			if inStyle {
+				raw = b.rewriteCSS(raw, assetsURL)
+			}
+		}
+
+		out.WriteString(raw)
+	}
+
+	subject := strings.Join(strings.Fields(title.String()), " ")
+	for utf8.RuneCountInString(subject) > maxSubjectPartLength {
+		_, size := utf8.DecodeLastRuneInString(subject)
+		subject = subject[:len(subject)-size]
+	}
+
+	return out.String(), subject
+}
+
+// rewriteCSS replaces the relative urls in the css with their hosted urls.
+func (b *bundle) rewriteCSS(css, assetsURL string) string {
+	return cssURLRe.ReplaceAllStringFunc(css, func(match string) string {
+		m := cssURLRe.FindStringSubmatch(match)
+		hosted, ok := b.asset(strings.TrimSpace(m[2]), assetsURL)
+		if !ok {
+			return match
+		}
+
+		return "url(" + m[1] + hosted + m[3] + ")"
+	})
+}
+
+// asset resolves the reference to a file in the bundle and returns its hosted url.
+func (b *bundle) asset(ref, assetsURL string) (string, bool) {
+	if ref == "" || strings.Contains(ref, "{{") {
+		return "", false
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	var name string
+	if strings.HasPrefix(u.Path, "/") {
+		name = path.Clean(strings.TrimPrefix(u.Path, "/"))
+	} else {
+		name = path.Join(b.dir, u.Path)
+	}
+
+	if _, ok := b.files[name]; !ok || !bundleAssetTypes[strings.ToLower(path.Ext(name))] {
+		return "", false
+	}
+
+	segments := strings.Split(b.prefix+"/"+name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+
+	hosted := strings.TrimRight(assetsURL, "/") + "/" + strings.Join(segments, "/")
+	b.assets[name] = hosted
+
+	return hosted, true
+}
+
+// replaceAttr replaces the value of the attribute in the raw start tag.
+func replaceAttr(raw, key, value string) string {
+	re := regexp.MustCompile(`(?i)(\s` + regexp.QuoteMeta(key) + `\s*=\s*)("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	loc := re.FindStringSubmatchIndex(raw)
+	if loc == nil {
+		return raw
+	}
+
+	return raw[:loc[4]] + `"` + html.EscapeString(value) + `"` + raw[loc[5]:]
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+)
+
+func TestImportTemplate(t *testing.T) {
+	svc, db, dir := newTestService(t)
+	svc.filesBucket = "files"
+	svc.assetsBucket = "assets"
+	svc.assetsURL = "https://assets.example.com"
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"template/index.html": `<html><head><title>Welcome</title></head><body><img src="logo.png">{{> footer}}</body></html>`,
+		"template/logo.png":   "png",
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := svc.blobs.Put("files", "bundle.zip", bytes.NewReader(buf.Bytes()), blobs.PutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test the assets are not uploaded when the template is invalid
+	template := &entities.Template{BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "welcome"}, TextPart: "hi"}
+	err = svc.ImportTemplate(ctx, template, "bundle.zip")
+	assert.True(t, errors.Is(err, ErrPartialNotFound))
+
+	assert.Empty(t, assetFiles(t, dir))
+
+	// test import
+	err = db.CreateSnippet(&entities.Snippet{UserID: 1, Name: "footer", Body: "<p>footer</p>"})
+	assert.Nil(t, err)
+
+	template = &entities.Template{BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "welcome"}, TextPart: "hi"}
+	err = svc.ImportTemplate(ctx, template, "bundle.zip")
+	assert.Nil(t, err)
+	assert.Equal(t, "Welcome", template.SubjectPart)
+	assert.Contains(t, template.HTMLPart, `<img src="https://assets.example.com/`+blobs.PublicPrefix+"1/")
+
+	assert.Len(t, assetFiles(t, dir), 1)
+}
+
+// assetFiles returns the paths of the files stored in the assets bucket.
+func assetFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(filepath.Join(dir, "assets"), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
@@ -0,0 +1,473 @@
+package templates
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+var (
+	cssCommentRe = regexp.MustCompile(`/\*[\s\S]*?\*/`)
+	styleAttrRe  = regexp.MustCompile(`(?i)(\sstyle\s*=\s*)("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// nonVisualElements are never styled, neither are the elements nested in them.
+var nonVisualElements = map[string]bool{
+	"head": true, "title": true, "meta": true, "link": true, "style": true, "script": true, "base": true,
+}
+
+// cssRule is a rule with a single selector which can be inlined.
+type cssRule struct {
+	selector cascadia.Sel
+	decls    []cssDecl
+}
+
+type cssDecl struct {
+	property  string
+	value     string
+	important bool
+}
+
+// htmlToken is a token of the HTML source along with its raw text, so the source
+// can be written back without changes to the parts which are not inlined.
+type htmlToken struct {
+	typ  html.TokenType
+	raw  string
+	name string
+	node *html.Node
+	// style is set for the tokens of the style blocks.
+	style bool
+}
+
+// hasStyleBlock checks whether the HTML contains a style block.
+func hasStyleBlock(source string) bool {
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "style" {
+				return true
+			}
+		}
+	}
+}
+
+// inlineCSS moves the rules from the style blocks of the HTML into the style attributes of the
+// elements they match, since most email clients ignore the style blocks. The rules which can't be
+// inlined, such as media queries or rules with dynamic pseudo classes, are kept in a style block in
+// the head. Everything other than the style blocks and attributes is written back as it is, so
+// the mustache tags in the source are left intact.
+func inlineCSS(source string) string {
+	root := &html.Node{Type: html.DocumentNode}
+	cur := root
+
+	var (
+		tokens  []htmlToken
+		sheets  []string
+		inStyle bool
+		hasHead bool
+	)
+
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		t := htmlToken{typ: tt, raw: string(z.Raw())}
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			t.name = tok.Data
+			if tok.Data == "style" && tt == html.StartTagToken {
+				inStyle = true
+				t.style = true
+				break
+			}
+
+			n := &html.Node{Type: html.ElementNode, Data: tok.Data, DataAtom: tok.DataAtom, Attr: tok.Attr}
+			cur.AppendChild(n)
+			t.node = n
+			if tt == html.StartTagToken && !voidElements[tok.Data] {
+				cur = n
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			t.name = tok.Data
+			if tok.Data == "style" && inStyle {
+				inStyle = false
+				t.style = true
+				break
+			}
+			if tok.Data == "head" {
+				hasHead = true
+			}
+
+			for n := cur; n != root; n = n.Parent {
+				if n.Data == tok.Data {
+					cur = n.Parent
+					break
+				}
+			}
+		case html.TextToken:
+			if inStyle {
+				sheets = append(sheets, t.raw)
+				t.style = true
+				break
+			}
+
+			cur.AppendChild(&html.Node{Type: html.TextNode, Data: t.raw})
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if len(sheets) == 0 {
+		return source
+	}
+
+	var (
+		rules []cssRule
+		keep  []string
+	)
+	for _, sheet := range sheets {
+		r, k := parseCSS(sheet)
+		rules = append(rules, r...)
+		keep = append(keep, k...)
+	}
+
+	styles := make(map[*html.Node]string)
+	computeStyles(root, rules, styles)
+
+	var kept string
+	if len(keep) > 0 {
+		kept = "<style type=\"text/css\">\n" + strings.Join(keep, "\n") + "\n</style>"
+	}
+
+	var b strings.Builder
+	for _, t := range tokens {
+		if t.style {
+			// without a head the kept rules are written in place of the first style block.
+			if !hasHead && kept != "" && t.typ == html.StartTagToken {
+				b.WriteString(kept)
+				kept = ""
+			}
+			continue
+		}
+
+		if t.typ == html.EndTagToken && t.name == "head" && kept != "" {
+			b.WriteString(kept)
+			kept = ""
+		}
+
+		if style, ok := styles[t.node]; ok && t.node != nil {
+			b.WriteString(setStyleAttr(t.raw, t.name, style))
+			continue
+		}
+
+		b.WriteString(t.raw)
+	}
+
+	return b.String()
+}
+
+// computeStyles walks the element tree and computes the style attribute of each element
+// matched by at least one of the rules.
+func computeStyles(n *html.Node, rules []cssRule, styles map[*html.Node]string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || nonVisualElements[c.Data] {
+			continue
+		}
+
+		if style := computeStyle(c, rules); style != "" {
+			styles[c] = style
+		}
+
+		computeStyles(c, rules, styles)
+	}
+}
+
+// computeStyle merges the declarations of the matching rules, ordered by their specificity,
+// with the element's own style attribute which takes precedence over the non important rules.
+func computeStyle(n *html.Node, rules []cssRule) string {
+	type match struct {
+		decl        cssDecl
+		specificity cascadia.Specificity
+		order       int
+	}
+
+	var matches []match
+	for i, r := range rules {
+		if !r.selector.Match(n) {
+			continue
+		}
+		for _, d := range r.decls {
+			matches = append(matches, match{decl: d, specificity: r.selector.Specificity(), order: i})
+		}
+	}
+
+	if len(matches) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].decl.important != matches[j].decl.important {
+			return !matches[i].decl.important
+		}
+		if matches[i].specificity != matches[j].specificity {
+			return matches[i].specificity.Less(matches[j].specificity)
+		}
+		return matches[i].order < matches[j].order
+	})
+
+	var inline []cssDecl
+	for _, a := range n.Attr {
+		if a.Key == "style" {
+			inline = parseDecls(a.Val)
+		}
+	}
+
+	var (
+		props  []string
+		values = make(map[string]string)
+	)
+	set := func(prop, value string) {
+		if _, ok := values[prop]; ok {
+			for i, p := range props {
+				if p == prop {
+					props = append(props[:i], props[i+1:]...)
+					break
+				}
+			}
+		}
+		props = append(props, prop)
+		values[prop] = value
+	}
+
+	inlineImportant := make(map[string]bool)
+	for _, m := range matches {
+		if !m.decl.important {
+			set(m.decl.property, m.decl.value)
+		}
+	}
+	for _, d := range inline {
+		set(d.property, d.value)
+		if d.important {
+			inlineImportant[d.property] = true
+			values[d.property] = d.value + " !important"
+		}
+	}
+	for _, m := range matches {
+		if m.decl.important && !inlineImportant[m.decl.property] {
+			set(m.decl.property, m.decl.value)
+		}
+	}
+
+	decls := make([]string, 0, len(props))
+	for _, p := range props {
+		decls = append(decls, p+": "+values[p])
+	}
+
+	return strings.Join(decls, "; ")
+}
+
+// setStyleAttr replaces the style attribute of the raw start tag, or adds one when it is missing.
+func setStyleAttr(raw, name, style string) string {
+	value := `"` + strings.ReplaceAll(style, `"`, `'`) + `"`
+
+	if loc := styleAttrRe.FindStringSubmatchIndex(raw); loc != nil {
+		return raw[:loc[4]] + value + raw[loc[5]:]
+	}
+
+	// the raw tag starts with '<' followed by the tag name.
+	i := 1 + len(name)
+	return raw[:i] + " style=" + value + raw[i:]
+}
+
+// parseCSS splits the stylesheet into the rules which can be inlined and the raw rules
+// which must be kept in the style block.
+func parseCSS(css string) (rules []cssRule, keep []string) {
+	css = cssCommentRe.ReplaceAllString(css, "")
+
+	i := 0
+	for i < len(css) {
+		rest := strings.TrimLeft(css[i:], " \t\r\n")
+		i = len(css) - len(rest)
+		if i >= len(css) {
+			break
+		}
+
+		open := indexOutsideTags(css, i, '{')
+		if css[i] == '@' {
+			semi := indexOutsideTags(css, i, ';')
+			if semi >= 0 && (open < 0 || semi < open) {
+				keep = append(keep, strings.TrimSpace(css[i:semi+1]))
+				i = semi + 1
+				continue
+			}
+			if open < 0 {
+				break
+			}
+
+			end := matchingBrace(css, open)
+			keep = append(keep, strings.TrimSpace(css[i:end]))
+			i = end
+			continue
+		}
+
+		if open < 0 {
+			break
+		}
+
+		end := indexOutsideTags(css, open+1, '}')
+		if end < 0 {
+			end = len(css)
+		}
+
+		prelude := strings.TrimSpace(css[i:open])
+		body := css[open+1 : end]
+		decls := parseDecls(body)
+
+		for _, sel := range splitOutsideParens(prelude, ',') {
+			sel = strings.TrimSpace(sel)
+			if sel == "" {
+				continue
+			}
+
+			compiled, err := cascadia.Parse(sel)
+			if err != nil {
+				keep = append(keep, sel+" {"+strings.TrimRight(body, " \t\r\n")+" }")
+				continue
+			}
+
+			rules = append(rules, cssRule{selector: compiled, decls: decls})
+		}
+
+		i = end + 1
+	}
+
+	return rules, keep
+}
+
+// parseDecls parses the declarations of a rule or a style attribute.
+func parseDecls(body string) []cssDecl {
+	var decls []cssDecl
+	for _, d := range splitOutsideParens(body, ';') {
+		i := strings.Index(d, ":")
+		if i < 0 {
+			continue
+		}
+
+		prop := strings.ToLower(strings.TrimSpace(d[:i]))
+		value := strings.TrimSpace(d[i+1:])
+		if prop == "" || value == "" {
+			continue
+		}
+
+		decl := cssDecl{property: prop, value: value}
+		if j := strings.Index(strings.ToLower(value), "!important"); j >= 0 {
+			decl.important = true
+			decl.value = strings.TrimSpace(value[:j])
+		}
+
+		decls = append(decls, decl)
+	}
+
+	return decls
+}
+
+// splitOutsideParens splits the string on the separator when it is not
+// within parentheses or quotes.
+func splitOutsideParens(s string, sep byte) []string {
+	var (
+		parts []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// indexOutsideTags returns the index of the first occurrence of c starting from the given
+// index, skipping the mustache tags so rules like `color: {{color}}` are parsed as a whole.
+func indexOutsideTags(s string, from int, c byte) int {
+	for i := from; i < len(s); i++ {
+		if end, ok := tagEnd(s, i); ok {
+			i = end
+			continue
+		}
+		if s[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// matchingBrace returns the index after the brace which closes the block opened at the given index.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		if end, ok := tagEnd(s, i); ok {
+			i = end
+			continue
+		}
+
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(s)
+}
+
+// tagEnd returns the index of the last character of the mustache tag starting at the given index.
+func tagEnd(s string, i int) (int, bool) {
+	open, close := "{{", "}}"
+	if strings.HasPrefix(s[i:], "{{{") {
+		open, close = "{{{", "}}}"
+	}
+	if !strings.HasPrefix(s[i:], open) {
+		return 0, false
+	}
+
+	end := strings.Index(s[i+len(open):], close)
+	if end < 0 {
+		return 0, false
+	}
+
+	return i + len(open) + end + len(close) - 1, true
+}
@@ -0,0 +1,110 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineCSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		expect string
+	}{
+		{
+			name:   "without style block",
+			source: `<p class="a">{{name}}</p>`,
+			expect: `<p class="a">{{name}}</p>`,
+		},
+		{
+			name:   "specificity",
+			source: `<style>#x { color: red } .a { color: green } p { color: blue }</style><p id="x" class="a">hi</p><p class="a">hi</p><p>hi</p>`,
+			expect: `<p style="color: red" id="x" class="a">hi</p><p style="color: green" class="a">hi</p><p style="color: blue">hi</p>`,
+		},
+		{
+			name:   "source order",
+			source: `<style>.a { color: red } .b { color: green }</style><p class="a b">hi</p>`,
+			expect: `<p style="color: green" class="a b">hi</p>`,
+		},
+		{
+			name:   "inline style wins over rules",
+			source: `<style>p { color: red; margin: 0 }</style><p style="color: blue">hi</p>`,
+			expect: `<p style="margin: 0; color: blue">hi</p>`,
+		},
+		{
+			name:   "important wins over inline style",
+			source: `<style>p { color: red !important }</style><p style="color: blue">hi</p>`,
+			expect: `<p style="color: red">hi</p>`,
+		},
+		{
+			name:   "important inline style wins over important rule",
+			source: `<style>p { color: red !important }</style><p style="color: blue !important">hi</p>`,
+			expect: `<p style="color: blue !important">hi</p>`,
+		},
+		{
+			name:   "important wins over specificity",
+			source: `<style>p { color: red !important } #x { color: blue }</style><p id="x">hi</p>`,
+			expect: `<p style="color: red" id="x">hi</p>`,
+		},
+		{
+			name:   "media query kept in the head",
+			source: `<html><head><style>p { color: red } @media (max-width: 600px) { p { color: blue } }</style></head><body><p>hi</p></body></html>`,
+			expect: "<html><head><style type=\"text/css\">\n@media (max-width: 600px) { p { color: blue } }\n</style></head><body><p style=\"color: red\">hi</p></body></html>",
+		},
+		{
+			name:   "kept rules without head",
+			source: `<style>a:hover { color: red } a { color: blue }</style><a href="#">hi</a>`,
+			expect: "<style type=\"text/css\">\na:hover { color: red }\n</style><a style=\"color: blue\" href=\"#\">hi</a>",
+		},
+		{
+			name:   "invalid selector kept",
+			source: `<style>p[ { color: red } p { color: blue }</style><p>hi</p>`,
+			expect: "<style type=\"text/css\">\np[ { color: red }\n</style><p style=\"color: blue\">hi</p>",
+		},
+		{
+			name:   "missing closing brace",
+			source: `<style>p { color: red</style><p>hi</p>`,
+			expect: `<p style="color: red">hi</p>`,
+		},
+		{
+			name:   "missing opening brace",
+			source: `<style>p { color: red } color: blue</style><p>hi</p>`,
+			expect: `<p style="color: red">hi</p>`,
+		},
+		{
+			name:   "empty and invalid declarations",
+			source: `<style>p { ; color: ; margin 0; padding: 1px; } div {}</style><div><p>hi</p></div>`,
+			expect: `<div><p style="padding: 1px">hi</p></div>`,
+		},
+		{
+			name:   "comments",
+			source: `<style>/* p { color: red } */ p { color: blue }</style><p>hi</p>`,
+			expect: `<p style="color: blue">hi</p>`,
+		},
+		{
+			name:   "mustache tags preserved",
+			source: `<style>p { color: {{color}}; background: url({{{bg}}}) }</style>{{#vip}}<p>{{name}}</p>{{/vip}}`,
+			expect: `{{#vip}}<p style="color: {{color}}; background: url({{{bg}}})">{{name}}</p>{{/vip}}`,
+		},
+		{
+			name:   "non visual elements are not styled",
+			source: `<html><head><title>t</title><style>title, body { color: red }</style></head><body>hi</body></html>`,
+			expect: `<html><head><title>t</title></head><body style="color: red">hi</body></html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, inlineCSS(tt.source))
+		})
+	}
+}
+
+func TestParseCSS(t *testing.T) {
+	rules, keep := parseCSS(`@import url("a.css"); h1, .a > p { color: red; font-family: "A; B" } @font-face { font-family: x } :hover { color: blue }`)
+
+	assert.Len(t, rules, 2)
+	assert.Equal(t, []cssDecl{{property: "color", value: "red"}, {property: "font-family", value: `"A; B"`}}, rules[0].decls)
+	assert.Equal(t, []string{`@import url("a.css");`, "@font-face { font-family: x }", ":hover { color: blue }"}, keep)
+}
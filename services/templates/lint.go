@@ -0,0 +1,322 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"golang.org/x/net/html"
+
+	"github.com/mailbadger/app/engines"
+	"github.com/mailbadger/app/entities"
+)
+
+const (
+	// gmailClipSize is the size of the HTML after which Gmail clips the message.
+	gmailClipSize = 102 * 1024
+	// maxSubjectLength is the number of characters after which most email clients
+	// truncate the subject in the inbox view.
+	maxSubjectLength = 60
+)
+
+// voidElements are the HTML elements which have no closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndElements are the HTML elements whose closing tag can be omitted.
+var optionalEndElements = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+	"option": true, "optgroup": true, "colgroup": true, "thead": true, "tbody": true, "tfoot": true,
+	"tr": true, "td": true, "th": true,
+}
+
+// LintTemplate checks the template for problems which break the email or hurt its deliverability.
+// The partials and the layout of the template are taken into account when looking for the unsubscribe
+// url and calculating the size of the HTML, partials or a layout which can't be found are ignored
+// since they are reported when the template is saved. The variables are checked against the custom
+// fields of the user.
+func (s *service) LintTemplate(c context.Context, template *entities.Template) (*entities.TemplateLint, error) {
+	engine, err := templateEngine(template)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlIncludes, err := s.lintIncludes(engine, template, template.HTMLPart, false)
+	if err != nil {
+		return nil, fmt.Errorf("html part: %w", err)
+	}
+
+	textIncludes, err := s.lintIncludes(engine, template, template.TextPart, true)
+	if err != nil {
+		return nil, fmt.Errorf("text part: %w", err)
+	}
+
+	if template.LayoutID != nil && *template.LayoutID != template.ID {
+		layout, err := s.db.GetTemplate(*template.LayoutID, template.UserID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("get layout: %w", err)
+		}
+
+		if err == nil {
+			html, err := s.getHTMLPart(layout.UserID, layout.ID)
+			if err != nil && !errors.Is(err, ErrHTMLPartNotFound) && !errors.Is(err, ErrHTMLPartInvalidState) {
+				return nil, fmt.Errorf("get layout html part: %w", err)
+			}
+			if err == nil {
+				htmlIncludes = append(htmlIncludes, html)
+			}
+		}
+	}
+
+	l := lint(engine, template, htmlIncludes, textIncludes)
+
+	fields, err := s.db.GetCustomFields(template.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get custom fields: %w", err)
+	}
+	lintVariables(l, template, fields)
+
+	return l, nil
+}
+
+// lintVariables warns about the variables which are neither built in nor custom fields once the
+// user has any custom fields, they're only filled in from the default data of the campaigns.
+// The templates whose tags can't be parsed are skipped, the syntax errors are reported by lint.
+func lintVariables(l *entities.TemplateLint, template *entities.Template, fields entities.CustomFields) {
+	if len(fields) == 0 {
+		return
+	}
+
+	vars, err := template.Variables()
+	if err != nil {
+		return
+	}
+	for _, v := range vars {
+		if v.BuiltIn || fields.Find(v.Name) != nil {
+			continue
+		}
+		l.AddWarning(
+			entities.LintRuleUnknownVariable,
+			v.Parts[0],
+			fmt.Sprintf("The {{%s}} tag is not a custom field, it's only filled in from the default data of the campaign.", v.Name),
+		)
+	}
+}
+
+// lintIncludes returns the contents of the partials which can be resolved for the given source.
+func (s *service) lintIncludes(engine engines.Engine, template *entities.Template, source string, text bool) ([]string, error) {
+	if _, err := engine.Partials(source); err != nil {
+		return nil, nil
+	}
+
+	r := s.newPartialResolver(template.UserID, text, engine)
+	r.visiting[template.Name] = true
+
+	err := r.resolve(source)
+	if err != nil &&
+		!errors.Is(err, ErrPartialNotFound) &&
+		!errors.Is(err, ErrPartialCycle) &&
+		!errors.Is(err, ErrHTMLPartNotFound) &&
+		!errors.Is(err, ErrHTMLPartInvalidState) {
+		return nil, err
+	}
+
+	includes := make([]string, 0, len(r.partials))
+	for _, body := range r.partials {
+		includes = append(includes, body)
+	}
+
+	return includes, nil
+}
+
+// lint runs all the checks on the template parts. The includes are the contents
+// of the partials and the layout the parts are rendered with.
+func lint(engine engines.Engine, template *entities.Template, htmlIncludes, textIncludes []string) *entities.TemplateLint {
+	l := entities.NewTemplateLint()
+
+	parts := []struct {
+		name   string
+		source string
+		html   bool
+	}{
+		{entities.LintPartHTML, template.HTMLPart, true},
+		{entities.LintPartText, template.TextPart, false},
+		{entities.LintPartSubject, template.SubjectPart, false},
+	}
+	for _, p := range parts {
+		if _, err := engine.Parse(p.source, nil, p.html); err != nil {
+			l.AddError(entities.LintRuleTemplateSyntax, p.name, fmt.Sprintf("Unable to parse the template tags: %s.", err))
+		}
+	}
+
+	lintHTML(l, template.HTMLPart)
+
+	size := len(template.HTMLPart)
+	for _, inc := range htmlIncludes {
+		size += len(inc)
+	}
+	if size > gmailClipSize {
+		l.AddWarning(
+			entities.LintRuleHTMLSize,
+			entities.LintPartHTML,
+			fmt.Sprintf("The HTML is %dKB, Gmail clips messages larger than %dKB.", size/1024, gmailClipSize/1024),
+		)
+	}
+
+	if !includesTag(engine, template.HTMLPart, htmlIncludes, entities.TagUnsubscribeUrl) {
+		l.AddError(
+			entities.LintRuleMissingUnsubscribe,
+			entities.LintPartHTML,
+			fmt.Sprintf("The HTML part does not contain the {{%s}} tag.", entities.TagUnsubscribeUrl),
+		)
+	}
+
+	if strings.TrimSpace(template.TextPart) == "" {
+		l.AddWarning(entities.LintRuleEmptyTextPart, entities.LintPartText, "The text part is empty, it will be generated from the HTML part.")
+	} else if !includesTag(engine, template.TextPart, textIncludes, entities.TagUnsubscribeUrl) {
+		l.AddWarning(
+			entities.LintRuleMissingUnsubscribe,
+			entities.LintPartText,
+			fmt.Sprintf("The text part does not contain the {{%s}} tag.", entities.TagUnsubscribeUrl),
+		)
+	}
+
+	subjectLen := utf8.RuneCountInString(strings.TrimSpace(template.SubjectPart))
+	switch {
+	case subjectLen == 0:
+		l.AddError(entities.LintRuleSubjectLength, entities.LintPartSubject, "The subject is empty.")
+	case subjectLen > maxSubjectLength:
+		l.AddWarning(
+			entities.LintRuleSubjectLength,
+			entities.LintPartSubject,
+			fmt.Sprintf("The subject is %d characters long, most email clients truncate subjects longer than %d characters.", subjectLen, maxSubjectLength),
+		)
+	}
+
+	return l
+}
+
+// lintHTML tokenizes the HTML part and checks for tags which are not properly closed
+// and elements which are not supported by email clients.
+func lintHTML(l *entities.TemplateLint, source string) {
+	var open []string
+
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				l.AddError(entities.LintRuleMalformedHTML, entities.LintPartHTML, fmt.Sprintf("Unable to parse the HTML: %s.", err))
+				return
+			}
+
+			reportUnclosed(l, open)
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			lintElement(l, tok)
+
+			if tt == html.StartTagToken && !voidElements[tok.Data] {
+				open = append(open, tok.Data)
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			if voidElements[tok.Data] {
+				continue
+			}
+
+			i := len(open) - 1
+			for i >= 0 && open[i] != tok.Data {
+				i--
+			}
+			if i < 0 {
+				l.AddError(
+					entities.LintRuleMalformedHTML,
+					entities.LintPartHTML,
+					fmt.Sprintf("The closing </%s> tag has no matching opening tag.", tok.Data),
+				)
+				continue
+			}
+
+			reportUnclosed(l, open[i+1:])
+			open = open[:i]
+		}
+	}
+}
+
+// reportUnclosed adds an error for each of the open elements which must be closed.
+func reportUnclosed(l *entities.TemplateLint, open []string) {
+	for i := len(open) - 1; i >= 0; i-- {
+		if optionalEndElements[open[i]] {
+			continue
+		}
+
+		l.AddError(entities.LintRuleUnclosedTag, entities.LintPartHTML, fmt.Sprintf("The <%s> tag is not closed.", open[i]))
+	}
+}
+
+// lintElement checks a single element and its attributes.
+func lintElement(l *entities.TemplateLint, tok html.Token) {
+	switch tok.Data {
+	case "script":
+		l.AddError(entities.LintRuleScript, entities.LintPartHTML, "Scripts are removed by email clients.")
+	case "form":
+		l.AddWarning(entities.LintRuleForm, entities.LintPartHTML, "Forms are removed or disabled by most email clients.")
+	case "img":
+		if _, ok := attr(tok, "alt"); !ok {
+			src, _ := attr(tok, "src")
+			l.AddWarning(entities.LintRuleImageAlt, entities.LintPartHTML, fmt.Sprintf("The image %q has no alt text.", src))
+		}
+	}
+
+	for _, a := range tok.Attr {
+		switch {
+		case strings.HasPrefix(a.Key, "on"):
+			l.AddError(
+				entities.LintRuleScript,
+				entities.LintPartHTML,
+				fmt.Sprintf("The %s attribute of the <%s> tag is removed by email clients.", a.Key, tok.Data),
+			)
+		case (a.Key == "href" || a.Key == "src" || a.Key == "action") &&
+			strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:"):
+			l.AddError(
+				entities.LintRuleJavascriptLink,
+				entities.LintPartHTML,
+				fmt.Sprintf("The <%s> tag links to javascript, such links are removed by email clients.", tok.Data),
+			)
+		}
+	}
+}
+
+// attr returns the value of the attribute with the given key.
+func attr(tok html.Token, key string) (string, bool) {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
+
+// includesTag checks whether the source or one of its includes contains a variable tag with the given name.
+func includesTag(engine engines.Engine, source string, includes []string, name string) bool {
+	if hasTag(engine, source, name) {
+		return true
+	}
+
+	for _, inc := range includes {
+		if hasTag(engine, inc, name) {
+			return true
+		}
+	}
+
+	return false
+}
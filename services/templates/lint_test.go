@@ -0,0 +1,252 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/engines"
+	"github.com/mailbadger/app/entities"
+)
+
+func newLintTemplate(html, text, subject string) *entities.Template {
+	return &entities.Template{
+		BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "welcome", SubjectPart: subject},
+		HTMLPart:     html,
+		TextPart:     text,
+	}
+}
+
+func TestLintHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		errors   []entities.LintIssue
+		warnings []entities.LintIssue
+	}{
+		{
+			name:   "valid",
+			source: `<div><p>hi <a href="https://example.com">there</a><br><img src="a.png" alt=""></p></div>`,
+		},
+		{
+			name:   "unclosed tag",
+			source: `<div><span>hi</div>`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleUnclosedTag, Part: entities.LintPartHTML, Message: "The <span> tag is not closed."},
+			},
+		},
+		{
+			name:   "unclosed tag at the end",
+			source: `<table><tr><td>hi`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleUnclosedTag, Part: entities.LintPartHTML, Message: "The <table> tag is not closed."},
+			},
+		},
+		{
+			name:   "stray end tag",
+			source: `<p>hi</p></div>`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleMalformedHTML, Part: entities.LintPartHTML, Message: "The closing </div> tag has no matching opening tag."},
+			},
+		},
+		{
+			name:   "optional end tags",
+			source: `<html><body><ul><li>a<li>b</ul><table><tbody><tr><td>x<td>y</table><p>hi</body></html>`,
+		},
+		{
+			name:   "void element end tag",
+			source: `<p>hi<br></br></p>`,
+		},
+		{
+			name:   "script",
+			source: `<script>x()</script>`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleScript, Part: entities.LintPartHTML, Message: "Scripts are removed by email clients."},
+			},
+		},
+		{
+			name:   "event handler attribute",
+			source: `<a href="https://example.com" onclick="x()">hi</a>`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleScript, Part: entities.LintPartHTML, Message: "The onclick attribute of the <a> tag is removed by email clients."},
+			},
+		},
+		{
+			name:   "javascript link",
+			source: `<a href=" JavaScript:x()">hi</a>`,
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleJavascriptLink, Part: entities.LintPartHTML, Message: "The <a> tag links to javascript, such links are removed by email clients."},
+			},
+		},
+		{
+			name:   "form",
+			source: `<form action="https://example.com"></form>`,
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleForm, Part: entities.LintPartHTML, Message: "Forms are removed or disabled by most email clients."},
+			},
+		},
+		{
+			name:   "image without alt",
+			source: `<img src="logo.png">`,
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleImageAlt, Part: entities.LintPartHTML, Message: `The image "logo.png" has no alt text.`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := entities.NewTemplateLint()
+			lintHTML(l, tt.source)
+
+			if tt.errors == nil {
+				tt.errors = []entities.LintIssue{}
+			}
+			if tt.warnings == nil {
+				tt.warnings = []entities.LintIssue{}
+			}
+			assert.Equal(t, tt.errors, l.Errors)
+			assert.Equal(t, tt.warnings, l.Warnings)
+		})
+	}
+}
+
+func TestLint(t *testing.T) {
+	engine, err := engines.Get(engines.Mustache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := `<p>hi</p><a href="{{unsubscribe_url}}">unsubscribe</a>`
+	text := "hi {{unsubscribe_url}}"
+
+	tests := []struct {
+		name         string
+		template     *entities.Template
+		htmlIncludes []string
+		textIncludes []string
+		errors       []entities.LintIssue
+		warnings     []entities.LintIssue
+	}{
+		{
+			name:     "valid",
+			template: newLintTemplate(html, text, "Welcome"),
+		},
+		{
+			name:     "template syntax",
+			template: newLintTemplate(html, text, "Welcome {{#name}}"),
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleTemplateSyntax, Part: entities.LintPartSubject},
+			},
+		},
+		{
+			name:         "html size",
+			template:     newLintTemplate(html+strings.Repeat("a", gmailClipSize-len(html)), text, "Welcome"),
+			htmlIncludes: []string{"<p>footer</p>"},
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleHTMLSize, Part: entities.LintPartHTML, Message: "The HTML is 102KB, Gmail clips messages larger than 102KB."},
+			},
+		},
+		{
+			name:     "missing unsubscribe url",
+			template: newLintTemplate("<p>hi</p>", "hi", "Welcome"),
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleMissingUnsubscribe, Part: entities.LintPartHTML, Message: "The HTML part does not contain the {{unsubscribe_url}} tag."},
+			},
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleMissingUnsubscribe, Part: entities.LintPartText, Message: "The text part does not contain the {{unsubscribe_url}} tag."},
+			},
+		},
+		{
+			name:         "unsubscribe url in the includes",
+			template:     newLintTemplate("<p>hi</p>{{> footer}}", "hi {{> footer}}", "Welcome"),
+			htmlIncludes: []string{`<a href="{{unsubscribe_url}}">unsubscribe</a>`},
+			textIncludes: []string{"{{unsubscribe_url}}"},
+		},
+		{
+			name:     "empty text part",
+			template: newLintTemplate(html, " ", "Welcome"),
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleEmptyTextPart, Part: entities.LintPartText, Message: "The text part is empty, it will be generated from the HTML part."},
+			},
+		},
+		{
+			name:     "empty subject",
+			template: newLintTemplate(html, text, " "),
+			errors: []entities.LintIssue{
+				{Rule: entities.LintRuleSubjectLength, Part: entities.LintPartSubject, Message: "The subject is empty."},
+			},
+		},
+		{
+			name:     "long subject",
+			template: newLintTemplate(html, text, strings.Repeat("ш", maxSubjectLength+1)),
+			warnings: []entities.LintIssue{
+				{Rule: entities.LintRuleSubjectLength, Part: entities.LintPartSubject, Message: "The subject is 61 characters long, most email clients truncate subjects longer than 60 characters."},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lint(engine, tt.template, tt.htmlIncludes, tt.textIncludes)
+
+			if tt.errors == nil {
+				tt.errors = []entities.LintIssue{}
+			}
+			if tt.warnings == nil {
+				tt.warnings = []entities.LintIssue{}
+			}
+			// the syntax error messages come from the engine, only the rules and parts are compared.
+			for i := range l.Errors {
+				if l.Errors[i].Rule == entities.LintRuleTemplateSyntax {
+					l.Errors[i].Message = ""
+				}
+			}
+			assert.Equal(t, tt.errors, l.Errors)
+			assert.Equal(t, tt.warnings, l.Warnings)
+		})
+	}
+}
+
+func TestLintVariables(t *testing.T) {
+	fields := entities.CustomFields{
+		{Key: "city", Type: entities.CustomFieldTypeString},
+	}
+	template := newLintTemplate(
+		`<p>Hi {{name}} from {{city}}, {{#vip}}{{discount}}{{/vip}}</p><a href="{{unsubscribe_url}}">unsubscribe</a>`,
+		"Hi {{name}} {{unsubscribe_url}}",
+		"Welcome {{company}}",
+	)
+
+	// without custom fields the variables are not checked
+	l := entities.NewTemplateLint()
+	lintVariables(l, template, nil)
+	assert.Empty(t, l.Warnings)
+
+	l = entities.NewTemplateLint()
+	lintVariables(l, template, fields)
+	assert.Empty(t, l.Errors)
+	assert.ElementsMatch(t, []entities.LintIssue{
+		{
+			Rule:    entities.LintRuleUnknownVariable,
+			Part:    entities.LintPartSubject,
+			Message: "The {{company}} tag is not a custom field, it's only filled in from the default data of the campaign.",
+		},
+		{
+			Rule:    entities.LintRuleUnknownVariable,
+			Part:    entities.LintPartHTML,
+			Message: "The {{vip}} tag is not a custom field, it's only filled in from the default data of the campaign.",
+		},
+		{
+			Rule:    entities.LintRuleUnknownVariable,
+			Part:    entities.LintPartHTML,
+			Message: "The {{discount}} tag is not a custom field, it's only filled in from the default data of the campaign.",
+		},
+	}, l.Warnings)
+
+	// the templates which can't be parsed are skipped
+	l = entities.NewTemplateLint()
+	lintVariables(l, newLintTemplate("{{#vip}}", "", ""), fields)
+	assert.Empty(t, l.Warnings)
+}
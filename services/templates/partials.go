@@ -0,0 +1,367 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/mailbadger/app/engines"
+	"github.com/mailbadger/app/entities"
+)
+
+var (
+	ErrPartialNotFound = errors.New("partial not found")
+	ErrPartialCycle    = errors.New("partials include each other")
+	ErrLayoutNotFound  = errors.New("layout not found")
+	ErrInvalidLayout   = errors.New("layout is invalid")
+	ErrLayoutInUse     = errors.New("template is used as a layout")
+	ErrParseSnippet    = errors.New("failed to parse snippet")
+	ErrSnippetInUse    = errors.New("snippet is included as a partial")
+)
+
+// partialResolver loads the partials included in a template source, and every partial
+// they include in turn, from the user's snippets or, when there is no snippet with
+// the partial's name, from the user's templates. The resolved partials are cached
+// so the resulting provider can render any number of emails without hitting the
+// database or S3.
+type partialResolver struct {
+	svc    *service
+	userID int64
+	// engine is the engine of the template the partials are included in.
+	engine engines.Engine
+	// text is set when resolving partials for the text or subject part, in which
+	// case partials from templates are taken from their text part.
+	text bool
+
+	partials  map[string]string
+	overrides map[string]string
+	visiting  map[string]bool
+}
+
+func (s *service) newPartialResolver(userID int64, text bool, engine engines.Engine) *partialResolver {
+	return &partialResolver{
+		svc:       s,
+		userID:    userID,
+		engine:    engine,
+		text:      text,
+		partials:  make(map[string]string),
+		overrides: make(map[string]string),
+		visiting:  make(map[string]bool),
+	}
+}
+
+// resolve walks the partial tags of the source recursively and caches their contents.
+func (r *partialResolver) resolve(source string) error {
+	names, err := r.engine.Partials(source)
+	if err != nil {
+		return fmt.Errorf("parse string: %w", err)
+	}
+
+	for _, name := range names {
+		if r.visiting[name] {
+			return fmt.Errorf("%s partial: %w", name, ErrPartialCycle)
+		}
+
+		if _, ok := r.partials[name]; ok {
+			continue
+		}
+
+		body, err := r.load(name)
+		if err != nil {
+			return err
+		}
+
+		r.visiting[name] = true
+		err = r.resolve(body)
+		delete(r.visiting, name)
+		if err != nil {
+			return err
+		}
+
+		r.partials[name] = body
+	}
+
+	return nil
+}
+
+// load returns the contents of the partial with the given name.
+func (r *partialResolver) load(name string) (string, error) {
+	if body, ok := r.overrides[name]; ok {
+		return body, nil
+	}
+
+	snippet, err := r.svc.db.GetSnippetByName(name, r.userID)
+	if err == nil {
+		return snippet.Body, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("get snippet by name: %w", err)
+	}
+
+	template, err := r.svc.db.GetTemplateByName(name, r.userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%s partial: %w", name, ErrPartialNotFound)
+		}
+		return "", fmt.Errorf("get template by name: %w", err)
+	}
+
+	if r.text {
+		return template.TextPart, nil
+	}
+
+	html, err := r.svc.getHTMLPart(template.UserID, template.ID)
+	if err != nil {
+		return "", fmt.Errorf("%s partial: %w", name, err)
+	}
+
+	return html, nil
+}
+
+// parse resolves the partials of the given source and compiles it.
+func (r *partialResolver) parse(source string) (engines.Template, error) {
+	if err := r.resolve(source); err != nil {
+		return nil, err
+	}
+
+	return r.engine.Parse(source, r.partials, !r.text)
+}
+
+// flatten resolves the partials of the given source and replaces
+// the partial tags with the contents of the partials.
+func (r *partialResolver) flatten(source string) (string, error) {
+	if err := r.resolve(source); err != nil {
+		return "", err
+	}
+
+	return r.engine.Inline(source, r.partials), nil
+}
+
+// flattenHTML flattens the HTML source and the layout it is wrapped in, if any, into a single source.
+func (r *partialResolver) flattenHTML(source, layout string) (string, error) {
+	flat, err := r.flatten(source)
+	if err != nil {
+		return "", err
+	}
+	if layout == "" {
+		return flat, nil
+	}
+
+	flatLayout, err := r.flatten(layout)
+	if err != nil {
+		return "", fmt.Errorf("layout: %w", err)
+	}
+
+	return r.engine.Embed(flatLayout, flat), nil
+}
+
+// hasTag checks whether the source contains a tag with the given name.
+func hasTag(engine engines.Engine, source, name string) bool {
+	tags, err := engine.Tags(source)
+	if err != nil {
+		return false
+	}
+
+	for _, tag := range tags {
+		if tag == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPartial checks whether the source includes the partial with the given name.
+func hasPartial(engine engines.Engine, source, name string) bool {
+	partials, err := engine.Partials(source)
+	if err != nil {
+		return false
+	}
+
+	for _, partial := range partials {
+		if partial == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// templateEngine returns the engine the template is written for.
+func templateEngine(template *entities.Template) (engines.Engine, error) {
+	return engines.Get(template.Engine)
+}
+
+// validatePartials checks that every partial included in the template exists
+// and that the partials do not include each other.
+func (s *service) validatePartials(c context.Context, template *entities.Template) error {
+	engine, err := templateEngine(template)
+	if err != nil {
+		return err
+	}
+
+	text := s.newPartialResolver(template.UserID, true, engine)
+	html := s.newPartialResolver(template.UserID, false, engine)
+
+	// a template could include itself as a partial through its own name.
+	text.visiting[template.Name] = true
+	html.visiting[template.Name] = true
+
+	if err := text.resolve(template.SubjectPart); err != nil {
+		return fmt.Errorf("subject part: %w", err)
+	}
+	if err := text.resolve(template.TextPart); err != nil {
+		return fmt.Errorf("text part: %w", err)
+	}
+	if err := html.resolve(template.HTMLPart); err != nil {
+		return fmt.Errorf("html part: %w", err)
+	}
+
+	return nil
+}
+
+// validateLayout checks that the template's layout exists, is written for the same engine,
+// contains the content tag and isn't itself wrapped in a layout. A template which is used
+// as a layout can't be wrapped in a layout either, since layouts don't nest.
+func (s *service) validateLayout(c context.Context, template *entities.Template) error {
+	if template.LayoutID == nil {
+		return nil
+	}
+
+	if *template.LayoutID == template.ID {
+		return fmt.Errorf("template wraps itself: %w", ErrInvalidLayout)
+	}
+
+	if template.ID != 0 {
+		wrapped, err := s.db.GetTotalTemplatesByLayoutID(template.ID, template.UserID)
+		if err != nil {
+			return fmt.Errorf("get total templates by layout: %w", err)
+		}
+		if wrapped > 0 {
+			return fmt.Errorf("template is a layout of other templates: %w", ErrInvalidLayout)
+		}
+	}
+
+	layout, err := s.db.GetTemplate(*template.LayoutID, template.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLayoutNotFound
+		}
+		return fmt.Errorf("get layout: %w", err)
+	}
+
+	if layout.LayoutID != nil {
+		return fmt.Errorf("layout is wrapped in another layout: %w", ErrInvalidLayout)
+	}
+
+	engine, err := templateEngine(template)
+	if err != nil {
+		return err
+	}
+	if layoutEngine, err := engines.Get(layout.Engine); err != nil || layoutEngine != engine {
+		return fmt.Errorf("layout engine %s: %w", layout.Engine, ErrInvalidLayout)
+	}
+
+	html, err := s.getHTMLPart(layout.UserID, layout.ID)
+	if err != nil {
+		return fmt.Errorf("get layout html part: %w", err)
+	}
+
+	if !engine.IsLayout(html) {
+		return fmt.Errorf("missing %s tag: %w", entities.TagContent, ErrInvalidLayout)
+	}
+
+	return nil
+}
+
+// ValidateSnippet checks that the snippet body parses and that the partials it includes
+// exist and do not include the snippet back. Snippets can be included in templates of any
+// engine, so the body is checked with each engine it parses with.
+func (s *service) ValidateSnippet(c context.Context, snippet *entities.Snippet) error {
+	parsed := false
+	for _, name := range engines.Names() {
+		engine, err := engines.Get(name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := engine.Parse(snippet.Body, nil, true); err != nil {
+			continue
+		}
+		parsed = true
+
+		for _, text := range []bool{true, false} {
+			r := s.newPartialResolver(snippet.UserID, text, engine)
+			r.overrides[snippet.Name] = snippet.Body
+			r.visiting[snippet.Name] = true
+			if err := r.resolve(snippet.Body); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !parsed {
+		return ErrParseSnippet
+	}
+
+	return nil
+}
+
+// DeleteSnippet deletes the snippet when it isn't included as a partial
+// in any of the user's templates or other snippets.
+func (s *service) DeleteSnippet(c context.Context, snippet *entities.Snippet) error {
+	snippets, err := s.db.GetAllSnippetsForUser(snippet.UserID)
+	if err != nil {
+		return fmt.Errorf("get all snippets for user: %w", err)
+	}
+
+	for _, other := range snippets {
+		if other.ID == snippet.ID {
+			continue
+		}
+
+		// snippets aren't bound to an engine, the partial could be included with any of them.
+		for _, name := range engines.Names() {
+			engine, err := engines.Get(name)
+			if err != nil {
+				return err
+			}
+			if hasPartial(engine, other.Body, snippet.Name) {
+				return ErrSnippetInUse
+			}
+		}
+	}
+
+	templates, err := s.db.GetAllTemplatesForUser(snippet.UserID)
+	if err != nil {
+		return fmt.Errorf("get all templates for user: %w", err)
+	}
+
+	for _, template := range templates {
+		engine, err := templateEngine(&template)
+		if err != nil {
+			return err
+		}
+
+		if hasPartial(engine, template.SubjectPart, snippet.Name) || hasPartial(engine, template.TextPart, snippet.Name) {
+			return ErrSnippetInUse
+		}
+
+		html, err := s.getHTMLPart(template.UserID, template.ID)
+		if err != nil {
+			return fmt.Errorf("get html part of template %d: %w", template.ID, err)
+		}
+		if hasPartial(engine, html, snippet.Name) {
+			return ErrSnippetInUse
+		}
+	}
+
+	err = s.db.DeleteSnippet(snippet.ID, snippet.UserID)
+	if err != nil {
+		return fmt.Errorf("delete snippet: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+)
+
+// newTestService creates the service with an in-memory database and the blobs
+// stored in a temporary directory, which is returned as well.
+func newTestService(t *testing.T) (*service, storage.Storage, string) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	store, err := blobs.NewFilesystem(dir, "http://localhost/api/blobs/", "secret", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := storage.New("sqlite3", ":memory:")
+	return New(db, store, TemplateBucket("templates")).(*service), db, dir
+}
+
+func TestValidateLayout(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	newTemplate := func(name, html string, layoutID *int64) *entities.Template {
+		return &entities.Template{
+			BaseTemplate: entities.BaseTemplate{UserID: 1, Name: name, SubjectPart: "hi", LayoutID: layoutID},
+			HTMLPart:     html,
+			TextPart:     "hi",
+		}
+	}
+
+	layout := newTemplate("layout", "<div>{{{content}}}</div>", nil)
+	assert.Nil(t, svc.AddTemplate(ctx, layout))
+
+	other := newTemplate("other", "<section>{{{content}}}</section>", nil)
+	assert.Nil(t, svc.AddTemplate(ctx, other))
+
+	escaped := newTemplate("escaped", "<div>{{content}}</div>", nil)
+	assert.Nil(t, svc.AddTemplate(ctx, escaped))
+
+	// the layout must embed the content unescaped
+	err := svc.AddTemplate(ctx, newTemplate("wrapped", "<p>hi</p>", &escaped.ID))
+	assert.True(t, errors.Is(err, ErrInvalidLayout))
+
+	wrapped := newTemplate("wrapped", "<p>hi</p>", &layout.ID)
+	assert.Nil(t, svc.AddTemplate(ctx, wrapped))
+
+	// the layout can't be wrapped in another layout since it wraps a template
+	layout.LayoutID = &other.ID
+	err = svc.UpdateTemplate(ctx, layout)
+	assert.True(t, errors.Is(err, ErrInvalidLayout))
+
+	// the wrapped template can't be used as a layout
+	err = svc.AddTemplate(ctx, newTemplate("nested", "<p>hi</p>", &wrapped.ID))
+	assert.True(t, errors.Is(err, ErrInvalidLayout))
+
+	wrapped.LayoutID = &wrapped.ID
+	err = svc.UpdateTemplate(ctx, wrapped)
+	assert.True(t, errors.Is(err, ErrInvalidLayout))
+
+	missing := int64(1000)
+	err = svc.AddTemplate(ctx, newTemplate("missing", "<p>hi</p>", &missing))
+	assert.True(t, errors.Is(err, ErrLayoutNotFound))
+}
+
+func TestDeleteSnippet(t *testing.T) {
+	svc, db, _ := newTestService(t)
+	ctx := context.Background()
+
+	address := &entities.Snippet{UserID: 1, Name: "address", Body: "<p>{{street}}</p>"}
+	footer := &entities.Snippet{UserID: 1, Name: "footer", Body: "<p>{{company}}</p>{{> address}}"}
+	for _, s := range []*entities.Snippet{address, footer} {
+		assert.Nil(t, db.CreateSnippet(s))
+	}
+
+	err := svc.AddTemplate(ctx, &entities.Template{
+		BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "welcome", SubjectPart: "hi"},
+		HTMLPart:     "<p>hi</p>{{#vip}}{{> footer}}{{/vip}}",
+		TextPart:     "hi",
+	})
+	assert.Nil(t, err)
+
+	// test snippet included in another snippet
+	err = svc.DeleteSnippet(ctx, address)
+	assert.True(t, errors.Is(err, ErrSnippetInUse))
+
+	// test snippet included in the html part of a template
+	err = svc.DeleteSnippet(ctx, footer)
+	assert.True(t, errors.Is(err, ErrSnippetInUse))
+
+	// test snippet of another user with the same name
+	other := &entities.Snippet{UserID: 2, Name: "footer", Body: "<p>footer</p>"}
+	assert.Nil(t, db.CreateSnippet(other))
+	assert.Nil(t, svc.DeleteSnippet(ctx, other))
+
+	_, err = db.GetSnippet(other.ID, 2)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+	_, err = db.GetSnippet(footer.ID, 1)
+	assert.Nil(t, err)
+}
@@ -1,19 +1,17 @@
 package templates
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"github.com/cbroglie/mustache"
+	"github.com/jinzhu/gorm"
 
+	"github.com/mailbadger/app/blobs"
+	"github.com/mailbadger/app/engines"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/storage"
 )
@@ -34,6 +32,13 @@ type Service interface {
 	DeleteTemplate(c context.Context, templateID, userID int64) error
 	GetTemplate(c context.Context, templateID int64, userID int64) (*entities.Template, error)
 	ParseTemplate(c context.Context, templateID int64, userID int64) (*entities.CampaignTemplateData, error)
+	ValidateSnippet(c context.Context, snippet *entities.Snippet) error
+	DeleteSnippet(c context.Context, snippet *entities.Snippet) error
+	LintTemplate(c context.Context, template *entities.Template) (*entities.TemplateLint, error)
+	GenerateTextPart(c context.Context, template *entities.Template, links string) (string, error)
+	ImportTemplate(c context.Context, template *entities.Template, key string) error
+	GetTemplateVariables(c context.Context, template *entities.Template) (*entities.TemplateVariables, error)
+	PreviewTemplate(c context.Context, templateID, userID int64, data map[string]string) (*entities.TemplatePreview, error)
 }
 
 type Opts func(s *service)
@@ -48,91 +53,128 @@ func TemplateBucket(bucket string) Opts {
 // service implements the Service interface
 type service struct {
 	db              storage.Storage
-	s3              s3iface.S3API
+	blobs           blobs.Store
 	templatesBucket string
+	filesBucket     string
+	// assetsBucket holds the images of the imported templates under the assets
+	// prefix, which must be publicly readable through assetsURL.
+	assetsBucket string
+	assetsURL    string
 }
 
-func New(db storage.Storage, s3 s3iface.S3API, opts ...Opts) Service {
+func New(db storage.Storage, store blobs.Store, opts ...Opts) Service {
 	s := &service{
 		db:              db,
-		s3:              s3,
+		blobs:           store,
 		templatesBucket: os.Getenv("TEMPLATES_BUCKET"),
+		filesBucket:     os.Getenv("FILES_BUCKET"),
+		assetsBucket:    os.Getenv("ASSETS_BUCKET"),
+		assetsURL:       os.Getenv("ASSETS_URL"),
 	}
 
 	for _, option := range opts {
 		option(s)
 	}
 
+	if s.assetsBucket == "" {
+		s.assetsBucket = s.filesBucket
+	}
+	if s.assetsURL == "" {
+		if fs, ok := store.(*blobs.FilesystemStore); ok {
+			s.assetsURL = fs.URL(s.assetsBucket, "")
+		} else {
+			s.assetsURL = fmt.Sprintf("https://%s.s3.amazonaws.com", s.assetsBucket)
+		}
+	}
+
 	return s
 }
 
 func (s service) AddTemplate(c context.Context, template *entities.Template) error {
-	// parse string to validate template params
-	_, err := mustache.ParseString(template.HTMLPart)
+	err := s.validateTemplate(c, template)
 	if err != nil {
-		return ErrParseHTMLPart
+		return err
+	}
+
+	return s.createTemplate(template)
+}
+
+// validateTemplate parses the template parts and checks its partials and layout.
+func (s service) validateTemplate(c context.Context, template *entities.Template) error {
+	if template.Engine == "" {
+		template.Engine = engines.Mustache
 	}
-	// parse string to validate template params
-	_, err = mustache.ParseString(template.TextPart)
+
+	err := parseParts(template)
 	if err != nil {
-		return ErrParseTextPart
+		return err
 	}
-	// parse string to validate template params
-	_, err = mustache.ParseString(template.SubjectPart)
+
+	err = s.validatePartials(c, template)
 	if err != nil {
-		return ErrParseSubjectPart
+		return fmt.Errorf("validate partials: %w", err)
 	}
 
-	err = s.db.CreateTemplate(template)
+	err = s.validateLayout(c, template)
 	if err != nil {
-		return fmt.Errorf("create template: %w", err)
+		return fmt.Errorf("validate layout: %w", err)
 	}
 
-	s3Input := &s3.PutObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
-		Body:   bytes.NewReader([]byte(template.HTMLPart)),
+	return nil
+}
+
+// createTemplate persists the validated template and uploads its HTML part.
+func (s service) createTemplate(template *entities.Template) error {
+	err := s.db.CreateTemplate(template)
+	if err != nil {
+		return fmt.Errorf("create template: %w", err)
 	}
 
-	_, err = s.s3.PutObject(s3Input)
+	err = s.blobs.Put(s.templatesBucket, templateKey(template.UserID, template.ID), strings.NewReader(template.HTMLPart), blobs.PutOptions{})
 	if err != nil {
-		return fmt.Errorf("upload template: put s3 object: %w", err)
+		return fmt.Errorf("upload template: %w", err)
 	}
 
 	return nil
 }
 
 func (s service) UpdateTemplate(c context.Context, template *entities.Template) error {
-	// parse string to validate template params
-	_, err := mustache.ParseString(template.HTMLPart)
+	err := s.validateTemplate(c, template)
 	if err != nil {
-		return ErrParseHTMLPart
+		return err
 	}
-	// parse string to validate template params
-	_, err = mustache.ParseString(template.TextPart)
+
+	err = s.blobs.Put(s.templatesBucket, templateKey(template.UserID, template.ID), strings.NewReader(template.HTMLPart), blobs.PutOptions{})
 	if err != nil {
-		return ErrParseTextPart
+		return fmt.Errorf("upload template: %w", err)
 	}
-	// parse string to validate template params
-	_, err = mustache.ParseString(template.SubjectPart)
+
+	err = s.db.UpdateTemplate(template)
 	if err != nil {
-		return ErrParseSubjectPart
+		return fmt.Errorf("update template: %w", err)
 	}
 
-	s3Input := &s3.PutObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
-		Body:   bytes.NewReader([]byte(template.HTMLPart)),
-	}
+	return nil
+}
 
-	_, err = s.s3.PutObject(s3Input)
+// parseParts parses the template parts with the template's engine to validate them.
+func parseParts(template *entities.Template) error {
+	engine, err := templateEngine(template)
 	if err != nil {
-		return fmt.Errorf("upload template: put s3 object: %w", err)
+		return err
 	}
 
-	err = s.db.UpdateTemplate(template)
+	_, err = engine.Parse(template.HTMLPart, nil, true)
 	if err != nil {
-		return fmt.Errorf("update template: %w", err)
+		return ErrParseHTMLPart
+	}
+	_, err = engine.Parse(template.TextPart, nil, false)
+	if err != nil {
+		return ErrParseTextPart
+	}
+	_, err = engine.Parse(template.SubjectPart, nil, false)
+	if err != nil {
+		return ErrParseSubjectPart
 	}
 
 	return nil
@@ -146,12 +188,17 @@ func (s service) GetTemplates(c context.Context, userID int64, p *storage.Pagina
 
 // DeleteTemplate deletes the given template
 func (s *service) DeleteTemplate(c context.Context, templateID, userID int64) error {
-	_, err := s.s3.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(userID, templateID)),
-	})
+	wrapped, err := s.db.GetTotalTemplatesByLayoutID(templateID, userID)
+	if err != nil {
+		return fmt.Errorf("get total templates by layout: %w", err)
+	}
+	if wrapped > 0 {
+		return ErrLayoutInUse
+	}
+
+	err = s.blobs.Delete(s.templatesBucket, templateKey(userID, templateID))
 	if err != nil {
-		return fmt.Errorf("delete object: %w", err)
+		return fmt.Errorf("delete html part: %w", err)
 	}
 
 	err = s.db.DeleteTemplate(templateID, userID)
@@ -163,72 +210,188 @@ func (s *service) DeleteTemplate(c context.Context, templateID, userID int64) er
 }
 
 // GetTemplate returns the template with given template id and user id
-func (s service) GetTemplate(c context.Context, templateID int64, userID int64) (template *entities.Template, err error) {
-	template, err = s.db.GetTemplate(templateID, userID)
+func (s service) GetTemplate(c context.Context, templateID int64, userID int64) (*entities.Template, error) {
+	template, err := s.db.GetTemplate(templateID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get template: %w", err)
 	}
 
-	resp, err := s.s3.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
-	})
+	template.HTMLPart, err = s.getHTMLPart(template.UserID, template.ID)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchKey:
-				return nil, ErrHTMLPartNotFound
-			case s3.ErrCodeInvalidObjectState:
-				return nil, ErrHTMLPartInvalidState
-			default:
-				return nil, fmt.Errorf("get object: %w", aerr)
-			}
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// getHTMLPart fetches the HTML part of the template from the templates bucket.
+func (s service) getHTMLPart(userID, templateID int64) (html string, err error) {
+	obj, err := s.blobs.Get(s.templatesBucket, templateKey(userID, templateID))
+	if err != nil {
+		switch {
+		case errors.Is(err, blobs.ErrNotFound):
+			return "", ErrHTMLPartNotFound
+		case errors.Is(err, blobs.ErrUnavailable):
+			return "", ErrHTMLPartInvalidState
+		default:
+			return "", fmt.Errorf("get html part: %w", err)
 		}
-		return nil, fmt.Errorf("get object: %w", err)
 	}
 
 	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
+		if cerr := obj.Body.Close(); cerr != nil {
 			err = cerr
 		}
 	}()
 
-	htmlBytes, err := ioutil.ReadAll(resp.Body)
+	htmlBytes, err := ioutil.ReadAll(obj.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read: %w", err)
+		return "", fmt.Errorf("read: %w", err)
 	}
 
-	template.HTMLPart = string(htmlBytes)
-
-	return
+	return string(htmlBytes), nil
 }
 
+// ParseTemplate fetches the template and compiles its parts along with the partials they include
+// and the layout the HTML part is wrapped in. When the text part is empty it is generated from the HTML part.
 func (s *service) ParseTemplate(c context.Context, templateID int64, userID int64) (*entities.CampaignTemplateData, error) {
 	template, err := s.GetTemplate(c, templateID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: get template: %w", err)
 	}
 
-	html, err := mustache.ParseString(template.HTMLPart)
+	engine, err := templateEngine(template)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: %w", err)
+	}
+
+	htmlPartials := s.newPartialResolver(userID, false, engine)
+	textPartials := s.newPartialResolver(userID, true, engine)
+
+	htmlSource := template.HTMLPart
+	textSource := template.TextPart
+	var layoutSource string
+	if template.LayoutID != nil {
+		layout, err := s.GetTemplate(c, *template.LayoutID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("campaign service: get layout: %w", err)
+		}
+		layoutSource = layout.HTMLPart
+	}
+
+	emptyText := strings.TrimSpace(textSource) == ""
+	if !template.DisableCSSInlining || emptyText {
+		// the style blocks and the text can be anywhere in the layout or the partials,
+		// so they are flattened into a single source which is processed as a whole.
+		flat, err := htmlPartials.flattenHTML(htmlSource, layoutSource)
+		if err != nil {
+			return nil, fmt.Errorf("campaign service: flatten html part: %w", err)
+		}
+
+		if emptyText {
+			textSource = HTMLToText(flat, LinksFootnotes)
+		}
+
+		if !template.DisableCSSInlining && hasStyleBlock(flat) {
+			htmlSource = inlineCSS(flat)
+			layoutSource = ""
+		}
+	}
+
+	html, err := htmlPartials.parse(htmlSource)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: parse html part: %w", err)
 	}
-	text, err := mustache.ParseString(template.TextPart)
+	text, err := textPartials.parse(textSource)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: parse text part: %w", err)
 	}
-	sub, err := mustache.ParseString(template.SubjectPart)
+	sub, err := textPartials.parse(template.SubjectPart)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: parse subject part: %w", err)
 	}
-	return &entities.CampaignTemplateData{
+
+	data := &entities.CampaignTemplateData{
 		Template:    template,
 		HTMLPart:    html,
 		SubjectPart: sub,
 		TextPart:    text,
+	}
+
+	if layoutSource != "" {
+		data.Layout, err = htmlPartials.parse(layoutSource)
+		if err != nil {
+			return nil, fmt.Errorf("campaign service: parse layout: %w", err)
+		}
+	}
+
+	return data, nil
+}
+
+// PreviewTemplate renders the parts of the template, wrapped in its layout and with the CSS inlined
+// as they are in the campaign emails, with the given data, e.g. the data of a sample data set.
+func (s *service) PreviewTemplate(c context.Context, templateID, userID int64, data map[string]string) (*entities.TemplatePreview, error) {
+	tmpl, err := s.ParseTemplate(c, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var html, subject, text strings.Builder
+	if tmpl.Layout != nil {
+		err = tmpl.HTMLPart.RenderInLayout(&html, tmpl.Layout, data)
+	} else {
+		err = tmpl.HTMLPart.Render(&html, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("render html part: %w", err)
+	}
+	err = tmpl.SubjectPart.Render(&subject, data)
+	if err != nil {
+		return nil, fmt.Errorf("render subject part: %w", err)
+	}
+	err = tmpl.TextPart.Render(&text, data)
+	if err != nil {
+		return nil, fmt.Errorf("render text part: %w", err)
+	}
+
+	return &entities.TemplatePreview{
+		SubjectPart: subject.String(),
+		HTMLPart:    html.String(),
+		TextPart:    text.String(),
 	}, nil
 }
 
+// GenerateTextPart converts the HTML part of the template, wrapped in its layout and
+// with the included partials, to a plain text part.
+func (s *service) GenerateTextPart(c context.Context, template *entities.Template, links string) (string, error) {
+	var layoutSource string
+	if template.LayoutID != nil {
+		layout, err := s.GetTemplate(c, *template.LayoutID, template.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrLayoutNotFound
+			}
+			return "", fmt.Errorf("get layout: %w", err)
+		}
+		layoutSource = layout.HTMLPart
+	}
+
+	engine, err := templateEngine(template)
+	if err != nil {
+		return "", err
+	}
+
+	r := s.newPartialResolver(template.UserID, false, engine)
+	r.visiting[template.Name] = true
+
+	flat, err := r.flattenHTML(template.HTMLPart, layoutSource)
+	if err != nil {
+		return "", fmt.Errorf("flatten html part: %w", err)
+	}
+
+	return HTMLToText(flat, links), nil
+}
+
 // templateKey generates template key
 func templateKey(userID, templateID int64) string {
 	return fmt.Sprintf("temnplates/%d/%d", userID, templateID)
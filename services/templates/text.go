@@ -0,0 +1,424 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Styles of the links in the generated text part.
+const (
+	// LinksFootnotes numbers the links and lists their urls at the end of the text.
+	LinksFootnotes = "footnotes"
+	// LinksInline writes the url of the link in parentheses after the link's text.
+	LinksInline = "inline"
+)
+
+// textWidth is the column at which the lines of the text part are wrapped.
+const textWidth = 78
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// skippedElements are the elements whose content is not part of the text.
+var skippedElements = map[string]bool{
+	"head": true, "title": true, "style": true, "script": true,
+}
+
+// blockElements start a new line.
+var blockElements = map[string]bool{
+	"div": true, "section": true, "article": true, "header": true, "footer": true, "center": true,
+	"tr": true, "td": true, "th": true, "dl": true, "dt": true, "dd": true, "form": true,
+}
+
+// paragraphElements are separated from the surrounding text by a blank line.
+var paragraphElements = map[string]bool{
+	"p": true, "table": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+type textList struct {
+	ordered bool
+	n       int
+}
+
+// textWriter builds the text part while walking the tokens of the HTML.
+type textWriter struct {
+	links string
+
+	out strings.Builder
+	buf strings.Builder
+
+	// prefix is written before the first line of the current block and
+	// indent before the following lines, e.g. for list items.
+	prefix string
+	indent string
+
+	lists    []textList
+	quotes   int
+	pre      int
+	skip     int
+	anchors  []textAnchor
+	urls     []string
+	urlIndex map[string]int
+}
+
+type textAnchor struct {
+	href  string
+	start int
+}
+
+// HTMLToText converts the HTML part to a plain text part. Links are written either inline or
+// as numbered footnotes, depending on the given links style, lists are written as bulleted or
+// numbered items and the lines are wrapped at 78 columns. The mustache tags in the HTML part
+// are kept so the text can be rendered like the HTML.
+func HTMLToText(source, links string) string {
+	w := &textWriter{
+		links:    links,
+		urlIndex: make(map[string]int),
+	}
+
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return w.finish()
+		case html.TextToken:
+			if w.skip > 0 {
+				continue
+			}
+			if w.pre > 0 {
+				w.flush()
+				w.out.WriteString(string(z.Text()))
+				continue
+			}
+			w.buf.WriteString(string(z.Text()))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if skippedElements[tok.Data] {
+				if tt == html.StartTagToken {
+					w.skip++
+				}
+				continue
+			}
+			if w.skip > 0 {
+				continue
+			}
+			w.start(tok, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			tok := z.Token()
+			if skippedElements[tok.Data] {
+				if w.skip > 0 {
+					w.skip--
+				}
+				continue
+			}
+			if w.skip > 0 {
+				continue
+			}
+			w.end(tok)
+		}
+	}
+}
+
+func (w *textWriter) start(tok html.Token, selfClosing bool) {
+	switch tok.Data {
+	case "br":
+		if strings.TrimSpace(w.buf.String()) == "" {
+			w.buf.Reset()
+			w.out.WriteString(w.quotePrefix() + "\n")
+			return
+		}
+		w.flush()
+	case "hr":
+		w.flush()
+		w.breakLines(2)
+		w.out.WriteString(w.quotePrefix() + strings.Repeat("-", textWidth-len(w.quotePrefix())) + "\n")
+		w.breakLines(2)
+	case "img":
+		if alt, ok := attr(tok, "alt"); ok && strings.TrimSpace(alt) != "" {
+			w.buf.WriteString(" " + alt + " ")
+		}
+	case "a":
+		if selfClosing {
+			return
+		}
+		href, _ := attr(tok, "href")
+		w.anchors = append(w.anchors, textAnchor{href: strings.TrimSpace(href), start: w.buf.Len()})
+	case "h1", "h2":
+		w.flush()
+		w.breakLines(2)
+	case "ul", "ol":
+		w.flush()
+		if len(w.lists) == 0 {
+			w.breakLines(2)
+		} else {
+			w.breakLines(1)
+		}
+		w.lists = append(w.lists, textList{ordered: tok.Data == "ol"})
+	case "li":
+		w.flush()
+		w.breakLines(1)
+		if len(w.lists) == 0 {
+			w.prefix, w.indent = "* ", "  "
+			return
+		}
+
+		l := &w.lists[len(w.lists)-1]
+		l.n++
+		nesting := strings.Repeat("  ", len(w.lists)-1)
+		bullet := "* "
+		if l.ordered {
+			bullet = strconv.Itoa(l.n) + ". "
+		}
+		w.prefix = nesting + bullet
+		w.indent = nesting + strings.Repeat(" ", len(bullet))
+	case "blockquote":
+		w.flush()
+		w.breakLines(2)
+		w.quotes++
+	case "pre":
+		w.flush()
+		w.breakLines(2)
+		w.pre++
+	default:
+		if paragraphElements[tok.Data] {
+			w.flush()
+			w.breakLines(2)
+		} else if blockElements[tok.Data] {
+			w.flush()
+			w.breakLines(1)
+		}
+	}
+}
+
+func (w *textWriter) end(tok html.Token) {
+	switch tok.Data {
+	case "a":
+		if len(w.anchors) == 0 {
+			return
+		}
+		a := w.anchors[len(w.anchors)-1]
+		w.anchors = w.anchors[:len(w.anchors)-1]
+		w.writeLink(a)
+	case "h1", "h2":
+		underline := "="
+		if tok.Data == "h2" {
+			underline = "-"
+		}
+
+		lines := w.wrap(strings.TrimSpace(w.buf.String()))
+		w.buf.Reset()
+		if len(lines) == 0 {
+			return
+		}
+
+		longest := 0
+		for _, line := range lines {
+			if n := utf8.RuneCountInString(line); n > longest {
+				longest = n
+			}
+		}
+		lines = append(lines, w.quotePrefix()+strings.Repeat(underline, longest-len(w.quotePrefix())))
+		w.out.WriteString(strings.Join(lines, "\n") + "\n")
+		w.breakLines(2)
+	case "ul", "ol":
+		w.flush()
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+		if len(w.lists) == 0 {
+			w.breakLines(2)
+		}
+	case "li":
+		w.flush()
+	case "blockquote":
+		w.flush()
+		if w.quotes > 0 {
+			w.quotes--
+		}
+		w.breakLines(2)
+	case "pre":
+		if w.pre > 0 {
+			w.pre--
+		}
+		w.breakLines(2)
+	default:
+		if paragraphElements[tok.Data] {
+			w.flush()
+			w.breakLines(2)
+		} else if blockElements[tok.Data] {
+			w.flush()
+			w.breakLines(1)
+		}
+	}
+}
+
+// writeLink writes the url of the link after the link's text, either inline or as a footnote.
+func (w *textWriter) writeLink(a textAnchor) {
+	if a.href == "" || strings.HasPrefix(a.href, "#") || strings.HasPrefix(strings.ToLower(a.href), "javascript:") {
+		return
+	}
+
+	text := ""
+	if a.start <= w.buf.Len() {
+		text = strings.TrimSpace(w.buf.String()[a.start:])
+	}
+
+	url := a.href
+	if strings.HasPrefix(strings.ToLower(url), "mailto:") {
+		url = url[len("mailto:"):]
+	}
+
+	switch {
+	case text == "":
+		w.buf.WriteString(" " + url + " ")
+	case text == url:
+		return
+	case w.links == LinksInline:
+		w.buf.WriteString(" (" + url + ")")
+	default:
+		n, ok := w.urlIndex[url]
+		if !ok {
+			w.urls = append(w.urls, url)
+			n = len(w.urls)
+			w.urlIndex[url] = n
+		}
+		w.buf.WriteString(fmt.Sprintf(" [%d]", n))
+	}
+}
+
+// flush writes the buffered inline text as a wrapped block.
+func (w *textWriter) flush() {
+	text := w.buf.String()
+	w.buf.Reset()
+
+	// the anchors which are still open continue in the next block.
+	for i := range w.anchors {
+		w.anchors[i].start = 0
+	}
+
+	lines := w.wrap(text)
+	if len(lines) == 0 {
+		return
+	}
+
+	w.out.WriteString(strings.Join(lines, "\n") + "\n")
+	w.prefix = ""
+}
+
+// wrap splits the text into lines no longer than the text width, with the
+// block and quote prefixes.
+func (w *textWriter) wrap(text string) []string {
+	words := splitWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	quote := w.quotePrefix()
+	first := quote + w.prefix
+	rest := quote + w.indent
+	if w.prefix == "" {
+		first = quote + w.indent
+	}
+
+	var (
+		lines []string
+		line  = first
+		empty = true
+	)
+	for _, word := range words {
+		if !empty && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > textWidth {
+			lines = append(lines, line)
+			line = rest
+			empty = true
+		}
+		if !empty {
+			line += " "
+		}
+		line += word
+		empty = false
+	}
+
+	return append(lines, line)
+}
+
+func (w *textWriter) quotePrefix() string {
+	return strings.Repeat("> ", w.quotes)
+}
+
+// breakLines ends the output with at least n line breaks, unless nothing is written yet.
+func (w *textWriter) breakLines(n int) {
+	if w.out.Len() == 0 {
+		return
+	}
+
+	s := w.out.String()
+	for i := len(s) - 1; i >= 0 && s[i] == '\n' && n > 0; i-- {
+		n--
+	}
+	for ; n > 0; n-- {
+		w.out.WriteString("\n")
+	}
+
+	if w.indent != "" && len(w.lists) == 0 {
+		w.indent = ""
+	}
+}
+
+// finish flushes the remaining text, cleans up the blank lines and appends the footnotes.
+func (w *textWriter) finish() string {
+	w.flush()
+
+	lines := strings.Split(w.out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	text := strings.Join(lines, "\n")
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+	text = strings.TrimSpace(text)
+
+	if len(w.urls) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n\nLinks:\n")
+		for i, url := range w.urls {
+			b.WriteString(fmt.Sprintf("[%d] %s\n", i+1, url))
+		}
+		text = strings.TrimRight(b.String(), "\n")
+	}
+
+	return text
+}
+
+// splitWords splits the text on whitespace, keeping the mustache tags
+// with spaces in them as a single word.
+func splitWords(text string) []string {
+	var (
+		words []string
+		tag   []string
+	)
+	for _, f := range strings.Fields(text) {
+		if len(tag) > 0 {
+			tag = append(tag, f)
+			if strings.Contains(f, "}}") {
+				words = append(words, strings.Join(tag, " "))
+				tag = nil
+			}
+			continue
+		}
+
+		if i := strings.LastIndex(f, "{{"); i >= 0 && !strings.Contains(f[i:], "}}") {
+			tag = append(tag, f)
+			continue
+		}
+
+		words = append(words, f)
+	}
+
+	return append(words, tag...)
+}
@@ -0,0 +1,144 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLToText(t *testing.T) {
+	words := strings.TrimSpace(strings.Repeat("word ", 40))
+	line := strings.TrimSpace(strings.Repeat("word ", 15))
+
+	tests := []struct {
+		name   string
+		source string
+		links  string
+		expect string
+	}{
+		{
+			name:   "wrapped at 78 columns",
+			source: "<p>" + words + "</p>",
+			expect: line + "\n" + line + "\n" + strings.TrimSpace(strings.Repeat("word ", 10)),
+		},
+		{
+			name:   "paragraphs and line breaks",
+			source: "<p>first<br>line</p><p>second</p><div>block</div>",
+			expect: "first\nline\n\nsecond\n\nblock",
+		},
+		{
+			name:   "head, style and script are skipped",
+			source: "<html><head><title>T</title><style>p { color: red }</style></head><body><script>x()</script><p>hi</p></body></html>",
+			expect: "hi",
+		},
+		{
+			name:   "headings are underlined",
+			source: "<h1>Title</h1><h2>Sub title</h2><h3>Section</h3><p>text</p>",
+			expect: "Title\n=====\n\nSub title\n---------\n\nSection\n\ntext",
+		},
+		{
+			name:   "bulleted list",
+			source: "<p>before</p><ul><li>one</li><li>two</li></ul><p>after</p>",
+			expect: "before\n\n* one\n* two\n\nafter",
+		},
+		{
+			name:   "ordered list with a nested list",
+			source: "<ol><li>one</li><li>two<ul><li>a</li><li>b</li></ul></li><li>three</li></ol>",
+			expect: "1. one\n2. two\n  * a\n  * b\n3. three",
+		},
+		{
+			name:   "nested ordered list is numbered from one",
+			source: "<ol><li>one<ol><li>a</li><li>b</li></ol></li><li>two</li></ol>",
+			expect: "1. one\n  1. a\n  2. b\n2. two",
+		},
+		{
+			name:   "wrapped list item is indented",
+			source: "<ul><li>" + words + "</li></ul>",
+			expect: "* " + line + "\n  " + line + "\n  " + strings.TrimSpace(strings.Repeat("word ", 10)),
+		},
+		{
+			name:   "blockquotes are prefixed",
+			source: "<p>before</p><blockquote><p>quoted</p><blockquote>nested</blockquote></blockquote><p>after</p>",
+			expect: "before\n\n> quoted\n\n> > nested\n\nafter",
+		},
+		{
+			name:   "horizontal rule",
+			source: "<p>a</p><hr><p>b</p>",
+			expect: "a\n\n" + strings.Repeat("-", textWidth) + "\n\nb",
+		},
+		{
+			name:   "preformatted text is kept",
+			source: "<pre>  keep\n  this</pre>",
+			expect: "keep\n  this",
+		},
+		{
+			name:   "image alt text",
+			source: `<p><img src="logo.png" alt="Logo"> <img src="spacer.gif"></p>`,
+			expect: "Logo",
+		},
+		{
+			name:   "footnotes are deduplicated",
+			source: `<p><a href="https://a.com">A</a> and <a href="https://b.com">B</a> and <a href="https://a.com">again</a></p>`,
+			links:  LinksFootnotes,
+			expect: "A [1] and B [2] and again [1]\n\nLinks:\n[1] https://a.com\n[2] https://b.com",
+		},
+		{
+			name:   "inline links",
+			source: `<p><a href="https://a.com">A</a> and <a href="https://b.com">B</a></p>`,
+			links:  LinksInline,
+			expect: "A (https://a.com) and B (https://b.com)",
+		},
+		{
+			name:   "link text which is the url",
+			source: `<p><a href="https://a.com">https://a.com</a> <a href="https://c.com"></a></p>`,
+			links:  LinksInline,
+			expect: "https://a.com https://c.com",
+		},
+		{
+			name:   "mailto is stripped",
+			source: `<p><a href="mailto:jo@example.com">Email us</a> or <a href="mailto:jo@example.com">jo@example.com</a></p>`,
+			links:  LinksFootnotes,
+			expect: "Email us [1] or jo@example.com\n\nLinks:\n[1] jo@example.com",
+		},
+		{
+			name:   "anchors and javascript links are dropped",
+			source: `<p><a href="#top">top</a> <a href="javascript:void(0)">js</a></p>`,
+			links:  LinksFootnotes,
+			expect: "top js",
+		},
+		{
+			name:   "mustache tags with spaces are not wrapped",
+			source: `<p>Hi {{ name }}, ` + strings.Repeat("x", 60) + ` {{# vip }}vip{{/ vip }}</p>`,
+			expect: "Hi {{ name }}, " + strings.Repeat("x", 60) + "\n{{# vip }}vip{{/ vip }}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := HTMLToText(tt.source, tt.links)
+			assert.Equal(t, tt.expect, text)
+			for _, l := range strings.Split(text, "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(l), textWidth, l)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{"", nil},
+		{" a  b\n c ", []string{"a", "b", "c"}},
+		{"a {{ b c }} d", []string{"a", "{{ b c }}", "d"}},
+		{"x{{ b }}y {{e}}", []string{"x{{ b }}y", "{{e}}"}},
+		{"{{ unclosed tag", []string{"{{", "unclosed", "tag"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, splitWords(tt.text), tt.text)
+	}
+}
@@ -0,0 +1,52 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetTemplateVariables lists the variables used in the template parts along with the number of
+// active subscribers whose metadata does not contain each of them, and the types of the custom
+// fields of the variables.
+func (s *service) GetTemplateVariables(c context.Context, template *entities.Template) (*entities.TemplateVariables, error) {
+	vars, err := template.Variables()
+	if err != nil {
+		return nil, fmt.Errorf("get variables: %w", err)
+	}
+
+	fields, err := s.db.GetCustomFields(template.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get custom fields: %w", err)
+	}
+
+	res := &entities.TemplateVariables{
+		Collection: make([]entities.TemplateVariable, 0, len(vars)),
+	}
+	for _, v := range vars {
+		if f := fields.Find(v.Name); f != nil && !v.BuiltIn {
+			v.FieldType = f.Type
+		}
+		res.Collection = append(res.Collection, v)
+	}
+
+	keys := make([]string, 0, len(res.Collection))
+	for _, v := range res.Collection {
+		if v.Name != entities.TagUnsubscribeUrl && v.Name != entities.TagPreferencesURL {
+			keys = append(keys, v.Name)
+		}
+	}
+
+	total, missing, err := s.db.GetTotalMissingMetadata(template.UserID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("get total missing metadata: %w", err)
+	}
+
+	res.TotalSubscribers = total
+	for i, v := range res.Collection {
+		res.Collection[i].MissingSubscribers = missing[v.Name]
+	}
+
+	return res, nil
+}
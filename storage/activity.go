@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// activitySources are the sources of the campaign activities of a subscriber, the activities are
+// found by the user id and the email of the subscriber, except for the send logs which are found
+// by the subscriber id. The numeric ids are selected as num_id and the ksuids as str_id, so the
+// ids of each type are of the same kind.
+var activitySources = []struct {
+	typ    string
+	table  string
+	numID  string
+	strID  string
+	status string
+	detail string
+	key    string
+}{
+	{entities.ActivityTypeSend, "sends", "id", "''", "NULL", "message_id", "destination"},
+	{entities.ActivityTypeSendLog, "send_logs", "0", "id", "status", "description", "subscriber_id"},
+	{entities.ActivityTypeDelivery, "deliveries", "id", "''", "NULL", "smtp_response", "recipient"},
+	{entities.ActivityTypeOpen, "opens", "id", "''", "NULL", "user_agent", "recipient"},
+	{entities.ActivityTypeClick, "clicks", "id", "''", "NULL", "link", "recipient"},
+	{entities.ActivityTypeBounce, "bounces", "id", "''", "type", "diagnostic_code", "recipient"},
+	{entities.ActivityTypeComplaint, "complaints", "id", "''", "type", "NULL", "recipient"},
+}
+
+// activityRow is a row of the activities query.
+type activityRow struct {
+	NumID        int64
+	StrID        string
+	Type         string
+	CampaignID   *int64
+	CampaignName *string
+	Status       *string
+	Detail       *string
+	Data         entities.JSON
+	CreatedAt    activityTime
+}
+
+// activityTime scans the time of the activities. SQLite doesn't keep the type of the columns of
+// the union, so the times are returned as text.
+type activityTime struct {
+	time.Time
+}
+
+// Scan implements the Scanner interface.
+func (t *activityTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("activity: cannot scan type %T into time: %v", value, value)
+}
+
+func (t *activityTime) parse(s string) error {
+	s = strings.TrimSuffix(s, "Z")
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if parsed, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("activity: cannot parse time: %s", s)
+}
+
+// GetSubscriberActivity fetches the activity timeline of the subscriber, the newest activities first,
+// and populates the pagination obj. The timeline can be narrowed down to the given types, and it
+// starts after the given cursor when it's set.
+func (db *store) GetSubscriberActivity(
+	s *entities.Subscriber,
+	types []string,
+	after *entities.ActivityCursor,
+	p *PaginationCursor,
+) error {
+	p.SetResource("activity")
+
+	union, args := activityQuery(s, types)
+
+	var total struct{ Total int64 }
+	err := db.Raw("SELECT COUNT(*) AS total FROM ("+union+") a", args...).Scan(&total).Error
+	if err != nil {
+		return fmt.Errorf("activity: count: %w", err)
+	}
+	p.SetTotal(total.Total)
+
+	query := "SELECT a.*, campaigns.name AS campaign_name FROM (" + union + ") a " +
+		"LEFT JOIN campaigns ON campaigns.id = a.campaign_id AND campaigns.user_id = ?"
+	args = append(args, s.UserID)
+	if after != nil {
+		query += ` WHERE (a.created_at < ? OR (a.created_at = ? AND (a.type < ? OR (a.type = ? AND
+			(a.num_id < ? OR (a.num_id = ? AND a.str_id < ?))))))`
+		args = append(args,
+			after.CreatedAt, after.CreatedAt,
+			after.Type, after.Type,
+			after.NumID, after.NumID, after.StrID,
+		)
+	}
+	query += " ORDER BY a.created_at DESC, a.type DESC, a.num_id DESC, a.str_id DESC LIMIT ?"
+	// one more row is fetched to find out whether there is a next page
+	args = append(args, p.PerPage+1)
+
+	var rows []activityRow
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return fmt.Errorf("activity: find: %w", err)
+	}
+
+	hasNext := int64(len(rows)) > p.PerPage
+	if hasNext {
+		rows = rows[:p.PerPage]
+	}
+
+	activities := make([]entities.Activity, len(rows))
+	for i, r := range rows {
+		id := r.StrID
+		if id == "" {
+			id = fmt.Sprint(r.NumID)
+		}
+		activities[i] = entities.Activity{
+			ID:           id,
+			Type:         r.Type,
+			CampaignID:   r.CampaignID,
+			CampaignName: r.CampaignName,
+			Status:       r.Status,
+			Detail:       r.Detail,
+			Data:         r.Data,
+			CreatedAt:    r.CreatedAt.Time,
+			Cursor: entities.ActivityCursor{
+				CreatedAt: r.CreatedAt.Time,
+				Type:      r.Type,
+				NumID:     r.NumID,
+				StrID:     r.StrID,
+			},
+		}
+	}
+	p.SetCollection(&activities)
+
+	next := ""
+	if hasNext {
+		next = activities[len(activities)-1].Cursor.Encode()
+	}
+	p.PopulateLinks("", next)
+
+	return nil
+}
+
+// activityQuery returns the union of the queries of the subscriber's activities of the given types,
+// all types are included when no types are given.
+func activityQuery(s *entities.Subscriber, types []string) (string, []interface{}) {
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	var (
+		queries []string
+		args    []interface{}
+	)
+	for _, src := range activitySources {
+		if len(types) > 0 && !wanted[src.typ] {
+			continue
+		}
+		queries = append(queries, fmt.Sprintf(
+			`SELECT %s AS num_id, %s AS str_id, '%s' AS type, campaign_id, %s AS status, %s AS detail,
+			NULL AS data, created_at FROM %s WHERE user_id = ? AND %s = ?`,
+			src.numID, src.strID, src.typ, src.status, src.detail, src.table, src.key,
+		))
+		if src.key == "subscriber_id" {
+			args = append(args, s.UserID, s.ID)
+		} else {
+			args = append(args, s.UserID, s.Email)
+		}
+	}
+
+	var eventTypes []string
+	for _, t := range types {
+		if !isCampaignActivity(t) {
+			eventTypes = append(eventTypes, t)
+		}
+	}
+	if len(types) == 0 || len(eventTypes) > 0 {
+		q := `SELECT 0 AS num_id, id AS str_id, event_type AS type, NULL AS campaign_id, NULL AS status,
+			NULL AS detail, data, created_at FROM subscriber_events WHERE user_id = ? AND subscriber_email = ?`
+		args = append(args, s.UserID, s.Email)
+		if len(eventTypes) > 0 {
+			q += " AND event_type IN (?)"
+			args = append(args, eventTypes)
+		}
+		queries = append(queries, q)
+	}
+
+	return strings.Join(queries, " UNION ALL "), args
+}
+
+func isCampaignActivity(t string) bool {
+	for _, src := range activitySources {
+		if src.typ == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSubscriberActivity(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	campaign := &entities.Campaign{Name: "newsletter", UserID: 1, TemplateID: 1, Status: entities.StatusDraft}
+	err := store.CreateCampaign(campaign)
+	assert.Nil(t, err)
+
+	sub := &entities.Subscriber{Name: "Jane", Email: "jane@example.com", UserID: 1, Active: true}
+	err = store.CreateSubscriber(sub)
+	assert.Nil(t, err)
+
+	// activities of other subscribers and users
+	err = store.CreateSubscriber(&entities.Subscriber{Name: "John", Email: "john@example.com", UserID: 1, Active: true})
+	assert.Nil(t, err)
+	err = store.CreateOpen(&entities.Open{UserID: 2, CampaignID: campaign.ID, Recipient: sub.Email, CreatedAt: time.Now()})
+	assert.Nil(t, err)
+
+	// the campaign activities are after the subscriber events
+	start := time.Now().UTC().Add(time.Minute)
+	at := func(i int) time.Time {
+		return start.Add(time.Duration(i) * time.Second)
+	}
+	msgID := "message-1"
+
+	assert.Nil(t, store.CreateSendLog(&entities.SendLog{
+		ID:           ksuid.New(),
+		UserID:       1,
+		EventID:      ksuid.New(),
+		SubscriberID: sub.ID,
+		CampaignID:   campaign.ID,
+		Status:       entities.SendLogStatusSuccessful,
+		Description:  entities.SendLogDescriptionOnSuccessful,
+		MessageID:    &msgID,
+		CreatedAt:    at(1),
+	}))
+	assert.Nil(t, store.CreateSend(&entities.Send{UserID: 1, CampaignID: campaign.ID, MessageID: msgID, Destination: sub.Email, CreatedAt: at(2)}))
+	assert.Nil(t, store.CreateDelivery(&entities.Delivery{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, SMTPResponse: "250 OK", CreatedAt: at(3)}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, CreatedAt: at(4)}))
+	assert.Nil(t, store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, Link: "https://example.com", CreatedAt: at(5)}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, CreatedAt: at(5)}))
+	assert.Nil(t, store.CreateComplaint(&entities.Complaint{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, Type: "abuse", CreatedAt: at(6)}))
+	assert.Nil(t, store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, Type: "Permanent", CreatedAt: at(6)}))
+
+	err = store.UpdateSubscriberPreferences(sub, []entities.SubscriberEvent{
+		{EventType: entities.SubscriberEventTypeUpdated, Data: entities.JSON(`{"name":{"old":"Jane","new":"Jane"}}`)},
+	})
+	assert.Nil(t, err)
+
+	p := NewPaginationCursor("/api/subscribers/1/activity", 100)
+	err = store.GetSubscriberActivity(sub, nil, nil, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), p.Total)
+	assert.Nil(t, p.Links.Next)
+
+	activities := *p.Collection.(*[]entities.Activity)
+	assert.Len(t, activities, 10)
+
+	var types []string
+	for _, a := range activities {
+		types = append(types, a.Type)
+	}
+	// the newest activities first, the activities of the same time are ordered by their type
+	assert.Equal(t, []string{
+		entities.ActivityTypeComplaint,
+		entities.ActivityTypeBounce,
+		entities.ActivityTypeOpen,
+		entities.ActivityTypeClick,
+		entities.ActivityTypeOpen,
+		entities.ActivityTypeDelivery,
+		entities.ActivityTypeSend,
+		entities.ActivityTypeSendLog,
+		"updated",
+		"created",
+	}, types)
+
+	assert.Equal(t, "newsletter", *activities[0].CampaignName)
+	assert.Equal(t, "abuse", *activities[0].Status)
+	assert.Equal(t, "https://example.com", *activities[3].Detail)
+	assert.Equal(t, entities.SendLogStatusSuccessful, *activities[7].Status)
+	assert.Nil(t, activities[8].CampaignID)
+	assert.JSONEq(t, `{"name":{"old":"Jane","new":"Jane"}}`, string(activities[8].Data))
+
+	// test paginating through the timeline
+	var (
+		seen  []string
+		after *entities.ActivityCursor
+	)
+	for {
+		p = NewPaginationCursor("/api/subscribers/1/activity", 3)
+		err = store.GetSubscriberActivity(sub, nil, after, p)
+		assert.Nil(t, err)
+
+		for _, a := range *p.Collection.(*[]entities.Activity) {
+			seen = append(seen, a.Type+":"+a.ID)
+		}
+		if p.Links.Next == nil {
+			break
+		}
+
+		page := *p.Collection.(*[]entities.Activity)
+		token := page[len(page)-1].Cursor.Encode()
+		assert.Contains(t, *p.Links.Next, "starting_after="+token)
+		after, err = entities.DecodeActivityCursor(token)
+		assert.Nil(t, err)
+	}
+	assert.Len(t, seen, 10)
+	var all []string
+	for _, a := range activities {
+		all = append(all, a.Type+":"+a.ID)
+	}
+	assert.Equal(t, all, seen)
+
+	// test filtering by type
+	p = NewPaginationCursor("/api/subscribers/1/activity", 100)
+	err = store.GetSubscriberActivity(sub, []string{entities.ActivityTypeOpen, "created"}, nil, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), p.Total)
+
+	p = NewPaginationCursor("/api/subscribers/1/activity", 100)
+	err = store.GetSubscriberActivity(sub, []string{entities.ActivityTypeSendLog}, nil, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+	assert.Equal(t, entities.ActivityTypeSendLog, (*p.Collection.(*[]entities.Activity))[0].Type)
+}
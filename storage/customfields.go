@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetCustomFields returns all custom fields of the user ordered by their keys.
+func (db *store) GetCustomFields(userID int64) (entities.CustomFields, error) {
+	var fields entities.CustomFields
+	err := db.Where("user_id = ?", userID).Order("field_key").Find(&fields).Error
+	return fields, err
+}
+
+// GetCustomField returns the custom field by the given id and user id.
+func (db *store) GetCustomField(id, userID int64) (*entities.CustomField, error) {
+	var f = new(entities.CustomField)
+	err := db.Where("user_id = ? and id = ?", userID, id).Find(f).Error
+	return f, err
+}
+
+// GetCustomFieldByKey returns the custom field by the given key and user id.
+func (db *store) GetCustomFieldByKey(key string, userID int64) (*entities.CustomField, error) {
+	var f = new(entities.CustomField)
+	err := db.Where("user_id = ? and field_key = ?", userID, key).Find(f).Error
+	return f, err
+}
+
+// CreateCustomField creates a new custom field in the database.
+func (db *store) CreateCustomField(f *entities.CustomField) error {
+	return db.Create(f).Error
+}
+
+// UpdateCustomField edits an existing custom field in the database.
+func (db *store) UpdateCustomField(f *entities.CustomField) error {
+	return db.Where("id = ? and user_id = ?", f.ID, f.UserID).Save(f).Error
+}
+
+// DeleteCustomField deletes the custom field with the given id and user id from the database.
+// The values of the field are kept in the metadata of the subscribers.
+func (db *store) DeleteCustomField(id, userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.CustomField{Model: entities.Model{ID: id}}).Error
+}
+
+// GetMetadataValueCounts returns the distinct values of the metadata key of the subscribers of the
+// user, along with the number of the subscribers which have each of them.
+func (db *store) GetMetadataValueCounts(userID int64, key string) (map[string]int64, error) {
+	path := metadataPath(key)
+	rows, err := db.Model(&entities.Subscriber{}).
+		Select(metadataValue(db.Dialect().GetName())+", COUNT(*)", path).
+		Where("user_id = ? AND JSON_EXTRACT(metadata, ?) IS NOT NULL", userID, path).
+		Group("1").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("custom field store: count metadata values: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var (
+			v string
+			n int64
+		)
+		if err := rows.Scan(&v, &n); err != nil {
+			return nil, fmt.Errorf("custom field store: scan metadata values: %w", err)
+		}
+		counts[v] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("custom field store: count metadata values: %w", err)
+	}
+	return counts, nil
+}
+
+// DeleteAllCustomFieldsForUser deletes all custom fields of the user.
+func (db *store) DeleteAllCustomFieldsForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.CustomField{}).Error
+}
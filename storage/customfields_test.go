@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestCustomFields(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	fields, err := store.GetCustomFields(1)
+	assert.Nil(t, err)
+	assert.Empty(t, fields)
+
+	age := &entities.CustomField{UserID: 1, Key: "age", Label: "Age", Type: entities.CustomFieldTypeNumber, Required: true}
+	assert.Nil(t, store.CreateCustomField(age))
+	plan := &entities.CustomField{
+		UserID:       1,
+		Key:          "plan",
+		Label:        "Plan",
+		Type:         entities.CustomFieldTypeEnum,
+		DefaultValue: "free",
+		OptionsJSON:  entities.JSON(`["free","pro"]`),
+	}
+	assert.Nil(t, store.CreateCustomField(plan))
+	assert.Nil(t, store.CreateCustomField(&entities.CustomField{UserID: 2, Key: "age", Label: "Age", Type: entities.CustomFieldTypeString}))
+
+	// the keys are unique per user
+	assert.NotNil(t, store.CreateCustomField(&entities.CustomField{UserID: 1, Key: "age", Label: "Age", Type: entities.CustomFieldTypeString}))
+
+	// test get custom fields
+	fields, err = store.GetCustomFields(1)
+	assert.Nil(t, err)
+	assert.Len(t, fields, 2)
+	assert.Equal(t, "age", fields[0].Key)
+	assert.Equal(t, "plan", fields[1].Key)
+
+	opts, err := fields[1].GetOptions()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"free", "pro"}, opts)
+
+	// test get custom field
+	f, err := store.GetCustomField(age.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.CustomFieldTypeNumber, f.Type)
+	assert.True(t, f.Required)
+
+	_, err = store.GetCustomField(age.ID, 2)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	f, err = store.GetCustomFieldByKey("plan", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, plan.ID, f.ID)
+
+	// test update custom field
+	f.Label = "Pricing plan"
+	f.OptionsJSON = entities.JSON(`["free","pro","enterprise"]`)
+	assert.Nil(t, store.UpdateCustomField(f))
+
+	f, err = store.GetCustomField(plan.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Pricing plan", f.Label)
+	opts, err = f.GetOptions()
+	assert.Nil(t, err)
+	assert.Len(t, opts, 3)
+
+	// test delete custom field
+	assert.Nil(t, store.DeleteCustomField(age.ID, 1))
+	_, err = store.GetCustomField(age.ID, 1)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	assert.Nil(t, store.DeleteAllCustomFieldsForUser(1))
+	fields, err = store.GetCustomFields(1)
+	assert.Nil(t, err)
+	assert.Empty(t, fields)
+
+	fields, err = store.GetCustomFields(2)
+	assert.Nil(t, err)
+	assert.Len(t, fields, 1)
+
+	// test the counts of the metadata values
+	for i, meta := range []string{`{"age":"30"}`, `{"age":"twenty"}`, `{"age":"30","plan":"pro"}`, `{"plan":"free"}`} {
+		assert.Nil(t, store.CreateSubscriber(&entities.Subscriber{
+			UserID:   1,
+			Email:    fmt.Sprintf("sub%d@example.com", i),
+			MetaJSON: entities.JSON(meta),
+		}))
+	}
+	counts, err := store.GetMetadataValueCounts(1, "age")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"30": 2, "twenty": 1}, counts)
+
+	counts, err = store.GetMetadataValueCounts(2, "age")
+	assert.Nil(t, err)
+	assert.Empty(t, counts)
+}
@@ -93,6 +93,21 @@ func setupDb(driver, config string, fresh bool, db *gorm.DB) error {
 		return err
 	}
 
+	// The subscribers created before the emails were normalized are only reported, they're
+	// normalized by the fixtures normalize-emails command.
+	if !fresh {
+		n, duplicates, err := From(db).NormalizeSubscriberEmails(true)
+		if err != nil {
+			return err
+		}
+		if n > 0 || len(duplicates) > 0 {
+			log.WithFields(log.Fields{
+				"emails_to_normalize": n,
+				"duplicates":          len(duplicates),
+			}).Warn("Found subscribers whose emails are not normalized, run the fixtures normalize-emails command.")
+		}
+	}
+
 	// If the database didn't exist, initialize it with an admin user
 	if fresh {
 		err = initDb(config, db)
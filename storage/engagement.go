@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// SeekSubscribersToScore fetches the subscribers of all users with id greater than the given one,
+// whose engagement wasn't scored since the given time, used for scoring them in chunks.
+func (db *store) SeekSubscribersToScore(scoredBefore time.Time, nextID, limit int64) ([]entities.Subscriber, error) {
+	var s []entities.Subscriber
+	err := db.Where("id > ? and (engagement_scored_at IS NULL or engagement_scored_at < ?)", nextID, scoredBefore).
+		Order("id").
+		Limit(limit).
+		Find(&s).Error
+	return s, err
+}
+
+// GetEngagementStats returns the engagement stats of the user's subscribers by their emails, from the
+// campaigns which were sent since the given time. The subscribers who didn't receive, open or click
+// any of the campaigns are left out.
+func (db *store) GetEngagementStats(userID int64, emails []string, since time.Time) (map[string]*entities.EngagementStats, error) {
+	stats := make(map[string]*entities.EngagementStats, len(emails))
+	if len(emails) == 0 {
+		return stats, nil
+	}
+
+	get := func(email string) *entities.EngagementStats {
+		st, ok := stats[email]
+		if !ok {
+			st = &entities.EngagementStats{}
+			stats[email] = st
+		}
+		return st
+	}
+
+	rows, err := db.Table("sends").
+		Select("destination, COUNT(DISTINCT campaign_id)").
+		Where("user_id = ? and destination in (?) and created_at >= ?", userID, emails, since).
+		Group("destination").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: count sends: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			email string
+			n     int64
+		)
+		if err := rows.Scan(&email, &n); err != nil {
+			return nil, fmt.Errorf("subscription store: scan sends: %w", err)
+		}
+		get(email).Received = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("subscription store: count sends: %w", err)
+	}
+
+	for _, table := range []string{"opens", "clicks"} {
+		rows, err := db.Table(table).
+			Select("recipient, COUNT(DISTINCT campaign_id), MAX(created_at)").
+			Where("user_id = ? and recipient in (?) and created_at >= ?", userID, emails, since).
+			Group("recipient").
+			Rows()
+		if err != nil {
+			return nil, fmt.Errorf("subscription store: count %s: %w", table, err)
+		}
+		for rows.Next() {
+			var (
+				email string
+				n     int64
+				last  activityTime
+			)
+			if err := rows.Scan(&email, &n, &last); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("subscription store: scan %s: %w", table, err)
+			}
+
+			st := get(email)
+			if table == "opens" {
+				st.Opened = n
+			} else {
+				st.Clicked = n
+			}
+			if st.LastEngagedAt == nil || last.After(*st.LastEngagedAt) {
+				t := last.Time
+				st.LastEngagedAt = &t
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("subscription store: count %s: %w", table, err)
+		}
+	}
+
+	return stats, nil
+}
+
+// UpdateSubscribersEngagement stores the engagement scores and the times of the last engagement of the
+// subscribers, and marks them as scored at the given time, in a single transaction.
+func (db *store) UpdateSubscribersEngagement(subs []entities.Subscriber, scoredAt time.Time) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, s := range subs {
+		err := tx.Model(&entities.Subscriber{}).
+			Where("id = ? and user_id = ?", s.ID, s.UserID).
+			UpdateColumns(map[string]interface{}{
+				"engagement_score":     s.EngagementScore,
+				"last_engaged_at":      s.LastEngagedAt,
+				"engagement_scored_at": scoredAt,
+			}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: update engagement of subscriber %d: %w", s.ID, err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetUnengagedCampaigns returns the number of the campaigns which were sent to the user's subscribers
+// since they last opened or clicked one, or ever when they never did, by the ids of the subscribers.
+func (db *store) GetUnengagedCampaigns(userID int64, ids []int64) (map[int64]int64, error) {
+	counts := make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	rows, err := db.Table("sends").
+		Select("subscribers.id, COUNT(DISTINCT sends.campaign_id)").
+		Joins("JOIN subscribers ON subscribers.user_id = sends.user_id AND subscribers.email = sends.destination").
+		Where("sends.user_id = ? and subscribers.id in (?)", userID, ids).
+		Where("subscribers.last_engaged_at IS NULL or sends.created_at > subscribers.last_engaged_at").
+		Group("subscribers.id").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: count unengaged campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("subscription store: scan unengaged campaigns: %w", err)
+		}
+		counts[id] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// SunsetSubscribers deactivates the subscribers and adds the sunset events, whose subscriber ids
+// are the indexes of the subscribers, in a single transaction.
+func (db *store) SunsetSubscribers(subs []entities.Subscriber, events []entities.SubscriberEvent) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i, s := range subs {
+		err := tx.Model(&entities.Subscriber{}).
+			Where("id = ? and user_id = ?", s.ID, s.UserID).
+			Update("active", false).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: deactivate subscriber %d: %w", s.ID, err)
+		}
+
+		e := events[i]
+		e.ID = ksuid.New()
+		e.UserID = s.UserID
+		e.SubscriberEmail = s.Email
+		e.EventType = entities.SubscriberEventTypeSunset
+		if err := tx.Create(&e).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: add subscriber event (sunset): %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetSunsetPolicy returns the sunset policy of the user.
+func (db *store) GetSunsetPolicy(userID int64) (*entities.SunsetPolicy, error) {
+	var p = new(entities.SunsetPolicy)
+	err := db.Where("user_id = ?", userID).Find(p).Error
+	return p, err
+}
+
+// SaveSunsetPolicy creates or updates the sunset policy of the user.
+func (db *store) SaveSunsetPolicy(p *entities.SunsetPolicy) error {
+	return db.Save(p).Error
+}
+
+// DeleteSunsetPolicy deletes the sunset policy of the user.
+func (db *store) DeleteSunsetPolicy(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.SunsetPolicy{}).Error
+}
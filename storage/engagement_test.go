@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestEngagement(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(days int) time.Time {
+		return now.Add(time.Duration(days) * 24 * time.Hour)
+	}
+
+	var campaigns []*entities.Campaign
+	for _, name := range []string{"first", "second", "third"} {
+		c := &entities.Campaign{Name: name, UserID: 1, TemplateID: 1, Status: entities.StatusSent}
+		assert.Nil(t, store.CreateCampaign(c))
+		campaigns = append(campaigns, c)
+	}
+
+	jane := &entities.Subscriber{Name: "Jane", Email: "jane@example.com", UserID: 1, Active: true}
+	assert.Nil(t, store.CreateSubscriber(jane))
+	john := &entities.Subscriber{Name: "John", Email: "john@example.com", UserID: 1, Active: true}
+	assert.Nil(t, store.CreateSubscriber(john))
+	other := &entities.Subscriber{Name: "Jim", Email: "jim@example.com", UserID: 2, Active: true}
+	assert.Nil(t, store.CreateSubscriber(other))
+
+	for i, c := range campaigns {
+		for _, s := range []*entities.Subscriber{jane, john} {
+			assert.Nil(t, store.CreateSend(&entities.Send{UserID: 1, CampaignID: c.ID, MessageID: s.Email + c.Name, Destination: s.Email, CreatedAt: at(2*i - 6)}))
+		}
+	}
+	// an old campaign out of the window
+	assert.Nil(t, store.CreateSend(&entities.Send{UserID: 1, CampaignID: 99, MessageID: "old", Destination: jane.Email, CreatedAt: at(-200)}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaigns[0].ID, Recipient: jane.Email, CreatedAt: at(-5)}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaigns[0].ID, Recipient: jane.Email, CreatedAt: at(-4)}))
+	assert.Nil(t, store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaigns[1].ID, Recipient: jane.Email, Link: "https://example.com", CreatedAt: at(-3)}))
+
+	// test seek subscribers to score
+	subs, err := store.SeekSubscribersToScore(now, 0, 2)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 2)
+	assert.Equal(t, jane.ID, subs[0].ID)
+
+	subs, err = store.SeekSubscribersToScore(now, subs[1].ID, 2)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, other.ID, subs[0].ID)
+
+	// test get engagement stats
+	stats, err := store.GetEngagementStats(1, []string{jane.Email, john.Email, "nobody@example.com"}, at(-180))
+	assert.Nil(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, int64(3), stats[jane.Email].Received)
+	assert.Equal(t, int64(1), stats[jane.Email].Opened)
+	assert.Equal(t, int64(1), stats[jane.Email].Clicked)
+	assert.True(t, at(-3).Equal(*stats[jane.Email].LastEngagedAt))
+	assert.Equal(t, int64(3), stats[john.Email].Received)
+	assert.Nil(t, stats[john.Email].LastEngagedAt)
+
+	stats, err = store.GetEngagementStats(2, []string{jane.Email}, at(-180))
+	assert.Nil(t, err)
+	assert.Empty(t, stats)
+
+	// test update subscribers engagement
+	last := at(-3)
+	jane.EngagementScore = 72
+	jane.LastEngagedAt = &last
+	err = store.UpdateSubscribersEngagement([]entities.Subscriber{*jane, *john}, now)
+	assert.Nil(t, err)
+
+	sub, err := store.GetSubscriber(jane.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(72), sub.EngagementScore)
+	assert.True(t, last.Equal(*sub.LastEngagedAt))
+
+	subs, err = store.SeekSubscribersToScore(now, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, other.ID, subs[0].ID)
+
+	// test get unengaged campaigns
+	counts, err := store.GetUnengagedCampaigns(1, []int64{jane.ID, john.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), counts[jane.ID])
+	assert.Equal(t, int64(3), counts[john.ID])
+
+	// test the engagement filters
+	min, max := int64(50), int64(80)
+	tests := []struct {
+		name   string
+		filter *entities.SubscriberFilter
+		total  int64
+	}{
+		{"min score", &entities.SubscriberFilter{MinEngagementScore: &min}, 1},
+		{"max score", &entities.SubscriberFilter{MaxEngagementScore: &min}, 1},
+		{"score range", &entities.SubscriberFilter{MinEngagementScore: &min, MaxEngagementScore: &max}, 1},
+		{"last engaged after", &entities.SubscriberFilter{LastEngagedAfter: &now}, 0},
+		{"last engaged before", &entities.SubscriberFilter{LastEngagedBefore: &now}, 2},
+		{"never engaged", &entities.SubscriberFilter{LastEngagedBefore: &last}, 1},
+	}
+	for _, tc := range tests {
+		p := NewPaginationCursor("/api/subscribers", 10)
+		err = store.GetSubscribers(1, p, tc.filter)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.total, p.Total, tc.name)
+	}
+
+	// test sunset subscribers
+	err = store.SunsetSubscribers([]entities.Subscriber{*john}, []entities.SubscriberEvent{
+		{Data: entities.JSON(`{"unengaged_campaigns":3,"policy":3}`)},
+	})
+	assert.Nil(t, err)
+
+	sub, err = store.GetSubscriber(john.ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, sub.Active)
+
+	p := NewPaginationCursor("/api/subscribers/1/activity", 10)
+	err = store.GetSubscriberActivity(sub, []string{string(entities.SubscriberEventTypeSunset)}, nil, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+
+	// test sunset policies
+	_, err = store.GetSunsetPolicy(1)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	policy := &entities.SunsetPolicy{UserID: 1, Enabled: true, UnengagedCampaigns: 5}
+	assert.Nil(t, store.SaveSunsetPolicy(policy))
+
+	policy.UnengagedCampaigns = 10
+	assert.Nil(t, store.SaveSunsetPolicy(policy))
+
+	policy, err = store.GetSunsetPolicy(1)
+	assert.Nil(t, err)
+	assert.True(t, policy.Enabled)
+	assert.Equal(t, int64(10), policy.UnengagedCampaigns)
+
+	assert.Nil(t, store.DeleteSunsetPolicy(1))
+	_, err = store.GetSunsetPolicy(1)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
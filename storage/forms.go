@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetForms fetches forms by user id, and populates the pagination obj
+func (db *store) GetForms(userID int64, p *PaginationCursor) error {
+	p.SetCollection(&[]entities.Form{})
+	p.SetResource("forms")
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetForm returns the form by the given id and user id, along with its segments.
+func (db *store) GetForm(id, userID int64) (*entities.Form, error) {
+	var f = new(entities.Form)
+	err := db.Preload("Segments").Where("user_id = ? and id = ?", userID, id).Find(f).Error
+	return f, err
+}
+
+// GetFormByUUID returns the form by the given uuid, along with its segments.
+func (db *store) GetFormByUUID(uuid string) (*entities.Form, error) {
+	var f = new(entities.Form)
+	err := db.Preload("Segments").Where("uuid = ?", uuid).Find(f).Error
+	return f, err
+}
+
+// CreateForm creates a new form in the database.
+func (db *store) CreateForm(f *entities.Form) error {
+	return db.Create(f).Error
+}
+
+// UpdateForm edits an existing form in the database and replaces its segments.
+func (db *store) UpdateForm(f *entities.Form) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(f).Association("Segments").Replace(f.Segments).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("form store: update form's segments: %w", err)
+	}
+
+	if err := tx.Where("id = ? and user_id = ?", f.ID, f.UserID).Save(f).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("form store: update form: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteForm deletes the form with the given id and user id from the database
+// and also clears the segments association.
+func (db *store) DeleteForm(id, userID int64) error {
+	f := &entities.Form{Model: entities.Model{ID: id}, UserID: userID}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(f).Association("Segments").Clear().Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("form store: delete form's segment relation: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(f).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("form store: delete form: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteAllFormsForUser deletes all forms for user along with their segment relations.
+func (db *store) DeleteAllFormsForUser(userID int64) error {
+	forms := db.Table("forms").Select("id").Where("user_id = ?", userID).SubQuery()
+	if err := db.Exec("DELETE FROM forms_segments WHERE form_id IN ?", forms).Error; err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ?", userID).Delete(&entities.Form{}).Error
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestForm(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	seg1 := &entities.Segment{Name: "newsletter", UserID: 1}
+	err := store.CreateSegment(seg1)
+	assert.Nil(t, err)
+	seg2 := &entities.Segment{Name: "offers", UserID: 1}
+	err = store.CreateSegment(seg2)
+	assert.Nil(t, err)
+
+	// test create form
+	f := &entities.Form{
+		UUID:       "6f1c1c8e-3f44-4a4a-9d4e-0d1b5a6c7e8f",
+		UserID:     1,
+		Name:       "Newsletter signup",
+		Segments:   []entities.Segment{*seg1},
+		FieldsJSON: []byte(`["company","city"]`),
+	}
+	err = store.CreateForm(f)
+	assert.Nil(t, err)
+
+	// test get form
+	f, err = store.GetForm(f.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Newsletter signup", f.Name)
+	assert.Len(t, f.Segments, 1)
+
+	fields, err := f.GetFields()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"company", "city"}, fields)
+
+	_, err = store.GetForm(f.ID, 2)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test get form by uuid
+	f, err = store.GetFormByUUID("6f1c1c8e-3f44-4a4a-9d4e-0d1b5a6c7e8f")
+	assert.Nil(t, err)
+	assert.Equal(t, seg1.ID, f.Segments[0].ID)
+
+	// test update form replaces the segments
+	f.Name = "Offers signup"
+	f.Segments = []entities.Segment{*seg2}
+	err = store.UpdateForm(f)
+	assert.Nil(t, err)
+
+	f, err = store.GetForm(f.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Offers signup", f.Name)
+	assert.Len(t, f.Segments, 1)
+	assert.Equal(t, seg2.ID, f.Segments[0].ID)
+
+	// test get forms
+	p := NewPaginationCursor("/api/forms", 10)
+	err = store.GetForms(1, p)
+	assert.Nil(t, err)
+	col := p.Collection.(*[]entities.Form)
+	assert.Len(t, *col, 1)
+
+	// test delete segment removes it from the form
+	err = store.DeleteSegment(seg2.ID, 1)
+	assert.Nil(t, err)
+
+	f, err = store.GetForm(f.ID, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, f.Segments)
+
+	// test delete form
+	f.Segments = []entities.Segment{*seg1}
+	err = store.UpdateForm(f)
+	assert.Nil(t, err)
+
+	err = store.DeleteForm(f.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetForm(f.ID, 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test delete all forms for user
+	err = store.CreateForm(&entities.Form{UUID: "0b7e2a9c-7c1e-4d55-8a57-2f4e1f0c9d31", UserID: 1, Name: "Footer", Segments: []entities.Segment{*seg1}})
+	assert.Nil(t, err)
+	err = store.DeleteAllFormsForUser(1)
+	assert.Nil(t, err)
+
+	_, err = store.GetFormByUUID("0b7e2a9c-7c1e-4d55-8a57-2f4e1f0c9d31")
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	err = store.DeleteSegment(seg1.ID, 1)
+	assert.Nil(t, err)
+}
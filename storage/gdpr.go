@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSubscriberData returns everything that is stored about the subscriber, the campaign activities
+// and the events are found by the email and the send logs by the id of the subscriber.
+func (db *store) GetSubscriberData(s *entities.Subscriber) (*entities.SubscriberData, error) {
+	d := &entities.SubscriberData{
+		ExportedAt:   time.Now().UTC(),
+		Subscriber:   s,
+		Events:       []entities.SubscriberEvent{},
+		SendLogs:     []entities.SendLog{},
+		Sends:        []entities.Send{},
+		Deliveries:   []entities.Delivery{},
+		Opens:        []entities.Open{},
+		Clicks:       []entities.Click{},
+		Bounces:      []entities.Bounce{},
+		Complaints:   []entities.Complaint{},
+		Suppressions: []entities.Suppression{},
+	}
+
+	events, err := db.getSubscriberEvents(s.UserID, s.Email)
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: get subscriber's events: %w", err)
+	}
+	d.Events = append(d.Events, events...)
+
+	byEmail := []struct {
+		key  string
+		dest interface{}
+	}{
+		{"destination", &d.Sends},
+		{"recipient", &d.Deliveries},
+		{"recipient", &d.Opens},
+		{"recipient", &d.Clicks},
+		{"recipient", &d.Bounces},
+		{"recipient", &d.Complaints},
+	}
+	for _, q := range byEmail {
+		err := db.Where("user_id = ? and "+q.key+" = ?", s.UserID, s.Email).
+			Order("created_at").
+			Find(q.dest).Error
+		if err != nil {
+			return nil, fmt.Errorf("subscription store: get subscriber data: %w", err)
+		}
+	}
+
+	err = db.Where("user_id = ? and subscriber_id = ?", s.UserID, s.ID).
+		Order("created_at").
+		Find(&d.SendLogs).Error
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: get subscriber's send logs: %w", err)
+	}
+
+	sup, err := db.GetSuppressionsForEmails(s.UserID, []string{s.Email})
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: get subscriber's suppressions: %w", err)
+	}
+	d.Suppressions = append(d.Suppressions, sup...)
+
+	return d, nil
+}
+
+// getSubscriberEvents returns the events of the subscriber by the email, the times are scanned
+// by activityTime since SQLite returns the DATETIME(6) column of the events as text.
+func (db *store) getSubscriberEvents(userID int64, email string) ([]entities.SubscriberEvent, error) {
+	rows, err := db.Table("subscriber_events").
+		Select("id, user_id, subscriber_email, event_type, data, created_at").
+		Where("user_id = ? and subscriber_email = ?", userID, email).
+		Order("created_at").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []entities.SubscriberEvent
+	for rows.Next() {
+		var (
+			e         entities.SubscriberEvent
+			createdAt activityTime
+		)
+		err := rows.Scan(&e.ID, &e.UserID, &e.SubscriberEmail, &e.EventType, &e.Data, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		e.CreatedAt = createdAt.Time
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// EraseSubscriber erases the subscriber's data in a single transaction. The subscriber, its segment
+// memberships, events and send logs, and the suppressions of its email are deleted. The campaign
+// activities are kept for the reports of the campaigns, but their recipients are replaced by an address
+// which can't be traced back to the subscriber and the user agents and the ip addresses are cleared.
+// The hash of the email is suppressed, so the subscriber is not added again, and the erasure is stored
+// with the number of the affected rows by table.
+func (db *store) EraseSubscriber(s *entities.Subscriber, erasure *entities.GDPRErasure) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	summary := make(map[string]int64)
+
+	if err := tx.Model(s).Association("Segments").Clear().Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: delete subscriber's segment relation: %w", err)
+	}
+
+	deletes := []struct {
+		table string
+		where string
+		value interface{}
+	}{
+		{"subscriber_events", "subscriber_email = ?", s.Email},
+		{"send_logs", "subscriber_id = ?", s.ID},
+		{"suppressions", "type = 'email' and value = ?", s.Email},
+		{"subscribers", "id = ?", s.ID},
+	}
+	for _, d := range deletes {
+		q := tx.Exec("DELETE FROM "+d.table+" WHERE user_id = ? and "+d.where, s.UserID, d.value)
+		if q.Error != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: erase %s: %w", d.table, q.Error)
+		}
+		summary[d.table] = q.RowsAffected
+	}
+
+	anonymous := "erased-" + ksuid.New().String() + "@erased.invalid"
+	updates := []struct {
+		table  string
+		key    string
+		fields map[string]interface{}
+	}{
+		{"sends", "destination", map[string]interface{}{"destination": anonymous}},
+		{"deliveries", "recipient", map[string]interface{}{"recipient": anonymous}},
+		{"opens", "recipient", map[string]interface{}{"recipient": anonymous, "user_agent": "", "ip_address": ""}},
+		{"clicks", "recipient", map[string]interface{}{"recipient": anonymous, "user_agent": "", "ip_address": ""}},
+		{"bounces", "recipient", map[string]interface{}{"recipient": anonymous, "diagnostic_code": ""}},
+		{"complaints", "recipient", map[string]interface{}{"recipient": anonymous, "user_agent": ""}},
+	}
+	for _, u := range updates {
+		q := tx.Table(u.table).
+			Where("user_id = ? and "+u.key+" = ?", s.UserID, s.Email).
+			Updates(u.fields)
+		if q.Error != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: anonymise %s: %w", u.table, q.Error)
+		}
+		summary[u.table] = q.RowsAffected
+	}
+
+	hash := entities.HashEmail(s.Email)
+	var count int64
+	err := tx.Model(&entities.Suppression{}).
+		Where("user_id = ? and type = ? and value = ?", s.UserID, entities.SuppressionTypeEmailHash, hash).
+		Count(&count).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: get tombstone: %w", err)
+	}
+	if count == 0 {
+		err = tx.Create(&entities.Suppression{
+			UserID: s.UserID,
+			Type:   entities.SuppressionTypeEmailHash,
+			Value:  hash,
+			Reason: entities.SuppressionReasonErased,
+			Source: entities.SuppressionSourceErasure,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: create tombstone: %w", err)
+		}
+	}
+
+	erasure.UserID = s.UserID
+	erasure.SubscriberID = s.ID
+	erasure.EmailHash = hash
+	erasure.Summary, err = json.Marshal(summary)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: marshal erasure summary: %w", err)
+	}
+	if err := tx.Create(erasure).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: create erasure: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// GetGDPRErasures fetches the erasures by user id, and populates the pagination obj.
+func (db *store) GetGDPRErasures(userID int64, p *PaginationCursor) error {
+	p.SetCollection(&[]entities.GDPRErasure{})
+	p.SetResource("gdpr_erasures")
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// DeleteAllGDPRErasuresForUser deletes all erasures for user
+func (db *store) DeleteAllGDPRErasuresForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.GDPRErasure{}).Error
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestGDPR(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	seg := &entities.Segment{Name: "foo", UserID: 1}
+	assert.Nil(t, store.CreateSegment(seg))
+
+	campaign := &entities.Campaign{Name: "newsletter", UserID: 1, TemplateID: 1, Status: entities.StatusDraft}
+	assert.Nil(t, store.CreateCampaign(campaign))
+
+	sub := &entities.Subscriber{Name: "Jane", Email: "jane@example.com", UserID: 1, Active: true, Segments: []entities.Segment{*seg}}
+	assert.Nil(t, store.CreateSubscriber(sub))
+	other := &entities.Subscriber{Name: "John", Email: "john@example.com", UserID: 1, Active: true}
+	assert.Nil(t, store.CreateSubscriber(other))
+
+	assert.Nil(t, store.CreateSendLog(&entities.SendLog{
+		ID:           ksuid.New(),
+		UserID:       1,
+		EventID:      ksuid.New(),
+		SubscriberID: sub.ID,
+		CampaignID:   campaign.ID,
+		Status:       entities.SendLogStatusSuccessful,
+		CreatedAt:    time.Now(),
+	}))
+	assert.Nil(t, store.CreateSend(&entities.Send{UserID: 1, CampaignID: campaign.ID, MessageID: "message-1", Destination: sub.Email, CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, UserAgent: "Firefox", IPAddress: "10.0.0.1", CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: other.Email, CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, Link: "https://example.com", CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: campaign.ID, Recipient: sub.Email, Type: "Transient", CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateSuppression(&entities.Suppression{UserID: 1, Type: entities.SuppressionTypeEmail, Value: sub.Email, Reason: entities.SuppressionReasonManual}))
+
+	// test get subscriber data
+	data, err := store.GetSubscriberData(sub)
+	assert.Nil(t, err)
+	assert.Equal(t, sub.Email, data.Subscriber.Email)
+	assert.Len(t, data.Events, 1)
+	assert.Len(t, data.SendLogs, 1)
+	assert.Len(t, data.Sends, 1)
+	assert.Len(t, data.Deliveries, 0)
+	assert.Len(t, data.Opens, 1)
+	assert.Equal(t, "Firefox", data.Opens[0].UserAgent)
+	assert.Len(t, data.Clicks, 1)
+	assert.Len(t, data.Bounces, 1)
+	assert.Len(t, data.Complaints, 0)
+	assert.Len(t, data.Suppressions, 1)
+
+	// test erase subscriber
+	erasure := &entities.GDPRErasure{ErasedBy: "admin", IPAddress: "127.0.0.1"}
+	err = store.EraseSubscriber(sub, erasure)
+	assert.Nil(t, err)
+	assert.NotZero(t, erasure.ID)
+	assert.Equal(t, entities.HashEmail("jane@example.com"), erasure.EmailHash)
+
+	var summary map[string]int64
+	assert.Nil(t, json.Unmarshal(erasure.Summary, &summary))
+	assert.Equal(t, int64(1), summary["subscribers"])
+	assert.Equal(t, int64(1), summary["subscriber_events"])
+	assert.Equal(t, int64(1), summary["send_logs"])
+	assert.Equal(t, int64(1), summary["opens"])
+	assert.Equal(t, int64(1), summary["suppressions"])
+
+	_, err = store.GetSubscriber(sub.ID, 1)
+	assert.NotNil(t, err)
+
+	data, err = store.GetSubscriberData(sub)
+	assert.Nil(t, err)
+	assert.Len(t, data.Events, 0)
+	assert.Len(t, data.SendLogs, 0)
+	assert.Len(t, data.Opens, 0)
+	assert.Len(t, data.Clicks, 0)
+
+	// the activities are anonymised and the other subscribers are kept
+	var opens []entities.Open
+	assert.Nil(t, db.Where("user_id = ?", 1).Order("id").Find(&opens).Error)
+	assert.Len(t, opens, 2)
+	assert.Contains(t, opens[0].Recipient, "@erased.invalid")
+	assert.Empty(t, opens[0].UserAgent)
+	assert.Empty(t, opens[0].IPAddress)
+	assert.Equal(t, other.Email, opens[1].Recipient)
+
+	_, err = store.GetSubscriber(other.ID, 1)
+	assert.Nil(t, err)
+
+	// test the hash of the email is suppressed
+	s, err := store.GetSuppressionForEmail(1, "Jane@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionTypeEmailHash, s.Type)
+	assert.Equal(t, entities.SuppressionReasonErased, s.Reason)
+
+	sups, err := store.GetSuppressionsForEmails(1, []string{"jane@example.com", "john@example.com"})
+	assert.Nil(t, err)
+	assert.Len(t, sups, 1)
+
+	// test get erasures
+	p := NewPaginationCursor("/api/subscribers/gdpr-erasures", 10)
+	err = store.GetGDPRErasures(1, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+
+	erasures := *p.Collection.(*[]entities.GDPRErasure)
+	assert.Equal(t, sub.ID, erasures[0].SubscriberID)
+	assert.Equal(t, "admin", erasures[0].ErasedBy)
+
+	err = store.DeleteAllGDPRErasuresForUser(1)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `sample_data_sets` (
+    `id`          integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`     integer unsigned                            NOT NULL,
+    `template_id` integer unsigned                            NOT NULL,
+    `name`        varchar(191)                                NOT NULL,
+    `data`        JSON,
+    `created_at`  datetime(6)                                 NOT NULL,
+    `updated_at`  datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`template_id`) REFERENCES templates (`id`),
+    UNIQUE (`template_id`, `name`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `sample_data_sets`;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+ALTER TABLE `subscribers`
+    ADD COLUMN `pending` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `consent_at` DATETIME(6) DEFAULT NULL,
+    ADD COLUMN `consent_ip` VARCHAR(45) DEFAULT NULL,
+    ADD COLUMN `confirmed_at` DATETIME(6) DEFAULT NULL,
+    ADD COLUMN `confirmation_ip` VARCHAR(45) DEFAULT NULL,
+    ADD INDEX idx_pending_created_at (`pending`, `created_at`);
+
+-- +migrate Down
+
+ALTER TABLE `subscribers`
+    DROP INDEX idx_pending_created_at,
+    DROP COLUMN `confirmation_ip`,
+    DROP COLUMN `confirmed_at`,
+    DROP COLUMN `consent_ip`,
+    DROP COLUMN `consent_at`,
+    DROP COLUMN `pending`;
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `forms` (
+    `id`                       integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `uuid`                     varchar(36)                                 NOT NULL,
+    `user_id`                  integer unsigned                            NOT NULL,
+    `name`                     varchar(191)                                NOT NULL,
+    `fields`                   json,
+    `success_redirect`         varchar(191),
+    `double_opt_in`            tinyint(1)                                  NOT NULL DEFAULT 0,
+    `confirmation_source`      varchar(191),
+    `confirmation_template_id` integer unsigned DEFAULT NULL,
+    `created_at`               datetime(6)                                 NOT NULL,
+    `updated_at`               datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_id_created_at (`id`, `created_at`),
+    UNIQUE (`uuid`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `forms_segments` (
+    `form_id`    INTEGER UNSIGNED NOT NULL,
+    `segment_id` INTEGER UNSIGNED NOT NULL,
+    PRIMARY KEY (`form_id`, `segment_id`),
+    FOREIGN KEY (`form_id`) REFERENCES forms(`id`),
+    FOREIGN KEY (`segment_id`) REFERENCES segments(`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `forms_segments`;
+DROP TABLE `forms`;
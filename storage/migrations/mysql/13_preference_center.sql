@@ -0,0 +1,21 @@
+-- +migrate Up
+
+ALTER TABLE `segments`
+    ADD COLUMN `public` TINYINT(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE `subscribers`
+    ADD COLUMN `paused_until` DATETIME(6) DEFAULT NULL;
+
+ALTER TABLE `subscriber_events`
+    ADD COLUMN `data` JSON DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `subscriber_events`
+    DROP COLUMN `data`;
+
+ALTER TABLE `subscribers`
+    DROP COLUMN `paused_until`;
+
+ALTER TABLE `segments`
+    DROP COLUMN `public`;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `segments`
+    ADD COLUMN `dynamic` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `rules` JSON DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `segments`
+    DROP COLUMN `rules`,
+    DROP COLUMN `dynamic`;
@@ -0,0 +1,28 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `subscriber_imports` (
+    `id`           integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`      integer unsigned                            NOT NULL,
+    `file_name`    varchar(191)                                NOT NULL,
+    `mode`         varchar(191)                                NOT NULL,
+    `mapping`      json,
+    `segment_ids`  json,
+    `status`       varchar(191)                                NOT NULL,
+    `total`        integer unsigned                            NOT NULL DEFAULT 0,
+    `processed`    integer unsigned                            NOT NULL DEFAULT 0,
+    `created`      integer unsigned                            NOT NULL DEFAULT 0,
+    `updated`      integer unsigned                            NOT NULL DEFAULT 0,
+    `skipped`      integer unsigned                            NOT NULL DEFAULT 0,
+    `failed`       integer unsigned                            NOT NULL DEFAULT 0,
+    `error`        varchar(191),
+    `started_at`   datetime(6),
+    `completed_at` datetime(6),
+    `created_at`   datetime(6)                                 NOT NULL,
+    `updated_at`   datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_user_id_created_at (`user_id`, `created_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `subscriber_imports`;
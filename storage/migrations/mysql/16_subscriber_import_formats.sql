@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `subscriber_imports`
+    ADD COLUMN `format` varchar(191) NOT NULL DEFAULT 'csv',
+    ADD COLUMN `sheet` varchar(191) DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `subscriber_imports`
+    DROP COLUMN `sheet`,
+    DROP COLUMN `format`;
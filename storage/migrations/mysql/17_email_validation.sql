@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `subscribers`
+    ADD COLUMN `email_status` varchar(191) NOT NULL DEFAULT '',
+    ADD COLUMN `email_validation` JSON DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `subscribers`
+    DROP COLUMN `email_validation`,
+    DROP COLUMN `email_status`;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `suppressions` (
+    `id`         integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`    integer unsigned                            NOT NULL,
+    `type`       varchar(191)                                NOT NULL,
+    `value`      varchar(191)                                NOT NULL,
+    `reason`     varchar(191)                                NOT NULL,
+    `source`     varchar(191)                                NOT NULL DEFAULT '',
+    `created_at` datetime(6)                                 NOT NULL,
+    `updated_at` datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_id_created_at (`id`, `created_at`),
+    UNIQUE (`user_id`, `type`, `value`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `suppressions`;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `gdpr_erasures` (
+    `id`            integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`       integer unsigned                            NOT NULL,
+    `subscriber_id` integer unsigned                            NOT NULL,
+    `email_hash`    varchar(191)                                NOT NULL,
+    `erased_by`     varchar(191)                                NOT NULL,
+    `ip_address`    varchar(191)                                NOT NULL DEFAULT '',
+    `summary`       json,
+    `created_at`    datetime(6)                                 NOT NULL,
+    `updated_at`    datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_id_created_at (`id`, `created_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `gdpr_erasures`;
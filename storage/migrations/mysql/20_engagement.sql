@@ -0,0 +1,28 @@
+-- +migrate Up
+
+ALTER TABLE `subscribers`
+    ADD COLUMN `engagement_score` integer NOT NULL DEFAULT 0,
+    ADD COLUMN `last_engaged_at` datetime(6) DEFAULT NULL,
+    ADD COLUMN `engagement_scored_at` datetime(6) DEFAULT NULL,
+    ADD INDEX idx_engagement_scored_at (`engagement_scored_at`);
+
+CREATE TABLE IF NOT EXISTS `sunset_policies` (
+    `id`                  integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`             integer unsigned                            NOT NULL,
+    `enabled`             tinyint(1)                                  NOT NULL DEFAULT 0,
+    `unengaged_campaigns` integer unsigned                            NOT NULL,
+    `created_at`          datetime(6)                                 NOT NULL,
+    `updated_at`          datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    UNIQUE (`user_id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `sunset_policies`;
+
+ALTER TABLE `subscribers`
+    DROP INDEX idx_engagement_scored_at,
+    DROP COLUMN `engagement_scored_at`,
+    DROP COLUMN `last_engaged_at`,
+    DROP COLUMN `engagement_score`;
@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `custom_fields` (
+    `id`            integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`       integer unsigned                            NOT NULL,
+    `field_key`     varchar(191)                                NOT NULL,
+    `label`         varchar(191)                                NOT NULL,
+    `type`          varchar(191)                                NOT NULL,
+    `required`      tinyint(1)                                  NOT NULL DEFAULT 0,
+    `default_value` varchar(191)                                NOT NULL DEFAULT '',
+    `options`       json,
+    `created_at`    datetime(6)                                 NOT NULL,
+    `updated_at`    datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    UNIQUE (`user_id`, `field_key`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `custom_fields`;
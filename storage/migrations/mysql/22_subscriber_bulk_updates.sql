@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `subscriber_imports`
+    ADD COLUMN `kind` varchar(191) NOT NULL DEFAULT 'import',
+    ADD COLUMN `dry_run` tinyint(1) NOT NULL DEFAULT 0;
+
+-- +migrate Down
+
+ALTER TABLE `subscriber_imports`
+    DROP COLUMN `dry_run`,
+    DROP COLUMN `kind`;
@@ -0,0 +1,27 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `subscriber_bulk_actions` (
+    `id`             integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`        integer unsigned                            NOT NULL,
+    `action`         varchar(191)                                NOT NULL,
+    `segment_id`     integer unsigned                            NOT NULL DEFAULT 0,
+    `metadata_key`   varchar(191)                                NOT NULL DEFAULT '',
+    `metadata_value` varchar(191)                                NOT NULL DEFAULT '',
+    `filter`         json,
+    `status`         varchar(191)                                NOT NULL,
+    `total`          integer unsigned                            NOT NULL DEFAULT 0,
+    `processed`      integer unsigned                            NOT NULL DEFAULT 0,
+    `affected`       integer unsigned                            NOT NULL DEFAULT 0,
+    `failed`         integer unsigned                            NOT NULL DEFAULT 0,
+    `error`          varchar(191),
+    `started_at`     datetime(6),
+    `completed_at`   datetime(6),
+    `created_at`     datetime(6)                                 NOT NULL,
+    `updated_at`     datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_user_id_created_at (`user_id`, `created_at`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `subscriber_bulk_actions`;
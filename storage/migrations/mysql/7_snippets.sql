@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `snippets` (
+    `id`         integer unsigned primary key AUTO_INCREMENT NOT NULL,
+    `user_id`    integer unsigned                            NOT NULL,
+    `name`       varchar(191)                                NOT NULL,
+    `body`       text,
+    `created_at` datetime(6)                                 NOT NULL,
+    `updated_at` datetime(6)                                 NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    INDEX idx_id_created_at (`id`, `created_at`),
+    UNIQUE (`user_id`, `name`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+ALTER TABLE `templates` ADD COLUMN `layout_id` integer unsigned DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `templates` DROP COLUMN `layout_id`;
+DROP TABLE `snippets`;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE `templates` ADD COLUMN `disable_css_inlining` TINYINT(1) NOT NULL DEFAULT 0;
+
+-- +migrate Down
+
+ALTER TABLE `templates` DROP COLUMN `disable_css_inlining`;
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "sample_data_sets" (
+    "id"          integer primary key autoincrement,
+    "user_id"     integer unsigned NOT NULL,
+    "template_id" integer unsigned NOT NULL,
+    "name"        varchar(191)     NOT NULL,
+    "data"        json,
+    "created_at"  datetime,
+    "updated_at"  datetime,
+    UNIQUE("template_id", "name"),
+    foreign key ("user_id") references users("id"),
+    foreign key ("template_id") references templates("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "sample_data_sets";
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+ALTER TABLE "subscribers" ADD COLUMN "pending" integer NOT NULL DEFAULT 0;
+ALTER TABLE "subscribers" ADD COLUMN "consent_at" datetime;
+ALTER TABLE "subscribers" ADD COLUMN "consent_ip" varchar(45);
+ALTER TABLE "subscribers" ADD COLUMN "confirmed_at" datetime;
+ALTER TABLE "subscribers" ADD COLUMN "confirmation_ip" varchar(45);
+
+CREATE INDEX IF NOT EXISTS idx_pending_created_at ON "subscribers" (pending, created_at);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_pending_created_at;
+
+ALTER TABLE "subscribers" DROP COLUMN "confirmation_ip";
+ALTER TABLE "subscribers" DROP COLUMN "confirmed_at";
+ALTER TABLE "subscribers" DROP COLUMN "consent_ip";
+ALTER TABLE "subscribers" DROP COLUMN "consent_at";
+ALTER TABLE "subscribers" DROP COLUMN "pending";
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "forms" (
+    "id"                       integer primary key autoincrement,
+    "uuid"                     varchar(36)      NOT NULL UNIQUE,
+    "user_id"                  integer unsigned NOT NULL,
+    "name"                     varchar(191)     NOT NULL,
+    "fields"                   json,
+    "success_redirect"         varchar(191),
+    "double_opt_in"            integer          NOT NULL DEFAULT 0,
+    "confirmation_source"      varchar(191),
+    "confirmation_template_id" integer unsigned,
+    "created_at"               datetime,
+    "updated_at"               datetime,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE TABLE IF NOT EXISTS "forms_segments" (
+    "form_id"    integer,
+    "segment_id" integer,
+    primary key ("form_id", "segment_id"),
+    foreign key ("form_id") references forms("id"),
+    foreign key ("segment_id") references segments("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_forms_segments_segment ON "forms_segments" (segment_id);
+
+-- +migrate Down
+
+DROP TABLE "forms_segments";
+DROP TABLE "forms";
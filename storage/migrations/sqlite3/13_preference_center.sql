@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE "segments" ADD COLUMN "public" integer NOT NULL DEFAULT 0;
+ALTER TABLE "subscribers" ADD COLUMN "paused_until" datetime;
+ALTER TABLE "subscriber_events" ADD COLUMN "data" json;
+
+-- +migrate Down
+
+ALTER TABLE "subscriber_events" DROP COLUMN "data";
+ALTER TABLE "subscribers" DROP COLUMN "paused_until";
+ALTER TABLE "segments" DROP COLUMN "public";
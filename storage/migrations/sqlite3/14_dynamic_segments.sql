@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "segments" ADD COLUMN "dynamic" integer NOT NULL DEFAULT 0;
+ALTER TABLE "segments" ADD COLUMN "rules" json;
+
+-- +migrate Down
+
+ALTER TABLE "segments" DROP COLUMN "rules";
+ALTER TABLE "segments" DROP COLUMN "dynamic";
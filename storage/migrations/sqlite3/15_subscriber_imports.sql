@@ -0,0 +1,29 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "subscriber_imports" (
+    "id"           integer primary key autoincrement,
+    "user_id"      integer unsigned NOT NULL,
+    "file_name"    varchar(191)     NOT NULL,
+    "mode"         varchar(191)     NOT NULL,
+    "mapping"      json,
+    "segment_ids"  json,
+    "status"       varchar(191)     NOT NULL,
+    "total"        integer          NOT NULL DEFAULT 0,
+    "processed"    integer          NOT NULL DEFAULT 0,
+    "created"      integer          NOT NULL DEFAULT 0,
+    "updated"      integer          NOT NULL DEFAULT 0,
+    "skipped"      integer          NOT NULL DEFAULT 0,
+    "failed"       integer          NOT NULL DEFAULT 0,
+    "error"        varchar(191),
+    "started_at"   datetime,
+    "completed_at" datetime,
+    "created_at"   datetime,
+    "updated_at"   datetime,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_imports_user_id ON "subscriber_imports" (user_id);
+
+-- +migrate Down
+
+DROP TABLE "subscriber_imports";
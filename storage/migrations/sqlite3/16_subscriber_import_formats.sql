@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "subscriber_imports" ADD COLUMN "format" varchar(191) NOT NULL DEFAULT 'csv';
+ALTER TABLE "subscriber_imports" ADD COLUMN "sheet" varchar(191);
+
+-- +migrate Down
+
+ALTER TABLE "subscriber_imports" DROP COLUMN "sheet";
+ALTER TABLE "subscriber_imports" DROP COLUMN "format";
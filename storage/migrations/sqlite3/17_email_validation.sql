@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "subscribers" ADD COLUMN "email_status" varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE "subscribers" ADD COLUMN "email_validation" json;
+
+-- +migrate Down
+
+ALTER TABLE "subscribers" DROP COLUMN "email_validation";
+ALTER TABLE "subscribers" DROP COLUMN "email_status";
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "suppressions" (
+    "id"         integer primary key autoincrement,
+    "user_id"    integer unsigned NOT NULL,
+    "type"       varchar(191)     NOT NULL,
+    "value"      varchar(191)     NOT NULL,
+    "reason"     varchar(191)     NOT NULL,
+    "source"     varchar(191)     NOT NULL DEFAULT '',
+    "created_at" datetime,
+    "updated_at" datetime,
+    UNIQUE("user_id", "type", "value"),
+    foreign key ("user_id") references users("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "suppressions";
@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "gdpr_erasures" (
+    "id"            integer primary key autoincrement,
+    "user_id"       integer unsigned NOT NULL,
+    "subscriber_id" integer unsigned NOT NULL,
+    "email_hash"    varchar(191)     NOT NULL,
+    "erased_by"     varchar(191)     NOT NULL,
+    "ip_address"    varchar(191)     NOT NULL DEFAULT '',
+    "summary"       json,
+    "created_at"    datetime,
+    "updated_at"    datetime,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_gdpr_erasures_user_id ON "gdpr_erasures" (user_id);
+
+-- +migrate Down
+
+DROP TABLE "gdpr_erasures";
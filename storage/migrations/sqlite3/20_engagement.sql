@@ -0,0 +1,26 @@
+-- +migrate Up
+
+ALTER TABLE "subscribers" ADD COLUMN "engagement_score" integer NOT NULL DEFAULT 0;
+ALTER TABLE "subscribers" ADD COLUMN "last_engaged_at" datetime;
+ALTER TABLE "subscribers" ADD COLUMN "engagement_scored_at" datetime;
+
+CREATE INDEX IF NOT EXISTS idx_engagement_scored_at ON "subscribers" (engagement_scored_at);
+
+CREATE TABLE IF NOT EXISTS "sunset_policies" (
+    "id"                  integer primary key autoincrement,
+    "user_id"             integer unsigned NOT NULL,
+    "enabled"             boolean          NOT NULL DEFAULT 0,
+    "unengaged_campaigns" integer          NOT NULL,
+    "created_at"          datetime,
+    "updated_at"          datetime,
+    UNIQUE("user_id"),
+    foreign key ("user_id") references users("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "sunset_policies";
+DROP INDEX IF EXISTS idx_engagement_scored_at;
+ALTER TABLE "subscribers" DROP COLUMN "engagement_scored_at";
+ALTER TABLE "subscribers" DROP COLUMN "last_engaged_at";
+ALTER TABLE "subscribers" DROP COLUMN "engagement_score";
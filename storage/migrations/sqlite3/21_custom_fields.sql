@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "custom_fields" (
+    "id"            integer primary key autoincrement,
+    "user_id"       integer unsigned NOT NULL,
+    "field_key"     varchar(191)     NOT NULL,
+    "label"         varchar(191)     NOT NULL,
+    "type"          varchar(191)     NOT NULL,
+    "required"      boolean          NOT NULL DEFAULT 0,
+    "default_value" varchar(191)     NOT NULL DEFAULT '',
+    "options"       json,
+    "created_at"    datetime,
+    "updated_at"    datetime,
+    UNIQUE("user_id", "field_key"),
+    foreign key ("user_id") references users("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "custom_fields";
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "subscriber_imports" ADD COLUMN "kind" varchar(191) NOT NULL DEFAULT 'import';
+ALTER TABLE "subscriber_imports" ADD COLUMN "dry_run" boolean NOT NULL DEFAULT 0;
+
+-- +migrate Down
+
+ALTER TABLE "subscriber_imports" DROP COLUMN "dry_run";
+ALTER TABLE "subscriber_imports" DROP COLUMN "kind";
@@ -0,0 +1,28 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "subscriber_bulk_actions" (
+    "id"             integer primary key autoincrement,
+    "user_id"        integer unsigned NOT NULL,
+    "action"         varchar(191)     NOT NULL,
+    "segment_id"     integer          NOT NULL DEFAULT 0,
+    "metadata_key"   varchar(191)     NOT NULL DEFAULT '',
+    "metadata_value" varchar(191)     NOT NULL DEFAULT '',
+    "filter"         json,
+    "status"         varchar(191)     NOT NULL,
+    "total"          integer          NOT NULL DEFAULT 0,
+    "processed"      integer          NOT NULL DEFAULT 0,
+    "affected"       integer          NOT NULL DEFAULT 0,
+    "failed"         integer          NOT NULL DEFAULT 0,
+    "error"          varchar(191),
+    "started_at"     datetime,
+    "completed_at"   datetime,
+    "created_at"     datetime,
+    "updated_at"     datetime,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_bulk_actions_user_id ON "subscriber_bulk_actions" (user_id);
+
+-- +migrate Down
+
+DROP TABLE "subscriber_bulk_actions";
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "snippets" (
+    "id"          integer primary key autoincrement,
+    "user_id"     integer unsigned NOT NULL,
+    "name"        varchar(191)     NOT NULL,
+    "body"        text,
+    "created_at"  datetime,
+    "updated_at"  datetime,
+    UNIQUE("user_id", "name"),
+    foreign key ("user_id") references users("id")
+);
+
+ALTER TABLE "templates" ADD COLUMN "layout_id" integer unsigned;
+
+-- +migrate Down
+
+ALTER TABLE "templates" DROP COLUMN "layout_id";
+DROP TABLE "snippets";
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE "templates" ADD COLUMN "engine" varchar(191) NOT NULL DEFAULT 'mustache';
+
+-- +migrate Down
+
+ALTER TABLE "templates" DROP COLUMN "engine";
@@ -39,6 +39,7 @@ type PaginationCursor struct {
 	StartingAfter int64                     `json:"-"`
 	EndingBefore  int64                     `json:"-"`
 	Path          string                    `json:"-"`
+	Params        url.Values                `json:"-"`
 	Resource      string                    `json:"-"`
 	Direction     Direction                 `json:"-"`
 	PerPage       int64                     `json:"per_page"`
@@ -62,20 +63,20 @@ func NewPaginationCursor(path string, perPage int64) *PaginationCursor {
 
 // PopulateLinks populates the Links property with the query params needed for the
 // previous and next urls. It uses the BasePath and encodes the 'per_page', 'ending_before' and 'starting_after'
-// query parameters needed to create the links.
+// query parameters needed to create the links, along with the params of the cursor.
 func (c *PaginationCursor) PopulateLinks(prevID, nextID string) {
 
 	c.Links = Links{}
 
 	if prevID != "" && prevID != "0" {
-		params := url.Values{}
+		params := c.copyParams()
 		params.Add("per_page", strconv.FormatInt(c.PerPage, 10))
 		params.Add("ending_before", prevID)
 		l := c.Path + "?" + params.Encode()
 		c.Links.Previous = &l
 	}
 	if nextID != "" && nextID != "0" {
-		params := url.Values{}
+		params := c.copyParams()
 		params.Add("per_page", strconv.FormatInt(c.PerPage, 10))
 		params.Add("starting_after", nextID)
 		l := c.Path + "?" + params.Encode()
@@ -83,6 +84,21 @@ func (c *PaginationCursor) PopulateLinks(prevID, nextID string) {
 	}
 }
 
+// copyParams returns a copy of the params of the cursor.
+func (c *PaginationCursor) copyParams() url.Values {
+	params := url.Values{}
+	for k, v := range c.Params {
+		params[k] = append([]string(nil), v...)
+	}
+	return params
+}
+
+// SetParams sets the query params, such as the filters of the collection, which are
+// kept in the previous and next links.
+func (c *PaginationCursor) SetParams(params url.Values) {
+	c.Params = params
+}
+
 // SetCollection sets the collection in the cursor. Usually when setting a collection, it is empty, and
 // gets populated when invoking the Paginate() method.
 func (c *PaginationCursor) SetCollection(collection interface{}) {
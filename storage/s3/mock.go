@@ -29,6 +29,11 @@ func (m *MockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput,
 func (m *MockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	args := m.Called(input)
 
+	// the body can't be copied through json, so the output is returned as it is.
+	if out, ok := args.Get(0).(*s3.GetObjectOutput); ok {
+		return out, args.Error(1)
+	}
+
 	var obj s3.GetObjectOutput
 	objBytes, _ := json.Marshal(args.Get(0))
 
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSampleDataSets fetches the sample data sets of the template by the given template id and user id.
+func (db *store) GetSampleDataSets(templateID, userID int64) ([]entities.SampleDataSet, error) {
+	var s []entities.SampleDataSet
+	err := db.Where("user_id = ? and template_id = ?", userID, templateID).Order("name").Find(&s).Error
+	return s, err
+}
+
+// GetSampleDataSet returns the sample data set by the given id, template id and user id.
+func (db *store) GetSampleDataSet(id, templateID, userID int64) (*entities.SampleDataSet, error) {
+	var s = new(entities.SampleDataSet)
+	err := db.Where("user_id = ? and template_id = ? and id = ?", userID, templateID, id).Find(s).Error
+	return s, err
+}
+
+// GetSampleDataSetByName returns the sample data set by the given name, template id and user id.
+func (db *store) GetSampleDataSetByName(name string, templateID, userID int64) (*entities.SampleDataSet, error) {
+	var s = new(entities.SampleDataSet)
+	err := db.Where("user_id = ? and template_id = ? and name = ?", userID, templateID, name).Find(s).Error
+	return s, err
+}
+
+// CreateSampleDataSet creates a new sample data set in the database.
+func (db *store) CreateSampleDataSet(s *entities.SampleDataSet) error {
+	return db.Create(s).Error
+}
+
+// UpdateSampleDataSet edits an existing sample data set in the database.
+func (db *store) UpdateSampleDataSet(s *entities.SampleDataSet) error {
+	return db.Where("id = ? and user_id = ?", s.ID, s.UserID).Save(s).Error
+}
+
+// DeleteSampleDataSet deletes the sample data set with the given id, template id and user id from the database.
+func (db *store) DeleteSampleDataSet(id, templateID, userID int64) error {
+	return db.Where("user_id = ? and template_id = ?", userID, templateID).Delete(&entities.SampleDataSet{Model: entities.Model{ID: id}}).Error
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSampleDataSet(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	template := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      1,
+			Name:        "sample-data",
+			SubjectPart: "Hi {{name}}",
+		},
+	}
+	err := store.CreateTemplate(template)
+	assert.Nil(t, err)
+
+	// test create sample data set
+	s := &entities.SampleDataSet{
+		UserID:     1,
+		TemplateID: template.ID,
+		Name:       "with discount",
+		DataJSON:   entities.JSON(`{"discount":"SAVE10"}`),
+	}
+	err = store.CreateSampleDataSet(s)
+	assert.Nil(t, err)
+
+	err = store.CreateSampleDataSet(&entities.SampleDataSet{
+		UserID:     1,
+		TemplateID: template.ID,
+		Name:       "with discount",
+	})
+	assert.NotNil(t, err)
+
+	// test get sample data set
+	s, err = store.GetSampleDataSet(s.ID, template.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "with discount", s.Name)
+
+	data, err := s.GetData()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"discount": "SAVE10"}, data)
+
+	_, err = store.GetSampleDataSet(s.ID, template.ID, 2)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test get sample data set by name
+	s, err = store.GetSampleDataSetByName("with discount", template.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, template.ID, s.TemplateID)
+
+	// test update sample data set
+	s.Name = "discount"
+	err = store.UpdateSampleDataSet(s)
+	assert.Nil(t, err)
+
+	s, err = store.GetSampleDataSet(s.ID, template.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "discount", s.Name)
+
+	// test get sample data sets
+	err = store.CreateSampleDataSet(&entities.SampleDataSet{UserID: 1, TemplateID: template.ID, Name: "anonymous"})
+	assert.Nil(t, err)
+
+	sets, err := store.GetSampleDataSets(template.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, sets, 2)
+	assert.Equal(t, "anonymous", sets[0].Name)
+
+	// test delete sample data set
+	err = store.DeleteSampleDataSet(s.ID, template.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSampleDataSet(s.ID, template.ID, 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test delete template deletes the sample data sets
+	err = store.DeleteTemplate(template.ID, 1)
+	assert.Nil(t, err)
+
+	sets, err = store.GetSampleDataSets(template.ID, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, sets)
+}
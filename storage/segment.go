@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -22,7 +24,28 @@ func (db *store) GetSegments(userID int64, p *PaginationCursor) error {
 
 	p.SetQuery(query)
 
-	return db.Paginate(p, userID)
+	if err := db.Paginate(p, userID); err != nil {
+		return err
+	}
+
+	// the dynamic segments have no associations, their members are counted by the rules
+	segs := p.Collection.(*[]entities.SegmentWithTotalSubs)
+	for i := range *segs {
+		seg := &(*segs)[i]
+		if !seg.Dynamic {
+			continue
+		}
+		r, err := seg.GetRules()
+		if err != nil {
+			return fmt.Errorf("segment %d: get rules: %w", seg.ID, err)
+		}
+		seg.SubscribersInSeg, err = db.GetTotalSubscribersByRules(userID, r)
+		if err != nil {
+			return fmt.Errorf("segment %d: count subscribers: %w", seg.ID, err)
+		}
+	}
+
+	return nil
 }
 
 // GetTotalSegments fetches the total count by user id
@@ -41,6 +64,24 @@ func (db *store) GetSegmentsByIDs(userID int64, ids []int64) ([]entities.Segment
 	return lists, err
 }
 
+// GetPublicSegments fetches the static segments of the user which are listed in the preference center.
+func (db *store) GetPublicSegments(userID int64) ([]entities.Segment, error) {
+	var segs []entities.Segment
+
+	err := db.Where("user_id = ? AND public = ? AND dynamic = ?", userID, true, false).Order("name").Find(&segs).Error
+
+	return segs, err
+}
+
+// GetDynamicSegments fetches the dynamic segments of the user.
+func (db *store) GetDynamicSegments(userID int64) ([]entities.Segment, error) {
+	var segs []entities.Segment
+
+	err := db.Where("user_id = ? AND dynamic = ?", userID, true).Order("name").Find(&segs).Error
+
+	return segs, err
+}
+
 // GetSegment returns the list by the given id and user id
 func (db *store) GetSegment(id, userID int64) (*entities.Segment, error) {
 	var seg = new(entities.Segment)
@@ -65,13 +106,18 @@ func (db *store) UpdateSegment(l *entities.Segment) error {
 	return db.Where("id = ? and user_id = ?", l.ID, l.UserID).Save(l).Error
 }
 
-// DeleteSegment deletes an existing list from the database and also clears the subscribers association.
+// DeleteSegment deletes an existing list from the database and also clears the subscribers
+// association and removes the list from the forms targeting it.
 func (db *store) DeleteSegment(id, userID int64) error {
 	l := &entities.Segment{Model: entities.Model{ID: id}, UserID: userID}
 	if err := db.RemoveSubscribersFromSegment(l); err != nil {
 		return err
 	}
 
+	if err := db.Exec("DELETE FROM forms_segments WHERE segment_id = ?", id).Error; err != nil {
+		return err
+	}
+
 	return db.Delete(&l).Error
 }
 
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// engagementTables are the tables of the events the engagement rules match.
+var engagementTables = map[string]string{
+	entities.RuleFieldOpened:     "opens",
+	entities.RuleFieldClicked:    "clicks",
+	entities.RuleFieldBounced:    "bounces",
+	entities.RuleFieldComplained: "complaints",
+}
+
+// SegmentRules is a query scope that finds the subscribers matching the rule tree of a
+// dynamic segment. The rule tree has to be validated before it's compiled.
+func SegmentRules(dialect string, r *entities.SegmentRule) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		sql, args, err := compileSegmentRule(dialect, r, time.Now())
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Where(sql, args...)
+	}
+}
+
+// compileSegmentRule compiles the rule tree to a condition on the subscribers table.
+func compileSegmentRule(dialect string, r *entities.SegmentRule, now time.Time) (string, []interface{}, error) {
+	if r.IsGroup() {
+		var (
+			conds []string
+			args  []interface{}
+		)
+		for i := range r.Rules {
+			sql, a, err := compileSegmentRule(dialect, &r.Rules[i], now)
+			if err != nil {
+				return "", nil, err
+			}
+			conds = append(conds, sql)
+			args = append(args, a...)
+		}
+		if len(conds) == 0 {
+			return "", nil, fmt.Errorf("%w: empty group", entities.ErrInvalidSegmentRule)
+		}
+
+		glue := " AND "
+		if r.Combinator == entities.RuleCombinatorOr {
+			glue = " OR "
+		}
+		return "(" + strings.Join(conds, glue) + ")", args, nil
+	}
+
+	switch r.Field {
+	case entities.RuleFieldEmail, entities.RuleFieldName:
+		return compileStringCondition("subscribers."+r.Field, nil, r)
+	case entities.RuleFieldMetadata:
+		path := metadataPath(r.Key)
+		switch r.Operator {
+		case entities.RuleOperatorExists:
+			return "(JSON_EXTRACT(subscribers.metadata, ?) IS NOT NULL)", []interface{}{path}, nil
+		case entities.RuleOperatorNotExists:
+			return "(JSON_EXTRACT(subscribers.metadata, ?) IS NULL)", []interface{}{path}, nil
+		}
+		expr := strings.Replace(metadataValue(dialect), "metadata", "subscribers.metadata", 1)
+		switch r.Operator {
+		case entities.RuleOperatorGreaterThan, entities.RuleOperatorLessThan:
+			n, err := strconv.ParseFloat(r.Value, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s", entities.ErrInvalidSegmentRule, err)
+			}
+			op := ">"
+			if r.Operator == entities.RuleOperatorLessThan {
+				op = "<"
+			}
+			return "(" + metadataNumber(dialect, expr) + " " + op + " ?)", []interface{}{path, n}, nil
+		case entities.RuleOperatorBefore, entities.RuleOperatorAfter:
+			// the dates of the custom fields are stored in a format which sorts as strings.
+			d, err := (&entities.CustomField{Type: entities.CustomFieldTypeDate}).NormalizeValue(r.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s", entities.ErrInvalidSegmentRule, err)
+			}
+			op := "<"
+			if r.Operator == entities.RuleOperatorAfter {
+				op = ">"
+			}
+			return "(" + expr + " " + op + " ?)", []interface{}{path, d}, nil
+		}
+		return compileStringCondition(expr, []interface{}{path}, r)
+	case entities.RuleFieldActive, entities.RuleFieldBlacklisted:
+		return "(subscribers." + r.Field + " = ?)", []interface{}{r.Value == "true"}, nil
+	case entities.RuleFieldCreatedAt:
+		switch r.Operator {
+		case entities.RuleOperatorBefore, entities.RuleOperatorAfter:
+			t, err := time.Parse(time.RFC3339, r.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s", entities.ErrInvalidSegmentRule, err)
+			}
+			if r.Operator == entities.RuleOperatorBefore {
+				return "(subscribers.created_at < ?)", []interface{}{t}, nil
+			}
+			return "(subscribers.created_at > ?)", []interface{}{t}, nil
+		case entities.RuleOperatorWithinDays:
+			return "(subscribers.created_at >= ?)", []interface{}{daysAgo(now, r.Days)}, nil
+		case entities.RuleOperatorOlderThanDays:
+			return "(subscribers.created_at < ?)", []interface{}{daysAgo(now, r.Days)}, nil
+		}
+	case entities.RuleFieldSegment:
+		in := "IN"
+		if r.Operator == entities.RuleOperatorNotIn {
+			in = "NOT IN"
+		}
+		return "(subscribers.id " + in + " (SELECT subscriber_id FROM subscribers_segments WHERE segment_id = ?))",
+			[]interface{}{r.SegmentID}, nil
+	case entities.RuleFieldOpened, entities.RuleFieldClicked, entities.RuleFieldBounced, entities.RuleFieldComplained:
+		table := engagementTables[r.Field]
+		sql := "SELECT 1 FROM " + table + " e WHERE e.user_id = subscribers.user_id AND e.recipient = subscribers.email"
+		var args []interface{}
+		if r.CampaignID > 0 {
+			sql += " AND e.campaign_id = ?"
+			args = append(args, r.CampaignID)
+		}
+		if r.Days > 0 {
+			sql += " AND e.created_at >= ?"
+			args = append(args, daysAgo(now, r.Days))
+		}
+
+		exists := "EXISTS"
+		if r.Operator == entities.RuleOperatorNone {
+			exists = "NOT EXISTS"
+		}
+		return "(" + exists + " (" + sql + "))", args, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: the field '%s' does not support the operator '%s'",
+		entities.ErrInvalidSegmentRule, r.Field, r.Operator)
+}
+
+// compileStringCondition compiles the string operators on the given expression, the args
+// are the args of the expression itself. The negated operators also match the NULL values.
+func compileStringCondition(expr string, args []interface{}, r *entities.SegmentRule) (string, []interface{}, error) {
+	value := likeEscaper.Replace(r.Value)
+	switch r.Operator {
+	case entities.RuleOperatorEquals:
+		return "(" + expr + " = ?)", append(args, r.Value), nil
+	case entities.RuleOperatorNotEquals:
+		return "(" + expr + " IS NULL OR " + expr + " <> ?)", append(append(args, args...), r.Value), nil
+	case entities.RuleOperatorContains:
+		return "(" + expr + " LIKE ? ESCAPE '!')", append(args, "%"+value+"%"), nil
+	case entities.RuleOperatorNotContains:
+		return "(" + expr + " IS NULL OR " + expr + " NOT LIKE ? ESCAPE '!')", append(append(args, args...), "%"+value+"%"), nil
+	case entities.RuleOperatorStartsWith:
+		return "(" + expr + " LIKE ? ESCAPE '!')", append(args, value+"%"), nil
+	case entities.RuleOperatorEndsWith:
+		return "(" + expr + " LIKE ? ESCAPE '!')", append(args, "%"+value), nil
+	}
+
+	return "", nil, fmt.Errorf("%w: the field '%s' does not support the operator '%s'",
+		entities.ErrInvalidSegmentRule, r.Field, r.Operator)
+}
+
+// metadataNumber returns the expression of the number value of the metadata expression.
+func metadataNumber(dialect, expr string) string {
+	if dialect == "mysql" {
+		return "CAST(" + expr + " AS DECIMAL(65, 10))"
+	}
+	return "CAST(" + expr + " AS REAL)"
+}
+
+func daysAgo(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
+
+// compileSegmentsMembers compiles the condition matching the members of any of the segments,
+// the static segments match by their associations and the dynamic ones by their rules.
+func compileSegmentsMembers(dialect string, segs []entities.Segment, now time.Time) (string, []interface{}, error) {
+	var (
+		conds     []string
+		args      []interface{}
+		staticIDs []int64
+	)
+	for i := range segs {
+		if !segs[i].Dynamic {
+			staticIDs = append(staticIDs, segs[i].ID)
+			continue
+		}
+
+		r, err := segs[i].GetRules()
+		if err != nil {
+			return "", nil, fmt.Errorf("segment %d: get rules: %w", segs[i].ID, err)
+		}
+		sql, a, err := compileSegmentRule(dialect, r, now)
+		if err != nil {
+			return "", nil, fmt.Errorf("segment %d: compile rules: %w", segs[i].ID, err)
+		}
+		conds = append(conds, sql)
+		args = append(args, a...)
+	}
+
+	if len(staticIDs) > 0 {
+		conds = append([]string{"subscribers.id IN (SELECT subscriber_id FROM subscribers_segments WHERE segment_id IN (?))"}, conds...)
+		args = append([]interface{}{staticIDs}, args...)
+	}
+	if len(conds) == 0 {
+		return "1 = 0", nil, nil
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", args, nil
+}
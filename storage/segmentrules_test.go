@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSegmentRules(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	static := &entities.Segment{Name: "customers", UserID: 1}
+	err := store.CreateSegment(static)
+	assert.Nil(t, err)
+
+	var subs []*entities.Subscriber
+	for i := 0; i < 10; i++ {
+		s := &entities.Subscriber{
+			Name:     fmt.Sprintf("John %d", i),
+			Email:    fmt.Sprintf("john%d@example.com", i),
+			UserID:   1,
+			Active:   true,
+			MetaJSON: []byte(fmt.Sprintf(`{"city":"Skopje %d","age":"%d","birthday":"2000-01-%02d"}`, i, i*15, i+1)),
+		}
+		if i < 4 {
+			s.Segments = []entities.Segment{*static}
+		}
+		if i == 9 {
+			s.MetaJSON = []byte(`{"city":"Ohrid","plan-name":"pro"}`)
+		}
+		err = store.CreateSubscriber(s)
+		assert.Nil(t, err)
+		subs = append(subs, s)
+	}
+
+	// subscriber of another user with the same email
+	err = store.CreateSubscriber(&entities.Subscriber{Name: "John 0", Email: "john0@example.com", UserID: 2, Active: true})
+	assert.Nil(t, err)
+
+	old := time.Now().AddDate(0, 0, -60)
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 1, Recipient: "john1@example.com", CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 2, Recipient: "john2@example.com", CreatedAt: old}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 2, CampaignID: 3, Recipient: "john3@example.com", CreatedAt: time.Now()}))
+	assert.Nil(t, store.CreateClick(&entities.Click{UserID: 1, CampaignID: 2, Recipient: "john2@example.com", CreatedAt: old}))
+	assert.Nil(t, store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: 1, Recipient: "john5@example.com", CreatedAt: time.Now()}))
+
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name  string
+		rule  entities.SegmentRule
+		total int64
+	}{
+		{"email starts with", entities.SegmentRule{Field: entities.RuleFieldEmail, Operator: entities.RuleOperatorStartsWith, Value: "john1"}, 1},
+		{"email ends with", entities.SegmentRule{Field: entities.RuleFieldEmail, Operator: entities.RuleOperatorEndsWith, Value: "@example.com"}, 10},
+		{"name not equals", entities.SegmentRule{Field: entities.RuleFieldName, Operator: entities.RuleOperatorNotEquals, Value: "John 0"}, 9},
+		{"name not contains wildcard", entities.SegmentRule{Field: entities.RuleFieldName, Operator: entities.RuleOperatorNotContains, Value: "%"}, 10},
+		{"active", entities.SegmentRule{Field: entities.RuleFieldActive, Operator: entities.RuleOperatorEquals, Value: "false"}, 0},
+		{"metadata equals", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorEquals, Key: "city", Value: "Ohrid"}, 1},
+		{"metadata not equals", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorNotEquals, Key: "plan-name", Value: "pro"}, 9},
+		{"metadata contains", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorContains, Key: "city", Value: "skopje"}, 9},
+		{"metadata not exists", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorNotExists, Key: "plan-name"}, 9},
+		{"metadata greater than", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorGreaterThan, Key: "age", Value: "25"}, 7},
+		{"metadata less than", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorLessThan, Key: "age", Value: "25"}, 2},
+		{"metadata before", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorBefore, Key: "birthday", Value: "2000-01-03"}, 2},
+		{"metadata after", entities.SegmentRule{Field: entities.RuleFieldMetadata, Operator: entities.RuleOperatorAfter, Key: "birthday", Value: "2000-01-05T00:00:00Z"}, 4},
+		{"created before", entities.SegmentRule{Field: entities.RuleFieldCreatedAt, Operator: entities.RuleOperatorBefore, Value: future}, 10},
+		{"created within days", entities.SegmentRule{Field: entities.RuleFieldCreatedAt, Operator: entities.RuleOperatorWithinDays, Days: 1}, 10},
+		{"created older than days", entities.SegmentRule{Field: entities.RuleFieldCreatedAt, Operator: entities.RuleOperatorOlderThanDays, Days: 1}, 0},
+		{"in segment", entities.SegmentRule{Field: entities.RuleFieldSegment, Operator: entities.RuleOperatorIn, SegmentID: static.ID}, 4},
+		{"not in segment", entities.SegmentRule{Field: entities.RuleFieldSegment, Operator: entities.RuleOperatorNotIn, SegmentID: static.ID}, 6},
+		{"opened any", entities.SegmentRule{Field: entities.RuleFieldOpened, Operator: entities.RuleOperatorAny}, 2},
+		{"opened in the last 30 days", entities.SegmentRule{Field: entities.RuleFieldOpened, Operator: entities.RuleOperatorAny, Days: 30}, 1},
+		{"opened none", entities.SegmentRule{Field: entities.RuleFieldOpened, Operator: entities.RuleOperatorNone}, 8},
+		{"clicked campaign", entities.SegmentRule{Field: entities.RuleFieldClicked, Operator: entities.RuleOperatorAny, CampaignID: 2}, 1},
+		{"clicked other campaign", entities.SegmentRule{Field: entities.RuleFieldClicked, Operator: entities.RuleOperatorAny, CampaignID: 1}, 0},
+		{"bounced", entities.SegmentRule{Field: entities.RuleFieldBounced, Operator: entities.RuleOperatorAny}, 1},
+		{"complained", entities.SegmentRule{Field: entities.RuleFieldComplained, Operator: entities.RuleOperatorAny}, 0},
+		{"and group", entities.SegmentRule{
+			Combinator: entities.RuleCombinatorAnd,
+			Rules: []entities.SegmentRule{
+				{Field: entities.RuleFieldSegment, Operator: entities.RuleOperatorIn, SegmentID: static.ID},
+				{Field: entities.RuleFieldOpened, Operator: entities.RuleOperatorNone},
+			},
+		}, 2},
+		{"nested or group", entities.SegmentRule{
+			Combinator: entities.RuleCombinatorOr,
+			Rules: []entities.SegmentRule{
+				{Field: entities.RuleFieldBounced, Operator: entities.RuleOperatorAny},
+				{
+					Combinator: entities.RuleCombinatorAnd,
+					Rules: []entities.SegmentRule{
+						{Field: entities.RuleFieldOpened, Operator: entities.RuleOperatorAny},
+						{Field: entities.RuleFieldClicked, Operator: entities.RuleOperatorNone},
+					},
+				},
+			},
+		}, 2},
+	}
+
+	for _, tc := range tests {
+		assert.Nil(t, tc.rule.Validate(), tc.name)
+		total, err := store.GetTotalSubscribersByRules(1, &tc.rule)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.total, total, tc.name)
+	}
+
+	sample, err := store.GetSubscribersByRules(1, &entities.SegmentRule{Field: entities.RuleFieldEmail, Operator: entities.RuleOperatorContains, Value: "john"}, 3)
+	assert.Nil(t, err)
+	assert.Len(t, sample, 3)
+
+	// test the dynamic segments are resolved by their rules
+	dynamic := &entities.Segment{
+		Name:      "engaged",
+		UserID:    1,
+		Dynamic:   true,
+		RulesJSON: entities.JSON(`{"combinator":"or","rules":[{"field":"opened","operator":"any"},{"field":"bounced","operator":"any"}]}`),
+	}
+	err = store.CreateSegment(dynamic)
+	assert.Nil(t, err)
+
+	total, err := store.GetTotalSubscribersBySegment(dynamic.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+
+	total, err = store.GetTotalSubscribersBySegment(static.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+
+	p := NewPaginationCursor("/api/segments/1/subscribers", 10)
+	err = store.GetSubscribersBySegmentID(dynamic.ID, 1, p)
+	assert.Nil(t, err)
+	assert.Len(t, *p.Collection.(*[]entities.Subscriber), 3)
+
+	p = NewPaginationCursor("/api/segments/999/subscribers", 10)
+	err = store.GetSubscribersBySegmentID(999, 1, p)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	segs, err := store.GetDynamicSegments(1)
+	assert.Nil(t, err)
+	assert.Len(t, segs, 1)
+	assert.Equal(t, dynamic.ID, segs[0].ID)
+
+	p = NewPaginationCursor("/api/segments", 10)
+	err = store.GetSegments(1, p)
+	assert.Nil(t, err)
+	for _, seg := range *p.Collection.(*[]entities.SegmentWithTotalSubs) {
+		if seg.ID == dynamic.ID {
+			assert.Equal(t, int64(3), seg.SubscribersInSeg)
+		} else {
+			assert.Equal(t, int64(4), seg.SubscribersInSeg)
+		}
+	}
+
+	// test the campaign recipients of static and dynamic segments are distinct
+	recipients, err := store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID, dynamic.ID}, 1, false, true, time.Time{}, 0, 0)
+	assert.Nil(t, err)
+	var seen []int64
+	for _, s := range recipients {
+		seen = append(seen, s.ID)
+	}
+	// john0 to john3 are in the static segment, john1 and john2 opened and john5 bounced
+	assert.ElementsMatch(t, []int64{subs[0].ID, subs[1].ID, subs[2].ID, subs[3].ID, subs[5].ID}, seen)
+
+	recipients, err = store.GetDistinctSubscribersBySegmentIDs([]int64{dynamic.ID}, 1, false, true, time.Time{}, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, recipients, 3)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSnippets fetches snippets by user id, and populates the pagination obj
+func (db *store) GetSnippets(userID int64, p *PaginationCursor, scopeMap map[string]string) error {
+	p.SetCollection(&[]entities.Snippet{})
+	p.SetResource("snippets")
+
+	for k, v := range scopeMap {
+		if k == "name" {
+			p.AddScope(NameLike(v))
+		}
+	}
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetSnippet returns the snippet by the given id and user id
+func (db *store) GetSnippet(id, userID int64) (*entities.Snippet, error) {
+	var s = new(entities.Snippet)
+	err := db.Where("user_id = ? and id = ?", userID, id).Find(s).Error
+	return s, err
+}
+
+// GetSnippetByName returns the snippet by the given name and user id
+func (db *store) GetSnippetByName(name string, userID int64) (*entities.Snippet, error) {
+	var s = new(entities.Snippet)
+	err := db.Where("user_id = ? and name = ?", userID, name).Find(s).Error
+	return s, err
+}
+
+// GetAllSnippetsForUser fetches all snippets for user
+func (db *store) GetAllSnippetsForUser(userID int64) ([]entities.Snippet, error) {
+	var s []entities.Snippet
+	err := db.Where("user_id = ?", userID).Find(&s).Error
+	return s, err
+}
+
+// CreateSnippet creates a new snippet in the database.
+func (db *store) CreateSnippet(s *entities.Snippet) error {
+	return db.Create(s).Error
+}
+
+// UpdateSnippet edits an existing snippet in the database.
+func (db *store) UpdateSnippet(s *entities.Snippet) error {
+	return db.Where("id = ? and user_id = ?", s.ID, s.UserID).Save(s).Error
+}
+
+// DeleteSnippet deletes the snippet with the given id and user id from the database.
+func (db *store) DeleteSnippet(id, userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.Snippet{Model: entities.Model{ID: id}}).Error
+}
+
+// DeleteAllSnippetsForUser deletes all snippets for user
+func (db *store) DeleteAllSnippetsForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.Snippet{}).Error
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSnippet(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	// test create snippet
+	s := &entities.Snippet{
+		UserID: 1,
+		Name:   "footer",
+		Body:   "<p>Sent by {{company}}</p>",
+	}
+	err := store.CreateSnippet(s)
+	assert.Nil(t, err)
+
+	// test get snippet
+	s, err = store.GetSnippet(s.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "footer", s.Name)
+
+	// test get snippet by name
+	s, err = store.GetSnippetByName("footer", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Sent by {{company}}</p>", s.Body)
+
+	_, err = store.GetSnippetByName("footer", 2)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test update snippet
+	s.Body = "<p>Sent by {{company}} with love</p>"
+	err = store.UpdateSnippet(s)
+	assert.Nil(t, err)
+
+	s, err = store.GetSnippet(s.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Sent by {{company}} with love</p>", s.Body)
+
+	// test get snippets
+	p := NewPaginationCursor("/api/snippets", 10)
+	err = store.GetSnippets(1, p, map[string]string{"name": "foo"})
+	assert.Nil(t, err)
+	col := p.Collection.(*[]entities.Snippet)
+	assert.Len(t, *col, 1)
+
+	// test get all snippets for user
+	all, err := store.GetAllSnippetsForUser(1)
+	assert.Nil(t, err)
+	assert.Len(t, all, 1)
+
+	// test delete snippet
+	err = store.DeleteSnippet(s.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSnippet(s.ID, 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test delete all snippets for user
+	err = store.CreateSnippet(&entities.Snippet{UserID: 1, Name: "header", Body: "<h1>Hi</h1>"})
+	assert.Nil(t, err)
+	err = store.DeleteAllSnippetsForUser(1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSnippetByName("header", 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+}
@@ -57,6 +57,8 @@ type Storage interface {
 
 	GetSegments(int64, *PaginationCursor) error
 	GetSegmentsByIDs(userID int64, ids []int64) ([]entities.Segment, error)
+	GetPublicSegments(userID int64) ([]entities.Segment, error)
+	GetDynamicSegments(userID int64) ([]entities.Segment, error)
 	GetSegment(int64, int64) (*entities.Segment, error)
 	GetSegmentByName(name string, userID int64) (*entities.Segment, error)
 	GetTotalSegments(userID int64) (int64, error)
@@ -67,11 +69,12 @@ type Storage interface {
 	DetachSubscribers(*entities.Segment) error
 	DeleteAllSegmentsForUser(userID int64) error
 
-	GetSubscribers(userID int64, p *PaginationCursor, scopeMap map[string]string) error
+	GetSubscribers(userID int64, p *PaginationCursor, filter *entities.SubscriberFilter) error
 	GetSubscribersBySegmentID(int64, int64, *PaginationCursor) error
 	GetSubscriber(int64, int64) (*entities.Subscriber, error)
 	GetSubscribersByIDs([]int64, int64) ([]entities.Subscriber, error)
 	GetSubscriberByEmail(string, int64) (*entities.Subscriber, error)
+	GetSubscribersByEmails(emails []string, userID int64) ([]entities.Subscriber, error)
 	GetDistinctSubscribersBySegmentIDs(
 		listIDs []int64,
 		userID int64,
@@ -81,12 +84,26 @@ type Storage interface {
 	) ([]entities.Subscriber, error)
 	CreateSubscriber(*entities.Subscriber) error
 	UpdateSubscriber(*entities.Subscriber) error
+	SaveImportedSubscribers(created, updated []*entities.Subscriber) error
+	SaveBulkUpdatedSubscribers(subs []*entities.Subscriber, events []entities.SubscriberEvent) error
 	DeactivateSubscriber(userID int64, email string) error
+	MergeSubscribers(s *entities.Subscriber, duplicates []entities.Subscriber) error
+	NormalizeSubscriberEmails(dryRun bool) (int, []EmailDuplicate, error)
+	ConfirmSubscriber(*entities.Subscriber) error
+	UpdateSubscriberPreferences(s *entities.Subscriber, events []entities.SubscriberEvent) error
+	DeleteExpiredPendingSubscribers(before time.Time) (int64, error)
 	DeleteSubscriber(int64, int64) error
 	DeleteSubscriberByEmail(string, int64) error
 	GetTotalSubscribers(int64) (int64, error)
+	GetTotalMissingMetadata(userID int64, keys []string) (int64, map[string]int64, error)
 	GetTotalSubscribersBySegment(segmentID, userID int64) (int64, error)
+	GetTotalSubscribersByRules(userID int64, r *entities.SegmentRule) (int64, error)
+	GetSubscriberActivity(s *entities.Subscriber, types []string, after *entities.ActivityCursor, p *PaginationCursor) error
+	GetSubscribersByRules(userID int64, r *entities.SegmentRule, limit int64) ([]entities.Subscriber, error)
 	SeekSubscribersByUserID(userID int64, nextID int64, limit int64) ([]entities.Subscriber, error)
+	SeekSubscribersByFilter(userID int64, filter *entities.SubscriberFilter, nextID, limit int64) ([]entities.Subscriber, error)
+	GetTotalSubscribersByFilter(userID int64, filter *entities.SubscriberFilter) (int64, error)
+	DeleteSubscribers(userID int64, subs []entities.Subscriber) error
 	GetAllSubscribersForUser(userID int64) ([]entities.Subscriber, error)
 
 	GetAPIKeys(userID int64) ([]*entities.APIKey, error)
@@ -130,6 +147,18 @@ type Storage interface {
 	GetNumberOfReportsForDate(userID int64, time time.Time) (int64, error)
 	DeleteAllReportsForUser(userID int64) error
 
+	CreateSubscriberImport(i *entities.SubscriberImport) error
+	GetSubscriberImport(id, userID int64) (*entities.SubscriberImport, error)
+	UpdateSubscriberImport(i *entities.SubscriberImport, statuses ...string) (bool, error)
+	CancelSubscriberImport(id, userID int64) (bool, error)
+	DeleteAllSubscriberImportsForUser(userID int64) error
+
+	CreateSubscriberBulkAction(a *entities.SubscriberBulkAction) error
+	GetSubscriberBulkAction(id, userID int64) (*entities.SubscriberBulkAction, error)
+	UpdateSubscriberBulkAction(a *entities.SubscriberBulkAction, statuses ...string) (bool, error)
+	CancelSubscriberBulkAction(id, userID int64) (bool, error)
+	DeleteAllSubscriberBulkActionsForUser(userID int64) error
+
 	CreateTemplate(t *entities.Template) error
 	UpdateTemplate(t *entities.Template) error
 	GetTemplateByName(name string, userID int64) (*entities.Template, error)
@@ -137,6 +166,65 @@ type Storage interface {
 	GetTemplates(userID int64, p *PaginationCursor, scopeMap map[string]string) error
 	DeleteTemplate(templateID int64, userID int64) error
 	GetAllTemplatesForUser(userID int64) ([]entities.Template, error)
+	GetTotalTemplatesByLayoutID(layoutID, userID int64) (int64, error)
+
+	GetSampleDataSets(templateID, userID int64) ([]entities.SampleDataSet, error)
+	GetSampleDataSet(id, templateID, userID int64) (*entities.SampleDataSet, error)
+	GetSampleDataSetByName(name string, templateID, userID int64) (*entities.SampleDataSet, error)
+	CreateSampleDataSet(s *entities.SampleDataSet) error
+	UpdateSampleDataSet(s *entities.SampleDataSet) error
+	DeleteSampleDataSet(id, templateID, userID int64) error
+
+	GetSnippets(userID int64, p *PaginationCursor, scopeMap map[string]string) error
+	GetSnippet(id, userID int64) (*entities.Snippet, error)
+	GetSnippetByName(name string, userID int64) (*entities.Snippet, error)
+	GetAllSnippetsForUser(userID int64) ([]entities.Snippet, error)
+	CreateSnippet(s *entities.Snippet) error
+	UpdateSnippet(s *entities.Snippet) error
+	DeleteSnippet(id, userID int64) error
+	DeleteAllSnippetsForUser(userID int64) error
+
+	GetForms(userID int64, p *PaginationCursor) error
+	GetForm(id, userID int64) (*entities.Form, error)
+	GetFormByUUID(uuid string) (*entities.Form, error)
+	CreateForm(f *entities.Form) error
+	UpdateForm(f *entities.Form) error
+	DeleteForm(id, userID int64) error
+	DeleteAllFormsForUser(userID int64) error
+
+	GetSuppressions(userID int64, p *PaginationCursor, scopeMap map[string]string) error
+	GetSuppression(id, userID int64) (*entities.Suppression, error)
+	GetSuppressionByValue(userID int64, typ, value string) (*entities.Suppression, error)
+	GetSuppressionForEmail(userID int64, email string) (*entities.Suppression, error)
+	GetSuppressionsForEmails(userID int64, emails []string) ([]entities.Suppression, error)
+	SeekSuppressionsByUserID(userID, nextID, limit int64) ([]entities.Suppression, error)
+	CreateSuppression(s *entities.Suppression) error
+	AddSuppressions(userID int64, suppressions []*entities.Suppression) (int, error)
+	DeleteSuppression(id, userID int64) error
+	DeleteAllSuppressionsForUser(userID int64) error
+
+	GetSubscriberData(s *entities.Subscriber) (*entities.SubscriberData, error)
+	EraseSubscriber(s *entities.Subscriber, erasure *entities.GDPRErasure) error
+	GetGDPRErasures(userID int64, p *PaginationCursor) error
+	DeleteAllGDPRErasuresForUser(userID int64) error
+
+	SeekSubscribersToScore(scoredBefore time.Time, nextID, limit int64) ([]entities.Subscriber, error)
+	GetEngagementStats(userID int64, emails []string, since time.Time) (map[string]*entities.EngagementStats, error)
+	UpdateSubscribersEngagement(subs []entities.Subscriber, scoredAt time.Time) error
+	GetUnengagedCampaigns(userID int64, ids []int64) (map[int64]int64, error)
+	SunsetSubscribers(subs []entities.Subscriber, events []entities.SubscriberEvent) error
+	GetSunsetPolicy(userID int64) (*entities.SunsetPolicy, error)
+	SaveSunsetPolicy(p *entities.SunsetPolicy) error
+	DeleteSunsetPolicy(userID int64) error
+
+	GetCustomFields(userID int64) (entities.CustomFields, error)
+	GetCustomField(id, userID int64) (*entities.CustomField, error)
+	GetCustomFieldByKey(key string, userID int64) (*entities.CustomField, error)
+	CreateCustomField(f *entities.CustomField) error
+	UpdateCustomField(f *entities.CustomField) error
+	DeleteCustomField(id, userID int64) error
+	DeleteAllCustomFieldsForUser(userID int64) error
+	GetMetadataValueCounts(userID int64, key string) (map[string]int64, error)
 
 	DeleteAllEventsForUser(userID int64) error
 }
@@ -326,6 +414,16 @@ func GetSegmentsByIDs(c context.Context, userID int64, ids []int64) ([]entities.
 	return GetFromContext(c).GetSegmentsByIDs(userID, ids)
 }
 
+// GetPublicSegments fetches the segments listed in the preference center by user id.
+func GetPublicSegments(c context.Context, userID int64) ([]entities.Segment, error) {
+	return GetFromContext(c).GetPublicSegments(userID)
+}
+
+// GetDynamicSegments fetches the dynamic segments by user id.
+func GetDynamicSegments(c context.Context, userID int64) ([]entities.Segment, error) {
+	return GetFromContext(c).GetDynamicSegments(userID)
+}
+
 // GetSegment returns a Segment entity by the given id and user id.
 func GetSegment(c context.Context, id, userID int64) (*entities.Segment, error) {
 	return GetFromContext(c).GetSegment(id, userID)
@@ -367,9 +465,9 @@ func DetachSubscribers(c context.Context, l *entities.Segment) error {
 }
 
 // GetSubscribers populates a pagination object with a collection of
-// subscribers by the specified user id.
-func GetSubscribers(c context.Context, userID int64, p *PaginationCursor, scopeMap map[string]string) error {
-	return GetFromContext(c).GetSubscribers(userID, p, scopeMap)
+// subscribers by the specified user id and filter.
+func GetSubscribers(c context.Context, userID int64, p *PaginationCursor, filter *entities.SubscriberFilter) error {
+	return GetFromContext(c).GetSubscribers(userID, p, filter)
 }
 
 // GetSubscribersBySegmentID populates a pagination object with a collection of
@@ -415,11 +513,26 @@ func UpdateSubscriber(c context.Context, s *entities.Subscriber) error {
 	return GetFromContext(c).UpdateSubscriber(s)
 }
 
+// MergeSubscribers stores the subscriber which the duplicates were merged into and deletes the duplicates.
+func MergeSubscribers(c context.Context, s *entities.Subscriber, duplicates []entities.Subscriber) error {
+	return GetFromContext(c).MergeSubscribers(s, duplicates)
+}
+
 // DeactivateSubscriber blacklists a Subscriber entity by the given email.
 func DeactivateSubscriber(c context.Context, userID int64, email string) error {
 	return GetFromContext(c).DeactivateSubscriber(userID, email)
 }
 
+// ConfirmSubscriber activates a pending Subscriber entity.
+func ConfirmSubscriber(c context.Context, s *entities.Subscriber) error {
+	return GetFromContext(c).ConfirmSubscriber(s)
+}
+
+// UpdateSubscriberPreferences stores the changes made in the preference center of the Subscriber entity.
+func UpdateSubscriberPreferences(c context.Context, s *entities.Subscriber, events []entities.SubscriberEvent) error {
+	return GetFromContext(c).UpdateSubscriberPreferences(s, events)
+}
+
 // DeleteSubscriber deletes a Subscriber entity by the given id.
 func DeleteSubscriber(c context.Context, id, userID int64) error {
 	return GetFromContext(c).DeleteSubscriber(id, userID)
@@ -440,6 +553,27 @@ func GetTotalSubscribersBySegment(c context.Context, segmentID, userID int64) (i
 	return GetFromContext(c).GetTotalSubscribersBySegment(segmentID, userID)
 }
 
+// GetSubscriberActivity populates a pagination object with the activity timeline of the subscriber.
+func GetSubscriberActivity(
+	c context.Context,
+	s *entities.Subscriber,
+	types []string,
+	after *entities.ActivityCursor,
+	p *PaginationCursor,
+) error {
+	return GetFromContext(c).GetSubscriberActivity(s, types, after, p)
+}
+
+// GetTotalSubscribersByRules counts the subscribers of the user who match the rule tree.
+func GetTotalSubscribersByRules(c context.Context, userID int64, r *entities.SegmentRule) (int64, error) {
+	return GetFromContext(c).GetTotalSubscribersByRules(userID, r)
+}
+
+// GetSubscribersByRules fetches the latest subscribers of the user who match the rule tree.
+func GetSubscribersByRules(c context.Context, userID int64, r *entities.SegmentRule, limit int64) ([]entities.Subscriber, error) {
+	return GetFromContext(c).GetSubscribersByRules(userID, r, limit)
+}
+
 // SeekSubscribersByUserID returns subscribers for given user id
 func SeekSubscribersByUserID(c context.Context, userID, nextID, limit int64) ([]entities.Subscriber, error) {
 	return GetFromContext(c).SeekSubscribersByUserID(userID, nextID, limit)
@@ -556,6 +690,26 @@ func GetNumberOfReportsForDate(c context.Context, userID int64, time time.Time)
 	return GetFromContext(c).GetNumberOfReportsForDate(userID, time)
 }
 
+// CreateSubscriberImport adds a new subscriber import in the database.
+func CreateSubscriberImport(c context.Context, i *entities.SubscriberImport) error {
+	return GetFromContext(c).CreateSubscriberImport(i)
+}
+
+// GetSubscriberImport returns the subscriber import by the given id and user id.
+func GetSubscriberImport(c context.Context, id, userID int64) (*entities.SubscriberImport, error) {
+	return GetFromContext(c).GetSubscriberImport(id, userID)
+}
+
+// UpdateSubscriberImport updates the subscriber import when it's in one of the given statuses.
+func UpdateSubscriberImport(c context.Context, i *entities.SubscriberImport, statuses ...string) (bool, error) {
+	return GetFromContext(c).UpdateSubscriberImport(i, statuses...)
+}
+
+// CancelSubscriberImport cancels the subscriber import when it's pending or in progress.
+func CancelSubscriberImport(c context.Context, id, userID int64) (bool, error) {
+	return GetFromContext(c).CancelSubscriberImport(id, userID)
+}
+
 // GetTemplateByName returns a Template entity by the given name and user id.
 func GetTemplateByName(c context.Context, name string, userID int64) (*entities.Template, error) {
 	return GetFromContext(c).GetTemplateByName(name, userID)
@@ -577,6 +731,98 @@ func DeleteTemplate(c context.Context, templateID int64, userID int64) error {
 	return GetFromContext(c).DeleteTemplate(templateID, userID)
 }
 
+// GetSampleDataSets returns the sample data sets of the template by the given template id and user id.
+func GetSampleDataSets(c context.Context, templateID, userID int64) ([]entities.SampleDataSet, error) {
+	return GetFromContext(c).GetSampleDataSets(templateID, userID)
+}
+
+// GetSampleDataSet returns a SampleDataSet entity by the given id, template id and user id.
+func GetSampleDataSet(c context.Context, id, templateID, userID int64) (*entities.SampleDataSet, error) {
+	return GetFromContext(c).GetSampleDataSet(id, templateID, userID)
+}
+
+// GetSampleDataSetByName returns a SampleDataSet entity by the given name, template id and user id.
+func GetSampleDataSetByName(c context.Context, name string, templateID, userID int64) (*entities.SampleDataSet, error) {
+	return GetFromContext(c).GetSampleDataSetByName(name, templateID, userID)
+}
+
+// CreateSampleDataSet persists a new SampleDataSet entity in the datastore.
+func CreateSampleDataSet(c context.Context, s *entities.SampleDataSet) error {
+	return GetFromContext(c).CreateSampleDataSet(s)
+}
+
+// UpdateSampleDataSet updates a SampleDataSet entity.
+func UpdateSampleDataSet(c context.Context, s *entities.SampleDataSet) error {
+	return GetFromContext(c).UpdateSampleDataSet(s)
+}
+
+// DeleteSampleDataSet deletes a SampleDataSet entity by the given id, template id and user id.
+func DeleteSampleDataSet(c context.Context, id, templateID, userID int64) error {
+	return GetFromContext(c).DeleteSampleDataSet(id, templateID, userID)
+}
+
+// GetSnippets populates a pagination object with a collection of
+// snippets by the specified user id.
+func GetSnippets(c context.Context, userID int64, p *PaginationCursor, scopeMap map[string]string) error {
+	return GetFromContext(c).GetSnippets(userID, p, scopeMap)
+}
+
+// GetSnippet returns a Snippet entity by the given id and user id.
+func GetSnippet(c context.Context, id, userID int64) (*entities.Snippet, error) {
+	return GetFromContext(c).GetSnippet(id, userID)
+}
+
+// GetSnippetByName returns a Snippet entity by the given name and user id.
+func GetSnippetByName(c context.Context, name string, userID int64) (*entities.Snippet, error) {
+	return GetFromContext(c).GetSnippetByName(name, userID)
+}
+
+// CreateSnippet persists a new Snippet entity in the datastore.
+func CreateSnippet(c context.Context, s *entities.Snippet) error {
+	return GetFromContext(c).CreateSnippet(s)
+}
+
+// UpdateSnippet updates a Snippet entity.
+func UpdateSnippet(c context.Context, s *entities.Snippet) error {
+	return GetFromContext(c).UpdateSnippet(s)
+}
+
+// DeleteSnippet deletes a Snippet entity by the given id and user id.
+func DeleteSnippet(c context.Context, id, userID int64) error {
+	return GetFromContext(c).DeleteSnippet(id, userID)
+}
+
+// GetForms populates a pagination object with a collection of
+// forms by the specified user id.
+func GetForms(c context.Context, userID int64, p *PaginationCursor) error {
+	return GetFromContext(c).GetForms(userID, p)
+}
+
+// GetForm returns a Form entity by the given id and user id.
+func GetForm(c context.Context, id, userID int64) (*entities.Form, error) {
+	return GetFromContext(c).GetForm(id, userID)
+}
+
+// GetFormByUUID returns a Form entity by the given uuid.
+func GetFormByUUID(c context.Context, uuid string) (*entities.Form, error) {
+	return GetFromContext(c).GetFormByUUID(uuid)
+}
+
+// CreateForm persists a new Form entity in the datastore.
+func CreateForm(c context.Context, f *entities.Form) error {
+	return GetFromContext(c).CreateForm(f)
+}
+
+// UpdateForm updates a Form entity.
+func UpdateForm(c context.Context, f *entities.Form) error {
+	return GetFromContext(c).UpdateForm(f)
+}
+
+// DeleteForm deletes a Form entity by the given id and user id.
+func DeleteForm(c context.Context, id, userID int64) error {
+	return GetFromContext(c).DeleteForm(id, userID)
+}
+
 // CreateSendLog creates a SendLogs entity.
 func CreateSendLog(c context.Context, sendLogs *entities.SendLog) error {
 	return GetFromContext(c).CreateSendLog(sendLogs)
@@ -586,3 +832,134 @@ func CreateSendLog(c context.Context, sendLogs *entities.SendLog) error {
 func GetSendLogByUUID(c context.Context, id string) (*entities.SendLog, error) {
 	return GetFromContext(c).GetSendLogByUUID(id)
 }
+
+// GetSuppressions populates a pagination object with a collection of
+// suppressions by the specified user id.
+func GetSuppressions(c context.Context, userID int64, p *PaginationCursor, scopeMap map[string]string) error {
+	return GetFromContext(c).GetSuppressions(userID, p, scopeMap)
+}
+
+// GetSuppression returns a Suppression entity by the given id and user id.
+func GetSuppression(c context.Context, id, userID int64) (*entities.Suppression, error) {
+	return GetFromContext(c).GetSuppression(id, userID)
+}
+
+// GetSuppressionByValue returns the Suppression entity of the email or the domain.
+func GetSuppressionByValue(c context.Context, userID int64, typ, value string) (*entities.Suppression, error) {
+	return GetFromContext(c).GetSuppressionByValue(userID, typ, value)
+}
+
+// GetSuppressionForEmail returns the Suppression entity which applies to the email or its domain.
+func GetSuppressionForEmail(c context.Context, userID int64, email string) (*entities.Suppression, error) {
+	return GetFromContext(c).GetSuppressionForEmail(userID, email)
+}
+
+// SeekSuppressionsByUserID returns the Suppression entities of the user with id greater than nextID.
+func SeekSuppressionsByUserID(c context.Context, userID, nextID, limit int64) ([]entities.Suppression, error) {
+	return GetFromContext(c).SeekSuppressionsByUserID(userID, nextID, limit)
+}
+
+// CreateSuppression persists a new Suppression entity in the datastore.
+func CreateSuppression(c context.Context, s *entities.Suppression) error {
+	return GetFromContext(c).CreateSuppression(s)
+}
+
+// AddSuppressions persists the Suppression entities which don't exist yet.
+func AddSuppressions(c context.Context, userID int64, suppressions []*entities.Suppression) (int, error) {
+	return GetFromContext(c).AddSuppressions(userID, suppressions)
+}
+
+// DeleteSuppression deletes a Suppression entity by the given id and user id.
+func DeleteSuppression(c context.Context, id, userID int64) error {
+	return GetFromContext(c).DeleteSuppression(id, userID)
+}
+
+// GetSubscriberData returns everything that is stored about the subscriber.
+func GetSubscriberData(c context.Context, s *entities.Subscriber) (*entities.SubscriberData, error) {
+	return GetFromContext(c).GetSubscriberData(s)
+}
+
+// EraseSubscriber erases the subscriber's data and stores the erasure.
+func EraseSubscriber(c context.Context, s *entities.Subscriber, erasure *entities.GDPRErasure) error {
+	return GetFromContext(c).EraseSubscriber(s, erasure)
+}
+
+// GetGDPRErasures populates a pagination object with a collection of erasures by the specified user id.
+func GetGDPRErasures(c context.Context, userID int64, p *PaginationCursor) error {
+	return GetFromContext(c).GetGDPRErasures(userID, p)
+}
+
+// GetSunsetPolicy returns the SunsetPolicy entity of the user.
+func GetSunsetPolicy(c context.Context, userID int64) (*entities.SunsetPolicy, error) {
+	return GetFromContext(c).GetSunsetPolicy(userID)
+}
+
+// SaveSunsetPolicy creates or updates the SunsetPolicy entity of the user.
+func SaveSunsetPolicy(c context.Context, p *entities.SunsetPolicy) error {
+	return GetFromContext(c).SaveSunsetPolicy(p)
+}
+
+// DeleteSunsetPolicy deletes the SunsetPolicy entity of the user.
+func DeleteSunsetPolicy(c context.Context, userID int64) error {
+	return GetFromContext(c).DeleteSunsetPolicy(userID)
+}
+
+// GetCustomFields returns all CustomField entities of the user.
+func GetCustomFields(c context.Context, userID int64) (entities.CustomFields, error) {
+	return GetFromContext(c).GetCustomFields(userID)
+}
+
+// GetCustomField returns a CustomField entity by the given id and user id.
+func GetCustomField(c context.Context, id, userID int64) (*entities.CustomField, error) {
+	return GetFromContext(c).GetCustomField(id, userID)
+}
+
+// GetCustomFieldByKey returns a CustomField entity by the given key and user id.
+func GetCustomFieldByKey(c context.Context, key string, userID int64) (*entities.CustomField, error) {
+	return GetFromContext(c).GetCustomFieldByKey(key, userID)
+}
+
+// CreateCustomField persists a new CustomField entity in the datastore.
+func CreateCustomField(c context.Context, f *entities.CustomField) error {
+	return GetFromContext(c).CreateCustomField(f)
+}
+
+// UpdateCustomField updates a CustomField entity.
+func UpdateCustomField(c context.Context, f *entities.CustomField) error {
+	return GetFromContext(c).UpdateCustomField(f)
+}
+
+// DeleteCustomField deletes a CustomField entity by the given id and user id.
+func DeleteCustomField(c context.Context, id, userID int64) error {
+	return GetFromContext(c).DeleteCustomField(id, userID)
+}
+
+// GetMetadataValueCounts returns the distinct values of the metadata key along with the number of the subscribers.
+func GetMetadataValueCounts(c context.Context, userID int64, key string) (map[string]int64, error) {
+	return GetFromContext(c).GetMetadataValueCounts(userID, key)
+}
+
+// GetTotalSubscribersByFilter returns the number of the subscribers who match the filter.
+func GetTotalSubscribersByFilter(c context.Context, userID int64, filter *entities.SubscriberFilter) (int64, error) {
+	return GetFromContext(c).GetTotalSubscribersByFilter(userID, filter)
+}
+
+// CreateSubscriberBulkAction adds a new subscriber bulk action in the database.
+func CreateSubscriberBulkAction(c context.Context, a *entities.SubscriberBulkAction) error {
+	return GetFromContext(c).CreateSubscriberBulkAction(a)
+}
+
+// GetSubscriberBulkAction returns the subscriber bulk action by the given id and user id.
+func GetSubscriberBulkAction(c context.Context, id, userID int64) (*entities.SubscriberBulkAction, error) {
+	return GetFromContext(c).GetSubscriberBulkAction(id, userID)
+}
+
+// UpdateSubscriberBulkAction updates the subscriber bulk action when it's in one of the given statuses.
+func UpdateSubscriberBulkAction(c context.Context, a *entities.SubscriberBulkAction, statuses ...string) (bool, error) {
+	return GetFromContext(c).UpdateSubscriberBulkAction(a, statuses...)
+}
+
+// CancelSubscriberBulkAction cancels the subscriber bulk action when it's pending or in progress.
+func CancelSubscriberBulkAction(c context.Context, id, userID int64) (bool, error) {
+	return GetFromContext(c).CancelSubscriberBulkAction(id, userID)
+}
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -10,15 +12,17 @@ import (
 	"github.com/mailbadger/app/entities"
 )
 
-// GetSubscribers fetches subscribers by user id, and populates the pagination obj
-func (db *store) GetSubscribers(userID int64, p *PaginationCursor, scopeMap map[string]string) error {
+// GetSubscribers fetches subscribers by user id and the given filter, and populates the pagination obj
+func (db *store) GetSubscribers(userID int64, p *PaginationCursor, filter *entities.SubscriberFilter) error {
 	p.SetCollection(&[]entities.Subscriber{})
 	p.SetResource("subscribers")
 
-	for k, v := range scopeMap {
-		if k == "email" {
-			p.AddScope(EmailLike(v))
+	if !filter.IsEmpty() {
+		scope, err := db.subscriberFilter(userID, filter)
+		if err != nil {
+			return fmt.Errorf("subscription store: %w", err)
 		}
+		p.AddScope(scope)
 	}
 
 	query := db.Table(p.Resource).
@@ -31,19 +35,25 @@ func (db *store) GetSubscribers(userID int64, p *PaginationCursor, scopeMap map[
 	return db.Paginate(p, userID)
 }
 
-// EmailLike applies a scope for subscribers by the given email.
-// The wildcard is applied on the end of the email search.
-func EmailLike(email string) func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Where("email LIKE ?", email+"%")
-	}
-}
-
 // GetSubscribersBySegmentID fetches subscribers by user id and list id, and populates the pagination obj
 func (db *store) GetSubscribersBySegmentID(segmentID, userID int64, p *PaginationCursor) error {
 	p.SetCollection(&[]entities.Subscriber{})
 	p.SetResource("subscribers")
-	p.SetScopes(BelongsToUser(userID), BelongsToSegment(segmentID))
+
+	seg, err := db.GetSegment(segmentID, userID)
+	if err != nil {
+		return fmt.Errorf("subscription store: get segment: %w", err)
+	}
+
+	if seg.Dynamic {
+		r, err := seg.GetRules()
+		if err != nil {
+			return fmt.Errorf("subscription store: get segment rules: %w", err)
+		}
+		p.SetScopes(BelongsToUser(userID), SegmentRules(db.Dialect().GetName(), r))
+	} else {
+		p.SetScopes(BelongsToUser(userID), BelongsToSegment(segmentID))
+	}
 
 	query := db.Table(p.Resource).
 		Order("created_at desc, id desc").
@@ -69,10 +79,70 @@ func (db *store) GetTotalSubscribers(userID int64) (int64, error) {
 	return count, err
 }
 
+// GetTotalMissingMetadata counts the active subscribers of the user which are not blacklisted,
+// and the number of them whose metadata does not contain each of the given keys. The name key
+// is only missing when the subscriber has no name either, since the name is used in its place.
+func (db *store) GetTotalMissingMetadata(userID int64, keys []string) (int64, map[string]int64, error) {
+	var (
+		cols = []string{"COUNT(*)"}
+		args []interface{}
+	)
+	for _, k := range keys {
+		cond := "JSON_EXTRACT(metadata, ?) IS NULL"
+		if k == entities.TagName {
+			cond = "(name IS NULL OR name = '') AND " + cond
+		}
+		cols = append(cols, "COALESCE(SUM(CASE WHEN "+cond+" THEN 1 ELSE 0 END), 0)")
+		args = append(args, metadataPath(k))
+	}
+
+	rows, err := db.Model(&entities.Subscriber{}).
+		Select(strings.Join(cols, ", "), args...).
+		Where("user_id = ? AND active = ? AND blacklisted = ?", userID, true, false).
+		Rows()
+	if err != nil {
+		return 0, nil, fmt.Errorf("subscriber store: count missing metadata: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]int64, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, nil, fmt.Errorf("subscriber store: scan missing metadata: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("subscriber store: count missing metadata: %w", err)
+	}
+
+	missing := make(map[string]int64, len(keys))
+	for i, k := range keys {
+		missing[k] = counts[i+1]
+	}
+
+	return counts[0], missing, nil
+}
+
 // GetTotalSubscribersBySegment fetches the total count by user and segment id.
 func (db *store) GetTotalSubscribersBySegment(segmentID, userID int64) (int64, error) {
 	var seg = entities.Segment{Model: entities.Model{ID: segmentID}}
 
+	err := db.Where("user_id = ?", userID).First(&seg).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return 0, err
+	}
+	if seg.Dynamic {
+		r, err := seg.GetRules()
+		if err != nil {
+			return 0, err
+		}
+		return db.GetTotalSubscribersByRules(userID, r)
+	}
+
 	assoc := db.Model(&seg).Where("user_id = ?", userID).Association("Subscribers")
 	return int64(assoc.Count()), assoc.Error
 }
@@ -91,14 +161,39 @@ func (db *store) GetSubscribersByIDs(ids []int64, userID int64) ([]entities.Subs
 	return s, err
 }
 
-// GetSubscriberByEmail returns the subscriber by the given email and user id
+// GetSubscriberByEmail returns the subscriber by the given email and user id, see findSubscriberByEmail.
 func (db *store) GetSubscriberByEmail(email string, userID int64) (*entities.Subscriber, error) {
+	return findSubscriberByEmail(db.DB, userID, email)
+}
+
+// findSubscriberByEmail finds the subscriber by the exact email first and falls back to the normalized
+// email, so the subscribers whose emails were stored before they were normalized are found as well,
+// e.g. the duplicates which are left as they are by NormalizeSubscriberEmails.
+func findSubscriberByEmail(db *gorm.DB, userID int64, email string) (*entities.Subscriber, error) {
 	var s = new(entities.Subscriber)
 	err := db.Where("user_id = ? and email = ?", userID, email).Find(s).Error
+	if normalized := entities.NormalizeEmail(email); errors.Is(err, gorm.ErrRecordNotFound) && normalized != email {
+		s = new(entities.Subscriber)
+		err = db.Where("user_id = ? and email = ?", userID, normalized).Find(s).Error
+	}
+	return s, err
+}
+
+// GetSubscribersByEmails returns the subscribers by the given emails and user id, along with their segments.
+// The emails are normalized.
+func (db *store) GetSubscribersByEmails(emails []string, userID int64) ([]entities.Subscriber, error) {
+	var s []entities.Subscriber
+	normalized := make([]string, len(emails))
+	for i, e := range emails {
+		normalized[i] = entities.NormalizeEmail(e)
+	}
+	err := db.Preload("Segments").Where("user_id = ? and email in (?)", userID, normalized).Find(&s).Error
 	return s, err
 }
 
-// GetDistinctSubscribersBySegmentIDs fetches all distinct subscribers by user id and list ids
+// GetDistinctSubscribersBySegmentIDs fetches all distinct subscribers by user id and list ids,
+// the members of the dynamic segments are resolved by their rules. The subscribers whose emails
+// or domains are suppressed are left out.
 func (db *store) GetDistinctSubscribersBySegmentIDs(
 	listIDs []int64,
 	userID int64,
@@ -111,22 +206,33 @@ func (db *store) GetDistinctSubscribersBySegmentIDs(
 		limit = 1000
 	}
 
+	segs, err := db.GetSegmentsByIDs(userID, listIDs)
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: get segments: %w", err)
+	}
+
+	members, args, err := compileSegmentsMembers(db.Dialect().GetName(), segs, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: %w", err)
+	}
+
 	var subs []entities.Subscriber
 
-	err := db.Table("subscribers").
-		Select("DISTINCT(id), name, email, created_at, metadata").
-		Joins("INNER JOIN subscribers_segments ON subscribers_segments.subscriber_id = subscribers.id").
+	err = db.Table("subscribers").
+		Select("id, name, email, created_at, metadata").
+		Where(members, args...).
+		Where(notSuppressed).
 		Where(`
-			subscribers_segments.segment_id IN (?)
-			AND subscribers.user_id = ? 
+			subscribers.user_id = ? 
 			AND subscribers.blacklisted = ? 
 			AND subscribers.active = ?
+			AND (subscribers.paused_until IS NULL OR subscribers.paused_until < ?)
 			AND (created_at > ? OR (created_at = ? AND id > ?))
 			AND created_at < ?`,
-			listIDs,
 			userID,
 			blacklisted,
 			active,
+			time.Now(),
 			timestamp.Format(time.RFC3339Nano),
 			timestamp.Format(time.RFC3339Nano),
 			nextID,
@@ -138,8 +244,30 @@ func (db *store) GetDistinctSubscribersBySegmentIDs(
 	return subs, err
 }
 
+// GetTotalSubscribersByRules counts the subscribers of the user who match the rule tree.
+func (db *store) GetTotalSubscribersByRules(userID int64, r *entities.SegmentRule) (int64, error) {
+	var count int64
+	err := db.Model(entities.Subscriber{}).
+		Scopes(BelongsToUser(userID), SegmentRules(db.Dialect().GetName(), r)).
+		Count(&count).Error
+	return count, err
+}
+
+// GetSubscribersByRules fetches the latest subscribers of the user who match the rule tree.
+func (db *store) GetSubscribersByRules(userID int64, r *entities.SegmentRule, limit int64) ([]entities.Subscriber, error) {
+	var subs []entities.Subscriber
+	err := db.Scopes(BelongsToUser(userID), SegmentRules(db.Dialect().GetName(), r)).
+		Order("created_at desc, id desc").
+		Limit(limit).
+		Find(&subs).Error
+	return subs, err
+}
+
 // CreateSubscriber creates a new subscriber and create subscribers event in the database.
+// The email of the subscriber is normalized.
 func (db *store) CreateSubscriber(s *entities.Subscriber) error {
+	s.Email = entities.NormalizeEmail(s.Email)
+
 	tx := db.Begin()
 
 	defer func() {
@@ -188,8 +316,94 @@ func (db *store) UpdateSubscriber(s *entities.Subscriber) error {
 	return tx.Commit().Error
 }
 
+// SaveImportedSubscribers creates and updates the imported subscribers in a single transaction,
+// the segments of the updated subscribers are replaced and the created ones get the created events.
+// The emails of the created subscribers are normalized.
+func (db *store) SaveImportedSubscribers(created, updated []*entities.Subscriber) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, s := range created {
+		s.Email = entities.NormalizeEmail(s.Email)
+		if err := tx.Create(s).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: create subscriber: %w", err)
+		}
+
+		if err := tx.Create(&entities.SubscriberEvent{
+			ID:              ksuid.New(),
+			UserID:          s.UserID,
+			SubscriberEmail: s.Email,
+			EventType:       entities.SubscriberEventTypeCreated,
+		}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: add subscriber event (created): %w", err)
+		}
+	}
+
+	for _, s := range updated {
+		if err := tx.Model(s).Association("Segments").Replace(s.Segments).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: update subscriber's segment: %w", err)
+		}
+
+		if err := tx.Where("id = ? and user_id = ?", s.ID, s.UserID).Save(s).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: update subscriber: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// SaveBulkUpdatedSubscribers stores the names, the metadata, the flags and the segments of the
+// subscribers which were updated in bulk, along with the events of the changes, all of them in a
+// single transaction. The user ids and the emails of the events are set by the caller.
+func (db *store) SaveBulkUpdatedSubscribers(subs []*entities.Subscriber, events []entities.SubscriberEvent) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, s := range subs {
+		if err := tx.Model(s).Association("Segments").Replace(s.Segments).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: update subscriber's segment: %w", err)
+		}
+
+		if err := tx.Model(&entities.Subscriber{}).
+			Where("id = ? AND user_id = ?", s.ID, s.UserID).
+			Updates(map[string]interface{}{
+				"name":        s.Name,
+				"metadata":    s.MetaJSON,
+				"active":      s.Active,
+				"blacklisted": s.Blacklisted,
+			}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: update subscriber: %w", err)
+		}
+	}
+
+	for i := range events {
+		events[i].ID = ksuid.New()
+		if err := tx.Create(&events[i]).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: add subscriber event (%s): %w", events[i].EventType, err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
 // DeactivateSubscriber de-activates a subscriber by the given user and email
-// and adds unsubscribed subscriber event.
+// and adds unsubscribed subscriber event. The subscriber is found by the exact
+// email first and by the normalized email otherwise, see findSubscriberByEmail.
 func (db *store) DeactivateSubscriber(userID int64, email string) error {
 	tx := db.Begin()
 
@@ -199,11 +413,21 @@ func (db *store) DeactivateSubscriber(userID int64, email string) error {
 		}
 	}()
 
-	if err := tx.Model(&entities.Subscriber{}).
-		Where("user_id = ? AND email = ?", userID, email).
-		Update("active", false).Error; err != nil {
+	s, err := findSubscriberByEmail(tx, userID, email)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		email = entities.NormalizeEmail(email)
+	case err != nil:
 		tx.Rollback()
-		return fmt.Errorf("subscription store: deactivate subscriber: %w", err)
+		return fmt.Errorf("subscription store: find subscriber: %w", err)
+	default:
+		email = s.Email
+		if err := tx.Model(&entities.Subscriber{}).
+			Where("user_id = ? AND id = ?", userID, s.ID).
+			Update("active", false).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: deactivate subscriber: %w", err)
+		}
 	}
 
 	if err := tx.Create(&entities.SubscriberEvent{
@@ -219,6 +443,139 @@ func (db *store) DeactivateSubscriber(userID int64, email string) error {
 	return tx.Commit().Error
 }
 
+// ConfirmSubscriber activates a pending subscriber, stores the time and the ip
+// of the confirmation and adds confirmed subscriber event.
+func (db *store) ConfirmSubscriber(s *entities.Subscriber) error {
+	tx := db.Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&entities.Subscriber{}).
+		Where("id = ? AND user_id = ?", s.ID, s.UserID).
+		Updates(map[string]interface{}{
+			"active":          true,
+			"pending":         false,
+			"confirmed_at":    s.ConfirmedAt,
+			"confirmation_ip": s.ConfirmationIP,
+		}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: confirm subscriber: %w", err)
+	}
+
+	if err := tx.Create(&entities.SubscriberEvent{
+		ID:              ksuid.New(),
+		UserID:          s.UserID,
+		SubscriberEmail: s.Email,
+		EventType:       entities.SubscriberEventTypeConfirmed,
+	}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: add subscriber event (confirmed): %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// UpdateSubscriberPreferences stores the changes the subscriber made in the preference center,
+// the segments of the subscriber are replaced and the given events are added.
+func (db *store) UpdateSubscriberPreferences(s *entities.Subscriber, events []entities.SubscriberEvent) error {
+	tx := db.Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(s).Association("Segments").Replace(s.Segments).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: update subscriber's segment: %w", err)
+	}
+
+	if err := tx.Model(&entities.Subscriber{}).
+		Where("id = ? AND user_id = ?", s.ID, s.UserID).
+		Updates(map[string]interface{}{
+			"name":         s.Name,
+			"metadata":     s.MetaJSON,
+			"paused_until": s.PausedUntil,
+		}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: update subscriber preferences: %w", err)
+	}
+
+	for i := range events {
+		events[i].ID = ksuid.New()
+		events[i].UserID = s.UserID
+		events[i].SubscriberEmail = s.Email
+		if err := tx.Create(&events[i]).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: add subscriber event (%s): %w", events[i].EventType, err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteExpiredPendingSubscribers deletes the pending subscribers which were created
+// before the given time and haven't confirmed their subscription, along with their
+// segment relations, and adds deleted subscriber events. It returns the number of
+// deleted subscribers.
+func (db *store) DeleteExpiredPendingSubscribers(before time.Time) (int64, error) {
+	tx := db.Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var subs []entities.Subscriber
+	err := tx.Select("id, user_id, email").
+		Where("pending = ? AND created_at < ?", true, before).
+		Find(&subs).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("subscription store: find expired subscribers: %w", err)
+	}
+
+	if len(subs) == 0 {
+		return 0, tx.Commit().Error
+	}
+
+	ids := make([]int64, len(subs))
+	for i, s := range subs {
+		ids[i] = s.ID
+	}
+
+	if err := tx.Exec("DELETE FROM subscribers_segments WHERE subscriber_id IN (?)", ids).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("subscription store: delete expired subscribers' segment relation: %w", err)
+	}
+
+	res := tx.Where("id IN (?)", ids).Delete(&entities.Subscriber{})
+	if res.Error != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("subscription store: delete expired subscribers: %w", res.Error)
+	}
+
+	for _, s := range subs {
+		if err := tx.Create(&entities.SubscriberEvent{
+			ID:              ksuid.New(),
+			UserID:          s.UserID,
+			SubscriberEmail: s.Email,
+			EventType:       entities.SubscriberEventTypeDeleted,
+		}).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("subscription store: add subscriber event (deleted): %w", err)
+		}
+	}
+
+	return res.RowsAffected, tx.Commit().Error
+}
+
 // DeleteSubscriber deletes an existing subscriber from the database along with
 // all his metadata and adds deleted subscriber event.
 func (db *store) DeleteSubscriber(id, userID int64) error {
@@ -291,6 +648,87 @@ func (db *store) SeekSubscribersByUserID(userID, nextID, limit int64) ([]entitie
 	return s, err
 }
 
+// SeekSubscribersByFilter fetches chunk of subscribers who match the filter with id greater than nextID,
+// ordered by their ids and along with their segments.
+func (db *store) SeekSubscribersByFilter(
+	userID int64,
+	filter *entities.SubscriberFilter,
+	nextID, limit int64,
+) ([]entities.Subscriber, error) {
+	scope, err := db.subscriberFilter(userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("subscription store: %w", err)
+	}
+
+	var s []entities.Subscriber
+	err = db.Preload("Segments").
+		Scopes(scope).
+		Where("user_id = ? and subscribers.id > ?", userID, nextID).
+		Order("subscribers.id").
+		Limit(limit).
+		Find(&s).Error
+	return s, err
+}
+
+// GetTotalSubscribersByFilter returns the number of the subscribers who match the filter.
+func (db *store) GetTotalSubscribersByFilter(userID int64, filter *entities.SubscriberFilter) (int64, error) {
+	scope, err := db.subscriberFilter(userID, filter)
+	if err != nil {
+		return 0, fmt.Errorf("subscription store: %w", err)
+	}
+
+	var count int64
+	err = db.Model(&entities.Subscriber{}).
+		Scopes(scope).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteSubscribers deletes the subscribers of the user along with their segment relations, and adds
+// the deleted subscriber events, all of them in a single transaction.
+func (db *store) DeleteSubscribers(userID int64, subs []entities.Subscriber) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(subs))
+	for i, s := range subs {
+		ids[i] = s.ID
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Exec("DELETE FROM subscribers_segments WHERE subscriber_id IN (?)", ids).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: delete subscribers' segment relations: %w", err)
+	}
+
+	if err := tx.Where("user_id = ? AND id IN (?)", userID, ids).Delete(&entities.Subscriber{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: delete subscribers: %w", err)
+	}
+
+	for _, s := range subs {
+		if err := tx.Create(&entities.SubscriberEvent{
+			ID:              ksuid.New(),
+			UserID:          userID,
+			SubscriberEmail: s.Email,
+			EventType:       entities.SubscriberEventTypeDeleted,
+		}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: add subscriber event (deleted): %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
 // GetAllSubscribersForUser fetches all subscribers for a user
 func (db *store) GetAllSubscribersForUser(userID int64) ([]entities.Subscriber, error) {
 	var s []entities.Subscriber
@@ -122,4 +122,266 @@ func TestSubscriber(t *testing.T) {
 
 	err = store.DeleteSubscriber(1, 1)
 	assert.Nil(t, err)
+
+	//Test confirm pending subscriber
+	consentAt := time.Now().UTC().AddDate(0, 0, -10)
+	pending := &entities.Subscriber{
+		Email:     "pending@example.com",
+		UserID:    1,
+		Pending:   true,
+		ConsentAt: &consentAt,
+		ConsentIP: "10.0.0.1",
+	}
+	pending.Segments = append(pending.Segments, *l)
+	err = store.CreateSubscriber(pending)
+	assert.Nil(t, err)
+
+	confirmedAt := time.Now().UTC()
+	pending.ConfirmedAt = &confirmedAt
+	pending.ConfirmationIP = "10.0.0.2"
+	err = store.ConfirmSubscriber(pending)
+	assert.Nil(t, err)
+
+	s, err = store.GetSubscriber(pending.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, s.Active)
+	assert.False(t, s.Pending)
+	assert.Equal(t, "10.0.0.1", s.ConsentIP)
+	assert.Equal(t, "10.0.0.2", s.ConfirmationIP)
+	assert.NotNil(t, s.ConfirmedAt)
+
+	//Test delete expired pending subscribers
+	expired := &entities.Subscriber{
+		Email:   "expired@example.com",
+		UserID:  1,
+		Pending: true,
+	}
+	expired.Segments = append(expired.Segments, *l)
+	err = store.CreateSubscriber(expired)
+	assert.Nil(t, err)
+
+	n, err := store.DeleteExpiredPendingSubscribers(time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	n, err = store.DeleteExpiredPendingSubscribers(time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = store.GetSubscriber(expired.ID, 1)
+	assert.NotNil(t, err)
+	_, err = store.GetSubscriber(pending.ID, 1)
+	assert.Nil(t, err)
+
+	var deleted int64
+	err = db.Model(&entities.SubscriberEvent{}).
+		Where("subscriber_email = ? AND event_type = ?", expired.Email, entities.SubscriberEventTypeDeleted).
+		Count(&deleted).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	totalInSeg, err = store.GetTotalSubscribersBySegment(l.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), totalInSeg)
+
+	//Test update subscriber preferences
+	public := &entities.Segment{Name: "weekly", UserID: 1, Public: true}
+	err = store.CreateSegment(public)
+	assert.Nil(t, err)
+
+	segs, err := store.GetPublicSegments(1)
+	assert.Nil(t, err)
+	assert.Len(t, segs, 1)
+	assert.Equal(t, public.ID, segs[0].ID)
+
+	pausedUntil := time.Now().Add(24 * time.Hour)
+	s, err = store.GetSubscriber(pending.ID, 1)
+	assert.Nil(t, err)
+	s.Name = "Jane"
+	s.PausedUntil = &pausedUntil
+	s.Segments = append(s.Segments, *public)
+	err = store.UpdateSubscriberPreferences(s, []entities.SubscriberEvent{
+		{EventType: entities.SubscriberEventTypeSegmentJoin, Data: []byte(`{"segment_id":1}`)},
+		{EventType: entities.SubscriberEventTypePaused},
+	})
+	assert.Nil(t, err)
+
+	s, err = store.GetSubscriber(pending.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Jane", s.Name)
+	assert.Len(t, s.Segments, 2)
+	assert.True(t, s.IsPaused(time.Now()))
+
+	var events int64
+	err = db.Model(&entities.SubscriberEvent{}).Where("subscriber_email = ? AND event_type IN (?)", s.Email, []entities.EventType{
+		entities.SubscriberEventTypeSegmentJoin,
+		entities.SubscriberEventTypePaused,
+	}).Count(&events).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), events)
+
+	// the paused subscribers are not sent to
+	subs, err = store.GetDistinctSubscribersBySegmentIDs([]int64{l.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, subs)
+
+	s.PausedUntil = nil
+	err = store.UpdateSubscriberPreferences(s, nil)
+	assert.Nil(t, err)
+
+	subs, err = store.GetDistinctSubscribersBySegmentIDs([]int64{l.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+}
+
+func TestSaveBulkUpdatedSubscribers(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	vip := &entities.Segment{Name: "vip", UserID: 1}
+	old := &entities.Segment{Name: "old", UserID: 1}
+	assert.Nil(t, store.CreateSegment(vip))
+	assert.Nil(t, store.CreateSegment(old))
+
+	s := &entities.Subscriber{
+		Name:     "Jo",
+		Email:    "jo@example.com",
+		UserID:   1,
+		MetaJSON: []byte(`{"city":"Skopje"}`),
+		Active:   true,
+		Segments: []entities.Segment{*old},
+	}
+	assert.Nil(t, store.CreateSubscriber(s))
+
+	s.Name = "Joanna"
+	s.Active = false
+	s.Blacklisted = true
+	s.MetaJSON = []byte(`{"city":"Ohrid"}`)
+	s.Segments = []entities.Segment{*vip}
+	err := store.SaveBulkUpdatedSubscribers([]*entities.Subscriber{s}, []entities.SubscriberEvent{
+		{UserID: 1, SubscriberEmail: s.Email, EventType: entities.SubscriberEventTypeUpdated, Data: []byte(`{"name":{"old":"Jo","new":"Joanna"}}`)},
+		{UserID: 1, SubscriberEmail: s.Email, EventType: entities.SubscriberEventTypeSegmentLeave},
+		{UserID: 1, SubscriberEmail: s.Email, EventType: entities.SubscriberEventTypeSegmentJoin},
+	})
+	assert.Nil(t, err)
+
+	got, err := store.GetSubscriber(s.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Joanna", got.Name)
+	assert.False(t, got.Active)
+	assert.True(t, got.Blacklisted)
+	assert.JSONEq(t, `{"city":"Ohrid"}`, string(got.MetaJSON))
+	assert.Len(t, got.Segments, 1)
+	assert.Equal(t, vip.ID, got.Segments[0].ID)
+
+	var events int64
+	err = db.Model(&entities.SubscriberEvent{}).Where("subscriber_email = ? AND event_type IN (?)", s.Email, []entities.EventType{
+		entities.SubscriberEventTypeUpdated,
+		entities.SubscriberEventTypeSegmentLeave,
+		entities.SubscriberEventTypeSegmentJoin,
+	}).Count(&events).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), events)
+}
+
+func TestGetTotalMissingMetadata(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	for _, s := range []*entities.Subscriber{
+		{UserID: 1, Email: "jo@example.com", Name: "Jo", Active: true, MetaJSON: []byte(`{"discount":"SAVE10"}`)},
+		{UserID: 1, Email: "al@example.com", Active: true, MetaJSON: []byte(`{"name":"Al","city":"Skopje"}`)},
+		{UserID: 1, Email: "ed@example.com", Active: true},
+		{UserID: 1, Email: "ex@example.com", Active: false},
+		{UserID: 1, Email: "bl@example.com", Active: true, Blacklisted: true},
+		{UserID: 2, Email: "jo@example.com", Active: true},
+	} {
+		err := store.CreateSubscriber(s)
+		assert.Nil(t, err)
+	}
+
+	total, missing, err := store.GetTotalMissingMetadata(1, []string{"discount", "name", "city"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, map[string]int64{"discount": 2, "name": 1, "city": 2}, missing)
+
+	total, missing, err = store.GetTotalMissingMetadata(3, []string{"discount"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Equal(t, map[string]int64{"discount": 0}, missing)
+}
+
+func TestSubscriberLegacyEmail(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	sub := &entities.Subscriber{Email: "john@example.com", UserID: 1, Active: true}
+	assert.Nil(t, store.CreateSubscriber(sub))
+
+	// the legacy subscribers were created before the emails were normalized
+	legacy := &entities.Subscriber{Email: "John@Example.com", UserID: 1, Active: true}
+	assert.Nil(t, db.Create(legacy).Error)
+	unique := &entities.Subscriber{Email: "Jane@Example.com", UserID: 1, Active: true}
+	assert.Nil(t, db.Create(unique).Error)
+
+	// test the exact email is matched before the normalized one
+	s, err := store.GetSubscriberByEmail("John@Example.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, legacy.ID, s.ID)
+
+	s, err = store.GetSubscriberByEmail("JOHN@example.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, sub.ID, s.ID)
+
+	s, err = store.GetSubscriberByEmail("Jane@Example.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, unique.ID, s.ID)
+
+	// test deactivating the legacy subscriber keeps the normalized one active
+	assert.Nil(t, store.DeactivateSubscriber(1, "John@Example.com"))
+
+	legacy, err = store.GetSubscriber(legacy.ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, legacy.Active)
+
+	sub, err = store.GetSubscriber(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, sub.Active)
+
+	var count int
+	err = db.Model(&entities.SubscriberEvent{}).
+		Where("subscriber_email = ? AND event_type = ?", "John@Example.com", entities.SubscriberEventTypeUnsubscribed).
+		Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.Nil(t, store.DeactivateSubscriber(1, " JOHN@example.COM"))
+
+	sub, err = store.GetSubscriber(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, sub.Active)
+
+	assert.Nil(t, store.DeactivateSubscriber(1, "Jane@Example.com"))
+
+	unique, err = store.GetSubscriber(unique.ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, unique.Active)
 }
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateSubscriberBulkAction creates a subscriber bulk action.
+func (db *store) CreateSubscriberBulkAction(a *entities.SubscriberBulkAction) error {
+	return db.Create(a).Error
+}
+
+// GetSubscriberBulkAction returns the subscriber bulk action by the given id and user id.
+func (db *store) GetSubscriberBulkAction(id, userID int64) (*entities.SubscriberBulkAction, error) {
+	var a = new(entities.SubscriberBulkAction)
+	err := db.Where("user_id = ? and id = ?", userID, id).Find(a).Error
+	return a, err
+}
+
+// UpdateSubscriberBulkAction updates the status, the counters, the error and the times of the bulk
+// action, only when it's still in one of the given statuses, so the bulk actions which were cancelled
+// in the meantime aren't overwritten. It reports whether the bulk action was updated.
+func (db *store) UpdateSubscriberBulkAction(a *entities.SubscriberBulkAction, statuses ...string) (bool, error) {
+	return db.updateInStatuses(&entities.SubscriberBulkAction{}, a.ID, a.UserID, statuses, map[string]interface{}{
+		"status":       a.Status,
+		"processed":    a.Processed,
+		"affected":     a.Affected,
+		"failed":       a.Failed,
+		"error":        a.Error,
+		"started_at":   a.StartedAt,
+		"completed_at": a.CompletedAt,
+	})
+}
+
+// CancelSubscriberBulkAction cancels the bulk action when it's pending or in progress, the counters
+// are kept as they are. It reports whether the bulk action was cancelled.
+func (db *store) CancelSubscriberBulkAction(id, userID int64) (bool, error) {
+	q := db.Model(&entities.SubscriberBulkAction{}).
+		Where("id = ? and user_id = ? and status in (?)", id, userID, []string{entities.StatusPending, entities.StatusInProgress}).
+		Updates(map[string]interface{}{
+			"status":       entities.StatusCancelled,
+			"completed_at": time.Now().UTC(),
+		})
+	return q.RowsAffected > 0, q.Error
+}
+
+// DeleteAllSubscriberBulkActionsForUser deletes all subscriber bulk actions for user
+func (db *store) DeleteAllSubscriberBulkActionsForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.SubscriberBulkAction{}).Error
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSubscriberBulkActions(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	a := &entities.SubscriberBulkAction{
+		UserID:     1,
+		Action:     entities.BulkActionAddToSegment,
+		SegmentID:  3,
+		FilterJSON: []byte(`{"name":"jo","metadata":[{"key":"city","operator":"equals","value":"Skopje"}]}`),
+		Status:     entities.StatusPending,
+		Total:      10,
+	}
+	err := store.CreateSubscriberBulkAction(a)
+	assert.Nil(t, err)
+
+	// test get bulk action
+	got, err := store.GetSubscriberBulkAction(a.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.BulkActionAddToSegment, got.Action)
+	assert.Equal(t, int64(3), got.SegmentID)
+	assert.Equal(t, int64(10), got.Total)
+
+	filter, err := got.GetFilter()
+	assert.Nil(t, err)
+	assert.Equal(t, &entities.SubscriberFilter{
+		Name: "jo",
+		Metadata: []entities.MetadataCondition{
+			{Key: "city", Operator: entities.MetadataOperatorEquals, Value: "Skopje"},
+		},
+	}, filter)
+
+	_, err = store.GetSubscriberBulkAction(a.ID, 2)
+	assert.NotNil(t, err)
+
+	// test update only from the given statuses
+	got.Status = entities.StatusInProgress
+	got.StartedAt.SetValid(time.Now())
+	ok, err := store.UpdateSubscriberBulkAction(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.UpdateSubscriberBulkAction(got, entities.StatusPending)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	got.Processed = 5
+	got.Affected = 4
+	got.Failed = 1
+	ok, err = store.UpdateSubscriberBulkAction(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// the update which doesn't change the bulk action is still reported
+	ok, err = store.UpdateSubscriberBulkAction(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// test cancel
+	ok, err = store.CancelSubscriberBulkAction(a.ID, 2)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.CancelSubscriberBulkAction(a.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	got, err = store.GetSubscriberBulkAction(a.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.StatusCancelled, got.Status)
+	assert.Equal(t, int64(5), got.Processed)
+	assert.Equal(t, int64(4), got.Affected)
+	assert.True(t, got.CompletedAt.Valid)
+	assert.True(t, got.IsFinished())
+
+	// the cancelled bulk action is not updated anymore
+	got.Status = entities.StatusDone
+	ok, err = store.UpdateSubscriberBulkAction(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// test delete all bulk actions for user
+	err = store.DeleteAllSubscriberBulkActionsForUser(1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSubscriberBulkAction(a.ID, 1)
+	assert.NotNil(t, err)
+}
+
+func TestSeekSubscribersByFilter(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	seg := &entities.Segment{Name: "vip", UserID: 1}
+	assert.Nil(t, store.CreateSegment(seg))
+
+	for i, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		s := &entities.Subscriber{
+			Email:    email,
+			UserID:   1,
+			Active:   i%2 == 0,
+			Segments: []entities.Segment{*seg},
+		}
+		assert.Nil(t, store.CreateSubscriber(s))
+	}
+	assert.Nil(t, store.CreateSubscriber(&entities.Subscriber{Email: "a@example.com", UserID: 2, Active: true}))
+
+	active := true
+	filter := &entities.SubscriberFilter{Active: &active}
+
+	total, err := store.GetTotalSubscribersByFilter(1, filter)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.GetTotalSubscribersByFilter(1, &entities.SubscriberFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+
+	// test seek by the ids
+	subs, err := store.SeekSubscribersByFilter(1, filter, 0, 1)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, "a@example.com", subs[0].Email)
+	assert.Len(t, subs[0].Segments, 1)
+
+	subs, err = store.SeekSubscribersByFilter(1, filter, subs[0].ID, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, "c@example.com", subs[0].Email)
+
+	subs, err = store.SeekSubscribersByFilter(1, filter, subs[0].ID, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, subs)
+
+	// test delete subscribers
+	subs, err = store.SeekSubscribersByFilter(1, filter, 0, 10)
+	assert.Nil(t, err)
+	assert.Nil(t, store.DeleteSubscribers(1, subs))
+
+	total, err = store.GetTotalSubscribersByFilter(1, &entities.SubscriberFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.GetTotalSubscribersBySegment(seg.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+
+	var events int64
+	err = db.Model(&entities.SubscriberEvent{}).Where("user_id = ? AND event_type = ?", 1, entities.SubscriberEventTypeDeleted).Count(&events).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), events)
+
+	_, err = store.GetSubscriberByEmail("a@example.com", 2)
+	assert.Nil(t, err)
+}
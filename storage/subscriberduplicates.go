@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// MergeSubscribers stores the subscriber which the duplicates were merged into, see
+// entities.Subscriber.Merge, and deletes the duplicates in a single transaction. The send logs
+// and the events of the duplicates are moved to the subscriber, whose email is normalized unless
+// another subscriber already has the normalized email, and the merged event is added.
+func (db *store) MergeSubscribers(s *entities.Subscriber, duplicates []entities.Subscriber) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	ids := make([]int64, len(duplicates))
+	emails := make([]string, len(duplicates))
+	for i, d := range duplicates {
+		ids[i] = d.ID
+		emails[i] = d.Email
+	}
+
+	if err := tx.Table("send_logs").
+		Where("user_id = ? AND subscriber_id IN (?)", s.UserID, ids).
+		Update("subscriber_id", s.ID).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: move send logs: %w", err)
+	}
+
+	for i := range duplicates {
+		d := &duplicates[i]
+		if err := tx.Model(d).Association("Segments").Clear().Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: delete duplicate's segment relation: %w", err)
+		}
+		if err := tx.Where("user_id = ?", s.UserID).Delete(d).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: delete duplicate: %w", err)
+		}
+	}
+
+	// the email is normalized once the duplicates which had it are deleted.
+	renamed := []string{s.Email}
+	if email := entities.NormalizeEmail(s.Email); email != s.Email {
+		var count int64
+		if err := tx.Model(&entities.Subscriber{}).
+			Where("user_id = ? AND id <> ? AND email = ?", s.UserID, s.ID, email).
+			Count(&count).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: count subscribers by email: %w", err)
+		}
+		if count == 0 {
+			s.Email = email
+		}
+	}
+
+	for _, from := range append(renamed, emails...) {
+		if err := renameSubscriberEmail(tx, s.UserID, from, s.Email); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("subscription store: %w", err)
+		}
+	}
+
+	if err := tx.Model(s).Association("Segments").Replace(s.Segments).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: update subscriber's segment: %w", err)
+	}
+
+	if err := tx.Model(&entities.Subscriber{}).
+		Where("id = ? AND user_id = ?", s.ID, s.UserID).
+		Updates(map[string]interface{}{
+			"email":       s.Email,
+			"name":        s.Name,
+			"metadata":    s.MetaJSON,
+			"blacklisted": s.Blacklisted,
+		}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: update subscriber: %w", err)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"subscriber_ids": ids,
+		"emails":         emails,
+	})
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: marshal merged event: %w", err)
+	}
+
+	if err := tx.Create(&entities.SubscriberEvent{
+		ID:              ksuid.New(),
+		UserID:          s.UserID,
+		SubscriberEmail: s.Email,
+		EventType:       entities.SubscriberEventTypeMerged,
+		Data:            data,
+	}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: add subscriber event (merged): %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// renameSubscriberEmail moves the campaign activities and the events of the subscriber, which are
+// found by the email, from one email to the other.
+func renameSubscriberEmail(tx *gorm.DB, userID int64, from, to string) error {
+	if from == to {
+		return nil
+	}
+
+	for _, src := range activitySources {
+		if src.key == "subscriber_id" {
+			continue
+		}
+		if err := tx.Table(src.table).
+			Where("user_id = ? AND "+src.key+" = ?", userID, from).
+			Update(src.key, to).Error; err != nil {
+			return fmt.Errorf("rename email of %s: %w", src.table, err)
+		}
+	}
+
+	if err := tx.Table("subscriber_events").
+		Where("user_id = ? AND subscriber_email = ?", userID, from).
+		Update("subscriber_email", to).Error; err != nil {
+		return fmt.Errorf("rename email of subscriber_events: %w", err)
+	}
+
+	return nil
+}
+
+// EmailDuplicate is a subscriber whose email is not normalized, since another subscriber of the
+// user already has the normalized email. The duplicates are merged by POST /api/subscribers/{id}/merge.
+type EmailDuplicate struct {
+	UserID       int64
+	SubscriberID int64
+	Email        string
+	DuplicateOf  int64
+}
+
+// NormalizeSubscriberEmails normalizes the emails of the subscribers which were created before the
+// emails were normalized, along with the emails of their activities, see entities.NormalizeEmail.
+// The subscribers whose normalized emails are taken by other subscribers, or claimed by earlier
+// subscribers of the run, are not changed, they are returned as duplicates. It returns the number of
+// the normalized emails, nothing is changed on a dry run. The dry run is run when the migrations are.
+func (db *store) NormalizeSubscriberEmails(dryRun bool) (int, []EmailDuplicate, error) {
+	// MySQL compares the strings case insensitively by the collation of the column.
+	changed := "email <> LOWER(TRIM(email))"
+	if db.Dialect().GetName() == "mysql" {
+		changed = "CAST(email AS BINARY) <> CAST(LOWER(TRIM(email)) AS BINARY)"
+	}
+
+	var (
+		nextID     int64
+		limit      int64 = 1000
+		normalized int
+		duplicates []EmailDuplicate
+		// claimed are the ids of the subscribers by the normalized emails they're given during the
+		// run by user id, the emails are not changed on a dry run so they can't be looked up.
+		claimed = make(map[int64]map[string]int64)
+	)
+	for {
+		var subs []entities.Subscriber
+		err := db.Select("id, user_id, email").
+			Where("id > ? AND "+changed, nextID).
+			Order("id").
+			Limit(limit).
+			Find(&subs).Error
+		if err != nil {
+			return normalized, duplicates, fmt.Errorf("normalize emails: get subscribers: %w", err)
+		}
+
+		for _, s := range subs {
+			email := entities.NormalizeEmail(s.Email)
+			if email == s.Email {
+				continue
+			}
+
+			if claimed[s.UserID] == nil {
+				claimed[s.UserID] = make(map[string]int64)
+			}
+			if id, ok := claimed[s.UserID][email]; ok {
+				duplicates = append(duplicates, EmailDuplicate{
+					UserID:       s.UserID,
+					SubscriberID: s.ID,
+					Email:        s.Email,
+					DuplicateOf:  id,
+				})
+				continue
+			}
+
+			var existing []entities.Subscriber
+			err := db.Select("id").
+				Where("user_id = ? AND id <> ? AND email = ?", s.UserID, s.ID, email).
+				Find(&existing).Error
+			if err != nil {
+				return normalized, duplicates, fmt.Errorf("normalize emails: get subscribers by email: %w", err)
+			}
+			if len(existing) > 0 {
+				duplicates = append(duplicates, EmailDuplicate{
+					UserID:       s.UserID,
+					SubscriberID: s.ID,
+					Email:        s.Email,
+					DuplicateOf:  existing[0].ID,
+				})
+				continue
+			}
+
+			claimed[s.UserID][email] = s.ID
+			normalized++
+			if dryRun {
+				continue
+			}
+			if err := renameEmail(db.DB, s, email); err != nil {
+				return normalized, duplicates, fmt.Errorf("normalize emails: %w", err)
+			}
+		}
+
+		if len(subs) < int(limit) {
+			return normalized, duplicates, nil
+		}
+		nextID = subs[len(subs)-1].ID
+	}
+}
+
+// renameEmail changes the email of the subscriber along with the email of its activities.
+func renameEmail(db *gorm.DB, s entities.Subscriber, email string) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&entities.Subscriber{}).
+		Where("id = ?", s.ID).
+		Update("email", email).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update email of subscriber %d: %w", s.ID, err)
+	}
+
+	if err := renameSubscriberEmail(tx, s.UserID, s.Email, email); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
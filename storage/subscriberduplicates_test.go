@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestMergeSubscribers(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	seg1 := &entities.Segment{Name: "foo", UserID: 1}
+	assert.Nil(t, store.CreateSegment(seg1))
+	seg2 := &entities.Segment{Name: "bar", UserID: 1}
+	assert.Nil(t, store.CreateSegment(seg2))
+
+	campaign := &entities.Campaign{Name: "newsletter", UserID: 1, TemplateID: 1, Status: entities.StatusDraft}
+	assert.Nil(t, store.CreateCampaign(campaign))
+
+	sub := &entities.Subscriber{Name: "John", Email: "john@example.com", UserID: 1, Active: true, Segments: []entities.Segment{*seg1}}
+	assert.Nil(t, store.CreateSubscriber(sub))
+
+	// the duplicate was created before the emails were normalized
+	dup := &entities.Subscriber{Email: "John@Example.com", UserID: 1, Active: true, Segments: []entities.Segment{*seg2}}
+	assert.Nil(t, db.Create(dup).Error)
+
+	// test the lookup by email is case insensitive
+	s, err := store.GetSubscriberByEmail(" JOHN@example.COM", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, sub.ID, s.ID)
+
+	// test the duplicates are not normalized
+	n, duplicates, err := store.NormalizeSubscriberEmails(false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, []EmailDuplicate{
+		{UserID: 1, SubscriberID: dup.ID, Email: "John@Example.com", DuplicateOf: sub.ID},
+	}, duplicates)
+
+	dup, err = store.GetSubscriber(dup.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "John@Example.com", dup.Email)
+
+	assert.Nil(t, store.CreateSendLog(&entities.SendLog{
+		ID:           ksuid.New(),
+		UserID:       1,
+		EventID:      ksuid.New(),
+		SubscriberID: dup.ID,
+		CampaignID:   campaign.ID,
+		Status:       entities.SendLogStatusSuccessful,
+		CreatedAt:    time.Now(),
+	}))
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: dup.Email, CreatedAt: time.Now()}))
+
+	// test merge subscribers
+	sub, err = store.GetSubscriber(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, sub.Merge([]entities.Subscriber{*dup}))
+	assert.Nil(t, store.MergeSubscribers(sub, []entities.Subscriber{*dup}))
+
+	_, err = store.GetSubscriber(dup.ID, 1)
+	assert.NotNil(t, err)
+
+	sub, err = store.GetSubscriber(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, sub.Segments, 2)
+
+	p := NewPaginationCursor("/api/subscribers/1/activity", 100)
+	err = store.GetSubscriberActivity(sub, nil, nil, p)
+	assert.Nil(t, err)
+
+	var types []string
+	for _, a := range *p.Collection.(*[]entities.Activity) {
+		types = append(types, a.Type)
+	}
+	assert.ElementsMatch(t, []string{
+		entities.ActivityTypeSendLog,
+		entities.ActivityTypeOpen,
+		string(entities.SubscriberEventTypeMerged),
+		string(entities.SubscriberEventTypeCreated),
+	}, types)
+
+	// test the emails are normalized when there are no duplicates
+	other := &entities.Subscriber{Email: " Jane@Example.com", UserID: 1, Active: true}
+	assert.Nil(t, db.Create(other).Error)
+	assert.Nil(t, store.CreateOpen(&entities.Open{UserID: 1, CampaignID: campaign.ID, Recipient: other.Email, CreatedAt: time.Now()}))
+
+	// the dry run doesn't change the emails
+	n, duplicates, err = store.NormalizeSubscriberEmails(true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, duplicates)
+
+	unchanged, err := store.GetSubscriber(other.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, " Jane@Example.com", unchanged.Email)
+
+	n, duplicates, err = store.NormalizeSubscriberEmails(false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, duplicates)
+
+	other, err = store.GetSubscriber(other.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane@example.com", other.Email)
+
+	p = NewPaginationCursor("/api/subscribers/1/activity", 100)
+	err = store.GetSubscriberActivity(other, []string{entities.ActivityTypeOpen}, nil, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+
+	// test the variants of the same email are reported as duplicates on a dry run as well
+	bob := &entities.Subscriber{Email: "Bob@Example.com", UserID: 1, Active: true}
+	assert.Nil(t, db.Create(bob).Error)
+	bobDup := &entities.Subscriber{Email: "BOB@example.com", UserID: 1, Active: true}
+	assert.Nil(t, db.Create(bobDup).Error)
+	// the same email of another user is not a duplicate
+	bobOther := &entities.Subscriber{Email: "BOB@example.com", UserID: 2, Active: true}
+	assert.Nil(t, db.Create(bobOther).Error)
+
+	expected := []EmailDuplicate{
+		{UserID: 1, SubscriberID: bobDup.ID, Email: "BOB@example.com", DuplicateOf: bob.ID},
+	}
+	for _, dryRun := range []bool{true, false} {
+		n, duplicates, err = store.NormalizeSubscriberEmails(dryRun)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n, "dry run: %v", dryRun)
+		assert.Equal(t, expected, duplicates, "dry run: %v", dryRun)
+	}
+
+	bob, err = store.GetSubscriber(bob.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob@example.com", bob.Email)
+}
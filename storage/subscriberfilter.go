@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// likeEscaper escapes the wildcards of the LIKE patterns, the '!' escape character
+// is used because the backslash is treated differently by MySQL and SQLite.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// SubscriberFilter is a query scope that finds the subscribers matching the filter. The metadata
+// conditions are compiled to the JSON functions of the given dialect. The segments are the ones of
+// the filter's segment ids, the members of the dynamic segments are matched by their rules.
+func SubscriberFilter(
+	dialect string,
+	f *entities.SubscriberFilter,
+	segs []entities.Segment,
+	now time.Time,
+) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f == nil {
+			return db
+		}
+
+		if f.Email != "" {
+			db = db.Where("email LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f.Email)+"%")
+		}
+		if f.Name != "" {
+			db = db.Where("name LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f.Name)+"%")
+		}
+		if f.Active != nil {
+			db = db.Where("active = ?", *f.Active)
+		}
+		if f.Blacklisted != nil {
+			db = db.Where("blacklisted = ?", *f.Blacklisted)
+		}
+		if len(f.SegmentIDs) > 0 {
+			members, args, err := compileSegmentsMembers(dialect, segs, now)
+			if err != nil {
+				_ = db.AddError(fmt.Errorf("subscriber filter: %w", err))
+				return db
+			}
+			db = db.Where(members, args...)
+		}
+		if f.CreatedAfter != nil {
+			db = db.Where("created_at >= ?", *f.CreatedAfter)
+		}
+		if f.CreatedBefore != nil {
+			db = db.Where("created_at < ?", *f.CreatedBefore)
+		}
+		if f.MinEngagementScore != nil {
+			db = db.Where("engagement_score >= ?", *f.MinEngagementScore)
+		}
+		if f.MaxEngagementScore != nil {
+			db = db.Where("engagement_score <= ?", *f.MaxEngagementScore)
+		}
+		if f.LastEngagedAfter != nil {
+			db = db.Where("last_engaged_at >= ?", *f.LastEngagedAfter)
+		}
+		if f.LastEngagedBefore != nil {
+			db = db.Where("last_engaged_at IS NULL OR last_engaged_at < ?", *f.LastEngagedBefore)
+		}
+
+		for _, cond := range f.Metadata {
+			path := metadataPath(cond.Key)
+			switch cond.Operator {
+			case entities.MetadataOperatorEquals:
+				db = db.Where(metadataValue(dialect)+" = ?", path, cond.Value)
+			case entities.MetadataOperatorContains:
+				db = db.Where(metadataValue(dialect)+" LIKE ? ESCAPE '!'", path, "%"+likeEscaper.Replace(cond.Value)+"%")
+			case entities.MetadataOperatorExists:
+				db = db.Where("JSON_EXTRACT(metadata, ?) IS NOT NULL", path)
+			}
+		}
+
+		return db
+	}
+}
+
+// subscriberFilter returns the query scope of the filter, along with the segments of the filter
+// which are fetched by user id.
+func (db *store) subscriberFilter(userID int64, f *entities.SubscriberFilter) (func(*gorm.DB) *gorm.DB, error) {
+	var segs []entities.Segment
+	if f != nil && len(f.SegmentIDs) > 0 {
+		var err error
+		segs, err = db.GetSegmentsByIDs(userID, f.SegmentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("get segments: %w", err)
+		}
+	}
+
+	return SubscriberFilter(db.Dialect().GetName(), f, segs, time.Now()), nil
+}
+
+// metadataPath returns the JSON path of the metadata field, the key is quoted
+// so it can contain hyphens.
+func metadataPath(key string) string {
+	return `$."` + strings.ReplaceAll(key, `"`, "") + `"`
+}
+
+// metadataValue returns the expression of the string value of a metadata field
+// by its JSON path, MySQL returns the values of JSON_EXTRACT quoted.
+func metadataValue(dialect string) string {
+	if dialect == "mysql" {
+		return "JSON_UNQUOTE(JSON_EXTRACT(metadata, ?))"
+	}
+	return "JSON_EXTRACT(metadata, ?)"
+}
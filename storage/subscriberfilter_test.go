@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSubscriberFilter(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	store := From(db)
+
+	seg := &entities.Segment{Name: "customers", UserID: 1}
+	err := store.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	for i := 0; i < 12; i++ {
+		s := &entities.Subscriber{
+			Name:     fmt.Sprintf("John %d", i),
+			Email:    fmt.Sprintf("john%d@example.com", i),
+			UserID:   1,
+			Active:   i%2 == 0,
+			MetaJSON: []byte(fmt.Sprintf(`{"city":"Skopje %d","plan-name":"pro"}`, i)),
+		}
+		if i < 3 {
+			s.Segments = []entities.Segment{*seg}
+		}
+		if i == 11 {
+			s.Email = "jane_100%@example.com"
+			s.Blacklisted = true
+			s.MetaJSON = []byte(`{"city":"Ohrid"}`)
+		}
+		err = store.CreateSubscriber(s)
+		assert.Nil(t, err)
+	}
+
+	dynamic := &entities.Segment{
+		Name:      "skopje 1",
+		UserID:    1,
+		Dynamic:   true,
+		RulesJSON: entities.JSON(`{"field":"metadata","operator":"contains","key":"city","value":"skopje 1"}`),
+	}
+	err = store.CreateSegment(dynamic)
+	assert.Nil(t, err)
+
+	yes, no := true, false
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter *entities.SubscriberFilter
+		total  int64
+	}{
+		{"no filter", nil, 12},
+		{"email", &entities.SubscriberFilter{Email: "JOHN1"}, 2},
+		{"email wildcards are escaped", &entities.SubscriberFilter{Email: "_100%"}, 1},
+		{"email wildcard is not a wildcard", &entities.SubscriberFilter{Email: "%"}, 1},
+		{"name", &entities.SubscriberFilter{Name: "hn 1"}, 3},
+		{"active", &entities.SubscriberFilter{Active: &yes}, 6},
+		{"inactive and blacklisted", &entities.SubscriberFilter{Active: &no, Blacklisted: &yes}, 1},
+		{"segment", &entities.SubscriberFilter{SegmentIDs: []int64{seg.ID}}, 3},
+		{"dynamic segment", &entities.SubscriberFilter{SegmentIDs: []int64{dynamic.ID}}, 2},
+		{"static and dynamic segments", &entities.SubscriberFilter{SegmentIDs: []int64{seg.ID, dynamic.ID}}, 4},
+		{"missing segment", &entities.SubscriberFilter{SegmentIDs: []int64{seg.ID + 100}}, 0},
+		{"created before", &entities.SubscriberFilter{CreatedBefore: &future}, 12},
+		{"created after", &entities.SubscriberFilter{CreatedAfter: &future}, 0},
+		{"metadata equals", &entities.SubscriberFilter{Metadata: []entities.MetadataCondition{
+			{Key: "city", Operator: entities.MetadataOperatorEquals, Value: "Ohrid"},
+		}}, 1},
+		{"metadata contains", &entities.SubscriberFilter{Metadata: []entities.MetadataCondition{
+			{Key: "city", Operator: entities.MetadataOperatorContains, Value: "skopje 1"},
+		}}, 2},
+		{"metadata exists", &entities.SubscriberFilter{Metadata: []entities.MetadataCondition{
+			{Key: "plan-name", Operator: entities.MetadataOperatorExists},
+		}}, 11},
+		{"combined", &entities.SubscriberFilter{
+			Active:     &yes,
+			SegmentIDs: []int64{seg.ID},
+			Metadata: []entities.MetadataCondition{
+				{Key: "plan-name", Operator: entities.MetadataOperatorEquals, Value: "pro"},
+			},
+		}, 2},
+	}
+
+	for _, tc := range tests {
+		p := NewPaginationCursor("/api/subscribers", 100)
+		err = store.GetSubscribers(1, p, tc.filter)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.total, p.Total, tc.name)
+		assert.Len(t, *p.Collection.(*[]entities.Subscriber), int(tc.total), tc.name)
+	}
+
+	// test the members of the dynamic segment are matched by its rules
+	filter := &entities.SubscriberFilter{SegmentIDs: []int64{dynamic.ID}, Active: &yes}
+	p := NewPaginationCursor("/api/subscribers", 100)
+	err = store.GetSubscribers(1, p, filter)
+	assert.Nil(t, err)
+	subs := *p.Collection.(*[]entities.Subscriber)
+	if assert.Len(t, subs, 1) {
+		assert.Equal(t, "john10@example.com", subs[0].Email)
+	}
+
+	total, err := store.GetTotalSubscribersByFilter(1, filter)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+
+	subs, err = store.SeekSubscribersByFilter(1, &entities.SubscriberFilter{SegmentIDs: []int64{dynamic.ID}}, 0, 10)
+	assert.Nil(t, err)
+	if assert.Len(t, subs, 2) {
+		assert.Equal(t, "john1@example.com", subs[0].Email)
+		assert.Equal(t, "john10@example.com", subs[1].Email)
+	}
+
+	// test the filter is applied while paginating
+	filter = &entities.SubscriberFilter{Active: &yes}
+	var seen []int64
+	p = NewPaginationCursor("/api/subscribers", 4)
+	p.SetParams(map[string][]string{"active": {"true"}})
+	for {
+		err = store.GetSubscribers(1, p, filter)
+		assert.Nil(t, err)
+
+		for _, s := range *p.Collection.(*[]entities.Subscriber) {
+			assert.True(t, s.Active)
+			seen = append(seen, s.ID)
+		}
+
+		if p.Links.Next == nil {
+			break
+		}
+		assert.Contains(t, *p.Links.Next, "active=true")
+
+		next := p.Collection.(*[]entities.Subscriber)
+		last := (*next)[len(*next)-1].ID
+		p = NewPaginationCursor("/api/subscribers", 4)
+		p.SetStartingAfter(last)
+	}
+	assert.Len(t, seen, 6)
+}
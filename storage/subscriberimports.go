@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateSubscriberImport creates a subscriber import.
+func (db *store) CreateSubscriberImport(i *entities.SubscriberImport) error {
+	return db.Create(i).Error
+}
+
+// GetSubscriberImport returns the subscriber import by the given id and user id.
+func (db *store) GetSubscriberImport(id, userID int64) (*entities.SubscriberImport, error) {
+	var i = new(entities.SubscriberImport)
+	err := db.Where("user_id = ? and id = ?", userID, id).Find(i).Error
+	return i, err
+}
+
+// UpdateSubscriberImport updates the status, the counters, the error and the times of the import,
+// only when the import is still in one of the given statuses, so the imports which were cancelled
+// in the meantime aren't overwritten. It reports whether the import was updated.
+func (db *store) UpdateSubscriberImport(i *entities.SubscriberImport, statuses ...string) (bool, error) {
+	return db.updateInStatuses(&entities.SubscriberImport{}, i.ID, i.UserID, statuses, map[string]interface{}{
+		"status":       i.Status,
+		"processed":    i.Processed,
+		"created":      i.Created,
+		"updated":      i.Updated,
+		"skipped":      i.Skipped,
+		"failed":       i.Failed,
+		"error":        i.Error,
+		"started_at":   i.StartedAt,
+		"completed_at": i.CompletedAt,
+	})
+}
+
+// updateInStatuses updates the row of the model by the given id and user id, only when the row is
+// still in one of the given statuses, and reports whether it is. MySQL counts the changed rows as
+// affected instead of the matched ones, so when no row is affected the status is read back, since an
+// update which doesn't change the row doesn't mean the row is not in the statuses anymore.
+func (db *store) updateInStatuses(
+	model interface{},
+	id, userID int64,
+	statuses []string,
+	values map[string]interface{},
+) (bool, error) {
+	q := db.Model(model).
+		Where("id = ? and user_id = ? and status in (?)", id, userID, statuses).
+		Updates(values)
+	if q.Error != nil || q.RowsAffected > 0 {
+		return q.RowsAffected > 0, q.Error
+	}
+
+	var count int64
+	err := db.Model(model).
+		Where("id = ? and user_id = ? and status in (?)", id, userID, statuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CancelSubscriberImport cancels the import when it's pending or in progress, the counters are
+// kept as they are. It reports whether the import was cancelled.
+func (db *store) CancelSubscriberImport(id, userID int64) (bool, error) {
+	q := db.Model(&entities.SubscriberImport{}).
+		Where("id = ? and user_id = ? and status in (?)", id, userID, []string{entities.StatusPending, entities.StatusInProgress}).
+		Updates(map[string]interface{}{
+			"status":       entities.StatusCancelled,
+			"completed_at": time.Now().UTC(),
+		})
+	return q.RowsAffected > 0, q.Error
+}
+
+// DeleteAllSubscriberImportsForUser deletes all subscriber imports for user
+func (db *store) DeleteAllSubscriberImportsForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.SubscriberImport{}).Error
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSubscriberImports(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	imp := &entities.SubscriberImport{
+		UserID:         1,
+		FileName:       "list.csv",
+		Kind:           entities.SubscriberImportKindBulkUpdate,
+		Mode:           "skip",
+		DryRun:         true,
+		MappingJSON:    []byte(`{"Full name":"name"}`),
+		SegmentIDsJSON: []byte(`[1,2]`),
+		Status:         entities.StatusPending,
+		Total:          10,
+	}
+	err := store.CreateSubscriberImport(imp)
+	assert.Nil(t, err)
+
+	// test get import
+	got, err := store.GetSubscriberImport(imp.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "list.csv", got.FileName)
+	assert.Equal(t, int64(10), got.Total)
+	assert.True(t, got.IsBulkUpdate())
+	assert.True(t, got.DryRun)
+
+	mapping, err := got.GetMapping()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Full name": "name"}, mapping)
+
+	ids, err := got.GetSegmentIDs()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	_, err = store.GetSubscriberImport(imp.ID, 2)
+	assert.NotNil(t, err)
+
+	// test update only from the given statuses
+	got.Status = entities.StatusInProgress
+	got.StartedAt.SetValid(time.Now())
+	ok, err := store.UpdateSubscriberImport(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.UpdateSubscriberImport(got, entities.StatusPending)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	got.Processed = 5
+	got.Created = 4
+	got.Failed = 1
+	ok, err = store.UpdateSubscriberImport(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// the update which doesn't change the import is still reported
+	ok, err = store.UpdateSubscriberImport(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// test cancel
+	ok, err = store.CancelSubscriberImport(imp.ID, 2)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.CancelSubscriberImport(imp.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	got, err = store.GetSubscriberImport(imp.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.StatusCancelled, got.Status)
+	assert.Equal(t, int64(5), got.Processed)
+	assert.Equal(t, int64(4), got.Created)
+	assert.True(t, got.CompletedAt.Valid)
+	assert.True(t, got.IsFinished())
+
+	// the cancelled import is not updated anymore
+	got.Status = entities.StatusDone
+	ok, err = store.UpdateSubscriberImport(got, entities.StatusInProgress)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.CancelSubscriberImport(imp.ID, 1)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// test delete all imports for user
+	err = store.DeleteAllSubscriberImportsForUser(1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSubscriberImport(imp.ID, 1)
+	assert.NotNil(t, err)
+}
+
+func TestSaveImportedSubscribers(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	seg := &entities.Segment{Name: "imported", UserID: 1}
+	err := store.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	existing := &entities.Subscriber{Name: "Jo", Email: "jo@example.com", UserID: 1, Active: true}
+	err = store.CreateSubscriber(existing)
+	assert.Nil(t, err)
+
+	subs, err := store.GetSubscribersByEmails([]string{"jo@example.com", "ana@example.com"}, 1)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+
+	subs[0].Name = "Joanna"
+	subs[0].Segments = append(subs[0].Segments, *seg)
+	created := []*entities.Subscriber{
+		{Name: "Ana", Email: "ana@example.com", UserID: 1, Active: true, Segments: []entities.Segment{*seg}},
+	}
+	err = store.SaveImportedSubscribers(created, []*entities.Subscriber{&subs[0]})
+	assert.Nil(t, err)
+
+	subs, err = store.GetSubscribersByEmails([]string{"jo@example.com", "ana@example.com"}, 1)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 2)
+	for _, s := range subs {
+		assert.Len(t, s.Segments, 1)
+		assert.Equal(t, seg.ID, s.Segments[0].ID)
+		if s.Email == "jo@example.com" {
+			assert.Equal(t, "Joanna", s.Name)
+		}
+	}
+
+	subs, err = store.GetSubscribersByEmails([]string{"ana@example.com"}, 2)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 0)
+
+	total, err := store.GetTotalSubscribers(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// notSuppressed is the condition of the subscribers whose emails and domains are not suppressed,
+// INSTR and SUBSTR are supported by both of the dialects. The hashes of the emails are not checked,
+// they are the tombstones of the erased subscribers which can't be added again.
+const notSuppressed = `NOT EXISTS (
+	SELECT 1 FROM suppressions
+	WHERE suppressions.user_id = subscribers.user_id
+	AND (
+		(suppressions.type = 'email' AND suppressions.value = LOWER(subscribers.email))
+		OR (suppressions.type = 'domain' AND suppressions.value = LOWER(SUBSTR(subscribers.email, INSTR(subscribers.email, '@') + 1)))
+	)
+)`
+
+// GetSuppressions fetches the suppressions by user id, and populates the pagination obj.
+// The suppressions can be scoped by the start of the value, the type and the reason.
+func (db *store) GetSuppressions(userID int64, p *PaginationCursor, scopeMap map[string]string) error {
+	p.SetCollection(&[]entities.Suppression{})
+	p.SetResource("suppressions")
+
+	for k, v := range scopeMap {
+		switch k {
+		case "value":
+			p.AddScope(ValueLike(strings.ToLower(v)))
+		case "type", "reason":
+			p.AddScope(SuppressionsBy(k, v))
+		}
+	}
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetSuppression returns the suppression by the given id and user id.
+func (db *store) GetSuppression(id, userID int64) (*entities.Suppression, error) {
+	var s = new(entities.Suppression)
+	err := db.Where("user_id = ? and id = ?", userID, id).Find(s).Error
+	return s, err
+}
+
+// GetSuppressionByValue returns the suppression of the email or the domain by the user id.
+func (db *store) GetSuppressionByValue(userID int64, typ, value string) (*entities.Suppression, error) {
+	var s = new(entities.Suppression)
+	err := db.Where("user_id = ? and type = ? and value = ?", userID, typ, value).Find(s).Error
+	return s, err
+}
+
+// GetSuppressionsForEmails returns the suppressions of the user which apply to the emails,
+// either to the emails themselves, to their hashes or to their domains.
+func (db *store) GetSuppressionsForEmails(userID int64, emails []string) ([]entities.Suppression, error) {
+	var s []entities.Suppression
+	if len(emails) == 0 {
+		return s, nil
+	}
+
+	values := make([]string, len(emails))
+	hashes := make([]string, len(emails))
+	domains := make([]string, len(emails))
+	for i, e := range emails {
+		values[i] = strings.ToLower(strings.TrimSpace(e))
+		hashes[i] = entities.HashEmail(values[i])
+		domains[i] = entities.EmailDomain(values[i])
+	}
+
+	err := db.Where(
+		"user_id = ? and ((type = ? and value in (?)) or (type = ? and value in (?)) or (type = ? and value in (?)))",
+		userID,
+		entities.SuppressionTypeEmail,
+		values,
+		entities.SuppressionTypeEmailHash,
+		hashes,
+		entities.SuppressionTypeDomain,
+		domains,
+	).Order("id").Find(&s).Error
+	return s, err
+}
+
+// GetSuppressionForEmail returns the suppression which applies to the email, the suppressions
+// of the email itself and of its hash are preferred over the one of its domain.
+func (db *store) GetSuppressionForEmail(userID int64, email string) (*entities.Suppression, error) {
+	var s = new(entities.Suppression)
+	email = strings.ToLower(strings.TrimSpace(email))
+	err := db.Where(
+		"user_id = ? and ((type = ? and value = ?) or (type = ? and value = ?) or (type = ? and value = ?))",
+		userID,
+		entities.SuppressionTypeEmail,
+		email,
+		entities.SuppressionTypeEmailHash,
+		entities.HashEmail(email),
+		entities.SuppressionTypeDomain,
+		entities.EmailDomain(email),
+	).Order("type desc").First(s).Error
+	return s, err
+}
+
+// SeekSuppressionsByUserID fetches the suppressions of the user with id greater than
+// the given one, used for exporting them in chunks.
+func (db *store) SeekSuppressionsByUserID(userID, nextID, limit int64) ([]entities.Suppression, error) {
+	var s []entities.Suppression
+	err := db.Where("user_id = ? and id > ?", userID, nextID).
+		Order("id").
+		Limit(limit).
+		Find(&s).Error
+	return s, err
+}
+
+// CreateSuppression creates a new suppression in the database.
+func (db *store) CreateSuppression(s *entities.Suppression) error {
+	return db.Create(s).Error
+}
+
+// AddSuppressions creates the suppressions of the user which don't exist yet in a single
+// transaction, the existing ones are kept along with their reasons. It returns the number
+// of the created suppressions.
+func (db *store) AddSuppressions(userID int64, suppressions []*entities.Suppression) (n int, err error) {
+	if len(suppressions) == 0 {
+		return 0, nil
+	}
+
+	values := make([]string, len(suppressions))
+	for i, s := range suppressions {
+		values[i] = s.Value
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			err = fmt.Errorf("suppression store: add suppressions: %v", r)
+		}
+	}()
+
+	var existing []entities.Suppression
+	err = tx.Where("user_id = ? and value in (?)", userID, values).Find(&existing).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("suppression store: get existing: %w", err)
+	}
+
+	seen := make(map[string]bool, len(existing)+len(suppressions))
+	for _, s := range existing {
+		seen[s.Type+":"+s.Value] = true
+	}
+
+	for _, s := range suppressions {
+		key := s.Type + ":" + s.Value
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		s.UserID = userID
+		if err = tx.Create(s).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("suppression store: create %s: %w", s.Value, err)
+		}
+		n++
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("suppression store: commit: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteSuppression deletes the suppression with the given id and user id from the database.
+func (db *store) DeleteSuppression(id, userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.Suppression{Model: entities.Model{ID: id}}).Error
+}
+
+// DeleteAllSuppressionsForUser deletes all suppressions for user
+func (db *store) DeleteAllSuppressionsForUser(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.Suppression{}).Error
+}
+
+// ValueLike scopes the resource by the start of the value column.
+func ValueLike(value string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("value LIKE ?", value+"%")
+	}
+}
+
+// SuppressionsBy scopes the suppressions by the value of the type or the reason column.
+func SuppressionsBy(column, value string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(column+" = ?", value)
+	}
+}
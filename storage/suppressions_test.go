@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSuppression(t *testing.T) {
+	db := openTestDb()
+	defer func() {
+		err := db.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	store := From(db)
+
+	// test create suppression
+	s := &entities.Suppression{
+		UserID: 1,
+		Type:   entities.SuppressionTypeEmail,
+		Value:  "jo@example.com",
+		Reason: entities.SuppressionReasonManual,
+		Source: entities.SuppressionSourceAPI,
+	}
+	err := store.CreateSuppression(s)
+	assert.Nil(t, err)
+
+	// the value is unique per user and type
+	err = store.CreateSuppression(&entities.Suppression{
+		UserID: 1,
+		Type:   entities.SuppressionTypeEmail,
+		Value:  "jo@example.com",
+		Reason: entities.SuppressionReasonBounce,
+	})
+	assert.NotNil(t, err)
+
+	// test add suppressions
+	n, err := store.AddSuppressions(1, []*entities.Suppression{
+		{Type: entities.SuppressionTypeEmail, Value: "jo@example.com", Reason: entities.SuppressionReasonComplaint},
+		{Type: entities.SuppressionTypeDomain, Value: "spam.example.org", Reason: entities.SuppressionReasonComplaint},
+		{Type: entities.SuppressionTypeDomain, Value: "spam.example.org", Reason: entities.SuppressionReasonBounce},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = store.AddSuppressions(2, []*entities.Suppression{
+		{Type: entities.SuppressionTypeEmail, Value: "jo@example.com", Reason: entities.SuppressionReasonBounce},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	// test get suppression by value
+	s, err = store.GetSuppressionByValue(1, entities.SuppressionTypeEmail, "jo@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionReasonManual, s.Reason)
+
+	s, err = store.GetSuppressionByValue(2, entities.SuppressionTypeEmail, "jo@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionReasonBounce, s.Reason)
+
+	// test get suppression for email
+	s, err = store.GetSuppressionForEmail(1, "Jo@Example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "jo@example.com", s.Value)
+
+	s, err = store.GetSuppressionForEmail(1, "ana@spam.example.org")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.SuppressionTypeDomain, s.Type)
+
+	_, err = store.GetSuppressionForEmail(1, "ana@example.org")
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test get suppressions for emails
+	list, err := store.GetSuppressionsForEmails(1, []string{"jo@example.com", "ana@SPAM.example.org", "bo@example.com"})
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	// test get suppressions
+	p := NewPaginationCursor("/api/suppressions", 10)
+	err = store.GetSuppressions(1, p, map[string]string{"type": entities.SuppressionTypeDomain})
+	assert.Nil(t, err)
+	col := p.Collection.(*[]entities.Suppression)
+	assert.Len(t, *col, 1)
+
+	p = NewPaginationCursor("/api/suppressions", 10)
+	err = store.GetSuppressions(1, p, map[string]string{"value": "JO"})
+	assert.Nil(t, err)
+	col = p.Collection.(*[]entities.Suppression)
+	assert.Len(t, *col, 1)
+
+	// test seek suppressions
+	list, err = store.SeekSuppressionsByUserID(1, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	list, err = store.SeekSuppressionsByUserID(1, list[0].ID, 10)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	// test the suppressed subscribers are not sent campaigns
+	seg := &entities.Segment{Name: "all", UserID: 1}
+	err = store.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	for _, email := range []string{"JO@example.com", "ana@spam.example.org", "bo@example.com"} {
+		err = store.CreateSubscriber(&entities.Subscriber{
+			UserID:   1,
+			Email:    email,
+			Active:   true,
+			Segments: []entities.Segment{*seg},
+		})
+		assert.Nil(t, err)
+	}
+
+	subs, err := store.GetDistinctSubscribersBySegmentIDs([]int64{seg.ID}, 1, false, true, time.Time{}, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, "bo@example.com", subs[0].Email)
+
+	// test delete suppression
+	s, err = store.GetSuppressionForEmail(1, "jo@example.com")
+	assert.Nil(t, err)
+	err = store.DeleteSuppression(s.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSuppression(s.ID, 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// test delete all suppressions for user
+	err = store.DeleteAllSuppressionsForUser(1)
+	assert.Nil(t, err)
+
+	list, err = store.SeekSuppressionsByUserID(1, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, list, 0)
+
+	list, err = store.SeekSuppressionsByUserID(2, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -50,8 +52,26 @@ func (db *store) GetTemplates(userID int64, p *PaginationCursor, scopeMap map[st
 }
 
 // DeleteTemplate deletes the template with given template id and user id from db
+// along with its sample data sets.
 func (db *store) DeleteTemplate(templateID int64, userID int64) error {
-	return db.Where("user_id = ?", userID).Delete(entities.Template{BaseTemplate: entities.BaseTemplate{Model: entities.Model{ID: templateID}}}).Error
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("user_id = ? and template_id = ?", userID, templateID).Delete(&entities.SampleDataSet{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("template store: delete sample data sets: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(entities.Template{BaseTemplate: entities.BaseTemplate{Model: entities.Model{ID: templateID}}}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("template store: delete template: %w", err)
+	}
+
+	return tx.Commit().Error
 }
 
 // GetAllTemplatesForUser fetches all templates for user
@@ -59,4 +79,12 @@ func (db *store) GetAllTemplatesForUser(userID int64) ([]entities.Template, erro
 	var t []entities.Template
 	err := db.Where("user_id = ?", userID).Find(&t).Error
 	return t, err
-}
\ No newline at end of file
+}
+
+// GetTotalTemplatesByLayoutID returns the number of templates which are wrapped
+// in the layout with the given id.
+func (db *store) GetTotalTemplatesByLayoutID(layoutID, userID int64) (int64, error) {
+	var count int64
+	err := db.Model(entities.Template{}).Where("user_id = ? and layout_id = ?", userID, layoutID).Count(&count).Error
+	return count, err
+}
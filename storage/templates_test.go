@@ -131,6 +131,44 @@ func TestTemplate(t *testing.T) {
 			assert.Equal(t, 9, len(*col))
 		}
 	}
+
+	// wrap a template in a layout
+	layout := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      1,
+			Name:        "layout",
+			SubjectPart: "layout",
+		},
+	}
+	err = store.CreateTemplate(layout)
+	assert.Nil(t, err)
+
+	templateByID.LayoutID = &layout.ID
+	err = store.UpdateTemplate(templateByID)
+	assert.Nil(t, err)
+
+	wrapped, err := store.GetTotalTemplatesByLayoutID(layout.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), wrapped)
+
+	templateByID, err = store.GetTemplate(templateByID.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, layout.ID, *templateByID.LayoutID)
+
 	err = store.DeleteTemplate(templateByID.ID, 1)
 	assert.Nil(t, err)
+
+	wrapped, err = store.GetTotalTemplatesByLayoutID(layout.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), wrapped)
+
+	// opt the layout out of css inlining
+	assert.False(t, layout.DisableCSSInlining)
+	layout.DisableCSSInlining = true
+	err = store.UpdateTemplate(layout)
+	assert.Nil(t, err)
+
+	layout, err = store.GetTemplate(layout.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, layout.DisableCSSInlining)
 }
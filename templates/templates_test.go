@@ -43,6 +43,87 @@ func TestInit(t *testing.T) {
 	_, err = html.Parse(resp.Body)
 	assert.Nil(t, err)
 
+	// render confirm-subscription.html
+	err = r.HTMLRender.Instance("confirm-subscription.html", gin.H{
+		"email":  "foo@bar.com",
+		"t":      "foo",
+		"uuid":   "abcdefgh",
+		"failed": true,
+	}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// render confirm-subscription-success.html
+	err = r.HTMLRender.Instance("confirm-subscription-success.html", gin.H{}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// render form.html
+	err = r.HTMLRender.Instance("form.html", gin.H{
+		"name":             "Newsletter",
+		"uuid":             "abcdefgh",
+		"fields":           []string{"company", "city"},
+		"honeypot":         "website",
+		"recaptchaSiteKey": "key",
+	}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// render preferences.html
+	err = r.HTMLRender.Instance("preferences.html", gin.H{
+		"email":       "john@example.com",
+		"uuid":        "abcdefgh",
+		"t":           "token",
+		"name":        "John",
+		"saved":       "true",
+		"pausedUntil": "January 2, 2006",
+		"fields": []struct{ Key, Value string }{
+			{Key: "city", Value: "Skopje"},
+		},
+		"segments": []struct {
+			ID     int64
+			Name   string
+			Member bool
+		}{
+			{ID: 1, Name: "Weekly", Member: true},
+		},
+	}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// render form-success.html
+	err = r.HTMLRender.Instance("form-success.html", gin.H{}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// the confirmation email is parsed along with the other emails
+	assert.NotNil(t, GetEmailTemplates().Lookup("confirm-subscription.html"))
+
 	err = r.HTMLRender.Instance("non-existent-file.html", gin.H{}).Render(rec)
 	assert.NotNil(t, err)
 }
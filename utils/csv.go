@@ -1,10 +1,19 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/csv"
+	"errors"
 	"io"
 )
 
+// utf8BOM is the byte order mark some spreadsheet programs write at the start of the CSV files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// csvDelimiters are the delimiters the CSV files are sniffed for, the first one is the default.
+var csvDelimiters = []rune{',', ';', '\t'}
+
 func CountLines(r io.Reader) (int, error) {
 	buf := make([]byte, 32*1024)
 	count := 0
@@ -23,3 +32,83 @@ func CountLines(r io.Reader) (int, error) {
 		}
 	}
 }
+
+// NewCSVReader creates a CSV reader which skips the byte order mark and detects the delimiter
+// of the file by its first line. The records may have a different number of fields.
+func NewCSVReader(r io.Reader) (*csv.Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	bom, err := br.Peek(len(utf8BOM))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(bom, utf8BOM) {
+		if _, err := br.Discard(len(utf8BOM)); err != nil {
+			return nil, err
+		}
+	}
+
+	// the first line is sniffed as far as the buffer goes
+	head, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = SniffCSVDelimiter(head)
+	reader.FieldsPerRecord = -1
+	return reader, nil
+}
+
+// SniffCSVDelimiter returns the delimiter which occurs the most in the first line of the data,
+// the delimiters inside of quotes are not counted.
+func SniffCSVDelimiter(data []byte) rune {
+	counts := make(map[rune]int, len(csvDelimiters))
+	quoted := false
+	for _, b := range data {
+		if b == '"' {
+			quoted = !quoted
+			continue
+		}
+		if quoted {
+			continue
+		}
+		if b == '\n' || b == '\r' {
+			break
+		}
+		counts[rune(b)]++
+	}
+
+	delimiter := csvDelimiters[0]
+	for _, d := range csvDelimiters[1:] {
+		if counts[d] > counts[delimiter] {
+			delimiter = d
+		}
+	}
+	return delimiter
+}
+
+// CountCSVRecords counts the records of the CSV data, unlike the lines the records can
+// contain quoted newlines. The header is counted as a record.
+func CountCSVRecords(r io.Reader) (int, error) {
+	reader, err := NewCSVReader(r)
+	if err != nil {
+		return 0, err
+	}
+	reader.ReuseRecord = true
+	reader.LazyQuotes = true
+
+	count := 0
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		// the malformed records are counted as well
+		var perr *csv.ParseError
+		if err != nil && !errors.As(err, &perr) {
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FlattenJSON flattens the decoded JSON object to string values, the keys of the nested objects
+// are joined with dots, e.g. {"address":{"city":"Skopje"}} is flattened to {"address.city":"Skopje"}.
+// The arrays are kept as JSON and the nulls are flattened to empty strings. The numbers should be
+// decoded as json.Number so they are not reformatted.
+func FlattenJSON(obj map[string]interface{}) (map[string]string, error) {
+	flat := make(map[string]string, len(obj))
+	if err := flattenJSON("", obj, flat); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+func flattenJSON(prefix string, obj map[string]interface{}, flat map[string]string) error {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if err := flattenJSON(key, val, flat); err != nil {
+				return err
+			}
+		case nil:
+			flat[key] = ""
+		case string:
+			flat[key] = val
+		case json.Number:
+			flat[key] = val.String()
+		case bool:
+			flat[key] = fmt.Sprint(val)
+		case float64:
+			flat[key] = fmt.Sprint(val)
+		default:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return err
+			}
+			flat[key] = string(b)
+		}
+	}
+	return nil
+}
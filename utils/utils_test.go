@@ -1,7 +1,15 @@
 package utils
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,3 +46,239 @@ func TestSignData(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "bd209680297c13ce4d5eaf0c8dea68691de725cfb7ae116b8e8845a9606b22d4", hash)
 }
+
+func TestNewCSVReader(t *testing.T) {
+	data := "\xEF\xBB\xBFemail;name;city\n" +
+		"jane@example.com;\"Jane; Doe\";Skopje\n" +
+		"john@example.com;\"John\nDoe\"\n"
+
+	r, err := NewCSVReader(strings.NewReader(data))
+	assert.Nil(t, err)
+
+	records, err := r.ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"email", "name", "city"},
+		{"jane@example.com", "Jane; Doe", "Skopje"},
+		{"john@example.com", "John\nDoe"},
+	}, records)
+
+	count, err := CountCSVRecords(strings.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	r, err = NewCSVReader(strings.NewReader(""))
+	assert.Nil(t, err)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSniffCSVDelimiter(t *testing.T) {
+	assert.Equal(t, ',', SniffCSVDelimiter([]byte("email,name\nfoo;bar;baz")))
+	assert.Equal(t, ';', SniffCSVDelimiter([]byte("email;name;\"a,b,c\"")))
+	assert.Equal(t, '\t', SniffCSVDelimiter([]byte("email\tname")))
+	assert.Equal(t, ',', SniffCSVDelimiter([]byte("email")))
+}
+
+// newTestXLSX builds a workbook with the sheets, the shared strings are email, name and
+// a rich text of Jane Doe. The styles are general, a builtin date, a custom date and time,
+// and a custom number with a literal text.
+func newTestXLSX(t *testing.T, names []string, sheets []string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	write := func(name, content string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wb, rels strings.Builder
+	wb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range names {
+		fmt.Fprintf(&wb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		write(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheets[i])
+	}
+	wb.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	write("xl/workbook.xml", wb.String())
+	write("xl/_rels/workbook.xml.rels", rels.String())
+	write("xl/sharedStrings.xml", `<?xml version="1.0" encoding="UTF-8"?><sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>email</t></si><si><t>name</t></si><si><r><t>Jane </t></r><r><t>Doe</t></r></si></sst>`)
+	write("xl/styles.xml", `<?xml version="1.0" encoding="UTF-8"?><styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+
+		`<numFmts count="2"><numFmt numFmtId="164" formatCode="dd/mm/yyyy\ hh:mm"/><numFmt numFmtId="165" formatCode="0.00&quot; days&quot;"/></numFmts>`+
+		`<cellXfs count="4"><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`)
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestXLSXReader(t *testing.T) {
+	sheet := `<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>age</t></is></c></row>` +
+		`<row r="2"><c r="A2" t="inlineStr"><is><t>jane@example.com</t></is></c><c r="B2" t="s"><v>2</v></c><c r="C2"><v>42</v></c></row>` +
+		`<row r="4"><c r="A4" t="str"><v>john@example.com</v></c><c r="C4" t="b"><v>1</v></c></row>` +
+		`<row r="5"><c r="A5"/></row>` +
+		`<row r="6"><c r="A6" t="inlineStr"><is><t>ana@example.com</t></is></c></row>` +
+		`</sheetData></worksheet>`
+	other := `<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="B1" t="s"><v>0</v></c></row>` +
+		`</sheetData></worksheet>`
+	data := newTestXLSX(t, []string{"Subscribers", "Other"}, []string{sheet, other})
+
+	sheets, err := XLSXSheets(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Subscribers", "Other"}, sheets)
+
+	r, err := NewXLSXReader(data, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Subscribers", r.Sheet)
+
+	var records [][]string
+	var rows []int
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		records = append(records, record)
+		rows = append(rows, r.Row)
+	}
+	assert.Nil(t, r.Close())
+	assert.Equal(t, [][]string{
+		{"email", "name", "age"},
+		{"jane@example.com", "Jane Doe", "42"},
+		{"john@example.com", "", "true"},
+		{"ana@example.com"},
+	}, records)
+	assert.Equal(t, []int{1, 2, 4, 6}, rows)
+
+	r, err = NewXLSXReader(data, "Other")
+	assert.Nil(t, err)
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"", "email"}, record)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, r.Close())
+
+	_, err = NewXLSXReader(data, "Missing")
+	assert.True(t, errors.Is(err, ErrSheetNotFound))
+
+	_, err = NewXLSXReader([]byte("email,name"), "")
+	assert.True(t, errors.Is(err, ErrInvalidXLSX))
+}
+
+func TestXLSXReaderDates(t *testing.T) {
+	sheet := `<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" s="1"><v>45123</v></c><c r="B1" s="2"><v>45123.5</v></c><c r="C1" s="3"><v>45123</v></c><c r="D1"><v>45123</v></c>` +
+		`<c r="E1" s="1"><v>1</v></c><c r="F1" s="1" t="inlineStr"><is><t>soon</t></is></c></row>` +
+		`</sheetData></worksheet>`
+	data := newTestXLSX(t, []string{"Subscribers"}, []string{sheet})
+
+	r, err := NewXLSXReader(data, "")
+	assert.Nil(t, err)
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2023-07-16", "2023-07-16T12:00:00Z", "45123", "45123", "1900-01-01", "soon"}, record)
+	assert.Nil(t, r.Close())
+
+	date, ok := serialDate("0", true)
+	assert.True(t, ok)
+	assert.Equal(t, "1904-01-01", date)
+	_, ok = serialDate("-1", false)
+	assert.False(t, ok)
+
+	tests := []struct {
+		id   int
+		code string
+		date bool
+	}{
+		{0, "", false},
+		{14, "", true},
+		{46, "", true},
+		{164, "yyyy-mm-dd", true},
+		{164, "[h]:mm:ss", true},
+		{164, "[$-409]mmmm d, yyyy", true},
+		{164, `0.00" days"`, false},
+		{164, `#,##0\ \d`, false},
+		{164, "[Red]0.00", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.date, isDateFormat(tc.id, tc.code), tc.code)
+	}
+}
+
+func TestXLSXReaderLimits(t *testing.T) {
+	sheet := `<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="XFD1" t="inlineStr"><is><t>last</t></is></c></row>` +
+		`<row r="2"><c r="XFE2" t="inlineStr"><is><t>after</t></is></c></row>` +
+		`<row r="3"><c r="ZZZZZZZZZZZZZZZZZZZZ3" t="inlineStr"><is><t>overflow</t></is></c></row>` +
+		`</sheetData></worksheet>`
+	data := newTestXLSX(t, []string{"Subscribers"}, []string{sheet})
+
+	r, err := NewXLSXReader(data, "")
+	assert.Nil(t, err)
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Len(t, record, 16384)
+	assert.Equal(t, "last", record[16383])
+
+	_, err = r.Read()
+	assert.True(t, errors.Is(err, ErrInvalidXLSX))
+	assert.Equal(t, -1, columnIndex("ZZZZZZZZZZZZZZZZZZZZ3"))
+	assert.Nil(t, r.Close())
+
+	// the parts which are larger than the limit are rejected by their size in the header of the
+	// archive, and the reads fail when the decompressed size doesn't match the header
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := findZipFile(zr, "xl/sharedStrings.xml")
+	_, err = openZipFile(f, 10)
+	assert.True(t, errors.Is(err, ErrInvalidXLSX))
+
+	f.UncompressedSize64 = 5
+	rc, err := openZipFile(f, 10)
+	assert.Nil(t, err)
+	_, err = ioutil.ReadAll(rc)
+	assert.NotNil(t, err)
+	assert.Nil(t, rc.Close())
+}
+
+func TestFlattenJSON(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{
+		"email": "jane@example.com",
+		"age": 42.50,
+		"vip": true,
+		"nickname": null,
+		"tags": ["a", "b"],
+		"address": {"city": "Skopje", "geo": {"lat": 41.99}}
+	}`))
+	dec.UseNumber()
+
+	var obj map[string]interface{}
+	assert.Nil(t, dec.Decode(&obj))
+
+	flat, err := FlattenJSON(obj)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"email":           "jane@example.com",
+		"age":             "42.50",
+		"vip":             "true",
+		"nickname":        "",
+		"tags":            `["a","b"]`,
+		"address.city":    "Skopje",
+		"address.geo.lat": "41.99",
+	}, flat)
+}
@@ -0,0 +1,498 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidXLSX   = errors.New("xlsx: invalid file")
+	ErrSheetNotFound = errors.New("xlsx: sheet not found")
+)
+
+const (
+	// maxXLSXColumns is the number of the columns of a sheet, the last column is XFD.
+	maxXLSXColumns = 16384
+	// maxXLSXPartSize is the largest uncompressed size of the parts of the workbook which are read
+	// at once, e.g. the shared strings.
+	maxXLSXPartSize = 256 << 20
+	// maxXLSXSheetSize is the largest uncompressed size of the sheet, which is streamed.
+	maxXLSXSheetSize = 1 << 30
+)
+
+// XLSXReader reads the rows of a sheet of an XLSX workbook. Only the values of the cells are read,
+// the numbers are returned as they are stored, and the numbers which are formatted as dates are
+// converted to dates of format 2006-01-02, or to RFC 3339 times in UTC when they have a time of
+// the day, since the workbooks don't store the time zones.
+type XLSXReader struct {
+	dec     *xml.Decoder
+	closer  io.Closer
+	strings []string
+	// dates reports whether the cells of the styles are formatted as dates, by the style index.
+	dates    []bool
+	date1904 bool
+	// Sheet is the name of the sheet which is read.
+	Sheet string
+	// Row is the number of the row which was read last, the empty rows are skipped.
+	Row int
+}
+
+// XLSXSheets returns the names of the sheets of the workbook, in their order.
+func XLSXSheets(data []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidXLSX, err)
+	}
+
+	wb, err := readWorkbook(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(wb.Sheets))
+	for i, s := range wb.Sheets {
+		names[i] = s.Name
+	}
+	return names, nil
+}
+
+// NewXLSXReader opens the sheet of the workbook by its name, or the first sheet when the name is empty.
+// The caller must close the reader.
+func NewXLSXReader(data []byte, sheet string) (*XLSXReader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidXLSX, err)
+	}
+
+	wb, err := readWorkbook(zr)
+	if err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("%w: the workbook has no sheets", ErrInvalidXLSX)
+	}
+
+	s := wb.Sheets[0]
+	if sheet != "" {
+		found := false
+		for _, ws := range wb.Sheets {
+			if ws.Name == sheet {
+				s, found = ws, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, sheet)
+		}
+	}
+
+	target := ""
+	for _, rel := range wb.Rels {
+		if rel.ID == s.RelID {
+			target = rel.Target
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("%w: the sheet '%s' has no part", ErrInvalidXLSX, s.Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	shared, err := readSharedStrings(zr)
+	if err != nil {
+		return nil, err
+	}
+	dates, err := readDateStyles(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := findZipFile(zr, target)
+	if f == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidXLSX, target)
+	}
+	rc, err := openZipFile(f, maxXLSXSheetSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &XLSXReader{
+		dec:      xml.NewDecoder(rc),
+		closer:   rc,
+		strings:  shared,
+		dates:    dates,
+		date1904: wb.Props.Date1904,
+		Sheet:    s.Name,
+	}, nil
+}
+
+// Read returns the values of the cells of the next row which is not empty, the missing cells
+// are returned as empty strings. It returns io.EOF when there are no more rows.
+func (r *XLSXReader) Read() ([]string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("%w: %s", ErrInvalidXLSX, err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		var row xlsxRow
+		if err := r.dec.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidXLSX, err)
+		}
+		if row.R > 0 {
+			r.Row = row.R
+		} else {
+			r.Row++
+		}
+
+		record, err := r.record(&row)
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > 0 {
+			return record, nil
+		}
+	}
+}
+
+// Close closes the sheet.
+func (r *XLSXReader) Close() error {
+	return r.closer.Close()
+}
+
+// record returns the values of the cells of the row, without the trailing empty cells.
+func (r *XLSXReader) record(row *xlsxRow) ([]string, error) {
+	var record []string
+	for i, c := range row.Cells {
+		col := i
+		if c.Ref != "" {
+			col = columnIndex(c.Ref)
+			if col < 0 {
+				return nil, fmt.Errorf("%w: invalid cell reference %s", ErrInvalidXLSX, c.Ref)
+			}
+		}
+
+		v, err := r.value(&c)
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			continue
+		}
+		for len(record) <= col {
+			record = append(record, "")
+		}
+		record[col] = v
+	}
+	return record, nil
+}
+
+func (r *XLSXReader) value(c *xlsxCell) (string, error) {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(c.Value))
+		if err != nil || i < 0 || i >= len(r.strings) {
+			return "", fmt.Errorf("%w: invalid shared string %s", ErrInvalidXLSX, c.Value)
+		}
+		return r.strings[i], nil
+	case "inlineStr":
+		return c.Inline.String(), nil
+	case "b":
+		if strings.TrimSpace(c.Value) == "1" {
+			return "true", nil
+		}
+		return "false", nil
+	case "", "n":
+		if c.Style >= 0 && c.Style < len(r.dates) && r.dates[c.Style] {
+			if d, ok := serialDate(c.Value, r.date1904); ok {
+				return d, nil
+			}
+		}
+	}
+	return c.Value, nil
+}
+
+// columnIndex returns the index of the column of the cell reference, e.g. 0 for A1 and 27 for AB3.
+// It returns -1 when the reference has no column, or the column is after XFD.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A') + 1
+		if col > maxXLSXColumns {
+			return -1
+		}
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// serialDate converts the serial number of a date to a date, or to a time when it has a time of
+// the day. The serial numbers are the days since 1899-12-30, or since 1904-01-01 in the workbooks
+// which use the 1904 date system.
+func serialDate(v string, date1904 bool) (string, bool) {
+	serial, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || serial < 0 || serial > 2958465 {
+		return "", false
+	}
+
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	switch {
+	case date1904:
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	case serial < 61:
+		// Excel treats 1900 as a leap year, so the serial numbers before 1900-03-01 are off by a day.
+		base = base.AddDate(0, 0, 1)
+	}
+
+	days := int(serial)
+	secs := int64((serial-float64(days))*86400 + 0.5)
+	t := base.AddDate(0, 0, days).Add(time.Duration(secs) * time.Second)
+	if secs == 0 {
+		return t.Format("2006-01-02"), true
+	}
+	return t.Format(time.RFC3339), true
+}
+
+// isDateFormat reports whether the number format formats the numbers as dates or times, the
+// builtin formats by their ids and the custom ones by their codes.
+func isDateFormat(id int, code string) bool {
+	switch {
+	case id >= 14 && id <= 22, id >= 27 && id <= 36, id >= 45 && id <= 47, id >= 50 && id <= 58:
+		return true
+	case code == "":
+		return false
+	}
+
+	// the literal texts, the escaped characters and the sections in brackets such as the colors
+	// and the currencies are not part of the format of the number
+	var b strings.Builder
+	quoted, bracket, escaped := false, false, false
+	for _, ch := range code {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			quoted = ch != '"'
+		case bracket:
+			bracket = ch != ']'
+		case ch == '\\':
+			escaped = true
+		case ch == '"':
+			quoted = true
+		case ch == '[':
+			bracket = true
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	return strings.ContainsAny(strings.ToLower(b.String()), "dmyhs")
+}
+
+type xlsxWorkbook struct {
+	Props struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"-"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxRichText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String returns the text, the runs of the rich text are concatenated.
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	R     int        `xml:"r,attr"`
+	Cells []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	Ref    string       `xml:"r,attr"`
+	Type   string       `xml:"t,attr"`
+	Style  int          `xml:"s,attr"`
+	Value  string       `xml:"v"`
+	Inline xlsxRichText `xml:"is"`
+}
+
+func readWorkbook(zr *zip.Reader) (*xlsxWorkbook, error) {
+	wb := new(xlsxWorkbook)
+	if err := decodeZipFile(zr, "xl/workbook.xml", wb); err != nil {
+		return nil, err
+	}
+
+	rels := new(xlsxRels)
+	if err := decodeZipFile(zr, "xl/_rels/workbook.xml.rels", rels); err != nil {
+		return nil, err
+	}
+	wb.Rels = rels.Rels
+
+	return wb, nil
+}
+
+func readSharedStrings(zr *zip.Reader) ([]string, error) {
+	// the workbooks which have only numbers don't have shared strings
+	if findZipFile(zr, "xl/sharedStrings.xml") == nil {
+		return nil, nil
+	}
+
+	var sst struct {
+		Items []xlsxRichText `xml:"si"`
+	}
+	if err := decodeZipFile(zr, "xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		shared[i] = si.String()
+	}
+	return shared, nil
+}
+
+// readDateStyles returns whether the cells of the styles are formatted as dates, by the style index.
+func readDateStyles(zr *zip.Reader) ([]bool, error) {
+	if findZipFile(zr, "xl/styles.xml") == nil {
+		return nil, nil
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := decodeZipFile(zr, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+
+	codes := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		codes[f.ID] = f.Code
+	}
+
+	dates := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		dates[i] = isDateFormat(xf.NumFmtID, codes[xf.NumFmtID])
+	}
+	return dates, nil
+}
+
+func decodeZipFile(zr *zip.Reader, name string, v interface{}) error {
+	f := findZipFile(zr, name)
+	if f == nil {
+		return fmt.Errorf("%w: missing %s", ErrInvalidXLSX, name)
+	}
+
+	rc, err := openZipFile(f, maxXLSXPartSize)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidXLSX, name, err)
+	}
+	return nil
+}
+
+// openZipFile opens the file of the archive, the reads fail once more than the limit is decompressed,
+// since the uncompressed size in the header of the archive can't be trusted.
+func openZipFile(f *zip.File, limit int64) (io.ReadCloser, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidXLSX, f.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidXLSX, err)
+	}
+	return &limitedReadCloser{
+		Reader: io.LimitReader(rc, limit+1),
+		Closer: rc,
+		name:   f.Name,
+		limit:  limit,
+	}, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+	name  string
+	limit int64
+	read  int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, fmt.Errorf("%w: %s is too large", ErrInvalidXLSX, r.name)
+	}
+	return n, err
+}
+
+func findZipFile(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return nil
+}
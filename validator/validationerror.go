@@ -40,7 +40,7 @@ func (q *ValidationError) FormatErrors() {
 		switch err.ActualTag() {
 		case "email":
 			q.Errors[err.Field()] = "Invalid email format"
-		case "required":
+		case "required", "required_if":
 			q.Errors[err.Field()] = "This field is required"
 		case "max":
 			q.Errors[err.Field()] = "Max length allowed is " + err.Param()
@@ -54,6 +54,8 @@ func (q *ValidationError) FormatErrors() {
 			q.Errors[err.Field()] = "Content must be html"
 		case tagAlphanumericHyphen:
 			q.Errors[err.Field()] = "Must consist only of alphanumeric and hyphen characters"
+		case "excludesall":
+			q.Errors[err.Field()] = "Must not contain any of: " + err.Param()
 		case "datetime":
 			q.Errors[err.Field()] = "Must be of format: " + err.Param()
 		default: